@@ -0,0 +1,157 @@
+package cuckoo
+
+import "sync"
+
+// Parameters of the frequency sketch of AdmissionFilter.
+const (
+	// admissionDepth is the number of counters per key, the minimum of which is its estimate.
+	admissionDepth = 4
+	// admissionMaxCount is the maximum of the 4-bit counters.
+	admissionMaxCount = 15
+	// admissionSampleFactor is the number of recorded accesses per cached element after which
+	// all frequencies are halved.
+	admissionSampleFactor = 10
+)
+
+// AdmissionFilter decides which keys a cache of bounded size should admit, following the
+// W-TinyLFU policy: a new key is only admitted if it was accessed more often recently than
+// the key it would evict. See "TinyLFU: A Highly Efficient Cache Admission Policy" by Einziger
+// et al.
+//
+// Access frequencies are estimated with a count-min sketch of 4-bit counters. A Filter acts as
+// the doorkeeper: the first access of a key only goes into the filter, so the many keys that
+// are accessed once do not pollute the sketch. After 10 accesses per cached element, all
+// counters are halved and the doorkeeper is cleared, so frequencies age out.
+// AdmissionFilter is safe for concurrent use.
+type AdmissionFilter struct {
+	doorkeeper *Filter
+	// counters holds admissionDepth rows of 4-bit counters, 16 per word.
+	counters   []uint64
+	rowMask    uint
+	additions  uint
+	sampleSize uint
+	lock       sync.Mutex
+}
+
+// NewAdmissionFilter returns an AdmissionFilter for a cache holding up to numElements keys.
+func NewAdmissionFilter(numElements uint) *AdmissionFilter {
+	if numElements == 0 {
+		numElements = 1
+	}
+	rowSize := getNextPow2(uint64(numElements))
+	if rowSize < 16 {
+		rowSize = 16
+	}
+	sampleSize := admissionSampleFactor * numElements
+	return &AdmissionFilter{
+		doorkeeper: NewFilter(sampleSize),
+		counters:   make([]uint64, admissionDepth*rowSize/16),
+		rowMask:    rowSize - 1,
+		sampleSize: sampleSize,
+	}
+}
+
+// Record records an access of key.
+func (af *AdmissionFilter) Record(key []byte) {
+	hash := hashKey(key)
+
+	af.lock.Lock()
+	defer af.lock.Unlock()
+
+	if wasPresent, added := af.doorkeeper.ContainsOrAdd(key); !wasPresent && added {
+		return
+	}
+	// Increment the smallest counters only, which keeps overestimates low.
+	min := af.estimate(hash)
+	if min == admissionMaxCount {
+		return
+	}
+	for r := 0; r < admissionDepth; r++ {
+		if af.counter(hash, r) == min {
+			af.increment(hash, r)
+		}
+	}
+	af.additions++
+	if af.additions >= af.sampleSize {
+		af.age()
+	}
+}
+
+// Frequency returns the estimated number of recent accesses of key, at most 16.
+func (af *AdmissionFilter) Frequency(key []byte) uint {
+	hash := hashKey(key)
+
+	af.lock.Lock()
+	defer af.lock.Unlock()
+
+	return af.frequency(key, hash)
+}
+
+// ShouldAdmit returns true if key should replace victim in the cache, which is the case if key
+// was accessed more often recently.
+func (af *AdmissionFilter) ShouldAdmit(key, victim []byte) bool {
+	keyHash, victimHash := hashKey(key), hashKey(victim)
+
+	af.lock.Lock()
+	defer af.lock.Unlock()
+
+	return af.frequency(key, keyHash) > af.frequency(victim, victimHash)
+}
+
+// Reset forgets all recorded accesses.
+func (af *AdmissionFilter) Reset() {
+	af.lock.Lock()
+	defer af.lock.Unlock()
+
+	for i := range af.counters {
+		af.counters[i] = 0
+	}
+	af.doorkeeper.Reset()
+	af.additions = 0
+}
+
+// frequency returns the estimate of the sketch plus one access recorded by the doorkeeper.
+// The caller must hold the lock.
+func (af *AdmissionFilter) frequency(key []byte, hash uint64) uint {
+	f := af.estimate(hash)
+	if af.doorkeeper.Lookup(key) {
+		f++
+	}
+	return f
+}
+
+// estimate returns the minimum of the counters of hash.
+func (af *AdmissionFilter) estimate(hash uint64) uint {
+	min := uint(admissionMaxCount)
+	for r := 0; r < admissionDepth; r++ {
+		if c := af.counter(hash, r); c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// counterIndex returns the index of the counter of hash in row r.
+func (af *AdmissionFilter) counterIndex(hash uint64, r int) uint {
+	h1, h2 := uint(uint32(hash)), uint(hash>>32)|1
+	return uint(r)*(af.rowMask+1) + (h1+uint(r)*h2)&af.rowMask
+}
+
+func (af *AdmissionFilter) counter(hash uint64, r int) uint {
+	i := af.counterIndex(hash, r)
+	return uint(af.counters[i/16]>>(4*(i%16))) & admissionMaxCount
+}
+
+func (af *AdmissionFilter) increment(hash uint64, r int) {
+	i := af.counterIndex(hash, r)
+	af.counters[i/16] += 1 << (4 * (i % 16))
+}
+
+// age halves all counters and clears the doorkeeper.
+func (af *AdmissionFilter) age() {
+	for i, w := range af.counters {
+		af.counters[i] = w >> 1 & 0x7777777777777777
+	}
+	af.doorkeeper.ResetFast()
+	af.additions /= 2
+}
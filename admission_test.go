@@ -0,0 +1,62 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestAdmissionFilter(t *testing.T) {
+	af := NewAdmissionFilter(1000)
+	hot, cold, unseen := []byte("hot"), []byte("cold"), []byte("unseen")
+	for i := 0; i < 10; i++ {
+		af.Record(hot)
+	}
+	af.Record(cold)
+
+	if got := af.Frequency(hot); got != 10 {
+		t.Errorf("Frequency(hot) = %d, want 10", got)
+	}
+	if got := af.Frequency(cold); got != 1 {
+		t.Errorf("Frequency(cold) = %d, want 1", got)
+	}
+	if got := af.Frequency(unseen); got != 0 {
+		t.Errorf("Frequency(unseen) = %d, want 0", got)
+	}
+	if !af.ShouldAdmit(hot, cold) || af.ShouldAdmit(cold, hot) || af.ShouldAdmit(unseen, cold) {
+		t.Errorf("ShouldAdmit() does not prefer the more frequent key")
+	}
+
+	for i := 0; i < 100; i++ {
+		af.Record(hot)
+	}
+	if got := af.Frequency(hot); got != admissionMaxCount+1 {
+		t.Errorf("Frequency(hot) = %d, want saturation at %d", got, admissionMaxCount+1)
+	}
+
+	af.Reset()
+	if got := af.Frequency(hot); got != 0 {
+		t.Errorf("Frequency(hot) after Reset() = %d, want 0", got)
+	}
+}
+
+func TestAdmissionFilter_Aging(t *testing.T) {
+	af := NewAdmissionFilter(100)
+	hot := []byte("hot")
+	for i := 0; i < 9; i++ {
+		af.Record(hot)
+	}
+	// Fill the sample with other keys, each accessed twice so they reach the sketch, until
+	// the counters are halved.
+	for i := 0; i < 10000; i++ {
+		before := af.additions
+		key := []byte(strconv.Itoa(i))
+		af.Record(key)
+		af.Record(key)
+		if af.additions < before {
+			break
+		}
+	}
+	if got := af.Frequency(hot); got != 4 {
+		t.Errorf("Frequency(hot) after aging = %d, want 8 halved to 4", got)
+	}
+}
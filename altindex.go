@@ -0,0 +1,84 @@
+package cuckoo
+
+import "fmt"
+
+// AltIndexScheme is the derivation of the alternate bucket of a fingerprint from the bucket it
+// is in. Every scheme is an involution: deriving the alternate bucket twice gives the original
+// one, so kickouts can move fingerprints back and forth without knowing the key.
+type AltIndexScheme uint8
+
+const (
+	// AltIndexXOR derives the alternate bucket as i XOR hash(fp). It is the scheme of the
+	// original cuckoo filter paper and the default. When the masked hash is zero, both
+	// candidates are the same bucket: that happens for one in every len(buckets)
+	// fingerprints, which is noticeable in small filters and effectively halves the capacity
+	// of filters with one or two buckets.
+	AltIndexXOR AltIndexScheme = iota
+	// AltIndexOffset derives the alternate bucket as (hash(fp)|1) - i, modulo the number of
+	// buckets. The offset is odd, so the candidates of every fingerprint differ in the lowest
+	// bit and are never the same bucket, at the cost of a subtraction instead of an XOR.
+	// Kickout chains alternate between even and odd buckets.
+	AltIndexOffset
+	// AltIndexCacheLine derives the alternate bucket as i XOR d, where d in [1, 7] is derived
+	// from hash(fp), so both candidates are in the same aligned block of 8 buckets. A block
+	// is 64 bytes, one cache line on most CPUs as the buckets of large filters are page
+	// aligned, so misses read one cache line instead of two, which halves their memory
+	// traffic in filters much larger than the CPU caches. As fingerprints cannot leave their
+	// block, the first insert fails as soon as any block receives more than 32 items, at a
+	// load factor of about 50% in filters of 2^16 slots and 35% in filters of 2^23 slots,
+	// against 95% with the other schemes: the scheme trades memory for lookup speed, so size
+	// such filters for two to three times the items. Filters with fewer than 8 buckets
+	// behave like with AltIndexXOR.
+	AltIndexCacheLine
+)
+
+// cacheLineBuckets is the number of buckets in a block of AltIndexCacheLine.
+const cacheLineBuckets = 8
+
+// String returns the name of the scheme.
+func (s AltIndexScheme) String() string {
+	switch s {
+	case AltIndexXOR:
+		return "xor"
+	case AltIndexOffset:
+		return "offset"
+	case AltIndexCacheLine:
+		return "cacheline"
+	}
+	return fmt.Sprintf("AltIndexScheme(%d)", uint8(s))
+}
+
+// supportedAltIndexScheme returns true if alternate buckets can be derived with scheme s.
+func supportedAltIndexScheme(s AltIndexScheme) bool {
+	return s <= AltIndexCacheLine
+}
+
+// WithAltIndexScheme derives alternate buckets with the given scheme instead of AltIndexXOR.
+// Encode, MarshalCBOR and EncodeFlatBuffer record the scheme and their decoders restore it.
+// Filters with different schemes cannot be merged.
+func WithAltIndexScheme(s AltIndexScheme) Option {
+	return func(cf *Filter) {
+		cf.altScheme = s
+	}
+}
+
+// AltIndexScheme returns the scheme alternate buckets are derived with.
+func (cf *Filter) AltIndexScheme() AltIndexScheme {
+	return cf.altScheme
+}
+
+// altIndex returns the alternate bucket of fp in bucket i.
+func (cf *core) altIndex(fp fingerprint, i uint) uint {
+	return altIndex(cf.altScheme, fp, i, cf.bucketIndexMask)
+}
+
+// altIndex returns the alternate bucket of fp in bucket i with scheme s.
+func altIndex(s AltIndexScheme, fp fingerprint, i, bucketIndexMask uint) uint {
+	switch s {
+	case AltIndexOffset:
+		return (fingerprintHash(fp) | 1 - i) & bucketIndexMask
+	case AltIndexCacheLine:
+		return (i ^ (fingerprintHash(fp)%(cacheLineBuckets-1) + 1)) & bucketIndexMask
+	}
+	return getAltIndex(fp, i, bucketIndexMask)
+}
@@ -0,0 +1,171 @@
+package cuckoo
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestAltIndexSchemes(t *testing.T) {
+	for _, mask := range []uint{0, 1, 7, 1<<20 - 1} {
+		same := 0
+		for fp := fingerprint(1); fp < 1<<12; fp++ {
+			for _, i := range []uint{0, 1 & mask, mask / 2, mask} {
+				for _, s := range []AltIndexScheme{AltIndexXOR, AltIndexOffset, AltIndexCacheLine} {
+					alt := altIndex(s, fp, i, mask)
+					if alt > mask || altIndex(s, fp, alt, mask) != i {
+						t.Fatalf("%v scheme is not an involution for fp %d, bucket %d of %d", s, fp, i, mask+1)
+					}
+					if s == AltIndexCacheLine && mask >= cacheLineBuckets-1 && (alt == i || alt/cacheLineBuckets != i/cacheLineBuckets) {
+						t.Fatalf("cacheline scheme places fp %d from bucket %d into bucket %d", fp, i, alt)
+					}
+					if alt == i {
+						if s == AltIndexOffset && mask > 0 {
+							t.Fatalf("offset scheme gives fp %d the same candidates in %d buckets", fp, mask+1)
+						}
+						same++
+					}
+				}
+			}
+		}
+		if mask == 1 && same == 0 {
+			t.Error("xor scheme never gives the same candidates in 2 buckets")
+		}
+	}
+}
+
+func TestWithAltIndexScheme(t *testing.T) {
+	// Fingerprints always have two candidates, so two buckets fill up completely.
+	small := newFilter(make([]bucket, 2))
+	WithAltIndexScheme(AltIndexOffset)(small)
+	for i := 0; i < 1000 && small.Count() < uint(small.Cap()); i++ {
+		small.Insert([]byte(strconv.Itoa(i)))
+	}
+	if small.Count() != uint(small.Cap()) {
+		t.Errorf("filled %d of %d slots of two buckets", small.Count(), small.Cap())
+	}
+
+	for _, s := range []AltIndexScheme{AltIndexOffset, AltIndexCacheLine} {
+		t.Run(s.String(), func(t *testing.T) {
+			testAltIndexScheme(t, s)
+		})
+	}
+}
+
+func testAltIndexScheme(t *testing.T, s AltIndexScheme) {
+	// Leave room for the lower load factor of AltIndexCacheLine.
+	cf := NewFilter(4000, WithAltIndexScheme(s))
+	if got := cf.AltIndexScheme(); got != s {
+		t.Fatalf("AltIndexScheme() = %v", got)
+	}
+	for i := 0; i < 900; i++ {
+		if !cf.Insert([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Insert(%d) = false", i)
+		}
+	}
+	lookupAll := func(name string, lookup func([]byte) bool) {
+		t.Helper()
+		for i := 0; i < 900; i++ {
+			if !lookup([]byte(strconv.Itoa(i))) {
+				t.Errorf("%s: Lookup(%d) = false", name, i)
+				return
+			}
+		}
+	}
+	lookupAll("filter", cf.Lookup)
+
+	encoded := cf.Encode()
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.AltIndexScheme() != s {
+		t.Errorf("Decode() restored scheme %v", decoded.AltIndexScheme())
+	}
+	lookupAll("Decode", decoded.Lookup)
+	sf, err := NewStaticFilter(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookupAll("StaticFilter", sf.Lookup)
+	lf, err := DecodeLazy(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookupAll("LazyFilter", lf.Lookup)
+	ff, err := NewFlatFilter(cf.EncodeFlatBuffer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookupAll("FlatFilter", ff.Lookup)
+	cbor, err := cf.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = DecodeCBOR(cbor); err != nil {
+		t.Fatal(err)
+	}
+	lookupAll("DecodeCBOR", decoded.Lookup)
+	var csv bytes.Buffer
+	if err := cf.DumpCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = LoadCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	lookupAll("LoadCSV", decoded.Lookup)
+	var parquet bytes.Buffer
+	if err := cf.DumpParquet(&parquet); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = LoadParquet(parquet.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	lookupAll("LoadParquet", decoded.Lookup)
+
+	if err := NewFilter(1000).Merge(cf); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Merge() with different schemes = %v, want %v", err, ErrIncompatible)
+	}
+
+	for i := 0; i < 800; i++ {
+		cf.Delete([]byte(strconv.Itoa(i)))
+	}
+	if !cf.Shrink() {
+		t.Fatal("Shrink() = false")
+	}
+	for i := 800; i < 900; i++ {
+		if !cf.Lookup([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Lookup(%d) = false after Shrink", i)
+		}
+	}
+}
+
+func TestAltIndexScheme_Unsupported(t *testing.T) {
+	encoded := NewFilter(100).Encode()
+	encoded[6] = 3
+	// Without a known scheme, the header is not recognized.
+	if _, err := Decode(encoded); err == nil {
+		t.Error("Decode() with unknown scheme succeeded")
+	}
+	if _, err := NewStaticFilter(encoded); err == nil {
+		t.Error("NewStaticFilter() with unknown scheme succeeded")
+	}
+}
+
+func BenchmarkFilter_LookupMiss(b *testing.B) {
+	for _, s := range []AltIndexScheme{AltIndexXOR, AltIndexCacheLine} {
+		b.Run(s.String(), func(b *testing.B) {
+			cf := NewFilter(1<<24, WithAltIndexScheme(s))
+			for i := 0; i < 1<<22; i++ {
+				cf.Insert([]byte(strconv.Itoa(i)))
+			}
+			key := make([]byte, 8)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key[0], key[1], key[2], key[3] = byte(i), byte(i>>8), byte(i>>16), byte(i>>24)
+				cf.Lookup(key)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package cuckoo
+
+import (
+	"fmt"
+	"time"
+)
+
+// AuditOp is the kind of operation of an AuditRecord.
+type AuditOp int
+
+const (
+	// AuditInsert records an insert, including inserts of batches and failed inserts.
+	AuditInsert AuditOp = iota
+	// AuditDelete records a delete, including deletes that found nothing.
+	AuditDelete
+	// AuditReset records a reset removing all items, e.g. by Reset or ResetFast.
+	AuditReset
+)
+
+// String returns the name of the operation.
+func (op AuditOp) String() string {
+	switch op {
+	case AuditInsert:
+		return "insert"
+	case AuditDelete:
+		return "delete"
+	case AuditReset:
+		return "reset"
+	}
+	return fmt.Sprintf("AuditOp(%d)", int(op))
+}
+
+// AuditRecord describes an operation that modified or tried to modify a filter.
+type AuditRecord struct {
+	Op AuditOp
+	// KeyHash is the 64-bit hash the filter derives the fingerprint and buckets of the key
+	// from, or 0 for resets. Like the filter itself, it does not reveal the key, but the
+	// records of a known key can be found by hashing it the same way, see KeyHash. With a
+	// secret seed, see WithHashSeed, others cannot match records against guessed keys.
+	KeyHash uint64
+	// Result is the result of the operation: false for a failed insert or a delete of a
+	// missing item.
+	Result bool
+	Time   time.Time
+}
+
+// AuditSink receives the audit records of a filter, e.g. to investigate delete storms or
+// spikes of insert failures after the fact. Records are passed while the filter is locked,
+// so Audit must be fast, e.g. by appending to a buffer or a channel, and must not call back
+// into the filter.
+type AuditSink interface {
+	Audit(r AuditRecord)
+}
+
+// WithAuditSink passes a record of every insert, delete and reset to s. Records never hold
+// keys, only their hashes. Lookups are not recorded.
+func WithAuditSink(s AuditSink) Option {
+	return func(cf *Filter) {
+		cf.audit = &auditor{sink: s, now: time.Now}
+	}
+}
+
+// KeyHash returns the hash of data recorded in AuditRecord.KeyHash by this filter.
+func (cf *Filter) KeyHash(data []byte) uint64 {
+	return cf.keyHash(cf.normalize(data))
+}
+
+// keyHash returns the hash of the normalized key data.
+func (cf *core) keyHash(data []byte) uint64 {
+	return versionedHash(cf.hashVersion, cf.seed, data)
+}
+
+// auditor passes audit records to a sink.
+// All methods are safe to call on a nil receiver, which disables auditing.
+type auditor struct {
+	sink AuditSink
+	now  func() time.Time
+}
+
+func (a *auditor) record(op AuditOp, keyHash uint64, result bool) {
+	if a == nil {
+		return
+	}
+	a.sink.Audit(AuditRecord{Op: op, KeyHash: keyHash, Result: result, Time: a.now()})
+}
+
+// auditKey records op on the normalized key data, hashing it only if auditing is enabled.
+func (cf *core) auditKey(op AuditOp, data []byte, result bool) {
+	if cf.audit == nil {
+		return
+	}
+	cf.audit.record(op, cf.keyHash(data), result)
+}
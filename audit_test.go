@@ -0,0 +1,65 @@
+package cuckoo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	records []AuditRecord
+}
+
+func (s *recordingSink) Audit(r AuditRecord) {
+	s.records = append(s.records, r)
+}
+
+func TestWithAuditSink(t *testing.T) {
+	var sink recordingSink
+	cf := newFilter(make([]bucket, 1))
+	WithAuditSink(&sink)(cf)
+	// Fail inserts into the full bucket at the limit, before kickouts drop a random item.
+	cf.maxCount = bucketSize
+	now := time.Unix(1700000000, 0)
+	cf.audit.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		cf.Insert([]byte{byte(i)})
+	}
+	cf.Delete([]byte{0})
+	cf.Delete([]byte("missing"))
+	// Only one of a and b fits, so the batch is rejected.
+	if err := cf.InsertBatchAtomic([][]byte{[]byte("a"), []byte("b")}); !errors.Is(err, ErrFull) {
+		t.Fatalf("InsertBatchAtomic() = %v, want %v", err, ErrFull)
+	}
+	cf.Commit([]Prepared{cf.Prepare([]byte("c"))})
+	cf.ResetFast()
+	cf.Reset()
+
+	want := []AuditRecord{
+		{AuditInsert, cf.KeyHash([]byte{0}), true, now},
+		{AuditInsert, cf.KeyHash([]byte{1}), true, now},
+		{AuditInsert, cf.KeyHash([]byte{2}), true, now},
+		{AuditInsert, cf.KeyHash([]byte{3}), true, now},
+		// One bucket holds 4 items.
+		{AuditInsert, cf.KeyHash([]byte{4}), false, now},
+		{AuditDelete, cf.KeyHash([]byte{0}), true, now},
+		{AuditDelete, cf.KeyHash([]byte("missing")), false, now},
+		{AuditInsert, cf.KeyHash([]byte("a")), false, now},
+		{AuditInsert, cf.KeyHash([]byte("b")), false, now},
+		{AuditInsert, cf.KeyHash([]byte("c")), true, now},
+		{AuditReset, 0, true, now},
+		{AuditReset, 0, true, now},
+	}
+	if len(sink.records) != len(want) {
+		t.Fatalf("got %d records %+v, want %d", len(sink.records), sink.records, len(want))
+	}
+	for k, r := range sink.records {
+		if r != want[k] {
+			t.Errorf("record %d = %+v, want %+v", k, r, want[k])
+		}
+	}
+	if cf.KeyHash([]byte("a")) == cf.KeyHash([]byte("b")) {
+		t.Error("KeyHash() is the same for different keys")
+	}
+}
@@ -0,0 +1,108 @@
+package cuckoo
+
+import "fmt"
+
+// lookupBatchGroup is the number of keys whose buckets LookupBatch loads before probing them.
+// It is about the number of cache misses a core can have in flight.
+const lookupBatchGroup = 16
+
+// LookupBatch looks up all keys and returns the results in order. results is reused if it has
+// enough capacity.
+//
+// Keys are processed in groups: all keys of a group are hashed and their candidate buckets are
+// loaded before any of them is probed. The loads are independent of each other, so the CPU
+// overlaps their cache misses instead of waiting for one bucket at a time, which is what most
+// of the time of a lookup in a large filter is spent on. The read lock is taken once.
+func (cf *Filter) LookupBatch(keys [][]byte, results []bool) []bool {
+	if cap(results) < len(keys) {
+		results = make([]bool, len(keys))
+	}
+	results = results[:len(keys)]
+
+	var (
+		i1s, i2s [lookupBatchGroup]uint
+		fps      [lookupBatchGroup]fingerprint
+		b1s, b2s [lookupBatchGroup]bucket
+	)
+
+	matchGroup := matchImplementation().matchGroup
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	for start := 0; start < len(keys); start += lookupBatchGroup {
+		group := keys[start:]
+		if len(group) > lookupBatchGroup {
+			group = group[:lookupBatchGroup]
+		}
+		for k, key := range group {
+			i1, fp := cf.indexAndFingerprint(cf.normalize(key))
+			i1s[k], i2s[k], fps[k] = i1, cf.altIndex(fp, i1), fp
+		}
+		// Prefetch: issue the loads of both buckets of every key.
+		for k := range group {
+			b1s[k], b2s[k] = cf.buckets[i1s[k]], cf.buckets[i2s[k]]
+		}
+		m1, m2 := matchGroup(&b1s, &b2s, &fps, len(group))
+		for k := range group {
+			found := m1&(1<<k) != 0 && !cf.stale(i1s[k]) || m2&(1<<k) != 0 && !cf.stale(i2s[k]) ||
+				cf.stash.find(fps[k], i1s[k], i2s[k]) >= 0
+			results[start+k] = found
+		}
+	}
+	return results
+}
+
+// InsertBatchAtomic inserts either all keys or none of them. Keys are hashed before the write
+// lock is taken once for the whole batch. If a key cannot be placed, the keys inserted before
+// it are deleted again and an error wrapping ErrFull is returned; the filter then holds the
+// same items as before, although fingerprints moved by kickouts may sit in their alternate
+// bucket.
+func (cf *Filter) InsertBatchAtomic(keys [][]byte) error {
+	batch := make([]Prepared, len(keys))
+	for n, key := range keys {
+		batch[n] = cf.Prepare(key)
+	}
+
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	if cf.count+uint(len(batch)) > cf.maxItems() {
+		cf.log.insertFailed(cf.count, cf.Cap())
+		for _, p := range batch {
+			cf.audit.record(AuditInsert, p.keyHash, false)
+		}
+		return fmt.Errorf("%w: %d items do not fit with %d of %d, inserted none", ErrFull, len(batch), cf.count, cf.maxItems())
+	}
+	for n, p := range batch {
+		if p.mask != cf.bucketIndexMask {
+			// The filter was replaced while hashing, e.g. by UnmarshalCBOR.
+			cf.undoInserts(batch[:n])
+			return fmt.Errorf("%w: filter changed size during the batch", ErrIncompatible)
+		}
+		i2 := cf.altIndex(p.fp, p.i1)
+		if !cf.insert(p.fp, p.i1) && !cf.insert(p.fp, i2) && !cf.reinsertOrUndo(p.fp, cf.randi(p.i1, i2), maxCuckooKickouts) {
+			cf.undoInserts(batch[:n])
+			cf.log.insertFailed(cf.count, cf.Cap())
+			cf.audit.record(AuditInsert, p.keyHash, false)
+			return fmt.Errorf("%w: item %d of %d could not be placed, inserted none", ErrFull, n, len(batch))
+		}
+	}
+	for _, p := range batch {
+		cf.debug.recordInsert(p.data)
+		cf.distinct.add(p.hash)
+		cf.journal.recordInsert(p.fp, p.i1, p.data)
+		cf.audit.record(AuditInsert, p.keyHash, true)
+	}
+	return nil
+}
+
+// undoInserts deletes the fingerprints of the inserted items of a batch.
+// The caller must hold the write lock.
+func (cf *Filter) undoInserts(inserted []Prepared) {
+	for n := len(inserted) - 1; n >= 0; n-- {
+		p := inserted[n]
+		if !cf.delete(p.fp, p.i1) {
+			cf.delete(p.fp, cf.altIndex(p.fp, p.i1))
+		}
+	}
+}
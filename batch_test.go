@@ -0,0 +1,78 @@
+package cuckoo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestLookupBatch(t *testing.T) {
+	cf := NewFilter(1000)
+	var keys [][]byte
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprint(i))
+		if i%3 == 0 {
+			cf.Insert(key)
+		}
+		keys = append(keys, key)
+	}
+	results := cf.LookupBatch(keys, nil)
+	for i, got := range results {
+		if want := cf.Lookup(keys[i]); got != want {
+			t.Errorf("LookupBatch()[%d] = %v, want %v", i, got, want)
+		}
+	}
+
+	cf.ResetFast()
+	for i, got := range cf.LookupBatch(keys, results) {
+		if got {
+			t.Errorf("LookupBatch()[%d] after ResetFast() = true", i)
+		}
+	}
+}
+
+func TestInsertBatchAtomic(t *testing.T) {
+	cf := NewFilter(1000)
+	var keys [][]byte
+	for i := 0; i < 500; i++ {
+		keys = append(keys, []byte(fmt.Sprint(i)))
+	}
+	if err := cf.InsertBatchAtomic(keys); err != nil {
+		t.Fatalf("InsertBatchAtomic() = %v", err)
+	}
+	if cf.Count() != 500 {
+		t.Errorf("Count() = %d, want 500", cf.Count())
+	}
+
+	// The second batch cannot fit; none of it may remain and the first batch must survive
+	// the kickouts done on the way.
+	var more [][]byte
+	for i := 500; i < 500+cf.Cap(); i++ {
+		more = append(more, []byte(fmt.Sprint(i)))
+	}
+	if err := cf.InsertBatchAtomic(more); !errors.Is(err, ErrFull) {
+		t.Fatalf("InsertBatchAtomic() = %v, want %v", err, ErrFull)
+	}
+	if cf.Count() != 500 {
+		t.Errorf("Count() after failed InsertBatchAtomic() = %d, want 500", cf.Count())
+	}
+	for _, key := range keys {
+		if !cf.Lookup(key) {
+			t.Fatalf("Lookup(%q) after failed InsertBatchAtomic() = false, want true", key)
+		}
+	}
+}
+
+func BenchmarkFilter_LookupBatch(b *testing.B) {
+	cf := NewFilter(1 << 24)
+	keys := make([][]byte, 1024)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprint(i))
+		cf.Insert(keys[i])
+	}
+	results := make([]bool, len(keys))
+	b.ResetTimer()
+	for i := 0; i < b.N; i += len(keys) {
+		cf.LookupBatch(keys, results)
+	}
+}
@@ -0,0 +1,291 @@
+// Package bench compares cuckoo filter configurations on a workload, reporting throughput,
+// false positive rate and memory, so a configuration can be picked from measurements.
+//
+// Configurations are evaluated on a reference implementation that supports any fingerprint
+// size, bucket size and hash function. The configuration of cuckoo.Filter, DefaultConfig, is
+// measured with cuckoo.Filter itself.
+package bench
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	cuckoo "github.com/chenny7/cuckoofilter"
+	metro "github.com/dgryski/go-metro"
+)
+
+// ErrInvalidConfig is returned by RunProfile for configurations outside the supported range.
+var ErrInvalidConfig = errors.New("bench: invalid configuration")
+
+// maxKickouts is the maximum number of kickouts of an insert, as in cuckoo.Filter.
+const maxKickouts = 500
+
+// Hash functions supported by Config.
+const (
+	HashMetro = "metro"
+	HashFNV   = "fnv"
+	HashCRC64 = "crc64"
+)
+
+// Config is a filter configuration.
+type Config struct {
+	// FingerprintBits is the size of a fingerprint, from 2 to 32.
+	FingerprintBits int
+	// BucketSize is the number of slots of a bucket, from 1 to 16.
+	BucketSize int
+	// Hash is the hash function of keys, one of HashMetro, HashFNV and HashCRC64.
+	Hash string
+}
+
+// DefaultConfig is the configuration of cuckoo.Filter.
+var DefaultConfig = Config{FingerprintBits: 16, BucketSize: 4, Hash: HashMetro}
+
+func (c Config) String() string {
+	return fmt.Sprintf("%d-bit fingerprints, %d slots per bucket, %s", c.FingerprintBits, c.BucketSize, c.Hash)
+}
+
+// Workload describes the operations of a profile run.
+type Workload struct {
+	// Capacity is the capacity the filter is created with, see cuckoo.NewFilter.
+	Capacity uint
+	// Inserts is the number of distinct keys inserted.
+	Inserts int
+	// Lookups is the number of lookups of keys that were not inserted, which measure the
+	// false positive rate.
+	Lookups int
+	// KeySize is the length of the random keys in bytes, at least 8.
+	KeySize int
+	// Seed seeds the generation of keys, so runs with the same seed use the same keys.
+	Seed int64
+}
+
+// Report is the result of a profile run.
+type Report struct {
+	Config Config
+	// Inserted is the number of successful inserts, LoadFactor the resulting load factor.
+	Inserted   int
+	LoadFactor float64
+	// InsertsPerSecond and LookupsPerSecond are the measured throughputs.
+	InsertsPerSecond float64
+	LookupsPerSecond float64
+	// FalsePositiveRate is the fraction of lookups of absent keys that returned true.
+	FalsePositiveRate float64
+	// MemoryBytes is the size of the table with fingerprints packed to FingerprintBits.
+	MemoryBytes int
+}
+
+// set is the filter a profile runs on.
+type set interface {
+	Insert(data []byte) bool
+	Lookup(data []byte) bool
+	Delete(data []byte) bool
+	LoadFactor() float64
+}
+
+// RunProfile creates a filter for cfg, runs workload on it and reports the measurements.
+func RunProfile(cfg Config, workload Workload) (Report, error) {
+	if workload.KeySize < 8 {
+		return Report{}, fmt.Errorf("%w: key size %d, want at least 8", ErrInvalidConfig, workload.KeySize)
+	}
+	s, memory, err := newSet(cfg, workload.Capacity)
+	if err != nil {
+		return Report{}, err
+	}
+
+	r := rand.New(rand.NewSource(workload.Seed))
+	keys := make([][]byte, workload.Inserts+workload.Lookups)
+	for k := range keys {
+		keys[k] = make([]byte, workload.KeySize)
+		r.Read(keys[k])
+		// Make keys distinct.
+		binary.LittleEndian.PutUint64(keys[k], uint64(k))
+	}
+
+	report := Report{Config: cfg, MemoryBytes: memory}
+	start := time.Now()
+	for _, key := range keys[:workload.Inserts] {
+		if s.Insert(key) {
+			report.Inserted++
+		}
+	}
+	report.InsertsPerSecond = perSecond(workload.Inserts, time.Since(start))
+	report.LoadFactor = s.LoadFactor()
+
+	falsePositives := 0
+	start = time.Now()
+	for _, key := range keys[workload.Inserts:] {
+		if s.Lookup(key) {
+			falsePositives++
+		}
+	}
+	report.LookupsPerSecond = perSecond(workload.Lookups, time.Since(start))
+	if workload.Lookups > 0 {
+		report.FalsePositiveRate = float64(falsePositives) / float64(workload.Lookups)
+	}
+	return report, nil
+}
+
+// newSet returns a filter for cfg with the given capacity and its memory size.
+func newSet(cfg Config, capacity uint) (set, int, error) {
+	if cfg == DefaultConfig {
+		cf := cuckoo.NewFilter(capacity)
+		return cf, cf.Cap() * cfg.FingerprintBits / 8, nil
+	}
+	t, err := newTable(cfg, capacity)
+	if err != nil {
+		return nil, 0, err
+	}
+	return t, len(t.slots) * cfg.FingerprintBits / 8, nil
+}
+
+// Compare runs workload on every configuration and returns the reports in order.
+func Compare(cfgs []Config, workload Workload) ([]Report, error) {
+	reports := make([]Report, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		report, err := RunProfile(cfg, workload)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", cfg, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func perSecond(n int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// table is the reference implementation of a cuckoo filter with any configuration. Slots
+// hold fingerprints unpacked; 0 is the empty slot.
+type table struct {
+	slots      []uint32
+	bucketSize int
+	mask       uint64
+	fpMask     uint64
+	hash       func(data []byte) uint64
+	count      int
+}
+
+var ecmaTable = crc64.MakeTable(crc64.ECMA)
+
+func newTable(cfg Config, capacity uint) (*table, error) {
+	if cfg.FingerprintBits < 2 || cfg.FingerprintBits > 32 || cfg.BucketSize < 1 || cfg.BucketSize > 16 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, cfg)
+	}
+	t := &table{bucketSize: cfg.BucketSize, fpMask: 1<<uint(cfg.FingerprintBits) - 1}
+	switch cfg.Hash {
+	case HashMetro:
+		t.hash = func(data []byte) uint64 { return metro.Hash64(data, 1337) }
+	case HashFNV:
+		t.hash = func(data []byte) uint64 {
+			h := fnv.New64a()
+			h.Write(data)
+			return h.Sum64()
+		}
+	case HashCRC64:
+		t.hash = func(data []byte) uint64 { return crc64.Checksum(data, ecmaTable) }
+	default:
+		return nil, fmt.Errorf("%w: unknown hash function %q", ErrInvalidConfig, cfg.Hash)
+	}
+	// Size like cuckoo.NewFilter: a power of two of buckets, doubled above a load of 96%.
+	numBuckets := uint64(1)
+	for numBuckets*uint64(cfg.BucketSize) < uint64(capacity) {
+		numBuckets <<= 1
+	}
+	if float64(capacity)/float64(numBuckets*uint64(cfg.BucketSize)) > 0.96 {
+		numBuckets <<= 1
+	}
+	t.slots = make([]uint32, numBuckets*uint64(cfg.BucketSize))
+	t.mask = numBuckets - 1
+	return t, nil
+}
+
+func (t *table) indexAndFingerprint(data []byte) (uint64, uint32) {
+	h := t.hash(data)
+	// Fingerprints from the high bits, in [1, fpMask].
+	fp := uint32((h>>32)%t.fpMask + 1)
+	return h & t.mask, fp
+}
+
+func (t *table) altIndex(fp uint32, i uint64) uint64 {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], fp)
+	return (i ^ t.hash(b[:])) & t.mask
+}
+
+func (t *table) bucket(i uint64) []uint32 {
+	return t.slots[int(i)*t.bucketSize : int(i+1)*t.bucketSize]
+}
+
+func (t *table) put(fp uint32, i uint64) bool {
+	for j, s := range t.bucket(i) {
+		if s == 0 {
+			t.bucket(i)[j] = fp
+			t.count++
+			return true
+		}
+	}
+	return false
+}
+
+func (t *table) has(fp uint32, i uint64) bool {
+	for _, s := range t.bucket(i) {
+		if s == fp {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *table) Insert(data []byte) bool {
+	i1, fp := t.indexAndFingerprint(data)
+	i2 := t.altIndex(fp, i1)
+	if t.put(fp, i1) || t.put(fp, i2) {
+		return true
+	}
+	i := i1
+	if rand.Intn(2) == 0 {
+		i = i2
+	}
+	for k := 0; k < maxKickouts; k++ {
+		b := t.bucket(i)
+		j := rand.Intn(len(b))
+		fp, b[j] = b[j], fp
+		i = t.altIndex(fp, i)
+		if t.put(fp, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *table) Lookup(data []byte) bool {
+	i1, fp := t.indexAndFingerprint(data)
+	return t.has(fp, i1) || t.has(fp, t.altIndex(fp, i1))
+}
+
+func (t *table) Delete(data []byte) bool {
+	i1, fp := t.indexAndFingerprint(data)
+	for _, i := range [2]uint64{i1, t.altIndex(fp, i1)} {
+		for j, s := range t.bucket(i) {
+			if s == fp {
+				t.bucket(i)[j] = 0
+				t.count--
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *table) LoadFactor() float64 {
+	return float64(t.count) / float64(len(t.slots))
+}
@@ -0,0 +1,42 @@
+package bench
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRunProfile(t *testing.T) {
+	workload := Workload{Capacity: 10000, Inserts: 9000, Lookups: 100000, KeySize: 16, Seed: 1}
+	reports, err := Compare([]Config{
+		DefaultConfig,
+		{FingerprintBits: 16, BucketSize: 4, Hash: HashFNV},
+		{FingerprintBits: 8, BucketSize: 4, Hash: HashMetro},
+		{FingerprintBits: 12, BucketSize: 2, Hash: HashCRC64},
+	}, workload)
+	if err != nil {
+		t.Fatalf("Compare() = %v", err)
+	}
+	for _, r := range reports {
+		t.Logf("%v: load %.3f, %.0f inserts/s, %.0f lookups/s, FPP %.5f, %d bytes", r.Config,
+			r.LoadFactor, r.InsertsPerSecond, r.LookupsPerSecond, r.FalsePositiveRate, r.MemoryBytes)
+		if r.Inserted < 8900 || r.InsertsPerSecond <= 0 || r.LookupsPerSecond <= 0 {
+			t.Errorf("%v: inserted %d of 9000 items", r.Config, r.Inserted)
+		}
+	}
+	if reports[0].MemoryBytes != 16384*2 {
+		t.Errorf("MemoryBytes of DefaultConfig = %d, want %d", reports[0].MemoryBytes, 16384*2)
+	}
+	// 8-bit fingerprints have many more false positives than 16-bit ones.
+	if reports[2].FalsePositiveRate < 10*reports[0].FalsePositiveRate || reports[2].FalsePositiveRate > 0.05 {
+		t.Errorf("FalsePositiveRate of 8-bit fingerprints = %v, of 16-bit ones %v", reports[2].FalsePositiveRate, reports[0].FalsePositiveRate)
+	}
+	if reports[2].MemoryBytes*2 != reports[1].MemoryBytes {
+		t.Errorf("MemoryBytes of 8-bit fingerprints = %d, want half of %d", reports[2].MemoryBytes, reports[1].MemoryBytes)
+	}
+
+	for _, cfg := range []Config{{FingerprintBits: 40, BucketSize: 4, Hash: HashMetro}, {FingerprintBits: 16, BucketSize: 4, Hash: "md5"}} {
+		if _, err := RunProfile(cfg, workload); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("RunProfile(%v) = %v, want ErrInvalidConfig", cfg, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package bench
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	cuckoo "github.com/chenny7/cuckoofilter"
+	metro "github.com/dgryski/go-metro"
+)
+
+// workloadMagic starts the logs written by Recorder.
+const workloadMagic = "CKWL\x01"
+
+// Op is the kind of a recorded operation.
+type Op uint8
+
+// Recorded operations.
+const (
+	OpInsert Op = iota + 1
+	OpLookup
+	OpDelete
+)
+
+// recordSize is the size of a recorded operation: the Op and the 64-bit hash of the key.
+const recordSize = 9
+
+// Recorder is a set recording the operations on another set to a log, which Replay runs
+// against other configurations. Keys are logged as salted 64-bit hashes only, so a
+// production workload can be analyzed without exposing its keys; with a secret salt, logged
+// hashes cannot be matched against guessed keys. It is safe for concurrent use if the
+// underlying set is.
+type Recorder struct {
+	set  cuckoo.ApproxSet
+	salt uint64
+
+	lock sync.Mutex
+	w    *bufio.Writer
+	err  error
+}
+
+var _ cuckoo.ApproxSet = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder passing operations through to set and logging them to w.
+// Call Flush when done.
+func NewRecorder(set cuckoo.ApproxSet, w io.Writer, salt uint64) *Recorder {
+	r := &Recorder{set: set, salt: salt, w: bufio.NewWriter(w)}
+	_, r.err = r.w.WriteString(workloadMagic)
+	return r
+}
+
+func (r *Recorder) record(op Op, data []byte) {
+	var rec [recordSize]byte
+	rec[0] = byte(op)
+	binary.LittleEndian.PutUint64(rec[1:], metro.Hash64(data, r.salt))
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err == nil {
+		_, r.err = r.w.Write(rec[:])
+	}
+}
+
+// Insert inserts data into the underlying set and records the operation.
+func (r *Recorder) Insert(data []byte) bool {
+	r.record(OpInsert, data)
+	return r.set.Insert(data)
+}
+
+// Lookup looks up data in the underlying set and records the operation.
+func (r *Recorder) Lookup(data []byte) bool {
+	r.record(OpLookup, data)
+	return r.set.Lookup(data)
+}
+
+// Delete deletes data from the underlying set and records the operation.
+func (r *Recorder) Delete(data []byte) bool {
+	r.record(OpDelete, data)
+	return r.set.Delete(data)
+}
+
+// Count returns the number of items in the underlying set.
+func (r *Recorder) Count() uint {
+	return r.set.Count()
+}
+
+// Flush writes buffered records and returns the first error writing the log.
+func (r *Recorder) Flush() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	return r.err
+}
+
+// ReplayReport is the result of replaying a log with Replay.
+type ReplayReport struct {
+	Config Config
+	// Inserts, Lookups and Deletes are the numbers of replayed operations of every kind.
+	Inserts, Lookups, Deletes int
+	// FailedInserts, PositiveLookups and FailedDeletes count the operations returning false,
+	// true and false, respectively.
+	FailedInserts, PositiveLookups, FailedDeletes int
+	// LoadFactor is the load factor after the replay.
+	LoadFactor float64
+	// OpsPerSecond is the throughput of the replay.
+	OpsPerSecond float64
+	// MemoryBytes is the size of the table, see Report.
+	MemoryBytes int
+}
+
+// Replay runs the operations logged by a Recorder from r against a filter with configuration
+// cfg and the given capacity. The log is read completely before the replay is timed.
+func Replay(r io.Reader, cfg Config, capacity uint) (ReplayReport, error) {
+	s, memory, err := newSet(cfg, capacity)
+	if err != nil {
+		return ReplayReport{}, err
+	}
+	log, err := io.ReadAll(r)
+	if err != nil {
+		return ReplayReport{}, err
+	}
+	if len(log) < len(workloadMagic) || string(log[:len(workloadMagic)]) != workloadMagic {
+		return ReplayReport{}, fmt.Errorf("%w: not a workload log", cuckoo.ErrCorrupted)
+	}
+	log = log[len(workloadMagic):]
+	if len(log)%recordSize != 0 {
+		return ReplayReport{}, fmt.Errorf("%w: truncated workload log", cuckoo.ErrCorrupted)
+	}
+
+	report := ReplayReport{Config: cfg, MemoryBytes: memory}
+	start := time.Now()
+	for ; len(log) > 0; log = log[recordSize:] {
+		key := log[1:recordSize]
+		switch Op(log[0]) {
+		case OpInsert:
+			report.Inserts++
+			if !s.Insert(key) {
+				report.FailedInserts++
+			}
+		case OpLookup:
+			report.Lookups++
+			if s.Lookup(key) {
+				report.PositiveLookups++
+			}
+		case OpDelete:
+			report.Deletes++
+			if !s.Delete(key) {
+				report.FailedDeletes++
+			}
+		default:
+			return ReplayReport{}, fmt.Errorf("%w: unknown operation %d", cuckoo.ErrCorrupted, log[0])
+		}
+	}
+	report.OpsPerSecond = perSecond(report.Inserts+report.Lookups+report.Deletes, time.Since(start))
+	report.LoadFactor = s.LoadFactor()
+	return report, nil
+}
@@ -0,0 +1,55 @@
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	cuckoo "github.com/chenny7/cuckoofilter"
+)
+
+func TestRecordReplay(t *testing.T) {
+	var log bytes.Buffer
+	r := NewRecorder(cuckoo.NewFilter(1000), &log, 42)
+	for i := 0; i < 500; i++ {
+		r.Insert([]byte(fmt.Sprint(i)))
+	}
+	for i := 0; i < 1000; i++ {
+		r.Lookup([]byte(fmt.Sprint(i)))
+	}
+	for i := 0; i < 100; i++ {
+		r.Delete([]byte(fmt.Sprint(i)))
+	}
+	r.Delete([]byte("missing"))
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := log.Len(), len(workloadMagic)+1601*recordSize; got != want {
+		t.Fatalf("log size = %d, want %d", got, want)
+	}
+	if bytes.Contains(log.Bytes(), []byte("missing")) {
+		t.Error("log contains a raw key")
+	}
+
+	for _, cfg := range []Config{DefaultConfig, {FingerprintBits: 16, BucketSize: 4, Hash: "fnv"}} {
+		report, err := Replay(bytes.NewReader(log.Bytes()), cfg, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Inserts != 500 || report.Lookups != 1000 || report.Deletes != 101 {
+			t.Errorf("%+v: replayed %d/%d/%d operations", cfg, report.Inserts, report.Lookups, report.Deletes)
+		}
+		if report.FailedInserts != 0 || report.PositiveLookups < 500 || report.FailedDeletes < 1 {
+			t.Errorf("%+v: unexpected report %+v", cfg, report)
+		}
+	}
+}
+
+func TestReplayCorrupted(t *testing.T) {
+	for _, log := range []string{"", "nope", workloadMagic + "\x01", workloadMagic + "\x09\x00\x00\x00\x00\x00\x00\x00\x00"} {
+		if _, err := Replay(bytes.NewReader([]byte(log)), DefaultConfig, 100); !errors.Is(err, cuckoo.ErrCorrupted) {
+			t.Errorf("Replay(%q) = %v, want ErrCorrupted", log, err)
+		}
+	}
+}
@@ -3,65 +3,111 @@ package cuckoo
 import (
 	"bytes"
 	"fmt"
+	"math/bits"
 )
 
 // fingerprint represents a single entry in a bucket.
 type fingerprint uint16
 
-// bucket keeps track of fingerprints hashing to the same index.
-type bucket [bucketSize]fingerprint
+// bucket keeps track of fingerprints hashing to the same index. The fingerprint in slot j
+// is stored in bits [16j, 16j+16) of the word, so a bucket is read with a single load and
+// all slots are compared at once.
+type bucket uint64
 
 const (
 	nullFp              = 0
 	bucketSize          = 4
 	fingerprintSizeBits = 16
 	maxFingerprint      = (1 << fingerprintSizeBits) - 1
+	// bucketBytes is the size of an encoded bucket.
+	bucketBytes = bucketSize * fingerprintSizeBits / 8
 )
 
+// Lane masks for operating on all fingerprints of a bucket at once.
+const (
+	lanesLow  = 0x0001000100010001
+	lanesHigh = 0x8000800080008000
+	lanesRest = 0x7fff7fff7fff7fff
+)
+
+// zeroLanes returns a mask with the high bit set in every 16-bit lane of w that is zero.
+func zeroLanes(w uint64) uint64 {
+	// Adding lanesRest to the low 15 bits of a lane sets its high bit unless they are all zero.
+	// Lanes cannot carry into each other.
+	return ^((w&lanesRest + lanesRest) | w) & lanesHigh
+}
+
+// matchLanes returns a mask with the high bit set in every slot of b holding fp.
+func (b bucket) matchLanes(fp fingerprint) uint64 {
+	return zeroLanes(uint64(b) ^ uint64(fp)*lanesLow)
+}
+
+// get returns the fingerprint in slot j.
+func (b bucket) get(j int) fingerprint {
+	return fingerprint(b >> (fingerprintSizeBits * j))
+}
+
+// set stores fp in slot j.
+func (b *bucket) set(j int, fp fingerprint) {
+	shift := fingerprintSizeBits * j
+	*b = *b&^(maxFingerprint<<shift) | bucket(fp)<<shift
+}
+
+// swap stores fp in slot j and returns the fingerprint previously stored there.
+func (b *bucket) swap(j int, fp fingerprint) fingerprint {
+	old := b.get(j)
+	b.set(j, fp)
+	return old
+}
+
+// fingerprints returns the fingerprints of all slots.
+func (b bucket) fingerprints() [bucketSize]fingerprint {
+	return [bucketSize]fingerprint{b.get(0), b.get(1), b.get(2), b.get(3)}
+}
+
 // insert a fingerprint into a bucket. Returns true if there was enough space and insertion succeeded.
 // Note it allows inserting the same fingerprint multiple times.
 func (b *bucket) insert(fp fingerprint) bool {
-	for i, tfp := range b {
-		if tfp == nullFp {
-			b[i] = fp
-			return true
-		}
+	m := b.matchLanes(nullFp)
+	if m == 0 {
+		return false
 	}
-	return false
+	// m&-m isolates the high bit of the first empty lane; spread it over the whole lane.
+	low := m & -m
+	*b |= bucket(low>>(fingerprintSizeBits-1)) * bucket(fp)
+	return true
 }
 
 // delete a fingerprint from a bucket.
 // Returns true if the fingerprint was present and successfully removed.
 func (b *bucket) delete(fp fingerprint) bool {
-	for i, tfp := range b {
-		if tfp == fp {
-			b[i] = nullFp
-			return true
-		}
+	m := b.matchLanes(fp)
+	if m == 0 {
+		return false
 	}
-	return false
+	low := m & -m
+	*b &^= bucket(low>>(fingerprintSizeBits-1)) * maxFingerprint
+	return true
 }
 
-func (b *bucket) contains(needle fingerprint) bool {
-	for _, fp := range b {
-		if fp == needle {
-			return true
-		}
-	}
-	return false
+func (b bucket) contains(needle fingerprint) bool {
+	return b.matchLanes(needle) != 0
+}
+
+// free returns the number of empty slots in the bucket.
+func (b bucket) free() int {
+	return bits.OnesCount64(b.matchLanes(nullFp))
 }
 
 // reset deletes all fingerprints in the bucket.
 func (b *bucket) reset() {
-	for i := range b {
-		b[i] = nullFp
-	}
+	*b = 0
 }
 
-func (b *bucket) String() string {
+func (b bucket) String() string {
 	var buf bytes.Buffer
 	buf.WriteString("[")
-	for _, by := range b {
+	for _, by := range b.fingerprints() {
 		buf.WriteString(fmt.Sprintf("%5d ", by))
 	}
 	buf.WriteString("]")
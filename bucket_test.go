@@ -8,7 +8,7 @@ import (
 func TestBucket_Reset(t *testing.T) {
 	var bkt bucket
 	for i := fingerprint(0); i < bucketSize; i++ {
-		bkt[i] = i
+		bkt.set(int(i), i)
 	}
 	bkt.reset()
 
@@ -17,3 +17,49 @@ func TestBucket_Reset(t *testing.T) {
 		t.Errorf("bucket.reset() got %v, want %v", bkt, want)
 	}
 }
+
+func TestBucket_Ops(t *testing.T) {
+	var bkt bucket
+	for _, fp := range []fingerprint{1, 0x8000, 0xffff, 1} {
+		if !bkt.insert(fp) {
+			t.Fatalf("bucket.insert(%d) into %v failed", fp, bkt)
+		}
+	}
+	if bkt.insert(2) || bkt.free() != 0 {
+		t.Errorf("bucket.insert() into full bucket %v succeeded", bkt)
+	}
+	for _, fp := range []fingerprint{1, 0x8000, 0xffff} {
+		if !bkt.contains(fp) {
+			t.Errorf("bucket.contains(%d) = false for %v", fp, bkt)
+		}
+	}
+	for _, fp := range []fingerprint{2, 0x7fff, 0xfffe} {
+		if bkt.contains(fp) {
+			t.Errorf("bucket.contains(%d) = true for %v", fp, bkt)
+		}
+	}
+	if !bkt.delete(1) || !bkt.contains(1) || !bkt.delete(1) || bkt.contains(1) || bkt.delete(1) {
+		t.Errorf("bucket.delete() of duplicate fingerprint failed, got %v", bkt)
+	}
+	if want := [bucketSize]fingerprint{0, 0x8000, 0xffff, 0}; bkt.fingerprints() != want || bkt.free() != 2 {
+		t.Errorf("After deletes got %v with %d free slots, want %v", bkt, bkt.free(), want)
+	}
+}
+
+func BenchmarkBucket_Contains(b *testing.B) {
+	bkt := bucket(0x0004000300020001)
+	for i := 0; i < b.N; i++ {
+		bkt.contains(fingerprint(i))
+	}
+}
+
+func TestBucket_Slots(t *testing.T) {
+	var bkt bucket
+	bkt.set(2, 0xabcd)
+	if got := bkt.swap(2, 7); got != 0xabcd {
+		t.Errorf("bucket.swap() = %#x, want 0xabcd", got)
+	}
+	if want := [bucketSize]fingerprint{0, 0, 7, 0}; bkt.fingerprints() != want {
+		t.Errorf("bucket.fingerprints() = %v, want %v", bkt.fingerprints(), want)
+	}
+}
@@ -0,0 +1,60 @@
+package cuckoo
+
+import (
+	"fmt"
+	"math"
+)
+
+// budgetMaxLoad is the highest load factor NewFilterWithMemoryBudget admits, which inserts
+// reach reliably.
+const budgetMaxLoad = 0.95
+
+// NewFilterWithMemoryBudget returns the largest filter whose buckets fit into budget bytes,
+// limited to the number of items at which its false positive rate reaches targetFPP: inserts
+// beyond the limit fail, whatever the saturation policy. As fingerprints have 16 bits, the
+// rate only depends on the load factor, so a low target leaves most of the budget unused. It
+// returns an error wrapping ErrUnsupported if the budget cannot hold a single item at the
+// target rate. The limit is not encoded; see MaxCount.
+func NewFilterWithMemoryBudget(budget uint64, targetFPP float64, opts ...Option) (*Filter, error) {
+	if targetFPP <= 0 || targetFPP >= 1 {
+		return nil, fmt.Errorf("%w: false positive rate %v, want it in (0, 1)", ErrUnsupported, targetFPP)
+	}
+	numBuckets := uint64(1)
+	for numBuckets*2*bucketBytes <= budget && numBuckets*2 <= maxBuckets {
+		numBuckets *= 2
+	}
+	if numBuckets*bucketBytes > budget {
+		return nil, fmt.Errorf("%w: budget of %d bytes holds no bucket of %d bytes", ErrUnsupported, budget, bucketBytes)
+	}
+	// A lookup compares 2*bucketSize*loadFactor fingerprints.
+	loadFactor := math.Min(targetFPP*maxFingerprint/(2*bucketSize), budgetMaxLoad)
+	maxCount := uint(loadFactor * float64(numBuckets*bucketSize))
+	if maxCount == 0 {
+		return nil, fmt.Errorf("%w: budget of %d bytes holds no item at false positive rate %v", ErrUnsupported, budget, targetFPP)
+	}
+	cf := newFilter(make([]bucket, numBuckets))
+	for _, opt := range opts {
+		opt(cf)
+	}
+	cf.maxCount = maxCount
+	return cf, nil
+}
+
+// MaxCount returns the number of items inserts stop at, or 0 if they are only limited by the
+// capacity, see NewFilterWithMemoryBudget.
+func (cf *Filter) MaxCount() uint {
+	return cf.maxCount
+}
+
+// atLimit returns true if cf holds as many items as admitted by maxCount.
+func (cf *core) atLimit() bool {
+	return cf.maxCount > 0 && cf.count >= cf.maxCount
+}
+
+// maxItems returns the number of items cf admits.
+func (cf *core) maxItems() uint {
+	if cf.maxCount > 0 && cf.maxCount < uint(cf.Cap()) {
+		return cf.maxCount
+	}
+	return uint(cf.Cap())
+}
@@ -0,0 +1,68 @@
+package cuckoo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestNewFilterWithMemoryBudget(t *testing.T) {
+	cf, err := NewFilterWithMemoryBudget(100000, 1e-4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := len(cf.buckets) * bucketBytes; size > 100000 || 2*size <= 100000 {
+		t.Errorf("buckets take %d bytes for a budget of 100000", size)
+	}
+	// 1e-4 * 65535 / 8 is a load factor of 0.82.
+	if max := cf.MaxCount(); max != uint(0.8191875*float64(cf.Cap())) {
+		t.Errorf("MaxCount() = %d for %d slots", max, cf.Cap())
+	}
+	inserted := uint(0)
+	for i := 0; i < cf.Cap(); i++ {
+		if cf.Insert([]byte(strconv.Itoa(i))) {
+			inserted++
+		}
+	}
+	if inserted != cf.MaxCount() || cf.Count() != cf.MaxCount() {
+		t.Errorf("inserted %d items, count %d, want %d", inserted, cf.Count(), cf.MaxCount())
+	}
+	if _, err := cf.Commit([]Prepared{cf.Prepare([]byte("more"))}); !errors.Is(err, ErrFull) {
+		t.Errorf("Commit() beyond the limit = %v, want %v", err, ErrFull)
+	}
+	if err := cf.InsertBatchAtomic([][]byte{[]byte("more")}); !errors.Is(err, ErrFull) || cf.Count() != cf.MaxCount() {
+		t.Errorf("InsertBatchAtomic() beyond the limit = %v with count %d, want %v", err, cf.Count(), ErrFull)
+	}
+
+	// A batch that crosses the limit is rejected as a whole.
+	batched, _ := NewFilterWithMemoryBudget(100000, 1e-4)
+	keys := make([][]byte, batched.MaxCount()+1)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	if err := batched.InsertBatchAtomic(keys); !errors.Is(err, ErrFull) || batched.Count() != 0 {
+		t.Errorf("InsertBatchAtomic() of %d keys = %v with count %d, want %v", len(keys), err, batched.Count(), ErrFull)
+	}
+	if err := batched.InsertBatchAtomic(keys[1:]); err != nil || batched.Count() != batched.MaxCount() {
+		t.Errorf("InsertBatchAtomic() of %d keys = %v with count %d", len(keys)-1, err, batched.Count())
+	}
+
+	loose, err := NewFilterWithMemoryBudget(1<<20, 0.5)
+	if err != nil || loose.MaxCount() != uint(budgetMaxLoad*float64(loose.Cap())) {
+		t.Errorf("MaxCount() = %d, %v with a loose target", loose.MaxCount(), err)
+	}
+
+	for _, tc := range []struct {
+		budget uint64
+		fpp    float64
+	}{
+		{7, 0.01},
+		{1 << 20, 0},
+		{1 << 20, 1},
+		{8, 1e-6},
+	} {
+		if _, err := NewFilterWithMemoryBudget(tc.budget, tc.fpp); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("NewFilterWithMemoryBudget(%d, %v) = %v, want %v", tc.budget, tc.fpp, err, ErrUnsupported)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package cuckoo
+
+import (
+	"sync"
+	"time"
+)
+
+// BufferedWriter buffers inserts into a Filter and commits them in batches, taking the lock of
+// the filter once per batch. Give every ingesting goroutine its own BufferedWriter to cut
+// contention on the filter.
+//
+// Buffered items become visible to lookups on the filter after at most maxDelay, or earlier
+// when batchSize items are buffered or on Flush. Lookup on the writer itself also sees
+// buffered items.
+type BufferedWriter struct {
+	cf        *Filter
+	batchSize int
+	maxDelay  time.Duration
+
+	lock    sync.Mutex
+	pending []Prepared
+	timer   *time.Timer
+	// err is the error of a flush triggered by the timer, returned by the next call.
+	err error
+}
+
+// NewBufferedWriter returns a writer committing to cf in batches of batchSize items, and no
+// later than maxDelay after an item was buffered. Close it when done.
+func (cf *Filter) NewBufferedWriter(batchSize int, maxDelay time.Duration) *BufferedWriter {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &BufferedWriter{
+		cf:        cf,
+		batchSize: batchSize,
+		maxDelay:  maxDelay,
+		pending:   make([]Prepared, 0, batchSize),
+	}
+}
+
+// Insert buffers data for insertion. It returns the error of a previous flush, if any, which
+// wraps ErrFull if the filter could not hold all items.
+func (w *BufferedWriter) Insert(data []byte) error {
+	p := w.cf.Prepare(data)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.pending = append(w.pending, p)
+	if len(w.pending) >= w.batchSize {
+		w.flush()
+	} else if w.timer == nil {
+		w.timer = time.AfterFunc(w.maxDelay, func() {
+			w.lock.Lock()
+			defer w.lock.Unlock()
+			w.flush()
+		})
+	}
+	return w.takeErr()
+}
+
+// Lookup returns true if data is buffered or in the filter.
+func (w *BufferedWriter) Lookup(data []byte) bool {
+	p := w.cf.Prepare(data)
+	i2 := altIndex(w.cf.altScheme, p.fp, p.i1, p.mask)
+
+	w.lock.Lock()
+	for _, q := range w.pending {
+		if q.fp == p.fp && (q.i1 == p.i1 || q.i1 == i2) {
+			w.lock.Unlock()
+			return true
+		}
+	}
+	w.lock.Unlock()
+	return w.cf.Lookup(data)
+}
+
+// Flush commits all buffered items and returns the first error since the last call.
+func (w *BufferedWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.flush()
+	return w.takeErr()
+}
+
+// Close flushes the writer. It must not be used afterwards.
+func (w *BufferedWriter) Close() error {
+	return w.Flush()
+}
+
+// flush commits the pending items. The caller must hold w.lock.
+func (w *BufferedWriter) flush() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if len(w.pending) == 0 {
+		return
+	}
+	if _, err := w.cf.Commit(w.pending); err != nil && w.err == nil {
+		w.err = err
+	}
+	w.pending = w.pending[:0]
+}
+
+func (w *BufferedWriter) takeErr() error {
+	err := w.err
+	w.err = nil
+	return err
+}
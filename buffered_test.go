@@ -0,0 +1,62 @@
+package cuckoo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBufferedWriter(t *testing.T) {
+	cf := NewFilter(10000)
+	w := cf.NewBufferedWriter(100, time.Hour)
+	for i := 0; i < 150; i++ {
+		if err := w.Insert([]byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Insert() = %v", err)
+		}
+	}
+	if got := cf.Count(); got != 100 {
+		t.Errorf("After 150 Insert(): Count() = %d, want one batch of 100", got)
+	}
+	if !w.Lookup([]byte("149")) || cf.Lookup([]byte("149")) {
+		t.Errorf("Buffered item should only be visible through the writer")
+	}
+	if err := w.Close(); err != nil || cf.Count() != 150 {
+		t.Errorf("Close() = %v with Count() = %d, want nil, 150", err, cf.Count())
+	}
+}
+
+func TestBufferedWriter_MaxDelay(t *testing.T) {
+	cf := NewFilter(10000)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			w := cf.NewBufferedWriter(1000, time.Millisecond)
+			for i := 0; i < 10; i++ {
+				w.Insert([]byte(fmt.Sprint(g, i)))
+			}
+		}(g)
+	}
+	wg.Wait()
+	deadline := time.Now().Add(5 * time.Second)
+	for cf.Count() != 40 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := cf.Count(); got != 40 {
+		t.Errorf("After maxDelay: Count() = %d, want 40", got)
+	}
+}
+
+func TestBufferedWriter_Full(t *testing.T) {
+	w := NewFilter(8).NewBufferedWriter(10, time.Hour)
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		err = w.Insert([]byte(fmt.Sprint(i)))
+	}
+	if !errors.Is(err, ErrFull) {
+		t.Errorf("Insert() into full filter error = %v, want %v", err, ErrFull)
+	}
+}
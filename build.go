@@ -0,0 +1,197 @@
+package cuckoo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// buildBatchSize is the number of keys inserted per lock acquisition by BuildFromReader.
+	buildBatchSize = 1024
+	// sortedBuildMaxLoad is the load factor BuildFromSortedHashes sizes filters for.
+	sortedBuildMaxLoad = 0.97
+	// sortedBuildMaxVisits is the number of buckets BuildFromSortedHashes searches for a free
+	// slot before giving up on a filter size.
+	sortedBuildMaxVisits = 1 << 12
+)
+
+// BuildFromReader inserts all keys read from r, separated by delim, into the filter. A trailing
+// delimiter is optional and empty keys are skipped. Keys are hashed outside the lock and
+// inserted in batches, so building from huge files needs neither chunking by the caller nor
+// holding all keys in memory.
+//
+// Returns the number of inserted keys. If an insertion fails, it stops and returns an error
+// wrapping the one of Commit: ErrFull if the filter is full, in which case pick a filter with
+// a larger capacity, or ErrIncompatible if the filter was resized during the build.
+func (cf *Filter) BuildFromReader(r io.Reader, delim byte) (int, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+	batch := make([]Prepared, 0, buildBatchSize)
+	inserted := 0
+	insertBatch := func() error {
+		n, err := cf.Commit(batch)
+		inserted += n
+		batch = batch[:0]
+		if err != nil {
+			return fmt.Errorf("inserted %d keys: %w", inserted, err)
+		}
+		return nil
+	}
+
+	for {
+		key, err := br.ReadBytes(delim)
+		if len(key) > 0 && key[len(key)-1] == delim {
+			key = key[:len(key)-1]
+		}
+		if len(key) > 0 {
+			batch = append(batch, cf.Prepare(key))
+			if len(batch) == buildBatchSize {
+				if err := insertBatch(); err != nil {
+					return inserted, err
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return inserted, err
+		}
+	}
+	return inserted, insertBatch()
+}
+
+// HashKey returns the 64-bit hash of data that BuildFromSortedHashes expects, the hash of the
+// current hashing version with the default seed.
+func HashKey(data []byte) uint64 {
+	return hashKey(data)
+}
+
+// BuildFromSortedHashes returns a filter holding the items with the given hashes, as returned
+// by HashKey, which must be sorted in ascending order. Duplicates are stored once. It is meant
+// for static datasets built offline, e.g. by batch jobs sorting the hashes externally.
+//
+// Instead of random kickout chains, every item is placed along the shortest path of moves to
+// a free slot, found by breadth-first search. This reaches load factors of 97%, so the filter
+// is usually half the size NewFilter picks, and the placement is deterministic: the same
+// hashes always give the same encoding.
+func BuildFromSortedHashes(hashes []uint64) (*Filter, error) {
+	return buildSorted(hashes)
+}
+
+// buildSorted implements BuildFromSortedHashes for filters configured by opts, whose hashing
+// must match that of hashes.
+func buildSorted(hashes []uint64, opts ...Option) (*Filter, error) {
+	distinct := 0
+	for k, h := range hashes {
+		if k > 0 && h < hashes[k-1] {
+			return nil, fmt.Errorf("%w: hash %d is smaller than the one before", ErrUnsupported, k)
+		}
+		if k == 0 || h != hashes[k-1] {
+			distinct++
+		}
+	}
+	numBuckets := getNextPow2(uint64(math.Ceil(float64(distinct) / sortedBuildMaxLoad / bucketSize)))
+	if numBuckets == 0 {
+		numBuckets = 1
+	}
+	for ; numBuckets <= maxBuckets; numBuckets <<= 1 {
+		cf := newFilter(make([]bucket, numBuckets))
+		for _, opt := range opts {
+			opt(cf)
+		}
+		if cf.placeSorted(hashes) {
+			return cf, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d items", ErrTooLarge, distinct)
+}
+
+// placeSorted places all items of the sorted hashes into the empty filter cf. It returns false
+// if an item did not fit.
+func (cf *Filter) placeSorted(hashes []uint64) bool {
+	var paths pathFinder
+	for k, h := range hashes {
+		if k > 0 && h == hashes[k-1] {
+			continue
+		}
+		i1, fp := getIndexAndFingerprintFromHash(h, cf.bucketIndexMask)
+		i2 := cf.altIndex(fp, i1)
+		if cf.insert(fp, i1) || cf.insert(fp, i2) {
+			continue
+		}
+		i, ok := paths.makeRoom(&cf.core, sortedBuildMaxVisits, i1, i2)
+		if !ok {
+			return false
+		}
+		cf.insert(fp, i)
+	}
+	return true
+}
+
+// pathFinder frees slots by moving fingerprints along the shortest path to a free slot. It
+// keeps its buffers between searches.
+type pathFinder struct {
+	queue   []pathNode
+	visited map[uint]bool
+}
+
+type pathNode struct {
+	bucket uint
+	// parent is the index of the node whose fingerprint in slot moves into bucket.
+	parent, slot int
+}
+
+// makeRoom frees a slot in one of the full start buckets by breadth-first search over the
+// alternate buckets of their fingerprints, visiting at most about maxVisits buckets. Returns
+// the bucket with the freed slot, or false if no free slot was found. The caller must hold
+// the write lock.
+func (p *pathFinder) makeRoom(cf *core, maxVisits int, starts ...uint) (uint, bool) {
+	if p.visited == nil {
+		p.visited = make(map[uint]bool)
+	}
+	for b := range p.visited {
+		delete(p.visited, b)
+	}
+	p.queue = p.queue[:0]
+	for _, i := range starts {
+		if cf.clean(i); cf.buckets[i].free() > 0 {
+			return i, true
+		}
+		if !p.visited[i] {
+			p.visited[i] = true
+			p.queue = append(p.queue, pathNode{i, -1, 0})
+		}
+	}
+	free := -1
+	for q := 0; q < len(p.queue) && free < 0 && len(p.queue) < maxVisits; q++ {
+		b := p.queue[q].bucket
+		for j := 0; j < bucketSize; j++ {
+			alt := cf.altIndex(cf.buckets[b].get(j), b)
+			if p.visited[alt] {
+				continue
+			}
+			p.visited[alt] = true
+			p.queue = append(p.queue, pathNode{alt, q, j})
+			cf.clean(alt)
+			if cf.buckets[alt].free() > 0 {
+				free = len(p.queue) - 1
+				break
+			}
+		}
+	}
+	if free < 0 {
+		return 0, false
+	}
+	// Move fingerprints along the path, starting at the free slot.
+	n := p.queue[free]
+	for n.parent >= 0 {
+		from := &cf.buckets[p.queue[n.parent].bucket]
+		cf.buckets[n.bucket].insert(from.get(n.slot))
+		cf.summarize(n.bucket, from.get(n.slot))
+		from.set(n.slot, nullFp)
+		n = p.queue[n.parent]
+	}
+	return n.bucket, true
+}
@@ -0,0 +1,96 @@
+package cuckoo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBuildFromReader(t *testing.T) {
+	var keys strings.Builder
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&keys, "key%d\n", i)
+	}
+	keys.WriteString("\nlast")
+
+	cf := NewFilter(10000)
+	n, err := cf.BuildFromReader(strings.NewReader(keys.String()), '\n')
+	if n != 3001 || err != nil {
+		t.Fatalf("BuildFromReader() = %d, %v, want 3001, nil", n, err)
+	}
+	for i := 0; i < 3000; i++ {
+		if key := fmt.Sprintf("key%d", i); !cf.Lookup([]byte(key)) {
+			t.Fatalf("Lookup(%q) = false after BuildFromReader()", key)
+		}
+	}
+	if !cf.Lookup([]byte("last")) || cf.Lookup([]byte("")) {
+		t.Errorf("BuildFromReader() did not handle key without delimiter or empty key")
+	}
+}
+
+func TestBuildFromReader_Full(t *testing.T) {
+	keys := strings.Repeat("same,", 100)
+	cf := NewFilter(8)
+	if _, err := cf.BuildFromReader(strings.NewReader(keys), ','); !errors.Is(err, ErrFull) {
+		t.Errorf("BuildFromReader() into full filter error = %v, want %v", err, ErrFull)
+	}
+}
+
+// resizeReader resizes a filter when it is read, after the keys before it were prepared.
+type resizeReader struct {
+	cf *Filter
+}
+
+func (r resizeReader) Read([]byte) (int, error) {
+	r.cf.ResetWithCapacity(1 << 16)
+	return 0, io.EOF
+}
+
+func TestBuildFromReader_Resized(t *testing.T) {
+	cf := NewFilter(8)
+	r := io.MultiReader(strings.NewReader("one\ntwo\n"), resizeReader{cf})
+	if _, err := cf.BuildFromReader(r, '\n'); !errors.Is(err, ErrIncompatible) || errors.Is(err, ErrFull) {
+		t.Errorf("BuildFromReader() into resized filter error = %v, want %v", err, ErrIncompatible)
+	}
+}
+
+func TestBuildFromSortedHashes(t *testing.T) {
+	const n = 31700
+	hashes := make([]uint64, 0, n+1)
+	for i := 0; i < n; i++ {
+		hashes = append(hashes, HashKey([]byte(strconv.Itoa(i))))
+	}
+	hashes = append(hashes, hashes[0])
+	sort.Slice(hashes, func(x, y int) bool { return hashes[x] < hashes[y] })
+
+	cf, err := BuildFromSortedHashes(hashes)
+	if err != nil {
+		t.Fatalf("BuildFromSortedHashes() = %v", err)
+	}
+	if cf.Count() != n {
+		t.Errorf("Count() = %d, want %d", cf.Count(), n)
+	}
+	// 31700 items fit into 8192 buckets at 96.7% load, where NewFilter picks twice as many.
+	if cf.Cap() != 8192*bucketSize || NewFilter(n).Cap() <= cf.Cap() {
+		t.Errorf("Cap() = %d, want %d", cf.Cap(), 8192*bucketSize)
+	}
+	for i := 0; i < n; i++ {
+		if !cf.Lookup([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Lookup(%d) = false", i)
+		}
+	}
+	again, err := BuildFromSortedHashes(hashes)
+	if err != nil || !bytes.Equal(again.Encode(), cf.Encode()) {
+		t.Errorf("BuildFromSortedHashes() is not deterministic")
+	}
+
+	hashes[0], hashes[1] = hashes[1]+1, hashes[0]
+	if _, err := BuildFromSortedHashes(hashes); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("BuildFromSortedHashes(unsorted) = %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,297 @@
+package cuckoo
+
+import (
+	"math"
+	"sync"
+)
+
+// cascadeMaxLoad is the load factor above which a new, larger level is started instead of
+// merging further items into the current one.
+const cascadeMaxLoad = 0.9
+
+// Cascade is an LSM-style arrangement of filters. New items go into a small level-0 filter
+// that fits into CPU caches, and are merged down into larger levels in batches once it is
+// full, or when Flush is called. When a level fills up, a new level of twice the size is
+// added, see WithGrowthFactor, so the cascade grows without bound unless limited by
+// WithMaxMemory, and every item is written exactly twice.
+//
+// Fingerprints alone cannot be moved into a filter of a different size, so the cascade keeps
+// the 64-bit hashes of the items in level 0 until they are merged. Cascade is safe for
+// concurrent use.
+type Cascade struct {
+	lock sync.RWMutex
+	// level0 holds the items inserted since the last merge, hashes their hashKey.
+	level0 cascadeLevel
+	hashes []uint64
+	// levels holds the lower levels, the last one receives merges.
+	levels         []cascadeLevel
+	level0Elements uint
+	// targetFPP is the bound of the false positive rate of all levels, or 0, see
+	// WithTargetFPP.
+	targetFPP float64
+	// growth is the factor target grows by with every level, see WithGrowthFactor.
+	growth float64
+	// target is the number of slots the last level was sized for; levels are rounded down
+	// to a power of 2 of buckets.
+	target float64
+	// maxBytes limits bytes, the size of the buckets of all levels, or is 0, see
+	// WithMaxMemory.
+	maxBytes, bytes uint64
+}
+
+var _ ApproxSet = (*Cascade)(nil)
+
+// cascadeLevel is a level of a Cascade: a Filter, or a PackedFilter with fingerprints of at
+// most 32 bits for WithTargetFPP.
+type cascadeLevel interface {
+	ApproxSet
+	Cap() int
+	LoadFactor() float64
+	Reset()
+	insertHash(hash uint64) bool
+}
+
+// CascadeOption configures a Cascade, see NewCascade.
+type CascadeOption func(*Cascade)
+
+// WithGrowthFactor sizes every new level of a cascade for factor times the slots of the level
+// before, instead of twice. As filters have a power of 2 of buckets, levels are rounded down to
+// one: with a factor of 1.5, the levels alternate between keeping the size of the level before
+// and doubling it, which chains sub-filters of the same size but grows the total memory by
+// 1.5 per level on average. Factors below 1.1 are raised to 1.1.
+func WithGrowthFactor(factor float64) CascadeOption {
+	return func(c *Cascade) {
+		c.growth = math.Max(factor, 1.1)
+	}
+}
+
+// WithMaxMemory limits the buckets of all levels of a cascade, including level 0, to maxBytes.
+// Level 0 and the first lower level are always created. The last level that fits is made
+// smaller than the growth factor asks for, and once nothing fits, items that cannot be merged
+// stay in level 0 and inserts fail when it is full.
+func WithMaxMemory(maxBytes uint64) CascadeOption {
+	return func(c *Cascade) {
+		c.maxBytes = maxBytes
+	}
+}
+
+// WithTargetFPP bounds the false positive rate of a cascade, over all levels however many
+// are added, by fpp, which must be in (0, 1). Like for scalable Bloom filters, every level gets
+// half the budget of the level before, starting with half of fpp for level 0, and the levels
+// are PackedFilters whose fingerprints are just wide enough for their budget at full load.
+// Deeper levels therefore take more bits per item. Fingerprints are limited to 32 bits, so
+// the bound only holds for the first levels whose budget they meet, about 20 for a target of
+// 1%; see FalsePositiveRate for the actual bound.
+func WithTargetFPP(fpp float64) CascadeOption {
+	return func(c *Cascade) {
+		if fpp > 0 && fpp < 1 {
+			c.targetFPP = fpp
+		}
+	}
+}
+
+// NewCascade returns a cascade that merges level 0 after level0Elements inserts, with a first
+// lower level suitable for level1Elements items.
+func NewCascade(level0Elements, level1Elements uint, opts ...CascadeOption) *Cascade {
+	c := &Cascade{
+		hashes:         make([]uint64, 0, level0Elements),
+		level0Elements: level0Elements,
+		growth:         2,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.level0 = c.newLevel(0, uint64(numBucketsFor(level0Elements)))
+	c.levels = []cascadeLevel{c.newLevel(1, uint64(numBucketsFor(level1Elements)))}
+	c.target = float64(c.levels[0].Cap())
+	return c
+}
+
+// levelBits returns the size of the fingerprints of level k, where level 0 is the one
+// receiving inserts.
+func (c *Cascade) levelBits(k int) uint {
+	if c.targetFPP == 0 {
+		return fingerprintSizeBits
+	}
+	// A lookup in a full level compares 2*bucketSize fingerprints.
+	need := math.Log2(2 * bucketSize / (c.targetFPP / math.Exp2(float64(k+1))))
+	for _, bits := range []uint{4, 8, 12, 16} {
+		if float64(bits) >= need {
+			return bits
+		}
+	}
+	return 32
+}
+
+// levelBytes returns the size of a level of numBuckets buckets with fingerprints of the given
+// size.
+func levelBytes(numBuckets uint64, bits uint) uint64 {
+	return numBuckets * bucketSize * uint64(bits) / 8
+}
+
+// newLevel returns the empty level k of numBuckets buckets and accounts for its memory.
+func (c *Cascade) newLevel(k int, numBuckets uint64) cascadeLevel {
+	bits := c.levelBits(k)
+	c.bytes += levelBytes(numBuckets, bits)
+	if c.targetFPP == 0 {
+		return newFilter(make([]bucket, numBuckets))
+	}
+	return newPackedFilter(uint(numBuckets), bits)
+}
+
+// Insert data into level 0, merging it down first if it is full. Returns false only if the
+// cascade reached the limit of WithMaxMemory.
+func (c *Cascade) Insert(data []byte) bool {
+	hash := hashKey(data)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if uint(len(c.hashes)) >= c.level0Elements || !c.level0.insertHash(hash) {
+		c.flush()
+		if uint(len(c.hashes)) >= c.level0Elements || !c.level0.insertHash(hash) {
+			return false
+		}
+	}
+	c.hashes = append(c.hashes, hash)
+	return true
+}
+
+// Flush merges all items of level 0 into the lower levels.
+func (c *Cascade) Flush() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.flush()
+}
+
+func (c *Cascade) flush() {
+	merged := 0
+	for _, hash := range c.hashes {
+		last := c.levels[len(c.levels)-1]
+		if last.LoadFactor() < cascadeMaxLoad && last.insertHash(hash) {
+			merged++
+			continue
+		}
+		next := c.nextLevel()
+		if next == nil {
+			break
+		}
+		next.insertHash(hash)
+		c.levels = append(c.levels, next)
+		merged++
+	}
+	// Items that did not fit stay in level 0.
+	c.hashes = append(c.hashes[:0], c.hashes[merged:]...)
+	c.level0.Reset()
+	for _, hash := range c.hashes {
+		c.level0.insertHash(hash)
+	}
+}
+
+// nextLevel returns a new level sized by the growth factor and the memory limit, or nil if
+// no bucket fits into the limit.
+func (c *Cascade) nextLevel() cascadeLevel {
+	k := len(c.levels) + 1
+	bits := c.levelBits(k)
+	target := c.target * c.growth
+	numBuckets := uint64(1)
+	for numBuckets*2*bucketSize <= uint64(target) && numBuckets*2 <= maxBuckets {
+		numBuckets *= 2
+	}
+	if c.maxBytes > 0 {
+		if c.bytes+levelBytes(1, bits) > c.maxBytes {
+			return nil
+		}
+		for c.bytes+levelBytes(numBuckets, bits) > c.maxBytes {
+			numBuckets /= 2
+		}
+	}
+	c.target = target
+	return c.newLevel(k, numBuckets)
+}
+
+// Lookup returns true if data is in any level.
+func (c *Cascade) Lookup(data []byte) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.level0.Lookup(data) {
+		return true
+	}
+	for i := len(c.levels) - 1; i >= 0; i-- {
+		if c.levels[i].Lookup(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete data from the newest level containing it. Returns true if the data was found and deleted.
+// If a newer level holds a colliding fingerprint of another item, that one is deleted instead,
+// just like deleting an item that was never inserted into a Filter.
+func (c *Cascade) Delete(data []byte) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.level0.Delete(data) {
+		hash := hashKey(data)
+		for i, h := range c.hashes {
+			if h == hash {
+				last := len(c.hashes) - 1
+				c.hashes[i] = c.hashes[last]
+				c.hashes = c.hashes[:last]
+				break
+			}
+		}
+		return true
+	}
+	for i := len(c.levels) - 1; i >= 0; i-- {
+		if c.levels[i].Delete(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of items in all levels.
+func (c *Cascade) Count() uint {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	n := c.level0.Count()
+	for _, l := range c.levels {
+		n += l.Count()
+	}
+	return n
+}
+
+// FalsePositiveRate returns a bound of the current false positive rate of the cascade: the sum
+// of those of all levels, each derived from its load factor and fingerprint size. With
+// WithTargetFPP, it stays below the target unless the levels outgrow 32-bit fingerprints.
+func (c *Cascade) FalsePositiveRate() float64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	rate := 0.0
+	for k, l := range append([]cascadeLevel{c.level0}, c.levels...) {
+		rate += 2 * bucketSize * l.LoadFactor() / math.Exp2(float64(c.levelBits(k)))
+	}
+	return rate
+}
+
+// MemoryBytes returns the size of the buckets of all levels, including level 0.
+func (c *Cascade) MemoryBytes() uint64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.bytes
+}
+
+// Levels returns the number of levels below level 0.
+func (c *Cascade) Levels() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.levels)
+}
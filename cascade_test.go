@@ -0,0 +1,129 @@
+package cuckoo
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestCascade(t *testing.T) {
+	c := NewCascade(100, 1000)
+	const size = 5000
+	for i := 0; i < size; i++ {
+		c.Insert([]byte{byte(i), byte(i >> 8)})
+	}
+
+	if got, want := c.Count(), uint(size); got != want {
+		t.Errorf("Count() = %d, want %d", got, want)
+	}
+	if got := c.Levels(); got < 2 {
+		t.Errorf("Levels() = %d after inserting %d items into level of 1000, want >= 2", got, size)
+	}
+	for i := 0; i < size; i++ {
+		if !c.Lookup([]byte{byte(i), byte(i >> 8)}) {
+			t.Fatalf("Lookup(%d) = false for inserted item", i)
+		}
+	}
+
+	// A delete can hit a colliding fingerprint of another item in a newer level, which makes
+	// the delete of that other item fail later on. This is rare, but not impossible.
+	failed := 0
+	for i := 0; i < size; i++ {
+		if !c.Delete([]byte{byte(i), byte(i >> 8)}) {
+			failed++
+		}
+	}
+	if failed > 5 {
+		t.Errorf("Delete() of %d inserted items failed %d times, want <= 5", size, failed)
+	}
+	if got := c.Count(); got != uint(failed) {
+		t.Errorf("After deleting all items: Count() = %d, want %d", got, failed)
+	}
+}
+
+func TestCascade_DeleteBeforeFlush(t *testing.T) {
+	c := NewCascade(100, 1000)
+	c.Insert([]byte("one"))
+	c.Delete([]byte("one"))
+	c.Flush()
+
+	if c.Lookup([]byte("one")) {
+		t.Errorf("Lookup() = true for item deleted before Flush()")
+	}
+}
+
+func TestCascade_GrowthFactor(t *testing.T) {
+	for _, growth := range []float64{2, 1.5} {
+		c := NewCascade(100, 1000, WithGrowthFactor(growth))
+		for i := 0; i < 50000; i++ {
+			c.Insert([]byte{byte(i), byte(i >> 8), byte(i >> 16)})
+		}
+		c.Flush()
+		first := float64(c.levels[0].Cap())
+		for k, l := range c.levels {
+			// Levels are rounded down to a power of 2 of buckets.
+			want := first * math.Pow(growth, float64(k))
+			if got := float64(l.Cap()); got > want || got <= want/2 {
+				t.Errorf("growth %v: level %d has %v slots, want at most %v", growth, k, got, want)
+			}
+		}
+		if c.Count() != 50000 {
+			t.Errorf("growth %v: Count() = %d", growth, c.Count())
+		}
+	}
+}
+
+func TestCascade_MaxMemory(t *testing.T) {
+	const maxBytes = 1 << 14
+	c := NewCascade(100, 1000, WithMaxMemory(maxBytes))
+	inserted := 0
+	for i := 0; i < 20000; i++ {
+		if c.Insert([]byte{byte(i), byte(i >> 8), byte(i >> 16)}) {
+			inserted++
+		}
+	}
+	if got := c.MemoryBytes(); got > maxBytes {
+		t.Errorf("MemoryBytes() = %d, want at most %d", got, maxBytes)
+	}
+	if inserted == 20000 || inserted < maxBytes/bucketBytes*bucketSize/2 {
+		t.Errorf("inserted %d items into %d bytes", inserted, maxBytes)
+	}
+	if c.Count() != uint(inserted) {
+		t.Errorf("Count() = %d, want %d", c.Count(), inserted)
+	}
+}
+
+func TestCascade_TargetFPP(t *testing.T) {
+	const target = 0.01
+	c := NewCascade(100, 1000, WithTargetFPP(target))
+	for i := 0; i < 50000; i++ {
+		c.Insert([]byte(strconv.Itoa(i)))
+	}
+	c.Flush()
+	if c.Levels() < 4 {
+		t.Fatalf("Levels() = %d", c.Levels())
+	}
+	for k := 1; k <= c.Levels(); k++ {
+		if c.levelBits(k) < c.levelBits(k-1) {
+			t.Errorf("level %d has %d-bit fingerprints, level %d %d-bit ones", k, c.levelBits(k), k-1, c.levelBits(k-1))
+		}
+	}
+	for i := 0; i < 50000; i++ {
+		if !c.Lookup([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Lookup(%d) = false", i)
+		}
+	}
+	if rate := c.FalsePositiveRate(); rate > target {
+		t.Errorf("FalsePositiveRate() = %v, want at most %v", rate, target)
+	}
+	falsePositives := 0
+	for i := 50000; i < 150000; i++ {
+		if c.Lookup([]byte(strconv.Itoa(i))) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 100000; rate > target {
+		t.Errorf("false positive rate = %v, want at most %v", rate, target)
+	}
+
+}
@@ -0,0 +1,281 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// cborTag is the CBOR tag of encoded filters, "CKOO" as a big-endian number, which is in the
+// first come first served range of the IANA CBOR tags registry.
+const cborTag = 0x434b4f4f
+
+// CBOR major types.
+const (
+	cborUint   = 0
+	cborNegint = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTagged = 6
+	cborSimple = 7
+)
+
+// cborMaxDepth limits the nesting of skipped values.
+const cborMaxDepth = 16
+
+var errCBOR = errors.New("invalid CBOR")
+
+// MarshalCBOR returns the CBOR encoding of cf: a tagged map holding the format version, hash
+// version, hash seed and the buckets as encoded by Encode, with the keys "format", "hash",
+// "seed" and "buckets", and the key "alt" for alternate index schemes other than
+// AltIndexXOR. The encoding is deterministic.
+func (cf *Filter) MarshalCBOR() ([]byte, error) {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	if cf.stash.len() > 0 {
+		return nil, fmt.Errorf("%w: CBOR encoding of stashed items, use Encode", ErrUnsupported)
+	}
+	size := len(cf.buckets) * bucketSize * fingerprintSizeBits / 8
+	out := make([]byte, 0, 64+size)
+	out = appendCBORHead(out, cborTagged, cborTag)
+	if cf.altScheme != AltIndexXOR {
+		out = appendCBORHead(out, cborMap, 5)
+		out = appendCBORText(out, "alt")
+		out = appendCBORHead(out, cborUint, uint64(cf.altScheme))
+	} else {
+		out = appendCBORHead(out, cborMap, 4)
+	}
+	// Keys are sorted as required by the core deterministic encoding.
+	out = appendCBORText(out, "hash")
+	out = appendCBORHead(out, cborUint, uint64(cf.hashVersion))
+	out = appendCBORText(out, "seed")
+	out = appendCBORHead(out, cborUint, cf.seed)
+	out = appendCBORText(out, "format")
+	out = appendCBORHead(out, cborUint, CurrentFormatVersion)
+	out = appendCBORText(out, "buckets")
+	out = appendCBORHead(out, cborBytes, uint64(size))
+	return cf.appendBuckets(out, 0, len(cf.buckets)), nil
+}
+
+// UnmarshalCBOR sets cf to the filter encoded by MarshalCBOR, see DecodeCBOR. On error, cf is
+// left unchanged.
+func (cf *Filter) UnmarshalCBOR(data []byte) error {
+	decoded, err := DecodeCBOR(data)
+	if err != nil {
+		return err
+	}
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	cf.buckets = decoded.buckets
+	cf.count = decoded.count
+	cf.bucketIndexMask = decoded.bucketIndexMask
+	cf.generations = nil
+	cf.seed = decoded.seed
+	cf.hashVersion = decoded.hashVersion
+	cf.altScheme = decoded.altScheme
+	cf.formatVersion = decoded.formatVersion
+	cf.rebuildSummary()
+	cf.debug.reset()
+	cf.log.reset()
+	cf.distinct.reset()
+	cf.rate.reset()
+	cf.journal.reset()
+	return nil
+}
+
+// DecodeCBOR returns a Cuckoofilter from CBOR created using MarshalCBOR. The options are
+// applied to the decoded filter. Unknown map keys are ignored, so later versions can add
+// entries; an unknown format or hash version returns ErrIncompatible.
+func DecodeCBOR(data []byte, opts ...Option) (_ *Filter, err error) {
+	defer recoverInvalidState(&err)
+	cf := newFilter(nil)
+	for _, opt := range opts {
+		opt(cf)
+	}
+	if err := cf.decodeCBOR(data); err != nil {
+		cf.log.decodeFailed(err)
+		return nil, err
+	}
+	cf.rebuildSummary()
+	return cf, nil
+}
+
+func (cf *Filter) decodeCBOR(data []byte) error {
+	r := cborReader{buf: data}
+	if major, tag, err := r.head(); err != nil || major != cborTagged || tag != cborTag {
+		return fmt.Errorf("%w: expected CBOR tag %d", ErrCorrupted, cborTag)
+	}
+	major, n, err := r.head()
+	if err != nil || major != cborMap {
+		return fmt.Errorf("%w: expected CBOR map", ErrCorrupted)
+	}
+	var format, hash, alt uint64
+	var buckets []byte
+	seen := map[string]bool{}
+	for k := uint64(0); k < n; k++ {
+		key, err := r.text()
+		if err != nil {
+			return fmt.Errorf("%w: map key: %v", ErrCorrupted, err)
+		}
+		if seen[key] {
+			return fmt.Errorf("%w: duplicate map key %q", ErrCorrupted, key)
+		}
+		seen[key] = true
+		switch key {
+		case "format":
+			format, err = r.uint()
+		case "hash":
+			hash, err = r.uint()
+		case "alt":
+			alt, err = r.uint()
+		case "seed":
+			cf.seed, err = r.uint()
+		case "buckets":
+			buckets, err = r.bytes()
+		default:
+			err = r.skip(0)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: value of %q: %v", ErrCorrupted, key, err)
+		}
+	}
+	if r.pos != len(data) {
+		return fmt.Errorf("%w: %d trailing bytes", ErrCorrupted, len(data)-r.pos)
+	}
+	for _, key := range []string{"format", "hash", "seed", "buckets"} {
+		if !seen[key] {
+			return fmt.Errorf("%w: missing map key %q", ErrCorrupted, key)
+		}
+	}
+	if format > 0xff || !supportedFormatVersion(uint8(format)) {
+		return fmt.Errorf("%w: unsupported format version %d", ErrIncompatible, format)
+	}
+	if hash > 0xff || !supportedHashVersion(uint8(hash)) {
+		return fmt.Errorf("%w: unsupported hash version %d", ErrIncompatible, hash)
+	}
+	if alt > 0xff || !supportedAltIndexScheme(AltIndexScheme(alt)) {
+		return fmt.Errorf("%w: unsupported alternate index scheme %d", ErrIncompatible, alt)
+	}
+	cf.formatVersion = uint8(format)
+	cf.hashVersion = uint8(hash)
+	cf.altScheme = AltIndexScheme(alt)
+	return cf.decodeBuckets(buckets)
+}
+
+// appendCBORHead appends the head of a data item of the given major type and argument.
+func appendCBORHead(out []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(out, major<<5|byte(arg))
+	case arg <= 0xff:
+		return append(out, major<<5|24, byte(arg))
+	case arg <= 0xffff:
+		return append(out, major<<5|25, byte(arg>>8), byte(arg))
+	case arg <= 0xffffffff:
+		return append(out, major<<5|26, byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	default:
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], arg)
+		return append(append(out, major<<5|27), b[:]...)
+	}
+}
+
+func appendCBORText(out []byte, s string) []byte {
+	return append(appendCBORHead(out, cborText, uint64(len(s))), s...)
+}
+
+// cborReader decodes the subset of CBOR used by MarshalCBOR. Indefinite lengths are not
+// supported.
+type cborReader struct {
+	buf []byte
+	pos int
+}
+
+// head returns the major type and argument of the next data item.
+func (r *cborReader) head() (byte, uint64, error) {
+	if r.pos >= len(r.buf) {
+		return 0, 0, errCBOR
+	}
+	initial := r.buf[r.pos]
+	r.pos++
+	major, info := initial>>5, initial&0x1f
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+	if info > 27 {
+		return 0, 0, errCBOR
+	}
+	n := 1 << (info - 24)
+	if len(r.buf)-r.pos < n {
+		return 0, 0, errCBOR
+	}
+	var arg uint64
+	for _, b := range r.buf[r.pos : r.pos+n] {
+		arg = arg<<8 | uint64(b)
+	}
+	r.pos += n
+	return major, arg, nil
+}
+
+func (r *cborReader) uint() (uint64, error) {
+	major, arg, err := r.head()
+	if err != nil || major != cborUint {
+		return 0, errCBOR
+	}
+	return arg, nil
+}
+
+// str returns the content of a byte or text string of the given major type.
+func (r *cborReader) str(want byte) ([]byte, error) {
+	major, n, err := r.head()
+	if err != nil || major != want || n > uint64(len(r.buf)-r.pos) {
+		return nil, errCBOR
+	}
+	r.pos += int(n)
+	return r.buf[r.pos-int(n) : r.pos], nil
+}
+
+func (r *cborReader) bytes() ([]byte, error) { return r.str(cborBytes) }
+
+func (r *cborReader) text() (string, error) {
+	s, err := r.str(cborText)
+	return string(s), err
+}
+
+// skip skips the next data item.
+func (r *cborReader) skip(depth int) error {
+	if depth > cborMaxDepth {
+		return errCBOR
+	}
+	major, arg, err := r.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case cborBytes, cborText:
+		if arg > uint64(len(r.buf)-r.pos) {
+			return errCBOR
+		}
+		r.pos += int(arg)
+	case cborArray, cborMap:
+		if major == cborMap {
+			if arg > uint64(len(r.buf)) {
+				return errCBOR
+			}
+			arg *= 2
+		}
+		for k := uint64(0); k < arg; k++ {
+			if err := r.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+	case cborTagged:
+		return r.skip(depth + 1)
+	}
+	// Integers and simple values are fully contained in the head.
+	return nil
+}
@@ -0,0 +1,96 @@
+package cuckoo
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestMarshalCBOR(t *testing.T) {
+	cf := NewFilter(1000, WithHashSeed(1<<40))
+	for i := 0; i < 500; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	data, err := cf.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("MarshalCBOR() = %v", err)
+	}
+	// Tag 0x434b4f4f followed by a map of 4 entries.
+	if want := []byte{0xda, 'C', 'K', 'O', 'O', 0xa4, 0x64, 'h', 'a', 's', 'h', 0x01}; !bytes.HasPrefix(data, want) {
+		t.Errorf("MarshalCBOR() = %x..., want prefix %x", data[:len(want)], want)
+	}
+
+	got, err := DecodeCBOR(data)
+	if err != nil {
+		t.Fatalf("DecodeCBOR() = %v", err)
+	}
+	if !reflect.DeepEqual(got.buckets, cf.buckets) || got.Count() != cf.Count() || got.HashSeed() != 1<<40 {
+		t.Errorf("DecodeCBOR() = filter with %d items and seed %d, want %d and %d", got.Count(), got.HashSeed(), cf.Count(), uint64(1<<40))
+	}
+	for i := 0; i < 500; i++ {
+		if !got.Lookup([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Lookup(%d) after DecodeCBOR() = false, want true", i)
+		}
+	}
+
+	var unmarshaled Filter
+	if err := unmarshaled.UnmarshalCBOR(data); err != nil {
+		t.Fatalf("UnmarshalCBOR() = %v", err)
+	}
+	if !reflect.DeepEqual(unmarshaled.buckets, cf.buckets) || unmarshaled.Count() != cf.Count() {
+		t.Errorf("UnmarshalCBOR() = filter with %d items, want %d", unmarshaled.Count(), cf.Count())
+	}
+}
+
+func TestDecodeCBOR_UnknownKeys(t *testing.T) {
+	data := appendCBORHead(nil, cborTagged, cborTag)
+	data = appendCBORHead(data, cborMap, 5)
+	data = appendCBORText(data, "future")
+	data = appendCBORHead(data, cborArray, 2)
+	data = appendCBORText(data, "value")
+	data = appendCBORHead(data, cborMap, 0)
+	data = appendCBORText(data, "buckets")
+	data = appendCBORHead(data, cborBytes, 16)
+	data = append(data, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	data = appendCBORText(data, "format")
+	data = appendCBORHead(data, cborUint, 1)
+	data = appendCBORText(data, "hash")
+	data = appendCBORHead(data, cborUint, 1)
+	data = appendCBORText(data, "seed")
+	data = appendCBORHead(data, cborUint, 1337)
+	cf, err := DecodeCBOR(data)
+	if err != nil {
+		t.Fatalf("DecodeCBOR() = %v", err)
+	}
+	if cf.Count() != 1 || len(cf.buckets) != 2 {
+		t.Errorf("DecodeCBOR() = filter with %d items in %d buckets, want 1 in 2", cf.Count(), len(cf.buckets))
+	}
+}
+
+func TestDecodeCBOR_Invalid(t *testing.T) {
+	valid, _ := NewFilter(10).MarshalCBOR()
+	newer := append([]byte(nil), valid...)
+	newer[bytes.Index(newer, []byte("format"))+6] = CurrentFormatVersion + 1
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrCorrupted},
+		{"untagged", valid[5:], ErrCorrupted},
+		{"trailing bytes", append(append([]byte(nil), valid...), 0), ErrCorrupted},
+		{"truncated", valid[:len(valid)-1], ErrCorrupted},
+		{"newer format", newer, ErrIncompatible},
+	} {
+		if _, err := DecodeCBOR(tc.data); !errors.Is(err, tc.want) {
+			t.Errorf("DecodeCBOR(%s) = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	var cf Filter
+	if err := cf.UnmarshalCBOR(newer); !errors.Is(err, ErrIncompatible) || cf.buckets != nil {
+		t.Errorf("UnmarshalCBOR(newer format) = %v, want %v and unchanged filter", err, ErrIncompatible)
+	}
+}
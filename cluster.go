@@ -0,0 +1,309 @@
+package cuckoo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	metro "github.com/dgryski/go-metro"
+)
+
+const (
+	// clusterSeed is the hash seed for routing keys to nodes. It differs from the seed used
+	// within filters, so that all nodes see keys with uniformly distributed fingerprints.
+	clusterSeed = 7331
+	// clusterVirtualNodes is the number of points every node gets on the hash ring.
+	clusterVirtualNodes = 128
+)
+
+var (
+	// ErrNoNodes is returned by Cluster operations if the cluster has no nodes.
+	ErrNoNodes = errors.New("cuckoo: cluster has no nodes")
+	// ErrNoQuorum is returned by distributed operations if too few replicas answered.
+	ErrNoQuorum = errors.New("cuckoo: too few replicas answered")
+)
+
+// Node is a filter that is part of a Cluster, usually a client of a filter in another process.
+type Node interface {
+	Insert(ctx context.Context, data []byte) (bool, error)
+	Lookup(ctx context.Context, data []byte) (bool, error)
+	Delete(ctx context.Context, data []byte) (bool, error)
+}
+
+// LocalNode returns a Node for a filter in this process.
+func LocalNode(set ApproxSet) Node {
+	return localNode{set}
+}
+
+type localNode struct {
+	set ApproxSet
+}
+
+func (n localNode) Insert(_ context.Context, data []byte) (bool, error) {
+	return n.set.Insert(data), nil
+}
+
+func (n localNode) Lookup(_ context.Context, data []byte) (bool, error) {
+	return n.set.Lookup(data), nil
+}
+
+func (n localNode) Delete(_ context.Context, data []byte) (bool, error) {
+	return n.set.Delete(data), nil
+}
+
+type ringPoint struct {
+	hash uint64
+	name string
+}
+
+// Cluster distributes keys across nodes using consistent hashing, storing every key on
+// a fixed number of replicas. Adding or removing a node only moves the keys of its neighbors
+// on the hash ring. It is safe for concurrent use.
+type Cluster struct {
+	lock     sync.RWMutex
+	replicas int
+	// writeQuorum and readQuorum are the number of replicas that must succeed, see SetQuorum.
+	writeQuorum int
+	readQuorum  int
+	nodes       map[string]Node
+	// ring is sorted by hash.
+	ring []ringPoint
+}
+
+// NewCluster returns an empty cluster storing every key on the given number of replicas.
+// By default, writes must succeed on all replicas and reads on one, see SetQuorum. While the
+// cluster has fewer nodes than replicas, keys are stored on every node and the quorums are
+// capped at the number of nodes.
+func NewCluster(replicas int) *Cluster {
+	if replicas < 1 {
+		replicas = 1
+	}
+	return &Cluster{
+		replicas:    replicas,
+		writeQuorum: replicas,
+		readQuorum:  1,
+		nodes:       make(map[string]Node),
+	}
+}
+
+// SetQuorum sets the number of replicas on which writes must succeed and the number of
+// replicas that must answer reads, both clamped to [1, replicas]. With
+// writeQuorum + readQuorum > replicas, every read sees all completed writes, and up to
+// replicas - writeQuorum replicas can be lost without losing any data.
+func (c *Cluster) SetQuorum(writeQuorum, readQuorum int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.writeQuorum = clamp(writeQuorum, 1, c.replicas)
+	c.readQuorum = clamp(readQuorum, 1, c.replicas)
+}
+
+func clamp(x, min, max int) int {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
+
+// AddNode adds a node to the cluster, replacing any node of the same name.
+func (c *Cluster) AddNode(name string, n Node) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.nodes[name]; !ok {
+		for i := 0; i < clusterVirtualNodes; i++ {
+			point := []byte(name + "#" + strconv.Itoa(i))
+			c.ring = append(c.ring, ringPoint{metro.Hash64(point, clusterSeed), name})
+		}
+		sort.Slice(c.ring, func(i, j int) bool { return c.ring[i].hash < c.ring[j].hash })
+	}
+	c.nodes[name] = n
+}
+
+// RemoveNode removes a node from the cluster. Keys it was responsible for are only found on
+// their remaining replicas.
+func (c *Cluster) RemoveNode(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.nodes, name)
+	ring := c.ring[:0]
+	for _, p := range c.ring {
+		if p.name != name {
+			ring = append(ring, p)
+		}
+	}
+	c.ring = ring
+}
+
+// Replicas returns the names of the nodes responsible for data, in order of preference.
+func (c *Cluster) Replicas(data []byte) []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.replicaNames(data)
+}
+
+// replicaNames walks the ring clockwise from the hash of data, collecting distinct nodes.
+// The caller must hold at least the read lock.
+func (c *Cluster) replicaNames(data []byte) []string {
+	if len(c.ring) == 0 {
+		return nil
+	}
+	hash := metro.Hash64(data, clusterSeed)
+	start := sort.Search(len(c.ring), func(i int) bool { return c.ring[i].hash >= hash })
+	var names []string
+	for i := 0; i < len(c.ring) && len(names) < c.replicas; i++ {
+		name := c.ring[(start+i)%len(c.ring)].name
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func containsString(s []string, x string) bool {
+	for _, y := range s {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
+
+// replicaNodes returns the nodes responsible for data.
+func (c *Cluster) replicaNodes(data []byte) (replicaGroup, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	names := c.replicaNames(data)
+	if len(names) == 0 {
+		return replicaGroup{}, ErrNoNodes
+	}
+	// With fewer nodes than replicas, the quorums cannot exceed the replicas there are.
+	g := replicaGroup{
+		names:       names,
+		nodes:       make([]Node, len(names)),
+		writeQuorum: clamp(c.writeQuorum, 1, len(names)),
+		readQuorum:  clamp(c.readQuorum, 1, len(names)),
+	}
+	for i, name := range names {
+		g.nodes[i] = c.nodes[name]
+	}
+	return g, nil
+}
+
+// Insert data into all its replicas in parallel. Returns true once the write quorum of
+// replicas inserted it. The error wraps ErrNoQuorum if too many replicas failed.
+func (c *Cluster) Insert(ctx context.Context, data []byte) (bool, error) {
+	g, err := c.replicaNodes(data)
+	if err != nil {
+		return false, err
+	}
+	return g.write(ctx, data, Node.Insert)
+}
+
+// Delete data from all its replicas in parallel. Returns true once the write quorum of
+// replicas found and deleted it. The error wraps ErrNoQuorum if too many replicas failed.
+func (c *Cluster) Delete(ctx context.Context, data []byte) (bool, error) {
+	g, err := c.replicaNodes(data)
+	if err != nil {
+		return false, err
+	}
+	return g.write(ctx, data, Node.Delete)
+}
+
+// Lookup returns true if data is in the cluster, asking its replicas in parallel. Returns
+// true as soon as one replica finds it, and false once the read quorum of replicas did not.
+// The error wraps ErrNoQuorum if too many replicas failed.
+func (c *Cluster) Lookup(ctx context.Context, data []byte) (bool, error) {
+	g, err := c.replicaNodes(data)
+	if err != nil {
+		return false, err
+	}
+	return g.read(ctx, data)
+}
+
+// replicaGroup is a set of nodes holding the same keys, with quorums for operations.
+type replicaGroup struct {
+	names       []string
+	nodes       []Node
+	writeQuorum int
+	readQuorum  int
+}
+
+type nodeResult struct {
+	ok  bool
+	err error
+}
+
+// call runs op on all nodes in parallel and returns a channel receiving all results.
+func (g replicaGroup) call(ctx context.Context, data []byte, op func(Node, context.Context, []byte) (bool, error)) <-chan nodeResult {
+	results := make(chan nodeResult, len(g.nodes))
+	for i := range g.nodes {
+		go func(i int) {
+			ok, err := op(g.nodes[i], ctx, data)
+			if err != nil {
+				err = fmt.Errorf("node %s: %w", g.names[i], err)
+			}
+			results <- nodeResult{ok, err}
+		}(i)
+	}
+	return results
+}
+
+func (g replicaGroup) write(ctx context.Context, data []byte, op func(Node, context.Context, []byte) (bool, error)) (bool, error) {
+	results := g.call(ctx, data, op)
+	succeeded, answered := 0, 0
+	var lastErr error
+	for range g.nodes {
+		r := <-results
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		answered++
+		if r.ok {
+			succeeded++
+			if succeeded >= g.writeQuorum {
+				return true, nil
+			}
+		}
+	}
+	return false, g.quorumError(answered, g.writeQuorum, lastErr)
+}
+
+func (g replicaGroup) read(ctx context.Context, data []byte) (bool, error) {
+	results := g.call(ctx, data, Node.Lookup)
+	answered := 0
+	var lastErr error
+	for range g.nodes {
+		r := <-results
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		if r.ok {
+			return true, nil
+		}
+		answered++
+		if answered >= g.readQuorum {
+			return false, nil
+		}
+	}
+	return false, g.quorumError(answered, g.readQuorum, lastErr)
+}
+
+// quorumError returns nil if enough replicas answered, and an error wrapping ErrNoQuorum otherwise.
+func (g replicaGroup) quorumError(answered, quorum int, lastErr error) error {
+	if answered >= quorum {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d replicas answered, need %d, last error: %v", ErrNoQuorum, answered, len(g.nodes), quorum, lastErr)
+}
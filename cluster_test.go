@@ -0,0 +1,90 @@
+package cuckoo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// failingNode is a Node that is unavailable.
+type failingNode struct{}
+
+var errUnavailable = errors.New("unavailable")
+
+func (failingNode) Insert(context.Context, []byte) (bool, error) { return false, errUnavailable }
+func (failingNode) Lookup(context.Context, []byte) (bool, error) { return false, errUnavailable }
+func (failingNode) Delete(context.Context, []byte) (bool, error) { return false, errUnavailable }
+
+func TestCluster(t *testing.T) {
+	ctx := context.Background()
+	c := NewCluster(2)
+	if _, err := c.Lookup(ctx, []byte("one")); !errors.Is(err, ErrNoNodes) {
+		t.Errorf("Lookup() on empty cluster error = %v, want %v", err, ErrNoNodes)
+	}
+	filters := make(map[string]*Filter)
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("node%d", i)
+		filters[name] = NewFilter(1000)
+		c.AddNode(name, LocalNode(filters[name]))
+	}
+
+	for i := 0; i < 1000; i++ {
+		if ok, err := c.Insert(ctx, []byte{byte(i), byte(i >> 8)}); !ok || err != nil {
+			t.Fatalf("Insert() = %v, %v, want true, nil", ok, err)
+		}
+	}
+	var total uint
+	for name, f := range filters {
+		if f.Count() < 250 {
+			t.Errorf("node %s holds %d of 2000 replicas, want a balanced share", name, f.Count())
+		}
+		total += f.Count()
+	}
+	if total != 2000 {
+		t.Errorf("nodes hold %d replicas, want 2000", total)
+	}
+
+	// Every key survives the loss of one replica.
+	replicas := c.Replicas([]byte("one"))
+	c.Insert(ctx, []byte("one"))
+	c.AddNode(replicas[0], failingNode{})
+	if ok, err := c.Lookup(ctx, []byte("one")); !ok || err != nil {
+		t.Errorf("Lookup() with one replica down = %v, %v, want true, nil", ok, err)
+	}
+	if _, err := c.Insert(ctx, []byte("one")); !errors.Is(err, ErrNoQuorum) {
+		t.Errorf("Insert() with one replica down error = %v, want %v", err, ErrNoQuorum)
+	}
+	c.SetQuorum(1, 1)
+	if ok, err := c.Insert(ctx, []byte("one")); !ok || err != nil {
+		t.Errorf("Insert() with one replica down and write quorum 1 = %v, %v, want true, nil", ok, err)
+	}
+
+	c.RemoveNode(replicas[0])
+	if got := c.Replicas([]byte("one")); got[0] != replicas[1] {
+		t.Errorf("Replicas() after removing %s = %v, want %s first", replicas[0], got, replicas[1])
+	}
+}
+
+func TestClusterFewerNodesThanReplicas(t *testing.T) {
+	ctx := context.Background()
+	c := NewCluster(3)
+	c.AddNode("node0", LocalNode(NewFilter(100)))
+	if ok, err := c.Insert(ctx, []byte("one")); !ok || err != nil {
+		t.Errorf("Insert() on 1 of 3 replicas = %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := c.Lookup(ctx, []byte("one")); !ok || err != nil {
+		t.Errorf("Lookup() on 1 of 3 replicas = %v, %v, want true, nil", ok, err)
+	}
+	c.AddNode("node1", failingNode{})
+	if _, err := c.Insert(ctx, []byte("one")); !errors.Is(err, ErrNoQuorum) {
+		t.Errorf("Insert() on 2 of 3 replicas with one down error = %v, want %v", err, ErrNoQuorum)
+	}
+	if ok, err := c.Delete(ctx, []byte("two")); ok || !errors.Is(err, ErrNoQuorum) {
+		t.Errorf("Delete() on 2 of 3 replicas with one down = %v, %v, want false, %v", ok, err, ErrNoQuorum)
+	}
+	c.RemoveNode("node1")
+	if ok, err := c.Delete(ctx, []byte("one")); !ok || err != nil {
+		t.Errorf("Delete() on 1 of 3 replicas = %v, %v, want true, nil", ok, err)
+	}
+}
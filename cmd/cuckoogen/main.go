@@ -0,0 +1,69 @@
+// Command cuckoogen builds a cuckoo filter from a file of newline-separated keys and writes
+// it in the format of Filter.Encode, e.g. as an asset to embed with go:embed and load with
+// cuckoo.DecodeFS.
+//
+// Usage:
+//
+//	cuckoogen -in keys.txt -out keys.cuckoo [-capacity n]
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	cuckoo "github.com/chenny7/cuckoofilter"
+)
+
+func main() {
+	in := flag.String("in", "", "file with one key per line, - for stdin")
+	out := flag.String("out", "", "output file")
+	capacity := flag.Uint("capacity", 0, "capacity of the filter, defaults to the number of keys")
+	flag.Parse()
+	if *in == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	keys, err := readKeys(*in)
+	if err != nil {
+		log.Fatalf("reading keys: %v", err)
+	}
+	if *capacity == 0 {
+		*capacity = uint(len(keys))
+	}
+	cf := cuckoo.NewFilter(*capacity)
+	failed := 0
+	for _, k := range keys {
+		if !cf.Insert(k) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		log.Fatalf("inserting %d of %d keys failed, increase -capacity", failed, len(keys))
+	}
+	if err := ioutil.WriteFile(*out, cf.Encode(), 0o644); err != nil {
+		log.Fatalf("writing filter: %v", err)
+	}
+	fmt.Printf("wrote filter with %d keys to %s\n", cf.Count(), *out)
+}
+
+func readKeys(path string) ([][]byte, error) {
+	f := os.Stdin
+	if path != "-" {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			return nil, err
+		}
+		defer f.Close()
+	}
+	var keys [][]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		keys = append(keys, append([]byte{}, scanner.Bytes()...))
+	}
+	return keys, scanner.Err()
+}
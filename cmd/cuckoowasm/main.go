@@ -0,0 +1,93 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command cuckoowasm exposes cuckoo filters to JavaScript when compiled to WebAssembly, so
+// browsers can query filters shipped from the backend. It sets the global object cuckoo:
+//
+//	cuckoo.newFilter(capacity)  returns a new, empty filter
+//	cuckoo.decode(bytes)        returns the filter encoded by Filter.Encode given as a Uint8Array,
+//	                            or an Error if it cannot be decoded
+//
+// Filters have the methods insert(key), lookup(key) and delete(key), which take strings or
+// Uint8Arrays and return booleans, count() and encode(), which returns a Uint8Array.
+//
+// Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o cuckoo.wasm ./cmd/cuckoowasm
+//
+// and load it with wasm_exec.js from the Go distribution.
+package main
+
+import (
+	"syscall/js"
+
+	cuckoo "github.com/chenny7/cuckoofilter"
+)
+
+func main() {
+	js.Global().Set("cuckoo", exports())
+	// Keep the functions callable.
+	select {}
+}
+
+// exports returns the cuckoo object.
+func exports() js.Value {
+	return js.ValueOf(map[string]interface{}{
+		"newFilter": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) < 1 || args[0].Type() != js.TypeNumber || args[0].Int() < 0 {
+				return jsError("newFilter: capacity must be a non-negative number")
+			}
+			return wrap(cuckoo.NewFilter(uint(args[0].Int())))
+		}),
+		"decode": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) < 1 {
+				return jsError("decode: missing bytes")
+			}
+			cf, err := cuckoo.Decode(bytesOf(args[0]))
+			if err != nil {
+				return jsError("decode: " + err.Error())
+			}
+			return wrap(cf)
+		}),
+	})
+}
+
+// wrap returns a JavaScript object for cf.
+func wrap(cf *cuckoo.Filter) js.Value {
+	keyFunc := func(fn func([]byte) bool) js.Func {
+		return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) < 1 {
+				return false
+			}
+			return fn(bytesOf(args[0]))
+		})
+	}
+	return js.ValueOf(map[string]interface{}{
+		"insert": keyFunc(cf.Insert),
+		"lookup": keyFunc(cf.Lookup),
+		"delete": keyFunc(cf.Delete),
+		"count": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			return int(cf.Count())
+		}),
+		"encode": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			encoded := cf.Encode()
+			array := js.Global().Get("Uint8Array").New(len(encoded))
+			js.CopyBytesToJS(array, encoded)
+			return array
+		}),
+	})
+}
+
+// bytesOf returns the bytes of a string or Uint8Array.
+func bytesOf(v js.Value) []byte {
+	if v.Type() == js.TypeString {
+		return []byte(v.String())
+	}
+	b := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(b, v)
+	return b
+}
+
+func jsError(msg string) js.Value {
+	return js.Global().Get("Error").New(msg)
+}
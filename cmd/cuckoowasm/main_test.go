@@ -0,0 +1,41 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"syscall/js"
+	"testing"
+)
+
+func TestExports(t *testing.T) {
+	exported := exports()
+	cf := exported.Call("newFilter", 1000)
+	if !cf.Call("insert", "one").Bool() {
+		t.Fatalf("insert() = false")
+	}
+	key := js.Global().Get("Uint8Array").New(3)
+	js.CopyBytesToJS(key, []byte("two"))
+	cf.Call("insert", key)
+	if !cf.Call("lookup", "two").Bool() || cf.Call("lookup", "three").Bool() {
+		t.Errorf("lookup() after insert does not match")
+	}
+
+	decoded := exported.Call("decode", cf.Call("encode"))
+	if decoded.InstanceOf(js.Global().Get("Error")) {
+		t.Fatalf("decode() = %v", decoded)
+	}
+	if decoded.Call("count").Int() != 2 || !decoded.Call("lookup", "one").Bool() {
+		t.Errorf("decoded filter does not hold the inserted keys")
+	}
+	if !decoded.Call("delete", "one").Bool() || decoded.Call("count").Int() != 1 {
+		t.Errorf("delete() did not remove the key")
+	}
+
+	if got := exported.Call("decode", "garbage"); !got.InstanceOf(js.Global().Get("Error")) {
+		t.Errorf("decode(garbage) = %v, want an Error", got)
+	}
+	if got := exported.Call("newFilter", "x"); !got.InstanceOf(js.Global().Get("Error")) {
+		t.Errorf("newFilter(\"x\") = %v, want an Error", got)
+	}
+}
@@ -0,0 +1,40 @@
+package cuckoo
+
+// Compact re-places fingerprints to balance occupancy between the two candidate buckets of
+// each item. After large delete bursts, many buckets can remain full while their alternate
+// buckets are mostly empty, so later inserts into them need kickout chains and fail more
+// often. Compact moves fingerprints out of crowded buckets into their emptier alternate
+// bucket and returns the number of moved fingerprints. Slots freed by moves take stashed
+// fingerprints of the bucket, see WithOverflowStash.
+//
+// Compact holds the write lock while scanning the whole filter.
+func (cf *Filter) Compact() int {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	moved := 0
+	for i := range cf.buckets {
+		i := uint(i)
+		cf.clean(i)
+		b := &cf.buckets[i]
+		for j, fp := range b.fingerprints() {
+			if fp == nullFp {
+				continue
+			}
+			alt := cf.altIndex(fp, i)
+			cf.clean(alt)
+			// Only move if it makes the two buckets more balanced.
+			if cf.buckets[alt].free() <= b.free()+1 {
+				continue
+			}
+			cf.buckets[alt].insert(fp)
+			b.set(j, nullFp)
+			moved++
+			// The freed slot can take a stashed fingerprint.
+			cf.unstash(i)
+		}
+	}
+	// Drop the bits of moved and deleted fingerprints.
+	cf.rebuildSummary()
+	return moved
+}
@@ -0,0 +1,76 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	cf := NewFilter(1000)
+	var items [][]byte
+	for i := 0; i < 950; i++ {
+		items = append(items, []byte{byte(i), byte(i >> 8)})
+		cf.Insert(items[i])
+	}
+	// Delete most items, leaving the survivors wherever kickouts placed them.
+	for _, item := range items[:800] {
+		cf.Delete(item)
+	}
+
+	fullBefore := countFullBuckets(cf)
+	cf.Compact()
+	if got := countFullBuckets(cf); got > fullBefore {
+		t.Errorf("Compact() increased full buckets from %d to %d", fullBefore, got)
+	}
+	if got, want := cf.Count(), uint(150); got != want {
+		t.Errorf("After Compact(): Count() = %d, want %d", got, want)
+	}
+	for _, item := range items[800:] {
+		if !cf.Lookup(item) {
+			t.Errorf("After Compact(): Lookup(%v) = false, want true", item)
+		}
+	}
+}
+
+func countFullBuckets(cf *Filter) int {
+	n := 0
+	for _, b := range cf.buckets {
+		if b.free() == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCompactUnstash(t *testing.T) {
+	cf, n := fillWithStash(t)
+	// Delete the items that no stashed fingerprint could take the place of, leaving the
+	// stash in place, so that only Compact frees slots in the buckets of stashed fingerprints.
+	stashBuckets := make(map[uint]bool)
+	for _, e := range cf.stash.entries {
+		stashBuckets[e.i] = true
+		stashBuckets[cf.altIndex(e.fp, e.i)] = true
+	}
+	for k := 0; k < n; k++ {
+		item := []byte(strconv.Itoa(k))
+		i1, fp := cf.indexAndFingerprint(item)
+		if !stashBuckets[i1] && !stashBuckets[cf.altIndex(fp, i1)] {
+			cf.Delete(item)
+		}
+	}
+	stashed, count := cf.stash.len(), cf.Count()
+	if stashed != 16 {
+		t.Fatalf("stash holds %d entries after deletes, want 16", stashed)
+	}
+
+	cf.Compact()
+	if cf.stash.len() >= stashed {
+		t.Errorf("After Compact(): stash holds %d entries, want fewer than %d", cf.stash.len(), stashed)
+	}
+	if cf.Count() != count {
+		t.Errorf("After Compact(): Count() = %d, want %d", cf.Count(), count)
+	}
+	if err := cf.Validate(); err != nil {
+		t.Error(err)
+	}
+}
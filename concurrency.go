@@ -0,0 +1,57 @@
+package cuckoo
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Concurrency selects how a filter returned by NewConcurrentFilter synchronizes concurrent
+// operations. The best strategy depends on the mix of operations and the number of cores,
+// so it is chosen at construction to let callers benchmark them on their workload.
+type Concurrency int
+
+const (
+	// GlobalLock guards the whole filter with one RWMutex, like Filter. It has the lowest
+	// overhead without contention and supports concurrent lookups, but writers block
+	// everything else.
+	GlobalLock Concurrency = iota
+	// StripedLocks splits the filter into stripes with their own lock, like ShardedFilter,
+	// so that operations on different stripes do not contend.
+	StripedLocks
+	// LockFree runs lookups without taking locks, like OptimisticFilter. Writers latch the
+	// buckets they modify, and inserts needing kickouts and deletes are serialized, so it
+	// suits read-heavy workloads.
+	LockFree
+)
+
+// stripesPerCPU is the number of stripes per CPU used by StripedLocks, which keeps the chance
+// of two cores contending for the same stripe low.
+const stripesPerCPU = 4
+
+// String returns the name of the strategy.
+func (c Concurrency) String() string {
+	switch c {
+	case GlobalLock:
+		return "global-lock"
+	case StripedLocks:
+		return "striped-locks"
+	case LockFree:
+		return "lock-free"
+	}
+	return fmt.Sprintf("Concurrency(%d)", int(c))
+}
+
+// NewConcurrentFilter returns a filter suitable for the given number of elements, see
+// NewFilter, that is safe for concurrent use with strategy c. Unknown strategies return
+// ErrUnsupported.
+func NewConcurrentFilter(numElements uint, c Concurrency) (ApproxSet, error) {
+	switch c {
+	case GlobalLock:
+		return NewFilter(numElements), nil
+	case StripedLocks:
+		return NewShardedFilter(numElements, stripesPerCPU*runtime.GOMAXPROCS(0)), nil
+	case LockFree:
+		return NewOptimisticFilter(numElements), nil
+	}
+	return nil, fmt.Errorf("%w: concurrency strategy %v", ErrUnsupported, c)
+}
@@ -0,0 +1,68 @@
+package cuckoo
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestNewConcurrentFilter(t *testing.T) {
+	for _, c := range []Concurrency{GlobalLock, StripedLocks, LockFree} {
+		t.Run(c.String(), func(t *testing.T) {
+			set, err := NewConcurrentFilter(10000, c)
+			if err != nil {
+				t.Fatalf("NewConcurrentFilter() = %v", err)
+			}
+			var wg sync.WaitGroup
+			for w := 0; w < 4; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := w; i < 8000; i += 4 {
+						key := []byte(strconv.Itoa(i))
+						if !set.Insert(key) {
+							t.Errorf("Insert(%q) = false", key)
+						}
+						set.Lookup([]byte(strconv.Itoa(i / 2)))
+					}
+				}(w)
+			}
+			wg.Wait()
+			if got := set.Count(); got != 8000 {
+				t.Errorf("Count() = %d, want 8000", got)
+			}
+			for i := 0; i < 8000; i++ {
+				if !set.Lookup([]byte(strconv.Itoa(i))) {
+					t.Fatalf("Lookup(%d) = false", i)
+				}
+			}
+		})
+	}
+	if _, err := NewConcurrentFilter(100, Concurrency(-1)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("NewConcurrentFilter() with unknown strategy = %v, want %v", err, ErrUnsupported)
+	}
+}
+
+func BenchmarkConcurrentFilter(b *testing.B) {
+	for _, c := range []Concurrency{GlobalLock, StripedLocks, LockFree} {
+		b.Run(c.String(), func(b *testing.B) {
+			set, _ := NewConcurrentFilter(1<<20, c)
+			for i := 0; i < 1<<19; i++ {
+				set.Insert([]byte(strconv.Itoa(i)))
+			}
+			b.RunParallel(func(pb *testing.PB) {
+				key := make([]byte, 8)
+				for i := 0; pb.Next(); i++ {
+					key[0], key[1], key[2] = byte(i), byte(i>>8), byte(i>>16)
+					if i%10 == 0 {
+						set.Insert(key)
+						set.Delete(key)
+					} else {
+						set.Lookup(key)
+					}
+				}
+			})
+		})
+	}
+}
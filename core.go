@@ -0,0 +1,162 @@
+package cuckoo
+
+import "math/rand"
+
+// core holds the state and operations shared by Filter and UnsyncFilter. It does no
+// synchronization; Filter guards it with a lock.
+type core struct {
+	buckets []bucket
+	count   uint
+	// Bit mask set to len(buckets) - 1. As len(buckets) is always a power of 2,
+	// applying this mask mimics the operation x % len(buckets).
+	bucketIndexMask uint
+	// debug is non-nil if misuse detection is enabled, see WithMisuseDetection.
+	debug *misuseDetector
+	// log is non-nil if logging is enabled, see WithLogger.
+	log *eventLogger
+	// distinct is non-nil if distinct estimation is enabled, see WithDistinctEstimation.
+	distinct *distinctCounter
+	// journal is non-nil if operations are recorded, see WithJournal.
+	journal *opJournal
+	// rate is non-nil if rate tracking is enabled, see WithRateTracking.
+	rate *rateTracker
+	// stash is non-nil if the overflow stash is enabled, see WithOverflowStash.
+	stash *overflowStash
+	// saturation is the behavior of inserts into a full filter, see WithSaturationPolicy.
+	saturation SaturationPolicy
+	// evictions is the number of items evicted by the saturation policy, see Evictions.
+	evictions uint64
+	// kickouts is the number of fingerprints kicked out by inserts, which sampled inserts
+	// report, see WithSampledMetrics.
+	kickouts uint64
+	// audit is non-nil if operations are audited, see WithAuditSink.
+	audit *auditor
+	// metrics is non-nil if operations are sampled, see WithSampledMetrics. It is only set at
+	// construction, so it can be read without the lock.
+	metrics *opSampler
+	// maxCount is the number of items inserts stop at, or 0 for no limit, see
+	// NewFilterWithMemoryBudget.
+	maxCount uint
+	// rng is the source of randomness of kickouts, or nil for the global one, see
+	// WithDeterministicPlacement.
+	rng *rand.Rand
+	// generations holds the generation each bucket was last written in. Buckets from an
+	// older generation are logically empty, see ResetFast. Nil until ResetFast is first called.
+	generations []uint8
+	generation  uint8
+	// summary holds one byte per bucket with the bits of its fingerprints, or is nil, see
+	// WithLookupSummary.
+	summary []uint8
+	// transform normalizes keys before hashing, or is nil, see WithKeyTransform.
+	transform func([]byte) []byte
+	// altScheme derives alternate buckets, see WithAltIndexScheme.
+	altScheme AltIndexScheme
+	// seed is the seed keys are hashed with, see WithHashSeed.
+	seed uint64
+	// hashVersion and formatVersion are the versions of hashing and of the encoding the
+	// filter was decoded from, see HashVersion and FormatVersion.
+	hashVersion   uint8
+	formatVersion uint8
+}
+
+// newCore returns a core using the given buckets, which must be empty, with the default
+// configuration.
+func newCore(buckets []bucket) core {
+	return core{
+		buckets:         buckets,
+		bucketIndexMask: uint(len(buckets) - 1),
+		seed:            defaultHashSeed,
+		hashVersion:     CurrentHashVersion,
+		formatVersion:   CurrentFormatVersion,
+	}
+}
+
+// Cap returns the number of slots of the filter.
+func (cf *core) Cap() int {
+	return len(cf.buckets) * bucketSize
+}
+
+// lookup returns true if fp is in one of the candidate buckets of i1.
+func (cf *core) lookup(fp fingerprint, i1 uint) bool {
+	if cf.contains(fp, i1) {
+		return true
+	}
+	i2 := cf.altIndex(fp, i1)
+	return cf.contains(fp, i2) || cf.stash.find(fp, i1, i2) >= 0
+}
+
+// insertKey inserts the fingerprint fp of data and records the insert with the enabled hooks.
+// With EvictWhenFull, inserts into a full filter evict an older item rather than fail.
+func (cf *core) insertKey(data []byte, fp fingerprint, i1 uint) bool {
+	if cf.atLimit() {
+		cf.log.insertFailed(cf.count, cf.Cap())
+		cf.auditKey(AuditInsert, data, false)
+		return false
+	}
+	switch cf.saturation {
+	case EvictWhenFull:
+		if !cf.kickInto(fp, i1) {
+			cf.evictions++
+			if !cf.lookup(fp, i1) {
+				// The chain came back to fp and dropped it; evict a random item of i1 instead.
+				cf.buckets[i1].set(cf.intn(bucketSize), fp)
+				cf.summarize(i1, fp)
+			}
+		}
+	case EvictRandomWhenFull:
+		if !cf.insert(fp, i1) {
+			i2 := cf.altIndex(fp, i1)
+			if !cf.insert(fp, i2) {
+				i := cf.randi(i1, i2)
+				cf.buckets[i].set(cf.intn(bucketSize), fp)
+				cf.summarize(i, fp)
+				cf.evictions++
+			}
+		}
+	default:
+		if !cf.insertFingerprint(fp, i1) {
+			cf.auditKey(AuditInsert, data, false)
+			return false
+		}
+	}
+	cf.debug.recordInsert(data)
+	cf.distinct.addKey(data)
+	cf.journal.recordInsert(fp, i1, cf.debug.retain(data))
+	cf.auditKey(AuditInsert, data, true)
+	cf.checkInvariants()
+	return true
+}
+
+// deleteKey deletes the fingerprint fp of data and records the delete with the enabled hooks.
+func (cf *core) deleteKey(data []byte, fp fingerprint, i1 uint) bool {
+	cf.debug.checkDelete(data)
+	i2 := cf.altIndex(fp, i1)
+	if cf.delete(fp, i1) || cf.delete(fp, i2) || cf.deleteStashed(fp, i1, i2) {
+		cf.journal.recordDelete(fp, i1, cf.debug.retain(data))
+		cf.auditKey(AuditDelete, data, true)
+		cf.checkInvariants()
+		return true
+	}
+	cf.auditKey(AuditDelete, data, false)
+	return false
+}
+
+func (cf *core) loadFactor() float64 {
+	return float64(cf.count) / float64(len(cf.buckets)*bucketSize)
+}
+
+// intn returns a random number in [0, n).
+func (cf *core) intn(n int) int {
+	if cf.rng == nil {
+		return rand.Intn(n)
+	}
+	return cf.rng.Intn(n)
+}
+
+// randi returns either i1 or i2 randomly.
+func (cf *core) randi(i1, i2 uint) uint {
+	if cf.intn(2) == 0 {
+		return i1
+	}
+	return i2
+}
@@ -0,0 +1,201 @@
+package cuckoo
+
+import (
+	"math/rand"
+	"sync"
+)
+
+const (
+	countingCounterBits = 4
+	countingCounterMask = 1<<countingCounterBits - 1
+	// CountingMaxCount is the count at which counters of a CountingFilter saturate.
+	CountingMaxCount = countingCounterMask
+)
+
+// CountingFilter is a filter counting how often each item was inserted, e.g. to pre-filter
+// heavy hitters before tracking them exactly. Every fingerprint is stored once with a 4-bit
+// counter, using 20 bits per item. Counters saturate at CountingMaxCount: further inserts do
+// not change them, and deletes do not change saturated counters either, as their true count
+// is unknown. Items whose fingerprints collide share a counter. It is safe for concurrent use.
+type CountingFilter struct {
+	buckets []bucket
+	// counters holds the counters of the four slots of every bucket.
+	counters []uint16
+	count    uint
+	// Bit mask set to len(buckets) - 1, which is always a power of 2.
+	bucketIndexMask uint
+	lock            sync.RWMutex
+}
+
+var _ ApproxSet = (*CountingFilter)(nil)
+
+// NewCountingFilter returns a counting filter suitable for the given number of distinct
+// elements.
+func NewCountingFilter(numElements uint) *CountingFilter {
+	numBuckets := numBucketsFor(numElements)
+	return &CountingFilter{
+		buckets:         make([]bucket, numBuckets),
+		counters:        make([]uint16, numBuckets),
+		bucketIndexMask: numBuckets - 1,
+	}
+}
+
+func (cf *CountingFilter) counter(i uint, j int) uint16 {
+	return cf.counters[i] >> (countingCounterBits * j) & countingCounterMask
+}
+
+func (cf *CountingFilter) setCounter(i uint, j int, c uint16) {
+	shift := countingCounterBits * j
+	cf.counters[i] = cf.counters[i]&^(countingCounterMask<<shift) | c<<shift
+}
+
+// find returns the slot of bucket i holding fp, or -1.
+func (cf *CountingFilter) find(fp fingerprint, i uint) int {
+	for j := 0; j < bucketSize; j++ {
+		if cf.buckets[i].get(j) == fp {
+			return j
+		}
+	}
+	return -1
+}
+
+// EstimateCount returns how often data was inserted, less how often it was deleted, or more if
+// its fingerprint collides with that of other items. The result is at most CountingMaxCount.
+func (cf *CountingFilter) EstimateCount(data []byte) uint {
+	i1, fp := getIndexAndFingerprint(data, cf.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, cf.bucketIndexMask)
+
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	for _, i := range [2]uint{i1, i2} {
+		if j := cf.find(fp, i); j >= 0 {
+			return uint(cf.counter(i, j))
+		}
+	}
+	return 0
+}
+
+// Lookup returns true if data is in the filter.
+func (cf *CountingFilter) Lookup(data []byte) bool {
+	return cf.EstimateCount(data) > 0
+}
+
+// Insert data into the filter, incrementing its count if it is present. Returns false if
+// insertion failed, see Filter.Insert.
+func (cf *CountingFilter) Insert(data []byte) bool {
+	i1, fp := getIndexAndFingerprint(data, cf.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, cf.bucketIndexMask)
+
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	for _, i := range [2]uint{i1, i2} {
+		if j := cf.find(fp, i); j >= 0 {
+			if c := cf.counter(i, j); c < CountingMaxCount {
+				cf.setCounter(i, j, c+1)
+			}
+			return true
+		}
+	}
+	if cf.insert(fp, 1, i1) || cf.insert(fp, 1, i2) {
+		return true
+	}
+	i := randi(i1, i2)
+	c := uint16(1)
+	for k := 0; k < maxCuckooKickouts; k++ {
+		j := rand.Intn(bucketSize)
+		oldCounter := cf.counter(i, j)
+		fp = cf.buckets[i].swap(j, fp)
+		cf.setCounter(i, j, c)
+		c = oldCounter
+
+		i = getAltIndex(fp, i, cf.bucketIndexMask)
+		if cf.insert(fp, c, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// insert puts fp with counter c into a free slot of bucket i.
+func (cf *CountingFilter) insert(fp fingerprint, c uint16, i uint) bool {
+	for j := 0; j < bucketSize; j++ {
+		if cf.buckets[i].get(j) == nullFp {
+			cf.buckets[i].set(j, fp)
+			cf.setCounter(i, j, c)
+			cf.count++
+			return true
+		}
+	}
+	return false
+}
+
+// Delete decrements the count of data, removing it when the count reaches 0. Returns true if
+// the data was found.
+func (cf *CountingFilter) Delete(data []byte) bool {
+	i1, fp := getIndexAndFingerprint(data, cf.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, cf.bucketIndexMask)
+
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	for _, i := range [2]uint{i1, i2} {
+		if j := cf.find(fp, i); j >= 0 {
+			switch c := cf.counter(i, j); c {
+			case CountingMaxCount:
+			case 1:
+				cf.buckets[i].set(j, nullFp)
+				cf.setCounter(i, j, 0)
+				cf.count--
+			default:
+				cf.setCounter(i, j, c-1)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Decay halves all counts, rounding down, and removes items whose count drops to 0, so that
+// estimates reflect recent inserts only. Call it periodically, e.g. using a MaintenanceTask,
+// to detect recently frequent items. Saturated counters are halved as well.
+func (cf *CountingFilter) Decay() {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	for i := range cf.buckets {
+		i := uint(i)
+		for j := 0; j < bucketSize; j++ {
+			if cf.buckets[i].get(j) == nullFp {
+				continue
+			}
+			c := cf.counter(i, j) / 2
+			cf.setCounter(i, j, c)
+			if c == 0 {
+				cf.buckets[i].set(j, nullFp)
+				cf.count--
+			}
+		}
+	}
+}
+
+// Count returns the number of distinct fingerprints in the filter.
+func (cf *CountingFilter) Count() uint {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	return cf.count
+}
+
+// Reset removes all items from the filter, setting count to 0.
+func (cf *CountingFilter) Reset() {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	for i := range cf.buckets {
+		cf.buckets[i] = 0
+		cf.counters[i] = 0
+	}
+	cf.count = 0
+}
@@ -0,0 +1,96 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCountingFilter(t *testing.T) {
+	cf := NewCountingFilter(1000)
+	for i := 0; i < 500; i++ {
+		for k := 0; k <= i%5; k++ {
+			if !cf.Insert([]byte(strconv.Itoa(i))) {
+				t.Fatalf("Insert(%d) = false", i)
+			}
+		}
+	}
+	if cf.Count() > 500 || cf.Count() < 490 {
+		t.Errorf("Count() = %d, want about 500 distinct fingerprints", cf.Count())
+	}
+	for i := 0; i < 500; i++ {
+		if got := cf.EstimateCount([]byte(strconv.Itoa(i))); got < uint(i%5+1) {
+			t.Fatalf("EstimateCount(%d) = %d, want at least %d", i, got, i%5+1)
+		}
+	}
+
+	key := []byte("key")
+	cf.Insert(key)
+	cf.Insert(key)
+	if !cf.Delete(key) || cf.EstimateCount(key) != 1 || !cf.Lookup(key) {
+		t.Errorf("EstimateCount() after Delete() = %d, want 1", cf.EstimateCount(key))
+	}
+	if !cf.Delete(key) || cf.Lookup(key) || cf.Delete(key) {
+		t.Errorf("Lookup() after deleting all copies = true, want false")
+	}
+
+	for k := 0; k < 100; k++ {
+		cf.Insert(key)
+	}
+	cf.Delete(key)
+	if got := cf.EstimateCount(key); got != CountingMaxCount {
+		t.Errorf("EstimateCount() of a saturated counter = %d, want %d", got, CountingMaxCount)
+	}
+
+	cf.Reset()
+	if cf.Count() != 0 || cf.Lookup(key) {
+		t.Errorf("Reset() left %d items", cf.Count())
+	}
+}
+
+func TestCountingFilter_KickoutsKeepCounters(t *testing.T) {
+	cf := NewCountingFilter(1 << 10)
+	n := cf.bucketIndexMask + 1
+	// Fill the filter to 90%, so that inserts move fingerprints with their counters.
+	for i := uint(0); i < n*bucketSize*9/10; i++ {
+		key := []byte(strconv.Itoa(int(i)))
+		cf.Insert(key)
+		cf.Insert(key)
+	}
+	for i := uint(0); i < n*bucketSize*9/10; i++ {
+		if got := cf.EstimateCount([]byte(strconv.Itoa(int(i)))); got < 2 {
+			t.Fatalf("EstimateCount(%d) = %d, want at least 2", i, got)
+		}
+	}
+}
+
+func TestCountingFilter_Decay(t *testing.T) {
+	cf := NewCountingFilter(1000)
+	hot, warm, cold := []byte("hot"), []byte("warm"), []byte("cold")
+	for k := 0; k < 100; k++ {
+		cf.Insert(hot)
+	}
+	for k := 0; k < 3; k++ {
+		cf.Insert(warm)
+	}
+	cf.Insert(cold)
+
+	cf.Decay()
+	for key, want := range map[string]uint{"hot": CountingMaxCount / 2, "warm": 1, "cold": 0} {
+		if got := cf.EstimateCount([]byte(key)); got != want {
+			t.Errorf("EstimateCount(%s) after Decay() = %d, want %d", key, got, want)
+		}
+	}
+	if cf.Count() != 2 {
+		t.Errorf("Count() after Decay() = %d, want 2", cf.Count())
+	}
+	// Halved saturated counters count again.
+	cf.Insert(hot)
+	if got := cf.EstimateCount(hot); got != CountingMaxCount/2+1 {
+		t.Errorf("EstimateCount(hot) = %d, want %d", got, CountingMaxCount/2+1)
+	}
+	cf.Decay()
+	cf.Decay()
+	if got := cf.EstimateCount(warm); got != 0 || cf.Lookup(cold) {
+		t.Errorf("EstimateCount(warm) after Decay() = %d, want 0", got)
+	}
+}
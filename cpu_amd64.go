@@ -0,0 +1,30 @@
+//go:build amd64 && !gccgo
+// +build amd64,!gccgo
+
+package cuckoo
+
+// cpuid and xgetbv are implemented in cpu_amd64.s.
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+func xgetbv() (eax, edx uint32)
+
+func detectCPUFeatures() []string {
+	var features []string
+	maxLeaf, _, _, _ := cpuid(0, 0)
+	if maxLeaf < 1 {
+		return features
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	if ecx1&(1<<19) != 0 {
+		features = append(features, "sse4.1")
+	}
+	// AVX2 needs support by the OS for saving the YMM registers.
+	osxsave, avx := ecx1&(1<<27) != 0, ecx1&(1<<28) != 0
+	if maxLeaf >= 7 && osxsave && avx {
+		if xcr0, _ := xgetbv(); xcr0&0x6 == 0x6 {
+			if _, ebx7, _, _ := cpuid(7, 0); ebx7&(1<<5) != 0 {
+				features = append(features, "avx2")
+			}
+		}
+	}
+	return features
+}
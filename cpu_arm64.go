@@ -0,0 +1,6 @@
+package cuckoo
+
+// detectCPUFeatures returns neon, which every arm64 CPU supports.
+func detectCPUFeatures() []string {
+	return []string{"neon"}
+}
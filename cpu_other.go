@@ -0,0 +1,8 @@
+//go:build (!amd64 && !arm64) || (amd64 && gccgo)
+// +build !amd64,!arm64 amd64,gccgo
+
+package cuckoo
+
+func detectCPUFeatures() []string {
+	return nil
+}
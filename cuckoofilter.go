@@ -3,7 +3,7 @@ package cuckoo
 import (
 	"encoding/binary"
 	"fmt"
-	"math/rand"
+	"math"
 	"sync"
 )
 
@@ -11,81 +11,220 @@ import (
 // is attempted.
 const maxCuckooKickouts = 500
 
-// Filter is a probabilistic counter.
+// Filter is a probabilistic counter. It is safe for concurrent use, see UnsyncFilter for a
+// variant without synchronization.
 type Filter struct {
-	buckets []bucket
-	count   uint
-	// Bit mask set to len(buckets) - 1. As len(buckets) is always a power of 2,
-	// applying this mask mimics the operation x % len(buckets).
-	bucketIndexMask uint
-	lock            sync.RWMutex
+	core
+	lock sync.RWMutex
 }
 
 // NewFilter returns a new cuckoofilter suitable for the given number of elements.
 // When inserting more elements, insertion speed will drop significantly and insertions might fail altogether.
 // A capacity of 1000000 is a normal default, which allocates
 // about ~2MB on 64-bit machines.
-func NewFilter(numElements uint) *Filter {
-	numBuckets := getNextPow2(uint64(numElements / bucketSize))
-	if float64(numElements)/float64(numBuckets*bucketSize) > 0.96 {
+//
+// NewFilter panics with an error wrapping ErrTooLarge if the buckets for numElements do not fit
+// into the address space, which happens for billions of elements on 32-bit platforms; see
+// NewFilter64 for an error instead.
+func NewFilter(numElements uint, opts ...Option) *Filter {
+	cf := newFilter(make([]bucket, numBucketsFor(numElements)))
+	for _, opt := range opts {
+		opt(cf)
+	}
+	return cf
+}
+
+// NewFilter64 is like NewFilter, but takes the number of elements as a uint64 and returns an
+// error wrapping ErrTooLarge if the filter cannot be represented on the platform, rather than
+// panicking. Filters of up to 1<<29 slots are supported on 32-bit platforms.
+func NewFilter64(numElements uint64, opts ...Option) (*Filter, error) {
+	numBuckets, err := bucketsFor(numElements)
+	if err != nil {
+		return nil, err
+	}
+	cf := newFilter(make([]bucket, numBuckets))
+	for _, opt := range opts {
+		opt(cf)
+	}
+	return cf, nil
+}
+
+// newFilter returns a filter using the given buckets, which must be empty, with the default
+// configuration.
+func newFilter(buckets []bucket) *Filter {
+	return &Filter{core: newCore(buckets)}
+}
+
+// numBucketsFor returns the number of buckets of a filter for numElements elements. It panics
+// if they exceed maxBuckets.
+func numBucketsFor(numElements uint) uint {
+	numBuckets, err := bucketsFor(uint64(numElements))
+	if err != nil {
+		panic(err)
+	}
+	return numBuckets
+}
+
+// bucketsFor returns the number of buckets of a filter for numElements elements, or an error
+// wrapping ErrTooLarge if they exceed maxBuckets. It computes in 64 bits on all platforms.
+func bucketsFor(numElements uint64) (uint, error) {
+	if numElements/bucketSize > maxBuckets {
+		return 0, fmt.Errorf("%w: %d elements exceed %d buckets", ErrTooLarge, numElements, uint64(maxBuckets))
+	}
+	numBuckets := nextPow2(numElements / bucketSize)
+	if float64(numElements)/(float64(numBuckets)*bucketSize) > 0.96 {
 		numBuckets <<= 1
 	}
 	if numBuckets == 0 {
 		numBuckets = 1
 	}
-	buckets := make([]bucket, numBuckets)
-	return &Filter{
-		buckets:         buckets,
-		count:           0,
-		bucketIndexMask: uint(len(buckets) - 1),
-		lock:            sync.RWMutex{},
+	if numBuckets > maxBuckets {
+		return 0, fmt.Errorf("%w: %d elements exceed %d buckets", ErrTooLarge, numElements, uint64(maxBuckets))
 	}
+	return uint(numBuckets), nil
 }
 
 // Lookup returns true if data is in the filter.
 func (cf *Filter) Lookup(data []byte) bool {
-	i1, fp := getIndexAndFingerprint(data, cf.bucketIndexMask)
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+	if cf.metrics.sample() {
+		return cf.lookupSampled(fp, i1)
+	}
 
 	cf.lock.RLock()
 	defer cf.lock.RUnlock()
 
-	if b := cf.buckets[i1]; b.contains(fp) {
-		return true
+	return cf.lookup(fp, i1)
+}
+
+// contains returns true if bucket i holds fp. The caller must hold at least the read lock.
+func (cf *core) contains(fp fingerprint, i uint) bool {
+	if cf.stale(i) || !cf.mayContain(fp, i) {
+		return false
 	}
+	return cf.buckets[i].contains(fp)
+}
 
-	i2 := getAltIndex(fp, i1, cf.bucketIndexMask)
-	b := cf.buckets[i2]
-	return b.contains(fp)
+// stale returns true if bucket i was written before the last ResetFast and is therefore
+// logically empty.
+func (cf *core) stale(i uint) bool {
+	return cf.generations != nil && cf.generations[i] != cf.generation
+}
+
+// clean lazily empties bucket i if it is stale. The caller must hold the write lock.
+func (cf *core) clean(i uint) {
+	if cf.stale(i) {
+		cf.buckets[i].reset()
+		cf.generations[i] = cf.generation
+	}
 }
 
 // Reset removes all items from the filter, setting count to 0.
+//
+// Reset is safe to call concurrently with other methods. It waits until in-flight operations,
+// including the kickout chains of concurrent inserts, have completed and blocks new ones
+// until the filter is cleared. Every operation is therefore either fully applied before the
+// reset (and cleared by it) or fully applied after it; no fingerprint moved by a kickout
+// chain that started before Reset can survive it.
 func (cf *Filter) Reset() {
 	cf.lock.Lock()
 	defer cf.lock.Unlock()
 
+	cf.reset()
+}
+
+// ResetFast removes all items from the filter like Reset, but in constant time.
+// Buckets are tagged with a generation and emptied lazily when they are next written.
+// The first call allocates one byte of bookkeeping per bucket, and every 255th call
+// falls back to a full Reset. ResetFast has the same concurrency semantics as Reset.
+func (cf *Filter) ResetFast() {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	if cf.generations == nil {
+		cf.generations = make([]uint8, len(cf.buckets))
+	}
+	if cf.generation == math.MaxUint8 {
+		// Generations would wrap around and resurrect old buckets.
+		cf.reset()
+		return
+	}
+	cf.generation++
+	cf.count = 0
+	cf.stash.reset()
+	if cf.rng != nil {
+		cf.rng.Seed(deterministicSeed)
+	}
+	cf.debug.reset()
+	cf.log.reset()
+	cf.distinct.reset()
+	cf.rate.reset()
+	cf.journal.reset()
+	cf.audit.record(AuditReset, 0, true)
+}
+
+// ResetWithCapacity removes all items from the filter and resizes it for the given number of
+// elements, see NewFilter. Like Reset, it is a single operation: concurrent operations observe
+// either the old filter or the new empty one.
+func (cf *Filter) ResetWithCapacity(numElements uint) {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	cf.buckets = make([]bucket, numBucketsFor(numElements))
+	cf.bucketIndexMask = uint(len(cf.buckets) - 1)
+	cf.generations = nil
+	cf.reset()
+	cf.rebuildSummary()
+}
+
+func (cf *core) reset() {
 	for i := range cf.buckets {
 		cf.buckets[i].reset()
 	}
+	for i := range cf.generations {
+		cf.generations[i] = 0
+	}
+	for i := range cf.summary {
+		cf.summary[i] = 0
+	}
+	cf.generation = 0
 	cf.count = 0
+	cf.stash.reset()
+	if cf.rng != nil {
+		cf.rng.Seed(deterministicSeed)
+	}
+	cf.debug.reset()
+	cf.log.reset()
+	cf.distinct.reset()
+	cf.rate.reset()
+	cf.journal.reset()
+	cf.audit.record(AuditReset, 0, true)
 }
 
-// return the (result of Lookup, result of Insert)
+// LookupAndInsert returns the (result of Lookup, result of Insert).
+// It is equivalent to ContainsOrAdd.
 func (cf *Filter) LookupAndInsert(data []byte) (bool, bool) {
-	i1, fp := getIndexAndFingerprint(data, cf.bucketIndexMask)
-	i2 := getAltIndex(fp, i1, cf.bucketIndexMask)
+	return cf.ContainsOrAdd(data)
+}
+
+// ContainsOrAdd inserts data unless it is already in the filter, as a single atomic operation.
+// wasPresent reports whether data was found; added reports whether it was inserted.
+// If wasPresent is true, nothing is inserted.
+func (cf *Filter) ContainsOrAdd(data []byte) (wasPresent bool, added bool) {
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+	i2 := cf.altIndex(fp, i1)
 
 	cf.lock.Lock()
 	defer cf.lock.Unlock()
 
-	if cf.buckets[i1].contains(fp) || cf.buckets[i2].contains(fp) {
+	if cf.contains(fp, i1) || cf.contains(fp, i2) {
 		return true, false
 	}
 
-	if cf.insert(fp, i1) || cf.insert(fp, i2) {
-		return false, true
-	}
-
-	return false, cf.reinsert(fp, randi(i1, i2))
+	ok := cf.insertKey(data, fp, i1)
+	return false, ok
 }
 
 // Insert data into the filter. Returns false if insertion failed. In the resulting state, the filter
@@ -93,63 +232,164 @@ func (cf *Filter) LookupAndInsert(data []byte) (bool, bool) {
 // * Deletes are not guaranteed to work
 // To increase success rate of inserts, create a larger filter.
 func (cf *Filter) Insert(data []byte) bool {
-	i1, fp := getIndexAndFingerprint(data, cf.bucketIndexMask)
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+	if cf.metrics.sample() {
+		return cf.insertSampled(data, fp, i1)
+	}
 
 	cf.lock.Lock()
 	defer cf.lock.Unlock()
 
+	return cf.insertKey(data, fp, i1)
+}
+
+// InsertResult is the outcome of InsertStatus.
+type InsertResult int
+
+const (
+	// InsertFailed means the filter is full, see Insert.
+	InsertFailed InsertResult = iota
+	// InsertedNew means data was inserted and its fingerprint was not stored before.
+	InsertedNew
+	// ProbablyDuplicate means data was inserted, but its fingerprint was already stored in one
+	// of its buckets: data was most likely inserted before, or is a false positive.
+	ProbablyDuplicate
+)
+
+// InsertStatus inserts data into the filter like Insert and reports whether data was already
+// present, so callers deduplicating items can skip work without a separate Lookup.
+// Unlike ContainsOrAdd, it inserts duplicates as well, so they can be deleted as often as
+// they were inserted.
+func (cf *Filter) InsertStatus(data []byte) InsertResult {
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+	i2 := cf.altIndex(fp, i1)
+
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	duplicate := cf.contains(fp, i1) || cf.contains(fp, i2)
+	if !cf.insertKey(data, fp, i1) {
+		return InsertFailed
+	}
+	if duplicate {
+		return ProbablyDuplicate
+	}
+	return InsertedNew
+}
+
+// insertHash inserts the item with the given hashKey. It is used by structures that move
+// items between filters of different sizes, where fingerprints alone are not sufficient.
+// cf must use the default hash seed.
+func (cf *Filter) insertHash(hash uint64) bool {
+	i1, fp := getIndexAndFingerprintFromHash(hash, cf.bucketIndexMask)
+
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	return cf.insertFingerprint(fp, i1)
+}
+
+// insertFingerprint places fp into one of its candidate buckets, kicking out other
+// fingerprints if necessary. The caller must hold the write lock.
+func (cf *core) insertFingerprint(fp fingerprint, i1 uint) bool {
+	if !cf.atLimit() && cf.kickInto(fp, i1) {
+		return true
+	}
+	cf.log.insertFailed(cf.count, cf.Cap())
+	return false
+}
+
+// kickInto implements insertFingerprint. It returns false if the kickout chain ended
+// without finding a bucket for the last fingerprint kicked out, which is then dropped.
+func (cf *core) kickInto(fp fingerprint, i1 uint) bool {
 	if cf.insert(fp, i1) {
 		return true
 	}
-	i2 := getAltIndex(fp, i1, cf.bucketIndexMask)
+	i2 := cf.altIndex(fp, i1)
 	if cf.insert(fp, i2) {
 		return true
 	}
-	return cf.reinsert(fp, randi(i1, i2))
+	homeless, i, ok := cf.reinsert(fp, cf.randi(i1, i2))
+	return ok || cf.stashFingerprint(homeless, i)
 }
 
-func (cf *Filter) insert(fp fingerprint, i uint) bool {
+func (cf *core) insert(fp fingerprint, i uint) bool {
+	cf.clean(i)
 	if cf.buckets[i].insert(fp) {
+		cf.summarize(i, fp)
 		cf.count++
+		cf.log.checkLoad(cf.count, cf.Cap())
+		cf.rate.observe(cf.count)
 		return true
 	}
 	return false
 }
 
-func (cf *Filter) reinsert(fp fingerprint, i uint) bool {
+// reinsert places fp into bucket i, kicking out other fingerprints. If the chain ends without
+// finding a slot or runs into a cycle, it returns false and the last fingerprint kicked out
+// with its bucket.
+func (cf *core) reinsert(fp fingerprint, i uint) (fingerprint, uint, bool) {
+	var cycle kickoutCycle
 	for k := 0; k < maxCuckooKickouts; k++ {
-		j := rand.Intn(bucketSize)
+		j := cf.intn(bucketSize)
+		if k >= cycleCheckAfter && cycle.closed(cf, i, j) {
+			break
+		}
 		// Swap fingerprint with bucket entry.
-		cf.buckets[i][j], fp = fp, cf.buckets[i][j]
+		cf.summarize(i, fp)
+		fp = cf.buckets[i].swap(j, fp)
+		cf.kickouts++
 
 		// Move kicked out fingerprint to alternate location.
-		i = getAltIndex(fp, i, cf.bucketIndexMask)
+		i = cf.altIndex(fp, i)
 		if cf.insert(fp, i) {
-			return true
+			return 0, 0, true
 		}
 	}
-	return false
+	return fp, i, false
 }
 
 // Delete data from the filter. Returns true if the data was found and deleted.
 func (cf *Filter) Delete(data []byte) bool {
-	i1, fp := getIndexAndFingerprint(data, cf.bucketIndexMask)
-	i2 := getAltIndex(fp, i1, cf.bucketIndexMask)
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+	if cf.metrics.sample() {
+		return cf.deleteSampled(data, fp, i1)
+	}
 
 	cf.lock.Lock()
 	defer cf.lock.Unlock()
 
-	return cf.delete(fp, i1) || cf.delete(fp, i2)
+	return cf.deleteKey(data, fp, i1)
 }
 
-func (cf *Filter) delete(fp fingerprint, i uint) bool {
+func (cf *core) delete(fp fingerprint, i uint) bool {
+	cf.clean(i)
 	if cf.buckets[i].delete(fp) {
-		cf.count--
+		// Never wrap around, even if count is off because of external modification.
+		if cf.count > 0 {
+			cf.count--
+		}
+		cf.unstash(i)
 		return true
 	}
 	return false
 }
 
+// deleteStashed deletes fp with candidate buckets i1 and i2 from the stash. The caller must
+// hold the write lock.
+func (cf *core) deleteStashed(fp fingerprint, i1, i2 uint) bool {
+	k := cf.stash.find(fp, i1, i2)
+	if k < 0 {
+		return false
+	}
+	cf.stash.remove(k)
+	cf.count--
+	return true
+}
+
 // Count returns the number of items in the filter.
 func (cf *Filter) Count() uint {
 	cf.lock.RLock()
@@ -163,49 +403,116 @@ func (cf *Filter) LoadFactor() float64 {
 	cf.lock.RLock()
 	defer cf.lock.RUnlock()
 
-	return float64(cf.count) / float64(len(cf.buckets)*bucketSize)
+	return cf.loadFactor()
 }
 
-func (cf *Filter) Cap() int {
-	return len(cf.buckets) * bucketSize
+// Encode returns a byte slice representing a Cuckoofilter. It starts with a header recording
+// the format version, how keys are hashed and the number of items, followed by the buckets.
+func (cf *Filter) Encode() []byte {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	return cf.encode()
 }
 
-// Encode returns a byte slice representing a Cuckoofilter.
-func (cf *Filter) Encode() []byte {
-	//cf.lock.RLock()
-	//defer cf.lock.RUnlock()
-	bytes := make([]byte, 0, len(cf.buckets)*bucketSize*fingerprintSizeBits/8)
-	for _, b := range cf.buckets {
-		for _, f := range b {
-			next := make([]byte, 2)
-			binary.LittleEndian.PutUint16(next, uint16(f))
-			bytes = append(bytes, next...)
+// encode implements Encode. The caller must hold at least the read lock.
+func (cf *core) encode() []byte {
+	bytes := make([]byte, 0, encodingHeaderSize+len(cf.buckets)*bucketSize*fingerprintSizeBits/8)
+	bytes = cf.appendHeader(bytes)
+	bytes = cf.appendBuckets(bytes, 0, len(cf.buckets))
+	if cf.stash.len() > 0 {
+		bytes[4] = stashFormatVersion
+		bytes = cf.appendStash(bytes)
+	}
+	return bytes
+}
+
+// appendBuckets appends the encoding of buckets [from, to) to bytes.
+// The caller must hold at least the read lock.
+func (cf *core) appendBuckets(bytes []byte, from, to int) []byte {
+	for i := from; i < to; i++ {
+		b := cf.buckets[i]
+		if cf.stale(uint(i)) {
+			b = 0
 		}
+		// Slots are stored in order as little-endian uint16.
+		var next [8]byte
+		binary.LittleEndian.PutUint64(next[:], uint64(b))
+		bytes = append(bytes, next[:]...)
 	}
 	return bytes
 }
 
 // Decode returns a Cuckoofilter from a byte slice created using Encode.
-func Decode(bytes []byte) (*Filter, error) {
-	var count uint
-	if len(bytes)%bucketSize != 0 {
-		return nil, fmt.Errorf("expected bytes to be multiple of %d, got %d", bucketSize, len(bytes))
-	}
-	buckets := make([]bucket, len(bytes)/4*8/fingerprintSizeBits)
-	for i, b := range buckets {
-		for j := range b {
-			var next []byte
-			next, bytes = bytes[0:2], bytes[2:]
-
-			if fp := fingerprint(binary.LittleEndian.Uint16(next)); fp != 0 {
-				buckets[i][j] = fp
-				count++
-			}
+// The options are applied to the decoded filter. The hash seed is restored from the encoding,
+// except for encodings of older versions without header, which use the default seed unless
+// WithHashSeed is given.
+func Decode(bytes []byte, opts ...Option) (_ *Filter, err error) {
+	defer recoverInvalidState(&err)
+	cf := newFilter(nil)
+	// Encodings without header have the format of version 0 and the hashing of version 1.
+	cf.formatVersion = 0
+	cf.hashVersion = 1
+	for _, opt := range opts {
+		opt(cf)
+	}
+	var h encodingHeader
+	if hasEncodingHeader(bytes) {
+		if h, err = cf.decodeHeader(bytes); err != nil {
+			cf.log.decodeFailed(err)
+			return nil, err
+		}
+		bytes = bytes[h.size:]
+	}
+	var stashed []byte
+	if h.hasStash {
+		if bytes, stashed, err = splitStash(bytes); err != nil {
+			cf.log.decodeFailed(err)
+			return nil, err
 		}
 	}
-	return &Filter{
-		buckets:         buckets,
-		count:           count,
-		bucketIndexMask: uint(len(buckets) - 1),
-	}, nil
+	if err := cf.decodeBuckets(bytes); err != nil {
+		return nil, err
+	}
+	if h.hasStash {
+		if err := cf.decodeStash(stashed); err != nil {
+			cf.log.decodeFailed(err)
+			return nil, err
+		}
+	}
+	if h.hasCount && h.count != uint64(cf.count) {
+		err := fmt.Errorf("%w: header records %d items, buckets hold %d", ErrCorrupted, h.count, cf.count)
+		cf.log.decodeFailed(err)
+		return nil, err
+	}
+	return cf, nil
+}
+
+// checkBucketBytes returns an error wrapping ErrCorrupted unless n bytes encode a power-of-two
+// number of buckets.
+func checkBucketBytes(n int) error {
+	if numBuckets := uint64(n / bucketBytes); n%bucketBytes != 0 || numBuckets == 0 || getNextPow2(numBuckets) != uint(numBuckets) {
+		return fmt.Errorf("%w: expected bytes to be %d times a power of 2, got %d", ErrCorrupted, bucketBytes, n)
+	}
+	return nil
+}
+
+// decodeBuckets sets the buckets of cf to the encoded buckets.
+func (cf *Filter) decodeBuckets(bytes []byte) error {
+	var count uint
+	if err := checkBucketBytes(len(bytes)); err != nil {
+		cf.log.decodeFailed(err)
+		return err
+	}
+	buckets := make([]bucket, len(bytes)/bucketBytes)
+	for i := range buckets {
+		buckets[i] = bucket(binary.LittleEndian.Uint64(bytes[8*i:]))
+		count += uint(bucketSize - buckets[i].free())
+	}
+	cf.buckets = buckets
+	cf.count = count
+	cf.bucketIndexMask = uint(len(buckets) - 1)
+	cf.rebuildSummary()
+	cf.log.checkLoad(count, cf.Cap())
+	return nil
 }
@@ -3,11 +3,13 @@ package cuckoo
 import (
 	"bufio"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -74,6 +76,7 @@ func TestLookup(t *testing.T) {
 		{"five", false},
 	}
 	for _, tc := range testCases {
+		tc := tc
 		t.Run(fmt.Sprintf("cf.Lookup(%q)", tc.word), func(t *testing.T) {
 			t.Parallel()
 			if got := cf.Lookup([]byte(tc.word)); got != tc.want {
@@ -235,3 +238,144 @@ func TestEncodeDecode(t *testing.T) {
 		t.Errorf("Decode = %v, want %v, encoded = %v", got, cf, encoded)
 	}
 }
+
+func TestDecode_Corrupted(t *testing.T) {
+	if _, err := Decode([]byte{1, 2, 3}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Decode() error = %v, want %v", err, ErrCorrupted)
+	}
+}
+
+func TestResetFast(t *testing.T) {
+	cf := NewFilter(1000)
+	for i := 0; i < 300; i++ {
+		for j := 0; j < 100; j++ {
+			cf.Insert([]byte{byte(i), byte(j)})
+		}
+		cf.ResetFast()
+
+		if got := cf.Count(); got != 0 {
+			t.Fatalf("After %d ResetFast(): Count() = %d, want 0", i+1, got)
+		}
+		for j := 0; j < 100; j++ {
+			if cf.Lookup([]byte{byte(i), byte(j)}) {
+				t.Fatalf("After %d ResetFast(): Lookup(%v) = true, want false", i+1, []byte{byte(i), byte(j)})
+			}
+		}
+	}
+
+	cf.Insert([]byte("one"))
+	got, err := Decode(cf.Encode())
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if got.Count() != 1 || !got.Lookup([]byte("one")) {
+		t.Errorf("Decode(Encode()) after ResetFast(): Count() = %d, want 1", got.Count())
+	}
+}
+
+func TestResetWithCapacity(t *testing.T) {
+	cf := NewFilter(1000)
+	cf.ResetFast()
+	for i := 0; i < 500; i++ {
+		cf.Insert([]byte(fmt.Sprint(i)))
+	}
+	cf.ResetWithCapacity(100000)
+
+	if cf.Count() != 0 || cf.Cap() != NewFilter(100000).Cap() {
+		t.Fatalf("After ResetWithCapacity(): Count(), Cap() = %d, %d, want 0, %d", cf.Count(), cf.Cap(), NewFilter(100000).Cap())
+	}
+	for i := 0; i < 50000; i++ {
+		if !cf.Insert([]byte(fmt.Sprint(i))) {
+			t.Fatalf("Insert(%d) after ResetWithCapacity() failed", i)
+		}
+	}
+	if !cf.Lookup([]byte("49999")) {
+		t.Errorf("Lookup() after ResetWithCapacity() = false, want true")
+	}
+}
+
+func BenchmarkFilter_ResetFast(b *testing.B) {
+	const cap = 10000
+	filter := NewFilter(cap)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		filter.ResetFast()
+	}
+}
+
+func TestReset_Concurrent(t *testing.T) {
+	cf := NewFilter(100)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			// Overfill the filter so that inserts run long kickout chains.
+			for i := 0; i < 1000; i++ {
+				cf.Insert([]byte{byte(g), byte(i), byte(i >> 8)})
+				if i%100 == 0 {
+					cf.Reset()
+				}
+				if i%150 == 0 {
+					cf.ResetFast()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	cf.Reset()
+
+	if got := cf.Count(); got != 0 {
+		t.Errorf("After concurrent inserts and Reset(): Count() = %d, want 0", got)
+	}
+	for _, b := range cf.buckets {
+		if b != 0 {
+			t.Fatalf("After concurrent inserts and Reset(): bucket = %v, want empty", b)
+		}
+	}
+}
+
+func TestInsertStatus(t *testing.T) {
+	cf := NewFilter(8)
+	if got := cf.InsertStatus([]byte("one")); got != InsertedNew {
+		t.Errorf("InsertStatus() = %v, want %v", got, InsertedNew)
+	}
+	if got := cf.InsertStatus([]byte("one")); got != ProbablyDuplicate {
+		t.Errorf("InsertStatus() of inserted item = %v, want %v", got, ProbablyDuplicate)
+	}
+	if cf.Count() != 2 {
+		t.Errorf("Count() = %d, want 2", cf.Count())
+	}
+	var got InsertResult
+	for i := 0; i < 100 && got != InsertFailed; i++ {
+		got = cf.InsertStatus([]byte(fmt.Sprint(i)))
+	}
+	if got != InsertFailed {
+		t.Errorf("InsertStatus() into full filter = %v, want %v", got, InsertFailed)
+	}
+}
+
+func TestNewFilter64(t *testing.T) {
+	cf, err := NewFilter64(1000)
+	if err != nil || cf.Cap() != NewFilter(1000).Cap() {
+		t.Fatalf("NewFilter64(1000) = %v", err)
+	}
+	for _, n := range []uint64{maxBuckets * bucketSize, maxBuckets*bucketSize + 1, 1 << 63, ^uint64(0)} {
+		if _, err := NewFilter64(n); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("NewFilter64(%d) = %v, want %v", n, err, ErrTooLarge)
+		}
+	}
+	// The largest filter fits, without allocating it.
+	if n, err := bucketsFor(maxBuckets * bucketSize * 9 / 10); n != maxBuckets || err != nil {
+		t.Errorf("bucketsFor(90%% of the largest filter) = %d, %v", n, err)
+	}
+
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("NewFilter() of too many elements panicked with %v, want %v", err, ErrTooLarge)
+		}
+	}()
+	NewFilter(^uint(0))
+}
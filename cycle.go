@@ -0,0 +1,43 @@
+package cuckoo
+
+// cycleCheckAfter is the number of kickouts after which kickout chains start tracking the
+// slots they visit. Most chains end within a few kickouts and never pay for the tracking.
+const cycleCheckAfter = 16
+
+// kickoutCycle detects kickout chains that cannot end: when the full buckets a chain has
+// visited only hold fingerprints whose alternate bucket was visited too, every later kickout
+// stays among them, so the chain would bounce between the same buckets until it gives up.
+// The zero value is ready to use.
+type kickoutCycle struct {
+	// visited holds a bit mask of the visited slots of every visited bucket.
+	visited map[uint]uint8
+	// checked is the number of visited buckets at the last check.
+	checked int
+}
+
+// closed records that the chain kicks out slot j of bucket i and returns true if the chain
+// is caught in a cycle. The buckets are only checked when a slot is visited again without
+// the chain having reached a new bucket since the last check.
+func (c *kickoutCycle) closed(cf *core, i uint, j int) bool {
+	if c.visited == nil {
+		c.visited = make(map[uint]uint8)
+	}
+	bit := uint8(1) << j
+	seen := c.visited[i]&bit != 0
+	c.visited[i] |= bit
+	if !seen || len(c.visited) == c.checked {
+		return false
+	}
+	c.checked = len(c.visited)
+	for b := range c.visited {
+		for _, fp := range cf.buckets[b].fingerprints() {
+			if fp == nullFp {
+				return false
+			}
+			if _, ok := c.visited[cf.altIndex(fp, b)]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package cuckoo
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// countingSource counts the random numbers drawn, which is the number of kickouts.
+type countingSource struct {
+	rand.Source
+	n int
+}
+
+func (s *countingSource) Int63() int64 {
+	s.n++
+	return s.Source.Int63()
+}
+
+func TestKickoutCycle(t *testing.T) {
+	// With two buckets, all fingerprints have the same candidate buckets.
+	cf := newFilter(make([]bucket, 2))
+	for i := 0; cf.Count() < uint(cf.Cap()); i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	src := &countingSource{Source: rand.NewSource(1)}
+	cf.rng = rand.New(src)
+
+	if cf.Insert([]byte("one too many")) {
+		t.Fatal("Insert() into a full filter = true")
+	}
+	if src.n >= maxCuckooKickouts/4 {
+		t.Errorf("failed Insert() kicked out %d fingerprints", src.n)
+	}
+	src.n = 0
+	if cf.InsertOpt([]byte("one too many")) {
+		t.Fatal("InsertOpt() into a full filter = true")
+	}
+	if src.n >= maxCuckooKickouts/4 {
+		t.Errorf("failed InsertOpt() kicked out %d fingerprints", src.n)
+	}
+	if err := cf.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKickoutCycleOpen(t *testing.T) {
+	cf := newFilter(make([]bucket, 4))
+	for i := 0; i < 4; i++ {
+		cf.buckets[0].set(i, fingerprint(i+1))
+	}
+	var cycle kickoutCycle
+	cycle.closed(&cf.core, 0, 0)
+	// Bucket 0 holds fingerprints whose alternate buckets were not visited.
+	if cycle.closed(&cf.core, 0, 0) {
+		t.Error("closed() = true for a chain that can still reach other buckets")
+	}
+}
@@ -0,0 +1,72 @@
+package cuckoo
+
+import "fmt"
+
+// WithMisuseDetection enables a debug mode that keeps an exact shadow copy of all
+// inserted items and reports every Delete of an item that was never inserted (or was
+// deleted more often than inserted). Such deletes silently remove the fingerprint of
+// another item and introduce false negatives.
+//
+// onMisuse is called with the offending item while the filter is locked, so it must not
+// call back into the filter. If onMisuse is nil, the filter panics instead.
+// The shadow set grows with every inserted item, so this is meant for tests and debugging only.
+func WithMisuseDetection(onMisuse func(data []byte)) Option {
+	return func(cf *Filter) {
+		cf.debug = &misuseDetector{
+			inserted: make(map[string]uint),
+			onMisuse: onMisuse,
+		}
+	}
+}
+
+// misuseDetector tracks the multiset of items inserted into a filter.
+// All methods are safe to call on a nil receiver, which disables detection.
+type misuseDetector struct {
+	inserted map[string]uint
+	onMisuse func(data []byte)
+}
+
+func (d *misuseDetector) recordInsert(data []byte) {
+	if d == nil {
+		return
+	}
+	d.inserted[string(data)]++
+}
+
+// retain returns a copy of data to be recorded or checked later, or nil if detection is
+// disabled.
+func (d *misuseDetector) retain(data []byte) []byte {
+	if d == nil {
+		return nil
+	}
+	return append([]byte(nil), data...)
+}
+
+func (d *misuseDetector) checkDelete(data []byte) {
+	if d == nil {
+		return
+	}
+	n := d.inserted[string(data)]
+	switch {
+	case n == 0:
+		d.report(data)
+	case n == 1:
+		delete(d.inserted, string(data))
+	default:
+		d.inserted[string(data)] = n - 1
+	}
+}
+
+func (d *misuseDetector) report(data []byte) {
+	if d.onMisuse == nil {
+		panic(fmt.Sprintf("cuckoo: deleting %q, which was never inserted", data))
+	}
+	d.onMisuse(data)
+}
+
+func (d *misuseDetector) reset() {
+	if d == nil {
+		return
+	}
+	d.inserted = make(map[string]uint)
+}
@@ -0,0 +1,39 @@
+package cuckoo
+
+import (
+	"testing"
+)
+
+func TestMisuseDetection(t *testing.T) {
+	var misused []string
+	cf := NewFilter(100, WithMisuseDetection(func(data []byte) {
+		misused = append(misused, string(data))
+	}))
+	cf.Insert([]byte("one"))
+	cf.Insert([]byte("one"))
+	cf.LookupAndInsert([]byte("two"))
+
+	cf.Delete([]byte("one"))
+	cf.Delete([]byte("one"))
+	cf.Delete([]byte("two"))
+	cf.Delete([]byte("one"))
+	cf.Delete([]byte("never"))
+
+	want := []string{"one", "never"}
+	if len(misused) != len(want) || misused[0] != want[0] || misused[1] != want[1] {
+		t.Errorf("misuse reported for %q, want %q", misused, want)
+	}
+}
+
+func TestMisuseDetection_Panics(t *testing.T) {
+	cf := NewFilter(100, WithMisuseDetection(nil))
+	cf.Insert([]byte("one"))
+	cf.Reset()
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Delete() of item removed by Reset() did not panic")
+		}
+	}()
+	cf.Delete([]byte("one"))
+}
@@ -0,0 +1,37 @@
+package cuckoo
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// deterministicSeed seeds the kickouts of filters created with WithDeterministicPlacement.
+const deterministicSeed = 1
+
+// WithDeterministicPlacement makes kickouts use a fixed pseudo-random sequence instead of the
+// global random source, which restarts on Reset. The same sequence of operations then always
+// gives the same encoding. Use BuildDeterministic to get the same encoding for the same set of
+// keys regardless of their order.
+func WithDeterministicPlacement() Option {
+	return func(cf *Filter) {
+		cf.rng = rand.New(rand.NewSource(deterministicSeed))
+	}
+}
+
+// BuildDeterministic returns a filter holding keys whose encoding only depends on the set of
+// keys and opts, not on the order of keys or on randomness, for content-addressed caching of
+// filters and reproducible builds. Keys are hashed, sorted and placed like by
+// BuildFromSortedHashes, and later inserts into the filter use WithDeterministicPlacement.
+func BuildDeterministic(keys [][]byte, opts ...Option) (*Filter, error) {
+	opts = append(opts[:len(opts):len(opts)], WithDeterministicPlacement())
+	template := newFilter(make([]bucket, 1))
+	for _, opt := range opts {
+		opt(template)
+	}
+	hashes := make([]uint64, len(keys))
+	for k, key := range keys {
+		hashes[k] = versionedHash(template.hashVersion, template.seed, template.normalize(key))
+	}
+	sort.Slice(hashes, func(x, y int) bool { return hashes[x] < hashes[y] })
+	return buildSorted(hashes, opts...)
+}
@@ -0,0 +1,57 @@
+package cuckoo
+
+import (
+	"bytes"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestWithDeterministicPlacement(t *testing.T) {
+	build := func() *Filter {
+		cf := NewFilter(1000, WithDeterministicPlacement())
+		// Overfill the filter so that inserts run kickout chains.
+		for i := 0; i < 1100; i++ {
+			cf.Insert([]byte(strconv.Itoa(i)))
+		}
+		return cf
+	}
+	a, b := build(), build()
+	if !bytes.Equal(a.Encode(), b.Encode()) {
+		t.Errorf("filters built with WithDeterministicPlacement differ")
+	}
+	a.Reset()
+	for i := 0; i < 1100; i++ {
+		a.Insert([]byte(strconv.Itoa(i)))
+	}
+	if !bytes.Equal(a.Encode(), b.Encode()) {
+		t.Errorf("filter rebuilt after Reset() differs")
+	}
+}
+
+func TestBuildDeterministic(t *testing.T) {
+	keys := make([][]byte, 5000)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	a, err := BuildDeterministic(keys, WithHashSeed(42))
+	if err != nil {
+		t.Fatalf("BuildDeterministic() = %v", err)
+	}
+	rand.Shuffle(len(keys), func(x, y int) { keys[x], keys[y] = keys[y], keys[x] })
+	b, err := BuildDeterministic(keys, WithHashSeed(42))
+	if err != nil {
+		t.Fatalf("BuildDeterministic() = %v", err)
+	}
+	if !bytes.Equal(a.Encode(), b.Encode()) {
+		t.Errorf("BuildDeterministic() of shuffled keys gives a different encoding")
+	}
+	if a.HashSeed() != 42 || a.Count() != 5000 {
+		t.Errorf("HashSeed(), Count() = %d, %d, want 42, 5000", a.HashSeed(), a.Count())
+	}
+	for _, key := range keys {
+		if !a.Lookup(key) {
+			t.Fatalf("Lookup(%s) = false", key)
+		}
+	}
+}
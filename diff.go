@@ -0,0 +1,129 @@
+package cuckoo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Diff returns the fingerprints stored in b but not in a (added) and those stored in a but not
+// in b (removed). Both filters must have the same number of buckets, hash seed, hashing version
+// and alternate index scheme. Fingerprints are compared per bucket, so moving within a bucket
+// is no change, while a fingerprint kicked out to its alternate bucket is reported as removed
+// from one bucket and added to the other.
+//
+// Each filter is read under its own lock, one after the other.
+func Diff(a, b *Filter) (added, removed []StoredFP, err error) {
+	a.lock.RLock()
+	fpsA, numBucketsA := a.storedFingerprints(), len(a.buckets)
+	a.lock.RUnlock()
+	b.lock.RLock()
+	fpsB, numBucketsB := b.storedFingerprints(), len(b.buckets)
+	b.lock.RUnlock()
+
+	if numBucketsA != numBucketsB {
+		return nil, nil, fmt.Errorf("%w: %d and %d buckets", ErrIncompatible, numBucketsA, numBucketsB)
+	}
+	if a.seed != b.seed || a.hashVersion != b.hashVersion || a.altScheme != b.altScheme {
+		return nil, nil, fmt.Errorf("%w: different hashing", ErrIncompatible)
+	}
+	// Both lists are sorted by bucket, compare them bucket by bucket.
+	for len(fpsA) > 0 || len(fpsB) > 0 {
+		var bucketA, bucketB []StoredFP
+		i := nextBucket(fpsA, fpsB)
+		bucketA, fpsA = splitBucket(fpsA, i)
+		bucketB, fpsB = splitBucket(fpsB, i)
+		added = append(added, subtractFingerprints(bucketB, bucketA)...)
+		removed = append(removed, subtractFingerprints(bucketA, bucketB)...)
+	}
+	return added, removed, nil
+}
+
+// nextBucket returns the smallest bucket index at the head of a and b, which are not both empty.
+func nextBucket(a, b []StoredFP) uint {
+	switch {
+	case len(a) == 0:
+		return b[0].Bucket
+	case len(b) == 0 || a[0].Bucket < b[0].Bucket:
+		return a[0].Bucket
+	default:
+		return b[0].Bucket
+	}
+}
+
+// splitBucket splits the leading fingerprints of bucket i from fps.
+func splitBucket(fps []StoredFP, i uint) (bucket, rest []StoredFP) {
+	n := 0
+	for n < len(fps) && fps[n].Bucket == i {
+		n++
+	}
+	return fps[:n], fps[n:]
+}
+
+// subtractFingerprints returns the fingerprints in x without those in y, as multisets.
+func subtractFingerprints(x, y []StoredFP) []StoredFP {
+	var diff []StoredFP
+	used := make([]bool, len(y))
+outer:
+	for _, fx := range x {
+		for j, fy := range y {
+			if !used[j] && fx.Fingerprint == fy.Fingerprint {
+				used[j] = true
+				continue outer
+			}
+		}
+		diff = append(diff, fx)
+	}
+	return diff
+}
+
+// EstimateUnionCount estimates the number of distinct items in the union of a and b, e.g. to
+// size the destination of a merge before attempting it. Items stored in both filters have the
+// same fingerprint in the same pair of candidate buckets, so they are counted once; distinct
+// items whose fingerprints collide are counted once as well, which makes the estimate low by
+// about the false positive rate. The filters may have different numbers of buckets, but must
+// share the hash seed, hashing version and alternate index scheme.
+//
+// Each filter is read under its own lock, one after the other.
+func EstimateUnionCount(a, b *Filter) (uint, error) {
+	if a.seed != b.seed || a.hashVersion != b.hashVersion || a.altScheme != b.altScheme {
+		return 0, fmt.Errorf("%w: different hashing", ErrIncompatible)
+	}
+	mask := a.bucketIndexMask
+	if b.bucketIndexMask < mask {
+		mask = b.bucketIndexMask
+	}
+	keysA, keysB := a.canonicalKeys(mask), b.canonicalKeys(mask)
+	union := uint(len(keysA) + len(keysB))
+	// Subtract the multiset intersection of both sorted lists.
+	for len(keysA) > 0 && len(keysB) > 0 {
+		switch {
+		case keysA[0] < keysB[0]:
+			keysA = keysA[1:]
+		case keysA[0] > keysB[0]:
+			keysB = keysB[1:]
+		default:
+			union--
+			keysA, keysB = keysA[1:], keysB[1:]
+		}
+	}
+	return union, nil
+}
+
+// canonicalKeys returns the sorted keys of all stored fingerprints for a filter with the given
+// bucket index mask, which must not exceed that of cf. A key combines the fingerprint with
+// the smaller of its candidate buckets, so it does not depend on where the item was placed.
+func (cf *Filter) canonicalKeys(mask uint) []uint64 {
+	cf.lock.RLock()
+	keys := make([]uint64, 0, cf.count)
+	cf.forEachFingerprint(func(i, _ uint, fp fingerprint) {
+		i &= mask
+		if alt := altIndex(cf.altScheme, fp, i, mask); alt < i {
+			i = alt
+		}
+		keys = append(keys, uint64(i)<<fingerprintSizeBits|uint64(fp))
+	})
+	cf.lock.RUnlock()
+
+	sort.Slice(keys, func(x, y int) bool { return keys[x] < keys[y] })
+	return keys
+}
@@ -0,0 +1,80 @@
+package cuckoo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := NewFilter(100)
+	for i := byte(0); i < 20; i++ {
+		a.Insert([]byte{i})
+	}
+	b, err := Decode(a.Encode())
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	b.Insert([]byte("new"))
+	b.Insert([]byte("new"))
+	b.Delete([]byte{3})
+
+	added, removed, err := Diff(a, b)
+	if err != nil {
+		t.Fatalf("Diff() = %v", err)
+	}
+	i1, fp := getIndexAndFingerprint([]byte("new"), a.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, a.bucketIndexMask)
+	if len(added) != 2 || added[0].Fingerprint != uint16(fp) || (added[0].Bucket != i1 && added[0].Bucket != i2) {
+		t.Errorf("Diff() added = %v, want 2 copies of fingerprint %d in bucket %d or %d", added, fp, i1, i2)
+	}
+	_, fp = getIndexAndFingerprint([]byte{3}, a.bucketIndexMask)
+	if len(removed) != 1 || removed[0].Fingerprint != uint16(fp) {
+		t.Errorf("Diff() removed = %v, want fingerprint %d", removed, fp)
+	}
+
+	if added, removed, _ := Diff(a, a); len(added) != 0 || len(removed) != 0 {
+		t.Errorf("Diff(a, a) = %v, %v, want no changes", added, removed)
+	}
+	if _, _, err := Diff(a, NewFilter(1000)); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Diff() of different sizes error = %v, want %v", err, ErrIncompatible)
+	}
+	legacy := NewFilter(100)
+	legacy.hashVersion = 0
+	for name, other := range map[string]*Filter{
+		"seed":                   NewFilter(100, WithHashSeed(1)),
+		"hashing version":        legacy,
+		"alternate index scheme": NewFilter(100, WithAltIndexScheme(AltIndexOffset)),
+	} {
+		if _, _, err := Diff(a, other); !errors.Is(err, ErrIncompatible) {
+			t.Errorf("Diff() of different %s error = %v, want %v", name, err, ErrIncompatible)
+		}
+	}
+}
+
+func TestEstimateUnionCount(t *testing.T) {
+	a := NewFilter(4000)
+	b := NewFilter(1000)
+	for i := 0; i < 1000; i++ {
+		a.Insert([]byte(strconv.Itoa(i)))
+		b.Insert([]byte(strconv.Itoa(i + 500)))
+	}
+	// Colliding fingerprints of distinct items are counted once.
+	for _, tc := range []struct {
+		a, b     *Filter
+		min, max uint
+	}{
+		{a, b, 1490, 1500},
+		{b, a, 1490, 1500},
+		{a, a, 990, 1000},
+		{a, NewFilter(10), 1000, 1000},
+	} {
+		got, err := EstimateUnionCount(tc.a, tc.b)
+		if err != nil || got < tc.min || got > tc.max {
+			t.Errorf("EstimateUnionCount() = %d, %v, want between %d and %d", got, err, tc.min, tc.max)
+		}
+	}
+	if _, err := EstimateUnionCount(a, NewFilter(1000, WithHashSeed(1))); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("EstimateUnionCount() of different seeds error = %v, want %v", err, ErrIncompatible)
+	}
+}
@@ -0,0 +1,94 @@
+package cuckoo
+
+import (
+	"math"
+	"math/bits"
+
+	metro "github.com/dgryski/go-metro"
+)
+
+const (
+	// distinctPrecision is the number of hash bits selecting a HyperLogLog register.
+	// 2^14 registers give a standard error of about 0.8%.
+	distinctPrecision = 14
+	distinctRegisters = 1 << distinctPrecision
+	// distinctSeed is the hash seed for distinct counting, independent of the filter's hashing.
+	distinctSeed = 97
+)
+
+// WithDistinctEstimation keeps a HyperLogLog sketch of all inserted items, so that
+// EstimatedDistinct can tell how many unique items were inserted. Count counts every
+// successful insert, including duplicates. The sketch uses 16KiB of memory.
+func WithDistinctEstimation() Option {
+	return func(cf *Filter) {
+		cf.distinct = &distinctCounter{}
+	}
+}
+
+// EstimatedDistinct returns an estimate of the number of unique items inserted since the
+// filter was created or reset, with a standard error of about 0.8%. Deletes do not lower
+// the estimate. It returns 0 unless WithDistinctEstimation was given; the sketch is not
+// part of the encoding, so it is also 0 for decoded filters.
+func (cf *Filter) EstimatedDistinct() uint64 {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	return cf.distinct.estimate()
+}
+
+// distinctCounter is a HyperLogLog sketch.
+// All methods are safe to call on a nil receiver, which disables estimation.
+type distinctCounter struct {
+	registers [distinctRegisters]uint8
+}
+
+func distinctHash(data []byte) uint64 {
+	return metro.Hash64(data, distinctSeed)
+}
+
+func (d *distinctCounter) addKey(data []byte) {
+	if d == nil {
+		return
+	}
+	d.add(distinctHash(data))
+}
+
+func (d *distinctCounter) add(hash uint64) {
+	if d == nil {
+		return
+	}
+	r := hash >> (64 - distinctPrecision)
+	// The position of the first set bit of the remaining bits, capped by a sentinel bit.
+	rank := uint8(bits.LeadingZeros64(hash<<distinctPrecision|1<<(distinctPrecision-1))) + 1
+	if rank > d.registers[r] {
+		d.registers[r] = rank
+	}
+}
+
+func (d *distinctCounter) estimate() uint64 {
+	if d == nil {
+		return 0
+	}
+	const m = float64(distinctRegisters)
+	sum, zeros := 0.0, 0
+	for _, r := range d.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+func (d *distinctCounter) reset() {
+	if d == nil {
+		return
+	}
+	d.registers = [distinctRegisters]uint8{}
+}
@@ -0,0 +1,39 @@
+package cuckoo
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestEstimatedDistinct(t *testing.T) {
+	for _, n := range []int{10, 1000, 50000} {
+		cf := NewFilter(uint(3*n), WithDistinctEstimation())
+		for i := 0; i < n; i++ {
+			key := []byte(fmt.Sprint(i))
+			cf.Insert(key)
+			cf.Insert(key)
+		}
+		if got := cf.Count(); got != uint(2*n) {
+			t.Errorf("Count() = %d, want %d", got, 2*n)
+		}
+		got := cf.EstimatedDistinct()
+		if math.Abs(float64(got)-float64(n)) > 0.05*float64(n)+1 {
+			t.Errorf("EstimatedDistinct() = %d, want about %d", got, n)
+		}
+	}
+
+	cf := NewFilter(1000, WithDistinctEstimation())
+	batch := []Prepared{cf.Prepare([]byte("one")), cf.Prepare([]byte("two")), cf.Prepare([]byte("one"))}
+	cf.Commit(batch)
+	if got := cf.EstimatedDistinct(); got != 2 {
+		t.Errorf("EstimatedDistinct() after Commit() = %d, want 2", got)
+	}
+	cf.Reset()
+	if got := cf.EstimatedDistinct(); got != 0 {
+		t.Errorf("EstimatedDistinct() after Reset() = %d, want 0", got)
+	}
+	if got := NewFilter(10).EstimatedDistinct(); got != 0 {
+		t.Errorf("EstimatedDistinct() without WithDistinctEstimation() = %d, want 0", got)
+	}
+}
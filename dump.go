@@ -0,0 +1,164 @@
+package cuckoo
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// StoredFP is a fingerprint stored in a filter, together with its location.
+type StoredFP struct {
+	Bucket      uint
+	Slot        uint
+	Fingerprint uint16
+}
+
+// storedFingerprints returns all occupied slots in order of location.
+// The caller must hold at least the read lock.
+func (cf *Filter) storedFingerprints() []StoredFP {
+	var fps []StoredFP
+	cf.forEachFingerprint(func(i uint, j uint, fp fingerprint) {
+		fps = append(fps, StoredFP{Bucket: i, Slot: j, Fingerprint: uint16(fp)})
+	})
+	return fps
+}
+
+// ForEachSnapshot calls fn for all occupied slots in order of location until fn returns false.
+// It iterates over a copy of the buckets taken when it is called, so fn sees a consistent state
+// of the filter, writers are only blocked while copying, and fn may call back into the filter.
+func (cf *Filter) ForEachSnapshot(fn func(fp StoredFP) bool) {
+	buckets := cf.snapshotBuckets()
+	for i, b := range buckets {
+		for j, fp := range b.fingerprints() {
+			if fp != nullFp && !fn(StoredFP{Bucket: uint(i), Slot: uint(j), Fingerprint: uint16(fp)}) {
+				return
+			}
+		}
+	}
+}
+
+// snapshotBuckets returns a copy of the buckets with stale buckets emptied.
+func (cf *Filter) snapshotBuckets() []bucket {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	buckets := make([]bucket, len(cf.buckets))
+	copy(buckets, cf.buckets)
+	for i := range buckets {
+		if cf.stale(uint(i)) {
+			buckets[i].reset()
+		}
+	}
+	return buckets
+}
+
+// forEachFingerprint calls fn for all occupied slots in order of location.
+// The caller must hold at least the read lock.
+func (cf *Filter) forEachFingerprint(fn func(i uint, j uint, fp fingerprint)) {
+	for i, b := range cf.buckets {
+		if cf.stale(uint(i)) {
+			continue
+		}
+		for j, fp := range b.fingerprints() {
+			if fp != nullFp {
+				fn(uint(i), uint(j), fp)
+			}
+		}
+	}
+}
+
+// DumpCSV writes all stored fingerprints as CSV rows of bucket, slot and fingerprint, for
+// inspection and diffing of snapshots with standard tools. The rows follow a comment line
+// recording the number of buckets, the hash seed and alternate index schemes other than
+// AltIndexXOR, and a header row. LoadCSV reads the output back.
+func (cf *Filter) DumpCSV(w io.Writer) error {
+	cf.lock.RLock()
+	if cf.stash.len() > 0 {
+		cf.lock.RUnlock()
+		return fmt.Errorf("%w: dumping stashed items, use Encode", ErrUnsupported)
+	}
+	fps := cf.storedFingerprints()
+	numBuckets := len(cf.buckets)
+	cf.lock.RUnlock()
+
+	header := fmt.Sprintf("# buckets=%d seed=%d", numBuckets, cf.seed)
+	if cf.altScheme != AltIndexXOR {
+		header += fmt.Sprintf(" alt=%d", cf.altScheme)
+	}
+	if _, err := io.WriteString(w, header+"\n"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"bucket", "slot", "fingerprint"})
+	for _, fp := range fps {
+		cw.Write([]string{
+			strconv.FormatUint(uint64(fp.Bucket), 10),
+			strconv.FormatUint(uint64(fp.Slot), 10),
+			strconv.FormatUint(uint64(fp.Fingerprint), 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// LoadCSV returns a Cuckoofilter from CSV written by DumpCSV. Rows may be missing or edited,
+// e.g. when recovering from a partially damaged filter, but every row must be valid.
+func LoadCSV(r io.Reader) (_ *Filter, err error) {
+	defer recoverInvalidState(&err)
+	br := bufio.NewReader(r)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading number of buckets: %v", ErrCorrupted, err)
+	}
+	var numBuckets uint64
+	var seed uint64 = defaultHashSeed
+	var altScheme AltIndexScheme
+	// Dumps of older versions have no seed, and the scheme is omitted for AltIndexXOR.
+	if _, err := fmt.Sscanf(line, "# buckets=%d seed=%d alt=%d\n", &numBuckets, &seed, &altScheme); err != nil {
+		if _, err := fmt.Sscanf(line, "# buckets=%d seed=%d\n", &numBuckets, &seed); err != nil {
+			if _, err := fmt.Sscanf(line, "# buckets=%d\n", &numBuckets); err != nil {
+				return nil, fmt.Errorf("%w: reading number of buckets: %v", ErrCorrupted, err)
+			}
+		}
+	}
+	if !supportedAltIndexScheme(altScheme) {
+		return nil, fmt.Errorf("%w: unsupported alternate index scheme %d", ErrIncompatible, altScheme)
+	}
+	if numBuckets == 0 || numBuckets&(numBuckets-1) != 0 {
+		return nil, fmt.Errorf("%w: number of buckets %d is not a power of 2", ErrCorrupted, numBuckets)
+	}
+	if numBuckets > maxBuckets {
+		return nil, fmt.Errorf("%w: %d buckets", ErrTooLarge, numBuckets)
+	}
+	cf := newFilter(make([]bucket, numBuckets))
+	cf.seed = seed
+	cf.altScheme = altScheme
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = 3
+	cr.ReuseRecord = true
+	if _, err := cr.Read(); err != nil {
+		return nil, fmt.Errorf("%w: reading header: %v", ErrCorrupted, err)
+	}
+	for row := 1; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return cf, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+		}
+		i, errBucket := strconv.ParseUint(record[0], 10, 64)
+		j, errSlot := strconv.ParseUint(record[1], 10, 64)
+		fp, errFp := strconv.ParseUint(record[2], 10, 16)
+		if errBucket != nil || errSlot != nil || errFp != nil || i >= numBuckets || j >= bucketSize || fp == nullFp {
+			return nil, fmt.Errorf("%w: invalid row %d %q", ErrCorrupted, row, record)
+		}
+		if cf.buckets[i].get(int(j)) == nullFp {
+			cf.count++
+		}
+		cf.buckets[i].set(int(j), fingerprint(fp))
+	}
+}
@@ -0,0 +1,88 @@
+package cuckoo
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDumpLoadCSV(t *testing.T) {
+	cf := NewFilter(100)
+	for i := byte(0); i < 50; i++ {
+		cf.Insert([]byte{i})
+	}
+	var buf bytes.Buffer
+	if err := cf.DumpCSV(&buf); err != nil {
+		t.Fatalf("DumpCSV() = %v", err)
+	}
+	if got, want := strings.Count(buf.String(), "\n"), 52; got != want {
+		t.Errorf("DumpCSV() wrote %d lines, want %d", got, want)
+	}
+
+	got, err := LoadCSV(&buf)
+	if err != nil {
+		t.Fatalf("LoadCSV() = %v", err)
+	}
+	if !reflect.DeepEqual(got.buckets, cf.buckets) || got.Count() != cf.Count() {
+		t.Errorf("LoadCSV() = filter with %d items, want %d", got.Count(), cf.Count())
+	}
+}
+
+func TestLoadCSV_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"# buckets=3\nbucket,slot,fingerprint\n",
+		"# buckets=4\nbucket,slot,fingerprint\n4,0,1\n",
+		"# buckets=4\nbucket,slot,fingerprint\n0,4,1\n",
+		"# buckets=4\nbucket,slot,fingerprint\n0,0,0\n",
+		"# buckets=4\nbucket,slot,fingerprint\n0,0,65536\n",
+		"# buckets=4\nbucket,slot,fingerprint\n0,0\n",
+	} {
+		if _, err := LoadCSV(strings.NewReader(input)); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("LoadCSV(%q) error = %v, want %v", input, err, ErrCorrupted)
+		}
+	}
+	input := "# buckets=1152921504606846976\nbucket,slot,fingerprint\n"
+	if _, err := LoadCSV(strings.NewReader(input)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("LoadCSV(%q) error = %v, want %v", input, err, ErrTooLarge)
+	}
+}
+
+func TestForEachSnapshot(t *testing.T) {
+	cf := NewFilter(100)
+	for i := byte(0); i < 50; i++ {
+		cf.Insert([]byte{i})
+	}
+	cf.lock.RLock()
+	want := cf.storedFingerprints()
+	cf.lock.RUnlock()
+
+	var got []StoredFP
+	cf.ForEachSnapshot(func(fp StoredFP) bool {
+		got = append(got, fp)
+		// Writers must not block or affect the iteration.
+		cf.Delete([]byte{byte(len(got))})
+		cf.Insert([]byte{byte(100 + len(got))})
+		return true
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ForEachSnapshot() visited %d fingerprints, want %d as stored when called", len(got), len(want))
+	}
+
+	n := 0
+	cf.ForEachSnapshot(func(StoredFP) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("ForEachSnapshot() called fn %d times after it returned false, want 3", n)
+	}
+
+	cf.ResetFast()
+	cf.ForEachSnapshot(func(fp StoredFP) bool {
+		t.Errorf("ForEachSnapshot() after ResetFast() visited %+v", fp)
+		return true
+	})
+}
@@ -0,0 +1,22 @@
+package cuckoo
+
+import "errors"
+
+// Errors returned by the package. Functions wrap them with additional context,
+// so callers should compare using errors.Is.
+var (
+	// ErrFull is returned when an operation needs more free slots than the filter has left.
+	ErrFull = errors.New("cuckoo: filter is full")
+	// ErrCorrupted is returned when serialized data cannot be decoded into a valid filter.
+	ErrCorrupted = errors.New("cuckoo: corrupted data")
+	// ErrIncompatible is returned when two filters (or a filter and serialized data)
+	// do not share the geometry or configuration required by an operation.
+	ErrIncompatible = errors.New("cuckoo: incompatible filters")
+	// ErrTooLarge is returned when a requested size exceeds what can be represented.
+	ErrTooLarge = errors.New("cuckoo: filter too large")
+	// ErrUnsupported is returned when a requested configuration is not supported.
+	ErrUnsupported = errors.New("cuckoo: unsupported configuration")
+	// ErrInvalidState is returned when the state of a filter violates its invariants, e.g.
+	// because the memory of its Store was modified externally.
+	ErrInvalidState = errors.New("cuckoo: invalid filter state")
+)
@@ -2,6 +2,7 @@ package cuckoo_test
 
 import (
 	"fmt"
+	"testing/fstest"
 
 	cuckoo "github.com/chenny7/cuckoofilter"
 )
@@ -51,3 +52,28 @@ func ExampleFilter_Delete() {
 	// true
 	// false
 }
+
+func ExampleFilter_ContainsOrAdd() {
+	cf := cuckoo.NewFilter(1000)
+
+	fmt.Println(cf.ContainsOrAdd([]byte("pizza")))
+	fmt.Println(cf.ContainsOrAdd([]byte("pizza")))
+	// Output:
+	// false true
+	// true false
+}
+
+func ExampleDecodeFS() {
+	cf := cuckoo.NewFilter(1000)
+	cf.Insert([]byte("pizza"))
+	// An embed.FS works the same way.
+	fsys := fstest.MapFS{"food.cuckoo": {Data: cf.Encode()}}
+
+	decoded, err := cuckoo.DecodeFS(fsys, "food.cuckoo")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(decoded.Lookup([]byte("pizza")))
+	// Output:
+	// true
+}
@@ -0,0 +1,250 @@
+package cuckoo
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// expiryEpochs is the number of full epochs an item lives, after the partial epoch it was
+	// inserted in. More epochs would make expiry more precise, but 4-bit epoch tags can only
+	// tell apart ages below 16.
+	expiryEpochs = 4
+	// expirySweepEpochs is the number of epochs after which the next operation checks all
+	// buckets for expired items. Together with expiryEpochs, it keeps the age of every stored
+	// item below 16 epochs, so that tags never wrap around.
+	expirySweepEpochs = 4
+	expiryTagBits     = 4
+	expiryTagMask     = 1<<expiryTagBits - 1
+)
+
+// ExpiringFilter is a Filter whose items expire after a time to live. Every fingerprint is
+// tagged with the coarse time of its insertion, using 4 bits per item. Expired items are
+// ignored by lookups and evicted lazily by inserts into their buckets; about once per time
+// to live, an operation evicts all expired items. There are no background goroutines.
+//
+// Items expire between ttl and 1.25*ttl after they were last inserted. It is safe for
+// concurrent use.
+type ExpiringFilter struct {
+	buckets []bucket
+	// tags holds the epoch tags of the four slots of every bucket.
+	tags  []uint16
+	count uint
+	// Bit mask set to len(buckets) - 1, which is always a power of 2.
+	bucketIndexMask uint
+	lock            sync.RWMutex
+
+	epochLength time.Duration
+	now         func() time.Time
+	// lastSweep is the epoch all buckets were last swept in, lastWrite that of the last insert.
+	lastSweep int64
+	lastWrite int64
+}
+
+var _ ApproxSet = (*ExpiringFilter)(nil)
+
+// NewExpiringFilter returns a filter for the given number of elements whose items expire
+// after ttl.
+func NewExpiringFilter(numElements uint, ttl time.Duration) *ExpiringFilter {
+	numBuckets := numBucketsFor(numElements)
+	ef := &ExpiringFilter{
+		buckets:         make([]bucket, numBuckets),
+		tags:            make([]uint16, numBuckets),
+		bucketIndexMask: numBuckets - 1,
+		epochLength:     ttl / expiryEpochs,
+		now:             time.Now,
+	}
+	if ef.epochLength <= 0 {
+		ef.epochLength = 1
+	}
+	ef.lastSweep = ef.epoch()
+	ef.lastWrite = ef.lastSweep
+	return ef
+}
+
+func (ef *ExpiringFilter) epoch() int64 {
+	return ef.now().UnixNano() / int64(ef.epochLength)
+}
+
+func (ef *ExpiringFilter) tag(i uint, j int) uint16 {
+	return ef.tags[i] >> (expiryTagBits * j) & expiryTagMask
+}
+
+func (ef *ExpiringFilter) setTag(i uint, j int, tag uint16) {
+	shift := expiryTagBits * j
+	ef.tags[i] = ef.tags[i]&^(expiryTagMask<<shift) | tag<<shift
+}
+
+// live returns true if slot j of bucket i holds an item that has not expired at epoch now.
+func (ef *ExpiringFilter) live(i uint, j int, now int64) bool {
+	return ef.buckets[i].get(j) != nullFp && (uint16(now)-ef.tag(i, j))&expiryTagMask <= expiryEpochs
+}
+
+// find returns the slot of bucket i holding fp unexpired, or -1.
+func (ef *ExpiringFilter) find(fp fingerprint, i uint, now int64) int {
+	for j := 0; j < bucketSize; j++ {
+		if ef.buckets[i].get(j) == fp && ef.live(i, j, now) {
+			return j
+		}
+	}
+	return -1
+}
+
+// evict empties the slots of bucket i holding expired items. The caller must hold the write lock.
+func (ef *ExpiringFilter) evict(i uint, now int64) {
+	for j := 0; j < bucketSize; j++ {
+		if ef.buckets[i].get(j) != nullFp && !ef.live(i, j, now) {
+			ef.buckets[i].set(j, nullFp)
+			ef.count--
+		}
+	}
+}
+
+// sweepDue returns true if an operation at epoch now must sweep first.
+func (ef *ExpiringFilter) sweepDue(now int64) bool {
+	return now-ef.lastSweep >= expirySweepEpochs
+}
+
+// sweep evicts all expired items. The caller must hold the write lock.
+func (ef *ExpiringFilter) sweep(now int64) {
+	if now-ef.lastWrite > expiryEpochs {
+		// Everything expired, and the tags of old items could have wrapped around.
+		for i := range ef.buckets {
+			ef.buckets[i] = 0
+		}
+		ef.count = 0
+	} else {
+		for i := range ef.buckets {
+			ef.evict(uint(i), now)
+		}
+	}
+	ef.lastSweep = now
+}
+
+// Sweep evicts all expired items. Operations do so as needed, but Sweep can be called
+// periodically to free memory of expired items early, e.g. using SweepTask.
+func (ef *ExpiringFilter) Sweep() {
+	now := ef.epoch()
+
+	ef.lock.Lock()
+	defer ef.lock.Unlock()
+
+	ef.sweep(now)
+}
+
+// Lookup returns true if data is in the filter and has not expired.
+func (ef *ExpiringFilter) Lookup(data []byte) bool {
+	i1, fp := getIndexAndFingerprint(data, ef.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, ef.bucketIndexMask)
+	now := ef.epoch()
+
+	ef.lock.RLock()
+	if ef.sweepDue(now) {
+		ef.lock.RUnlock()
+		ef.lock.Lock()
+		if ef.sweepDue(now) {
+			ef.sweep(now)
+		}
+		ef.lock.Unlock()
+		ef.lock.RLock()
+	}
+	defer ef.lock.RUnlock()
+
+	return ef.find(fp, i1, now) >= 0 || ef.find(fp, i2, now) >= 0
+}
+
+// Insert data into the filter, or renew its time to live if it is present. Returns false if
+// insertion failed, see Filter.Insert.
+func (ef *ExpiringFilter) Insert(data []byte) bool {
+	i1, fp := getIndexAndFingerprint(data, ef.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, ef.bucketIndexMask)
+	now := ef.epoch()
+	tag := uint16(now) & expiryTagMask
+
+	ef.lock.Lock()
+	defer ef.lock.Unlock()
+
+	if ef.sweepDue(now) {
+		ef.sweep(now)
+	}
+	ef.lastWrite = now
+	for _, i := range [2]uint{i1, i2} {
+		if j := ef.find(fp, i, now); j >= 0 {
+			ef.setTag(i, j, tag)
+			return true
+		}
+	}
+	if ef.insert(fp, tag, i1, now) || ef.insert(fp, tag, i2, now) {
+		return true
+	}
+	i := randi(i1, i2)
+	for k := 0; k < maxCuckooKickouts; k++ {
+		j := rand.Intn(bucketSize)
+		oldTag := ef.tag(i, j)
+		fp = ef.buckets[i].swap(j, fp)
+		ef.setTag(i, j, tag)
+		tag = oldTag
+
+		i = getAltIndex(fp, i, ef.bucketIndexMask)
+		if ef.insert(fp, tag, i, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// insert puts fp with tag into a free slot of bucket i, evicting expired items first.
+func (ef *ExpiringFilter) insert(fp fingerprint, tag uint16, i uint, now int64) bool {
+	ef.evict(i, now)
+	for j := 0; j < bucketSize; j++ {
+		if ef.buckets[i].get(j) == nullFp {
+			ef.buckets[i].set(j, fp)
+			ef.setTag(i, j, tag)
+			ef.count++
+			return true
+		}
+	}
+	return false
+}
+
+// Delete data from the filter. Returns true if the data was found unexpired and deleted.
+func (ef *ExpiringFilter) Delete(data []byte) bool {
+	i1, fp := getIndexAndFingerprint(data, ef.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, ef.bucketIndexMask)
+	now := ef.epoch()
+
+	ef.lock.Lock()
+	defer ef.lock.Unlock()
+
+	if ef.sweepDue(now) {
+		ef.sweep(now)
+	}
+	for _, i := range [2]uint{i1, i2} {
+		if j := ef.find(fp, i, now); j >= 0 {
+			ef.buckets[i].set(j, nullFp)
+			ef.count--
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of stored items, which can include expired items not evicted yet.
+func (ef *ExpiringFilter) Count() uint {
+	ef.lock.RLock()
+	defer ef.lock.RUnlock()
+
+	return ef.count
+}
+
+// Reset removes all items from the filter, setting count to 0.
+func (ef *ExpiringFilter) Reset() {
+	ef.lock.Lock()
+	defer ef.lock.Unlock()
+
+	for i := range ef.buckets {
+		ef.buckets[i] = 0
+	}
+	ef.count = 0
+}
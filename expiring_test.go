@@ -0,0 +1,83 @@
+package cuckoo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestExpiringFilter(numElements uint, ttl time.Duration) (*ExpiringFilter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	ef := NewExpiringFilter(numElements, ttl)
+	ef.now = clock.now
+	ef.lastSweep = ef.epoch()
+	ef.lastWrite = ef.lastSweep
+	return ef, clock
+}
+
+func TestExpiringFilter(t *testing.T) {
+	ef, clock := newTestExpiringFilter(1000, time.Minute)
+	ef.Insert([]byte("old"))
+	clock.t = clock.t.Add(40 * time.Second)
+	ef.Insert([]byte("new"))
+	if !ef.Lookup([]byte("old")) || !ef.Lookup([]byte("new")) {
+		t.Fatalf("Lookup() before time to live = false")
+	}
+
+	clock.t = clock.t.Add(35 * time.Second)
+	if ef.Lookup([]byte("old")) {
+		t.Errorf("Lookup() of item inserted 75s ago = true, want expired")
+	}
+	if !ef.Lookup([]byte("new")) {
+		t.Errorf("Lookup() of item inserted 35s ago = false")
+	}
+
+	// Inserting again renews the time to live instead of adding a duplicate.
+	ef.Insert([]byte("new"))
+	clock.t = clock.t.Add(50 * time.Second)
+	if !ef.Lookup([]byte("new")) || ef.Count() != 1 {
+		t.Errorf("After renewing: Lookup() = %v, Count() = %d, want true, 1", ef.Lookup([]byte("new")), ef.Count())
+	}
+	if !ef.Delete([]byte("new")) || ef.Lookup([]byte("new")) {
+		t.Errorf("Delete() failed")
+	}
+}
+
+func TestExpiringFilter_NoWrapAround(t *testing.T) {
+	ef, clock := newTestExpiringFilter(1000, time.Minute)
+	for i := 0; i < 500; i++ {
+		ef.Insert([]byte(fmt.Sprint(i)))
+	}
+	// Without any operation for a long time, tags wrap around more than once.
+	for _, idle := range []time.Duration{4 * time.Minute, 15 * time.Second, 7 * time.Minute} {
+		clock.t = clock.t.Add(idle)
+		for i := 0; i < 500; i++ {
+			if ef.Lookup([]byte(fmt.Sprint(i))) {
+				t.Fatalf("Lookup(%d) after being idle for %v = true, want expired", i, idle)
+			}
+		}
+	}
+	if ef.Count() != 0 {
+		t.Errorf("Count() = %d, want expired items evicted", ef.Count())
+	}
+
+	// Eviction makes room for new items.
+	full := NewExpiringFilter(8, time.Minute)
+	full.now = clock.now
+	for i := 0; i < 8; i++ {
+		full.Insert([]byte(fmt.Sprint(i)))
+	}
+	clock.t = clock.t.Add(2 * time.Minute)
+	for i := 0; i < 8; i++ {
+		if !full.Insert([]byte(fmt.Sprint("new", i))) {
+			t.Fatalf("Insert() after expiry failed")
+		}
+	}
+}
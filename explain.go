@@ -0,0 +1,41 @@
+package cuckoo
+
+// LookupTrace describes how a lookup of an item proceeds, see Explain.
+type LookupTrace struct {
+	// Fingerprint is the fingerprint computed for the item.
+	Fingerprint uint16
+	// Index1 and Index2 are the candidate buckets of the item.
+	Index1, Index2 uint
+	// Found is true if one of the candidate buckets holds the fingerprint, in which case
+	// Bucket and Slot are the index of that bucket and the slot within it.
+	Found        bool
+	Bucket, Slot uint
+}
+
+// Explain returns a trace of the lookup of data, e.g. to debug suspected false positives.
+// Found equals the result of Lookup.
+func (cf *Filter) Explain(data []byte) LookupTrace {
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	trace := LookupTrace{
+		Fingerprint: uint16(fp),
+		Index1:      i1,
+		Index2:      cf.altIndex(fp, i1),
+	}
+	for _, i := range [2]uint{trace.Index1, trace.Index2} {
+		if cf.stale(i) {
+			continue
+		}
+		for j, f := range cf.buckets[i].fingerprints() {
+			if f == fp {
+				trace.Found, trace.Bucket, trace.Slot = true, i, uint(j)
+				return trace
+			}
+		}
+	}
+	return trace
+}
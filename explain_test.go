@@ -0,0 +1,33 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	cf := NewFilter(1000)
+	for i := 0; i < 500; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < 1000; i++ {
+		data := []byte(strconv.Itoa(i))
+		trace := cf.Explain(data)
+		if trace.Found != cf.Lookup(data) {
+			t.Fatalf("Explain(%q).Found = %v, want result of Lookup()", data, trace.Found)
+		}
+		i1, fp := cf.indexAndFingerprint(data)
+		if trace.Index1 != i1 || trace.Fingerprint != uint16(fp) || getAltIndex(fp, trace.Index2, cf.bucketIndexMask) != i1 {
+			t.Fatalf("Explain(%q) = %+v, want fingerprint %d and candidates of index %d", data, trace, fp, i1)
+		}
+		if !trace.Found {
+			continue
+		}
+		if trace.Bucket != trace.Index1 && trace.Bucket != trace.Index2 {
+			t.Fatalf("Explain(%q) matched in bucket %d, want a candidate", data, trace.Bucket)
+		}
+		if got := cf.buckets[trace.Bucket].get(int(trace.Slot)); got != fp {
+			t.Fatalf("Explain(%q) matched slot holding %d, want %d", data, got, fp)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package cuckoo
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// FirstSeenFilter is a filter remembering when each item was first inserted, e.g. to tell how
+// long an identifier has been around. Every fingerprint is stored with a 16-bit timestamp in
+// units of the resolution since the filter was created, using 32 bits per item. Items whose
+// fingerprints collide share the earliest timestamp. It is safe for concurrent use.
+type FirstSeenFilter struct {
+	buckets []bucket
+	// stamps holds the timestamps of the four slots of every bucket.
+	stamps []uint64
+	count  uint
+	// Bit mask set to len(buckets) - 1, which is always a power of 2.
+	bucketIndexMask uint
+	lock            sync.RWMutex
+
+	origin     time.Time
+	resolution time.Duration
+	now        func() time.Time
+}
+
+var _ ApproxSet = (*FirstSeenFilter)(nil)
+
+// NewFirstSeenFilter returns a filter for the given number of elements recording first
+// insertions with the given resolution. Timestamps cover 65535 times the resolution from now
+// on; later insertions are recorded as the end of that range.
+func NewFirstSeenFilter(numElements uint, resolution time.Duration) *FirstSeenFilter {
+	if resolution <= 0 {
+		resolution = time.Second
+	}
+	numBuckets := numBucketsFor(numElements)
+	return &FirstSeenFilter{
+		buckets:         make([]bucket, numBuckets),
+		stamps:          make([]uint64, numBuckets),
+		bucketIndexMask: numBuckets - 1,
+		origin:          time.Now(),
+		resolution:      resolution,
+		now:             time.Now,
+	}
+}
+
+// stampNow returns the timestamp of the current time.
+func (ff *FirstSeenFilter) stampNow() uint16 {
+	units := ff.now().Sub(ff.origin) / ff.resolution
+	switch {
+	case units < 0:
+		return 0
+	case units > math.MaxUint16:
+		return math.MaxUint16
+	}
+	return uint16(units)
+}
+
+func (ff *FirstSeenFilter) stamp(i uint, j int) uint16 {
+	return uint16(ff.stamps[i] >> (16 * j))
+}
+
+func (ff *FirstSeenFilter) setStamp(i uint, j int, stamp uint16) {
+	shift := 16 * j
+	ff.stamps[i] = ff.stamps[i]&^(math.MaxUint16<<shift) | uint64(stamp)<<shift
+}
+
+// find returns the slot of bucket i holding fp, or -1.
+func (ff *FirstSeenFilter) find(fp fingerprint, i uint) int {
+	for j := 0; j < bucketSize; j++ {
+		if ff.buckets[i].get(j) == fp {
+			return j
+		}
+	}
+	return -1
+}
+
+// FirstSeenAround returns when data was first inserted, rounded down to the resolution, and
+// true if data is in the filter.
+func (ff *FirstSeenFilter) FirstSeenAround(data []byte) (time.Time, bool) {
+	i1, fp := getIndexAndFingerprint(data, ff.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, ff.bucketIndexMask)
+
+	ff.lock.RLock()
+	defer ff.lock.RUnlock()
+
+	for _, i := range [2]uint{i1, i2} {
+		if j := ff.find(fp, i); j >= 0 {
+			return ff.origin.Add(time.Duration(ff.stamp(i, j)) * ff.resolution), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Lookup returns true if data is in the filter.
+func (ff *FirstSeenFilter) Lookup(data []byte) bool {
+	_, ok := ff.FirstSeenAround(data)
+	return ok
+}
+
+// Insert data into the filter with the current time, unless it is present already. Returns
+// false if insertion failed, see Filter.Insert.
+func (ff *FirstSeenFilter) Insert(data []byte) bool {
+	i1, fp := getIndexAndFingerprint(data, ff.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, ff.bucketIndexMask)
+	stamp := ff.stampNow()
+
+	ff.lock.Lock()
+	defer ff.lock.Unlock()
+
+	if ff.find(fp, i1) >= 0 || ff.find(fp, i2) >= 0 {
+		return true
+	}
+	if ff.insert(fp, stamp, i1) || ff.insert(fp, stamp, i2) {
+		return true
+	}
+	i := randi(i1, i2)
+	for k := 0; k < maxCuckooKickouts; k++ {
+		j := rand.Intn(bucketSize)
+		oldStamp := ff.stamp(i, j)
+		fp = ff.buckets[i].swap(j, fp)
+		ff.setStamp(i, j, stamp)
+		stamp = oldStamp
+
+		i = getAltIndex(fp, i, ff.bucketIndexMask)
+		if ff.insert(fp, stamp, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// insert puts fp with stamp into a free slot of bucket i.
+func (ff *FirstSeenFilter) insert(fp fingerprint, stamp uint16, i uint) bool {
+	for j := 0; j < bucketSize; j++ {
+		if ff.buckets[i].get(j) == nullFp {
+			ff.buckets[i].set(j, fp)
+			ff.setStamp(i, j, stamp)
+			ff.count++
+			return true
+		}
+	}
+	return false
+}
+
+// Delete data from the filter, forgetting when it was first seen. Returns true if the data
+// was found and deleted.
+func (ff *FirstSeenFilter) Delete(data []byte) bool {
+	i1, fp := getIndexAndFingerprint(data, ff.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, ff.bucketIndexMask)
+
+	ff.lock.Lock()
+	defer ff.lock.Unlock()
+
+	for _, i := range [2]uint{i1, i2} {
+		if j := ff.find(fp, i); j >= 0 {
+			ff.buckets[i].set(j, nullFp)
+			ff.setStamp(i, j, 0)
+			ff.count--
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of items in the filter.
+func (ff *FirstSeenFilter) Count() uint {
+	ff.lock.RLock()
+	defer ff.lock.RUnlock()
+
+	return ff.count
+}
@@ -0,0 +1,48 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFirstSeenFilter(t *testing.T) {
+	ff := NewFirstSeenFilter(1000, time.Minute)
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	ff.now = clock.now
+	ff.origin = clock.t
+
+	for i := 0; i < 900; i++ {
+		// Fill the filter enough for kickouts, which must move timestamps along.
+		ff.Insert([]byte(strconv.Itoa(i)))
+		clock.t = clock.t.Add(time.Minute)
+	}
+	clock.t = time.Unix(1000, 0).Add(time.Hour + 30*time.Second)
+	ff.Insert([]byte("later"))
+	// Inserting again keeps the first timestamp.
+	clock.t = clock.t.Add(time.Hour)
+	ff.Insert([]byte("later"))
+
+	for i := 0; i < 900; i++ {
+		got, ok := ff.FirstSeenAround([]byte(strconv.Itoa(i)))
+		// Colliding fingerprints can only make the timestamp earlier.
+		if want := time.Unix(1000, 0).Add(time.Duration(i) * time.Minute); !ok || got.After(want) {
+			t.Fatalf("FirstSeenAround(%d) = %v, %v, want %v", i, got, ok, want)
+		}
+	}
+	if got, ok := ff.FirstSeenAround([]byte("later")); !ok || !got.Equal(time.Unix(1000, 0).Add(time.Hour)) {
+		t.Errorf("FirstSeenAround(later) = %v, %v, want an hour after creation", got, ok)
+	}
+	if _, ok := ff.FirstSeenAround([]byte("unseen")); ok || ff.Lookup([]byte("unseen")) {
+		t.Errorf("FirstSeenAround(unseen) found an item never inserted")
+	}
+	if !ff.Delete([]byte("later")) || ff.Lookup([]byte("later")) || ff.Count() != 900 {
+		t.Errorf("Delete() did not remove the item, Count() = %d", ff.Count())
+	}
+
+	clock.t = time.Unix(1000, 0).Add(100000 * time.Minute)
+	ff.Insert([]byte("much later"))
+	if got, _ := ff.FirstSeenAround([]byte("much later")); !got.Equal(time.Unix(1000, 0).Add(65535 * time.Minute)) {
+		t.Errorf("FirstSeenAround() beyond the range = %v, want the end of the range", got)
+	}
+}
@@ -0,0 +1,177 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// flatBufferIdentifier is the file identifier of FlatBuffers written by EncodeFlatBuffer.
+var flatBufferIdentifier = encodingMagic
+
+// Fields of the FlatBuffers table written by EncodeFlatBuffer, in the order of the schema.
+const (
+	flatFormatVersion = iota
+	flatHashVersion
+	flatSeed
+	flatBuckets
+	flatAltScheme
+)
+
+// flatBufferHeaderSize is the size of a FlatBuffer written by EncodeFlatBuffer before the
+// buckets.
+const flatBufferHeaderSize = 48
+
+// EncodeFlatBuffer returns cf as a FlatBuffer of the schema
+//
+//	table Filter {
+//	  format_version:ubyte;
+//	  hash_version:ubyte;
+//	  seed:ulong;
+//	  buckets:[ulong];
+//	  alt_scheme:ubyte;
+//	}
+//	root_type Filter;
+//	file_identifier "CKOO";
+//
+// where every bucket holds its 4 fingerprints in order as little-endian uint16, like Encode.
+// NewFlatFilter queries the buffer without decoding it.
+func (cf *Filter) EncodeFlatBuffer() []byte {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	buf := make([]byte, flatBufferHeaderSize, flatBufferHeaderSize+8*len(cf.buckets))
+	// The root table starts at offset 24, after the identifier and the vtable at offset 8.
+	binary.LittleEndian.PutUint32(buf, 24)
+	copy(buf[4:], flatBufferIdentifier[:])
+	// The vtable: its size, the size of the table and the offsets of the fields in the table.
+	for k, v := range []uint16{14, 20, 4, 5, 8, 16, 6} {
+		binary.LittleEndian.PutUint16(buf[8+2*k:], v)
+	}
+	// The table: the offset to the vtable, the versions, the scheme, padding, the seed, and the
+	// offset to the vector of buckets, whose elements are aligned to 8 bytes at offset 48.
+	binary.LittleEndian.PutUint32(buf[24:], 24-8)
+	buf[28] = CurrentFormatVersion
+	buf[29] = cf.hashVersion
+	buf[30] = uint8(cf.altScheme)
+	binary.LittleEndian.PutUint64(buf[32:], cf.seed)
+	binary.LittleEndian.PutUint32(buf[40:], 4)
+	binary.LittleEndian.PutUint32(buf[44:], uint32(len(cf.buckets)))
+	return cf.appendBuckets(buf, 0, len(cf.buckets))
+}
+
+// FlatFilter is a read-only filter querying a FlatBuffer written by EncodeFlatBuffer in place,
+// without decoding or copying it, like StaticFilter. It is safe for concurrent use if the
+// buffer is not modified.
+type FlatFilter struct {
+	StaticFilter
+}
+
+// NewFlatFilter returns a FlatFilter querying buf, which is validated but not copied. Buffers
+// written by other FlatBuffers implementations of the same schema are accepted.
+func NewFlatFilter(buf []byte) (*FlatFilter, error) {
+	table, err := parseFlatTable(buf)
+	if err != nil {
+		return nil, err
+	}
+	if v := table.uint8(flatFormatVersion); !supportedFormatVersion(v) {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrIncompatible, v)
+	}
+	if v := table.uint8(flatHashVersion); !supportedHashVersion(v) {
+		return nil, fmt.Errorf("%w: unsupported hash version %d", ErrIncompatible, v)
+	}
+	altScheme := AltIndexScheme(table.uint8(flatAltScheme))
+	if !supportedAltIndexScheme(altScheme) {
+		return nil, fmt.Errorf("%w: unsupported alternate index scheme %d", ErrIncompatible, altScheme)
+	}
+	buckets, err := table.vector(flatBuckets, 8)
+	if err != nil {
+		return nil, err
+	}
+	ff := &FlatFilter{StaticFilter{seed: table.uint64(flatSeed), hashVersion: table.uint8(flatHashVersion), altScheme: altScheme}}
+	if err := ff.setBuckets(buckets); err != nil {
+		return nil, err
+	}
+	return ff, nil
+}
+
+// flatTable is a validated FlatBuffers table.
+type flatTable struct {
+	buf []byte
+	// pos and vtable are the offsets of the table and its vtable in buf.
+	pos, vtable int
+}
+
+// parseFlatTable returns the root table of buf, which must have the identifier of filters.
+func parseFlatTable(buf []byte) (flatTable, error) {
+	if len(buf) < 8 || string(buf[4:8]) != string(flatBufferIdentifier[:]) {
+		return flatTable{}, fmt.Errorf("%w: not a FlatBuffer of a filter", ErrCorrupted)
+	}
+	pos := uint64(binary.LittleEndian.Uint32(buf))
+	if pos+4 > uint64(len(buf)) {
+		return flatTable{}, fmt.Errorf("%w: root table out of range", ErrCorrupted)
+	}
+	vtable := int64(pos) - int64(int32(binary.LittleEndian.Uint32(buf[pos:])))
+	if vtable < 0 || vtable+4 > int64(len(buf)) {
+		return flatTable{}, fmt.Errorf("%w: vtable out of range", ErrCorrupted)
+	}
+	t := flatTable{buf: buf, pos: int(pos), vtable: int(vtable)}
+	vtableSize, tableSize := t.vtableEntry(0), t.vtableEntry(1)
+	if vtableSize < 4 || vtableSize%2 != 0 || t.vtable+vtableSize > len(buf) || tableSize < 4 || t.pos+tableSize > len(buf) {
+		return flatTable{}, fmt.Errorf("%w: invalid vtable", ErrCorrupted)
+	}
+	for field := 0; 4+2*field < vtableSize; field++ {
+		if off := t.vtableEntry(2 + field); off != 0 && (off < 4 || off >= tableSize) {
+			return flatTable{}, fmt.Errorf("%w: field %d out of range", ErrCorrupted, field)
+		}
+	}
+	return t, nil
+}
+
+func (t flatTable) vtableEntry(k int) int {
+	return int(binary.LittleEndian.Uint16(t.buf[t.vtable+2*k:]))
+}
+
+// field returns the offset of field in buf and whether it has size bytes, or 0 if the field
+// is absent and has its default value.
+func (t flatTable) field(field, size int) (int, bool) {
+	if 4+2*field+2 > t.vtableEntry(0) {
+		return 0, true
+	}
+	off := t.vtableEntry(2 + field)
+	if off == 0 {
+		return 0, true
+	}
+	return t.pos + off, off+size <= t.vtableEntry(1)
+}
+
+func (t flatTable) uint8(field int) uint8 {
+	if off, ok := t.field(field, 1); off != 0 && ok {
+		return t.buf[off]
+	}
+	return 0
+}
+
+func (t flatTable) uint64(field int) uint64 {
+	if off, ok := t.field(field, 8); off != 0 && ok {
+		return binary.LittleEndian.Uint64(t.buf[off:])
+	}
+	return 0
+}
+
+// vector returns the elements of the vector in field, which are of the given size.
+func (t flatTable) vector(field, size int) ([]byte, error) {
+	off, ok := t.field(field, 4)
+	if off == 0 || !ok {
+		return nil, fmt.Errorf("%w: missing vector field %d", ErrCorrupted, field)
+	}
+	start := uint64(off) + uint64(binary.LittleEndian.Uint32(t.buf[off:]))
+	if start+4 > uint64(len(t.buf)) {
+		return nil, fmt.Errorf("%w: vector field %d out of range", ErrCorrupted, field)
+	}
+	n := uint64(binary.LittleEndian.Uint32(t.buf[start:]))
+	start += 4
+	if n*uint64(size) > uint64(len(t.buf))-start {
+		return nil, fmt.Errorf("%w: vector field %d out of range", ErrCorrupted, field)
+	}
+	return t.buf[start : start+n*uint64(size)], nil
+}
@@ -0,0 +1,91 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestFlatFilter(t *testing.T) {
+	cf := NewFilter(1000, WithHashSeed(3))
+	for i := 0; i < 500; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	buf := cf.EncodeFlatBuffer()
+	if got, want := len(buf), flatBufferHeaderSize+8*len(cf.buckets); got != want {
+		t.Errorf("len(EncodeFlatBuffer()) = %d, want %d", got, want)
+	}
+	ff, err := NewFlatFilter(buf)
+	if err != nil {
+		t.Fatalf("NewFlatFilter() = %v", err)
+	}
+	if ff.Cap() != cf.Cap() {
+		t.Errorf("Cap() = %d, want %d", ff.Cap(), cf.Cap())
+	}
+	for i := 0; i < 2000; i++ {
+		data := []byte(strconv.Itoa(i))
+		if got, want := ff.Lookup(data), cf.Lookup(data); got != want {
+			t.Fatalf("Lookup(%q) = %v, want %v", data, got, want)
+		}
+	}
+}
+
+func TestNewFlatFilter_OtherLayout(t *testing.T) {
+	cf := newFilter(make([]bucket, 2))
+	cf.seed = 0
+	cf.Insert([]byte("one"))
+
+	// A buffer with the table first, then the vtable, then the vector, and the seed omitted,
+	// which means 0.
+	buf := make([]byte, 56)
+	binary.LittleEndian.PutUint32(buf, 8)
+	copy(buf[4:], "CKOO")
+	// The table at 8, with the vtable at 20 and the vector at 36.
+	binary.LittleEndian.PutUint32(buf[8:], uint32(0x100000000-12))
+	buf[12] = 1
+	buf[13] = CurrentFormatVersion
+	binary.LittleEndian.PutUint32(buf[16:], 36-16)
+	for k, v := range []uint16{12, 12, 5, 4, 0, 8} {
+		binary.LittleEndian.PutUint16(buf[20+2*k:], v)
+	}
+	binary.LittleEndian.PutUint32(buf[36:], 2)
+	binary.LittleEndian.PutUint64(buf[40:], uint64(cf.buckets[0]))
+	binary.LittleEndian.PutUint64(buf[48:], uint64(cf.buckets[1]))
+
+	ff, err := NewFlatFilter(buf)
+	if err != nil {
+		t.Fatalf("NewFlatFilter() = %v", err)
+	}
+	if !ff.Lookup([]byte("one")) {
+		t.Errorf("Lookup() = false, want true")
+	}
+}
+
+func TestNewFlatFilter_Invalid(t *testing.T) {
+	valid := NewFilter(10).EncodeFlatBuffer()
+	newer := append([]byte(nil), valid...)
+	newer[28] = CurrentFormatVersion + 1
+	for _, tc := range []struct {
+		name string
+		buf  []byte
+		want error
+	}{
+		{"empty", nil, ErrCorrupted},
+		{"wrong identifier", append([]byte{24, 0, 0, 0, 'X'}, valid[5:]...), ErrCorrupted},
+		{"truncated", valid[:len(valid)-1], ErrCorrupted},
+		{"newer format", newer, ErrIncompatible},
+	} {
+		if _, err := NewFlatFilter(tc.buf); !errors.Is(err, tc.want) {
+			t.Errorf("NewFlatFilter(%s) = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	// Damaging any byte must not panic.
+	for n := range valid {
+		damaged := append([]byte(nil), valid...)
+		damaged[n] ^= 0xff
+		if ff, err := NewFlatFilter(damaged); err == nil {
+			ff.Lookup([]byte("one"))
+		}
+	}
+}
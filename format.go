@@ -0,0 +1,192 @@
+package cuckoo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	metro "github.com/dgryski/go-metro"
+)
+
+// encodingMagic starts the header written by Encode.
+var encodingMagic = [4]byte{'C', 'K', 'O', 'O'}
+
+// Versions of the encoding and of the hashing of keys. Hashing versions define how keys map to
+// buckets and fingerprints. Existing versions never change: a new derivation gets a new
+// version, and filters of all older versions stay queryable after decoding them.
+const (
+	// CurrentFormatVersion is the version of the encoding written by Encode. Version 0 is the
+	// encoding without header of older releases, version 1 has a header without the number of
+	// items and version 2 adds it, see EncodedCount. Filters with stashed items are encoded
+	// with version 3, see WithOverflowStash.
+	CurrentFormatVersion = 2
+	// CurrentHashVersion is the hashing version of filters created by NewFilter.
+	// Version 1 hashes keys with 64-bit metro hash and the hash seed, using the low bits for
+	// the bucket index and the high bits for the fingerprint.
+	CurrentHashVersion = 1
+)
+
+const (
+	// encodingHeaderSize is the size of the header written by Encode: magic, format version,
+	// hash version, alternate index scheme, a reserved byte, the hash seed and the number of
+	// items. Encodings of releases before WithAltIndexScheme have the scheme byte zero, which
+	// is AltIndexXOR.
+	encodingHeaderSize = 24
+	// encodingHeaderSizeV1 is the size of the header of format version 1, which lacks the
+	// number of items.
+	encodingHeaderSizeV1 = 16
+	// defaultHashSeed is the seed used for hashing keys unless WithHashSeed is given.
+	defaultHashSeed = 1337
+)
+
+// WithHashSeed hashes keys with the given seed instead of the default one. Filters with
+// different seeds place the same key differently, so an adversary who does not know the seed
+// cannot craft keys that collide. Encode records the seed and Decode restores it.
+func WithHashSeed(seed uint64) Option {
+	return func(cf *Filter) {
+		cf.seed = seed
+	}
+}
+
+// HashSeed returns the seed keys are hashed with.
+func (cf *Filter) HashSeed() uint64 {
+	return cf.seed
+}
+
+// HashVersion returns the hashing version of the filter, see CurrentHashVersion.
+func (cf *Filter) HashVersion() int {
+	return int(cf.hashVersion)
+}
+
+// FormatVersion returns the version of the encoding the filter was decoded from, or
+// CurrentFormatVersion for filters that were not decoded.
+func (cf *Filter) FormatVersion() int {
+	return int(cf.formatVersion)
+}
+
+// supportedFormatVersion returns true if encodings with header of format version v can be
+// decoded.
+func supportedFormatVersion(v uint8) bool {
+	return v == 1 || v == CurrentFormatVersion
+}
+
+// supportedHashVersion returns true if keys can be hashed with hashing version v.
+func supportedHashVersion(v uint8) bool {
+	return v == 1
+}
+
+// indexAndFingerprint returns the primary bucket index and fingerprint of data.
+func (cf *core) indexAndFingerprint(data []byte) (uint, fingerprint) {
+	return versionedIndexAndFingerprint(cf.hashVersion, cf.seed, data, cf.bucketIndexMask)
+}
+
+// versionedIndexAndFingerprint returns the primary bucket index and fingerprint of data with
+// hashing version hashVersion and the given seed.
+func versionedIndexAndFingerprint(hashVersion uint8, seed uint64, data []byte, bucketIndexMask uint) (uint, fingerprint) {
+	return getIndexAndFingerprintFromHash(versionedHash(hashVersion, seed, data), bucketIndexMask)
+}
+
+// versionedHash returns the 64-bit hash of data with hashing version hashVersion and the given
+// seed, from which bucket index and fingerprint are derived.
+func versionedHash(hashVersion uint8, seed uint64, data []byte) uint64 {
+	// Add new hashing versions as cases, keeping all existing ones.
+	switch hashVersion {
+	default:
+		return metro.Hash64(data, seed)
+	}
+}
+
+// appendHeader appends the encoding header of cf to bytes.
+func (cf *core) appendHeader(bytes []byte) []byte {
+	var header [encodingHeaderSize]byte
+	copy(header[:], encodingMagic[:])
+	header[4] = CurrentFormatVersion
+	header[5] = cf.hashVersion
+	header[6] = uint8(cf.altScheme)
+	binary.LittleEndian.PutUint64(header[8:], cf.seed)
+	binary.LittleEndian.PutUint64(header[16:], uint64(cf.count))
+	return append(bytes, header[:]...)
+}
+
+// hasEncodingHeader returns true if encoded starts with the header written by Encode.
+// Encodings without header, written by older versions, hold only buckets: as 16 bytes of
+// buckets must start with the magic, a known alternate index scheme and a zero reserved byte
+// to be mistaken for a header, this is practically unambiguous.
+func hasEncodingHeader(encoded []byte) bool {
+	return len(encoded) >= encodingHeaderSizeV1 && bytes.Equal(encoded[:4], encodingMagic[:]) && supportedAltIndexScheme(AltIndexScheme(encoded[6])) && encoded[7] == 0
+}
+
+// encodingHeader is a parsed encoding header.
+type encodingHeader struct {
+	formatVersion, hashVersion uint8
+	altScheme                  AltIndexScheme
+	seed                       uint64
+	// count is the number of items, if hasCount is set.
+	count    uint64
+	hasCount bool
+	// hasStash is set if the buckets are followed by a stash section, see appendStash.
+	hasStash bool
+	// size is the size of the header.
+	size int
+}
+
+// parseEncodingHeader parses the header of encoded, which must start with one, see
+// hasEncodingHeader.
+func parseEncodingHeader(encoded []byte) (encodingHeader, error) {
+	h := encodingHeader{formatVersion: encoded[4], hashVersion: encoded[5], altScheme: AltIndexScheme(encoded[6]), size: encodingHeaderSizeV1}
+	if !supportedFormatVersion(h.formatVersion) && h.formatVersion != stashFormatVersion {
+		return h, fmt.Errorf("%w: unsupported format version %d", ErrIncompatible, h.formatVersion)
+	}
+	if !supportedHashVersion(h.hashVersion) {
+		return h, fmt.Errorf("%w: unsupported hash version %d", ErrIncompatible, h.hashVersion)
+	}
+	h.seed = binary.LittleEndian.Uint64(encoded[8:])
+	if h.formatVersion >= 2 {
+		if len(encoded) < encodingHeaderSize {
+			return h, fmt.Errorf("%w: truncated header", ErrCorrupted)
+		}
+		h.count, h.hasCount, h.size = binary.LittleEndian.Uint64(encoded[16:]), true, encodingHeaderSize
+	}
+	h.hasStash = h.formatVersion == stashFormatVersion
+	return h, nil
+}
+
+// decodeHeader applies the encoding header to cf and returns it.
+func (cf *Filter) decodeHeader(encoded []byte) (encodingHeader, error) {
+	h, err := parseEncodingHeader(encoded)
+	if err != nil {
+		return h, err
+	}
+	cf.formatVersion = h.formatVersion
+	cf.hashVersion = h.hashVersion
+	cf.altScheme = h.altScheme
+	cf.seed = h.seed
+	return h, nil
+}
+
+// EncodedCount returns the number of items of a filter encoded by Encode. For the current
+// format, it reads the header only and takes constant time; encodings of older versions are
+// counted slot by slot.
+func EncodedCount(encoded []byte) (uint, error) {
+	if hasEncodingHeader(encoded) {
+		h, err := parseEncodingHeader(encoded)
+		if err != nil {
+			return 0, err
+		}
+		if h.hasCount {
+			if h.count > maxBuckets*bucketSize {
+				return 0, fmt.Errorf("%w: header records %d items", ErrCorrupted, h.count)
+			}
+			return uint(h.count), nil
+		}
+		if h.hasStash {
+			return 0, fmt.Errorf("%w: stash without count", ErrCorrupted)
+		}
+		encoded = encoded[h.size:]
+	}
+	cf := newFilter(nil)
+	if err := cf.decodeBuckets(encoded); err != nil {
+		return 0, err
+	}
+	return cf.count, nil
+}
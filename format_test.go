@@ -0,0 +1,113 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestWithHashSeed(t *testing.T) {
+	cf := NewFilter(1000, WithHashSeed(42))
+	other := NewFilter(1000)
+	cf.Insert([]byte("one"))
+	other.Insert([]byte("one"))
+	if i, _ := cf.indexAndFingerprint([]byte("one")); cf.buckets[i] == other.buckets[i] {
+		t.Errorf("Filters with different seeds stored key in the same bucket")
+	}
+
+	got, err := Decode(cf.Encode())
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if got.HashSeed() != 42 || !got.Lookup([]byte("one")) {
+		t.Errorf("Decode(Encode()) has seed %d, want 42", got.HashSeed())
+	}
+	if _, _, err := Diff(cf, other); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Diff() of filters with different seeds error = %v, want %v", err, ErrIncompatible)
+	}
+}
+
+func TestDecode_Headerless(t *testing.T) {
+	cf := NewFilter(1000)
+	cf.Insert([]byte("one"))
+	legacy := cf.Encode()[encodingHeaderSize:]
+	got, err := Decode(legacy)
+	if err != nil {
+		t.Fatalf("Decode() of headerless encoding = %v", err)
+	}
+	if got.HashSeed() != defaultHashSeed || !got.Lookup([]byte("one")) || got.Count() != 1 {
+		t.Errorf("Decode() of headerless encoding lost items")
+	}
+
+	if seed := binary.LittleEndian.Uint64(cf.Encode()[8:]); seed != defaultHashSeed {
+		t.Errorf("Encode() recorded seed %d, want %d", seed, defaultHashSeed)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	cf := NewFilter(100)
+	if cf.HashVersion() != CurrentHashVersion || cf.FormatVersion() != CurrentFormatVersion {
+		t.Errorf("NewFilter() has versions %d, %d, want %d, %d", cf.HashVersion(), cf.FormatVersion(), CurrentHashVersion, CurrentFormatVersion)
+	}
+	legacy, err := Decode(cf.Encode()[encodingHeaderSize:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy.HashVersion() != 1 || legacy.FormatVersion() != 0 {
+		t.Errorf("Decode() of headerless encoding has versions %d, %d, want 1, 0", legacy.HashVersion(), legacy.FormatVersion())
+	}
+	if got := legacy.Encode(); got[4] != CurrentFormatVersion || got[5] != 1 {
+		t.Errorf("Encode() of decoded filter wrote versions %d, %d", got[4], got[5])
+	}
+
+	unknown := cf.Encode()
+	unknown[5] = 99
+	if _, err := Decode(unknown); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Decode() with unknown hash version error = %v, want %v", err, ErrIncompatible)
+	}
+}
+
+// encodeV1 returns the encoding of cf in format version 1, without the number of items.
+func encodeV1(cf *Filter) []byte {
+	encoded := cf.Encode()
+	v1 := append([]byte(nil), encoded[:encodingHeaderSizeV1]...)
+	v1[4] = 1
+	return append(v1, encoded[encodingHeaderSize:]...)
+}
+
+func TestEncodedCount(t *testing.T) {
+	cf := NewFilter(1000)
+	for i := 0; i < 300; i++ {
+		cf.Insert([]byte{byte(i), byte(i >> 8)})
+	}
+	encoded := cf.Encode()
+	if got := binary.LittleEndian.Uint64(encoded[16:]); got != uint64(cf.Count()) {
+		t.Errorf("count in header = %d, want %d", got, cf.Count())
+	}
+	for name, enc := range map[string][]byte{
+		"current":    encoded,
+		"version 1":  encodeV1(cf),
+		"headerless": encoded[encodingHeaderSize:],
+	} {
+		if n, err := EncodedCount(enc); n != cf.Count() || err != nil {
+			t.Errorf("EncodedCount(%s) = %d, %v, want %d", name, n, err, cf.Count())
+		}
+		decoded, err := Decode(enc)
+		if err != nil || decoded.Count() != cf.Count() {
+			t.Errorf("Decode(%s) = %v with count %d", name, err, decoded.Count())
+		}
+	}
+	// The header alone is enough for the current format.
+	if n, err := EncodedCount(encoded[:encodingHeaderSize]); n != cf.Count() || err != nil {
+		t.Errorf("EncodedCount(header) = %d, %v, want %d", n, err, cf.Count())
+	}
+
+	wrong := append([]byte(nil), encoded...)
+	binary.LittleEndian.PutUint64(wrong[16:], 7)
+	if _, err := Decode(wrong); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Decode() with wrong count = %v, want %v", err, ErrCorrupted)
+	}
+	if _, err := Decode(encoded[:encodingHeaderSizeV1+2]); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Decode() of truncated header = %v, want %v", err, ErrCorrupted)
+	}
+}
@@ -0,0 +1,64 @@
+package cuckoo
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// loadProgressChunk is the number of bytes LoadFromFileWithProgress reads between reports.
+const loadProgressChunk = 4 << 20
+
+// DecodeFS returns a Cuckoofilter from the file at path in fsys, which was created using
+// Encode, e.g. by the cuckoogen command. Together with go:embed, this ships a prebuilt filter
+// inside a binary:
+//
+//	//go:embed passwords.cuckoo
+//	var assets embed.FS
+//
+//	cf, err := cuckoo.DecodeFS(assets, "passwords.cuckoo")
+func DecodeFS(fsys fs.FS, path string) (*Filter, error) {
+	bytes, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(bytes)
+}
+
+// LoadFromFileWithProgress returns a Cuckoofilter from the file at path, which was created
+// using Encode. While reading, it calls progress with the number of bytes read so far and the
+// size of the file, every 4MiB and once the file is read completely, so services loading
+// large filters at startup can report progress to health checks. Progress may be nil.
+func LoadFromFileWithProgress(path string, progress func(done, total int64), opts ...Option) (*Filter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	total := info.Size()
+	if total > int64(maxInt) {
+		return nil, fmt.Errorf("%w: file of %d bytes", ErrTooLarge, total)
+	}
+	bytes := make([]byte, total)
+	var done int64
+	for done < total {
+		end := done + loadProgressChunk
+		if end > total {
+			end = total
+		}
+		n, err := io.ReadFull(f, bytes[done:end])
+		done += int64(n)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+	return Decode(bytes, opts...)
+}
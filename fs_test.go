@@ -0,0 +1,42 @@
+package cuckoo
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestLoadFromFileWithProgress(t *testing.T) {
+	cf := NewFilter(5000000)
+	for i := 0; i < 1000; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	encoded := cf.Encode()
+	path := filepath.Join(t.TempDir(), "filter")
+	if err := ioutil.WriteFile(path, encoded, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []int64
+	got, err := LoadFromFileWithProgress(path, func(done, total int64) {
+		if total != int64(len(encoded)) {
+			t.Errorf("progress total = %d, want %d", total, len(encoded))
+		}
+		reports = append(reports, done)
+	})
+	if err != nil {
+		t.Fatalf("LoadFromFileWithProgress() = %v", err)
+	}
+	if got.Count() != 1000 || !got.Lookup([]byte("999")) {
+		t.Errorf("loaded Count(), Lookup(999) = %d, %v, want 1000, true", got.Count(), got.Lookup([]byte("999")))
+	}
+	wantReports := (len(encoded) + loadProgressChunk - 1) / loadProgressChunk
+	if len(reports) != wantReports || reports[len(reports)-1] != int64(len(encoded)) {
+		t.Errorf("progress reports = %v, want %d ending at %d", reports, wantReports, len(encoded))
+	}
+
+	if _, err := LoadFromFileWithProgress(filepath.Join(t.TempDir(), "missing"), nil); err == nil {
+		t.Errorf("LoadFromFileWithProgress(missing file) succeeded")
+	}
+}
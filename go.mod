@@ -1,6 +1,6 @@
 module github.com/chenny7/cuckoofilter
 
-go 1.15
+go 1.18
 
 require (
 	github.com/dgryski/go-metro v0.0.0-20200812162917-85c65e2d0165
@@ -0,0 +1,85 @@
+package cuckoo
+
+// OpOption configures a single operation such as InsertOpt.
+type OpOption func(*opConfig)
+
+type opConfig struct {
+	maxKickouts int
+}
+
+// WithMaxKickouts limits the number of fingerprints an insert may kick out of their bucket
+// to make room. Fewer kickouts bound the latency of an insert, but make it fail earlier as
+// the filter fills up.
+func WithMaxKickouts(n int) OpOption {
+	return func(c *opConfig) {
+		if n < 0 {
+			n = 0
+		}
+		c.maxKickouts = n
+	}
+}
+
+// WithNoKickout only inserts if one of the two candidate buckets has a free slot.
+// It is equivalent to WithMaxKickouts(0).
+func WithNoKickout() OpOption {
+	return WithMaxKickouts(0)
+}
+
+// InsertOpt inserts data into the filter like Insert, configured by opts. Returns false if
+// insertion failed. Unlike Insert, a failed InsertOpt leaves the filter unchanged: fingerprints
+// kicked out on the way are moved back.
+func (cf *Filter) InsertOpt(data []byte, opts ...OpOption) bool {
+	c := opConfig{maxKickouts: maxCuckooKickouts}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+	i2 := cf.altIndex(fp, i1)
+
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	ok := !cf.atLimit() && (cf.insert(fp, i1) || cf.insert(fp, i2) || cf.reinsertOrUndo(fp, cf.randi(i1, i2), c.maxKickouts))
+	if ok {
+		cf.debug.recordInsert(data)
+		cf.distinct.addKey(data)
+		cf.journal.recordInsert(fp, i1, cf.debug.retain(data))
+	} else {
+		cf.log.insertFailed(cf.count, cf.Cap())
+	}
+	cf.auditKey(AuditInsert, data, ok)
+	return ok
+}
+
+// reinsertOrUndo is like reinsert with at most maxKickouts kickouts, but restores all kicked
+// out fingerprints if it fails. Like reinsert, it gives up early on cycles.
+func (cf *Filter) reinsertOrUndo(fp fingerprint, i uint, maxKickouts int) bool {
+	type slot struct {
+		i uint
+		j int
+	}
+	var path []slot
+	var cycle kickoutCycle
+	for k := 0; k < maxKickouts; k++ {
+		j := cf.intn(bucketSize)
+		if k >= cycleCheckAfter && cycle.closed(&cf.core, i, j) {
+			break
+		}
+		cf.summarize(i, fp)
+		fp = cf.buckets[i].swap(j, fp)
+		cf.kickouts++
+		path = append(path, slot{i, j})
+
+		i = cf.altIndex(fp, i)
+		if cf.insert(fp, i) {
+			return true
+		}
+	}
+	// Walk the chain back, returning every fingerprint to the slot it was kicked out of.
+	for k := len(path) - 1; k >= 0; k-- {
+		s := path[k]
+		fp = cf.buckets[s.i].swap(s.j, fp)
+	}
+	return false
+}
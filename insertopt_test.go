@@ -0,0 +1,39 @@
+package cuckoo
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestInsertOpt(t *testing.T) {
+	cf := NewFilter(1 << 10)
+	for i := 0; ; i++ {
+		if !cf.InsertOpt([]byte(fmt.Sprint(i)), WithNoKickout()) {
+			break
+		}
+	}
+	noKickout := cf.Count()
+
+	cf.Reset()
+	for i := 0; ; i++ {
+		if !cf.InsertOpt([]byte(fmt.Sprint(i))) {
+			break
+		}
+	}
+	if full := cf.Count(); full <= noKickout {
+		t.Errorf("InsertOpt() with kickouts stored %d items, want more than %d without kickouts", full, noKickout)
+	}
+
+	// A failed InsertOpt leaves the filter unchanged.
+	before := cf.Encode()
+	for i := 0; i < 100; i++ {
+		if cf.InsertOpt([]byte(fmt.Sprint("more", i)), WithMaxKickouts(10)) {
+			before = cf.Encode()
+			continue
+		}
+		if !bytes.Equal(cf.Encode(), before) {
+			t.Fatalf("Failed InsertOpt() changed the filter")
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package cuckoo
+
+import "fmt"
+
+// validate returns an error wrapping ErrInvalidState if the state of cf violates an invariant
+// that operations rely on.
+func (cf *core) validate() error {
+	numBuckets := uint(len(cf.buckets))
+	if numBuckets == 0 || getNextPow2(uint64(numBuckets)) != numBuckets {
+		return fmt.Errorf("%w: %d buckets is not a power of 2", ErrInvalidState, numBuckets)
+	}
+	if cf.bucketIndexMask != numBuckets-1 {
+		return fmt.Errorf("%w: bucket index mask %#x for %d buckets", ErrInvalidState, cf.bucketIndexMask, numBuckets)
+	}
+	if cf.generations != nil && uint(len(cf.generations)) != numBuckets {
+		return fmt.Errorf("%w: %d generations for %d buckets", ErrInvalidState, len(cf.generations), numBuckets)
+	}
+	if cf.summary != nil && uint(len(cf.summary)) != numBuckets {
+		return fmt.Errorf("%w: %d summaries for %d buckets", ErrInvalidState, len(cf.summary), numBuckets)
+	}
+	var count uint
+	for i, b := range cf.buckets {
+		if cf.stale(uint(i)) {
+			continue
+		}
+		count += uint(bucketSize - b.free())
+		if s := summaryOf(b); cf.summary != nil && s&^cf.summary[i] != 0 {
+			return fmt.Errorf("%w: summary %#x of bucket %d misses %#x", ErrInvalidState, cf.summary[i], i, s)
+		}
+	}
+	count += uint(cf.stash.len())
+	if count != cf.count {
+		return fmt.Errorf("%w: count is %d, but %d slots are occupied", ErrInvalidState, cf.count, count)
+	}
+	return nil
+}
+
+// checkInvariants panics if the state of cf is invalid. It is a no-op unless built with the
+// cuckoodebug build tag.
+func (cf *core) checkInvariants() {
+	if !invariantChecks {
+		return
+	}
+	if err := cf.validate(); err != nil {
+		panic(err)
+	}
+}
+
+// recoverInvalidState turns a panic into an error wrapping ErrInvalidState. It is deferred by
+// entry points that parse untrusted input, as a last line of defense behind their validation.
+func recoverInvalidState(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: %v", ErrInvalidState, r)
+	}
+}
+
+// Validate returns an error wrapping ErrInvalidState if the state of the filter is invalid,
+// e.g. because the memory of its Store was modified externally. Operations on an invalid
+// filter do not panic, but may give wrong results.
+func (cf *Filter) Validate() error {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	return cf.validate()
+}
+
+// Validate returns an error wrapping ErrInvalidState if the state of the filter is invalid,
+// see Filter.Validate.
+func (cf *UnsyncFilter) Validate() error {
+	return cf.validate()
+}
@@ -0,0 +1,6 @@
+//go:build cuckoodebug
+
+package cuckoo
+
+// invariantChecks enables checking the invariants after every modification.
+const invariantChecks = true
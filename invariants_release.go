@@ -0,0 +1,7 @@
+//go:build !cuckoodebug
+
+package cuckoo
+
+// invariantChecks enables checking the invariants after every modification, see the
+// cuckoodebug build tag.
+const invariantChecks = false
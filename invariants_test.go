@@ -0,0 +1,136 @@
+package cuckoo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cf := NewFilter(1000)
+	for i := 0; i < 500; i++ {
+		cf.Insert([]byte{byte(i), byte(i >> 8)})
+	}
+	cf.ResetFast()
+	cf.Insert([]byte("a"))
+	if err := cf.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	cf.count++
+	if err := cf.Validate(); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Validate() with wrong count = %v, want ErrInvalidState", err)
+	}
+	cf.count = 0
+	if !cf.Delete([]byte("a")) || cf.Count() != 0 {
+		t.Errorf("Delete with zero count gave count %d", cf.Count())
+	}
+	cf.bucketIndexMask >>= 1
+	if err := cf.Validate(); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Validate() with wrong mask = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestDecodeInvalidGeometry(t *testing.T) {
+	for _, size := range []int{0, 4, 12, 24, 8 * 3} {
+		if _, err := Decode(make([]byte, size)); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("Decode of %d bytes = %v, want ErrCorrupted", size, err)
+		}
+	}
+}
+
+func TestRecoverInvalidState(t *testing.T) {
+	err := func() (err error) {
+		defer recoverInvalidState(&err)
+		var buckets []bucket
+		_ = buckets[1]
+		return nil
+	}()
+	if !errors.Is(err, ErrInvalidState) {
+		t.Errorf("recovered error = %v, want ErrInvalidState", err)
+	}
+}
+
+// fuzzFilter returns a filter for the seed corpus of the fuzz tests.
+func fuzzFilter() *Filter {
+	cf := NewFilter(100)
+	for i := 0; i < 50; i++ {
+		cf.Insert([]byte{byte(i)})
+	}
+	return cf
+}
+
+// checkDecoded checks that a filter decoded from fuzzed input is valid and stays valid when
+// used. It does nothing if decoding failed.
+func checkDecoded(t *testing.T, cf *Filter, err error) {
+	if err != nil {
+		return
+	}
+	if err := cf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		key := []byte{byte(i)}
+		cf.Lookup(key)
+		cf.Insert(key)
+		cf.Delete(key)
+	}
+	cf.Shrink()
+	if err := cf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	cf := fuzzFilter()
+	f.Add(cf.Encode())
+	f.Add(cf.Encode()[encodingHeaderSize:])
+	f.Fuzz(func(t *testing.T, encoded []byte) {
+		cf, err := Decode(encoded)
+		checkDecoded(t, cf, err)
+	})
+}
+
+func FuzzLoadCSV(f *testing.F) {
+	var buf bytes.Buffer
+	fuzzFilter().DumpCSV(&buf)
+	f.Add(buf.Bytes())
+	f.Add([]byte("# buckets=1152921504606846976\nbucket,slot,fingerprint\n"))
+	f.Fuzz(func(t *testing.T, dump []byte) {
+		cf, err := LoadCSV(bytes.NewReader(dump))
+		checkDecoded(t, cf, err)
+	})
+}
+
+func FuzzLoadParquet(f *testing.F) {
+	var buf bytes.Buffer
+	fuzzFilter().DumpParquet(&buf)
+	f.Add(buf.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cf, err := LoadParquet(data)
+		checkDecoded(t, cf, err)
+	})
+}
+
+func FuzzRestoreFrom(f *testing.F) {
+	var buf bytes.Buffer
+	fuzzFilter().SnapshotTo(context.Background(), &buf)
+	f.Add(buf.Bytes())
+	f.Fuzz(func(t *testing.T, snapshot []byte) {
+		cf, err := RestoreFrom(context.Background(), bytes.NewReader(snapshot))
+		checkDecoded(t, cf, err)
+	})
+}
+
+func FuzzKVPersisterLoad(f *testing.F) {
+	p := NewKVPersister([]byte("filter/"))
+	kv := &mapKV{values: make(map[string][]byte)}
+	p.Save(kv, fuzzFilter())
+	f.Add(kv.values[string(p.metaKey())], kv.values[string(p.chunkKey(0))])
+	f.Fuzz(func(t *testing.T, meta, chunk []byte) {
+		kv := &mapKV{values: map[string][]byte{string(p.metaKey()): meta, string(p.chunkKey(0)): chunk}}
+		cf, err := NewKVPersister([]byte("filter/")).Load(kv)
+		checkDecoded(t, cf, err)
+	})
+}
@@ -0,0 +1,104 @@
+package cuckoo
+
+import "fmt"
+
+// WithJournal records the last size inserts and deletes, so they can be reverted with
+// RollbackLast, e.g. when a pipeline detects a bad batch after inserting it. Every entry takes
+// a few bytes, plus a copy of the item if misuse detection is enabled.
+func WithJournal(size int) Option {
+	return func(cf *Filter) {
+		cf.journal = &opJournal{entries: make([]journalEntry, size)}
+	}
+}
+
+// RollbackLast reverts the last n recorded inserts and deletes, most recent first, and returns
+// the number of reverted operations. Reverting an insert deletes the fingerprint again, also
+// from the overflow stash; if it is no longer stored, e.g. because a failed insert dropped it,
+// RollbackLast stops with an error wrapping ErrInvalidState and the insert is discarded from
+// the journal. Reverting a delete inserts it again, which fails with an error wrapping ErrFull
+// if the filter has filled up since; the delete then stays in the journal. Fewer than n
+// operations are reverted if the journal holds fewer, as it only keeps the last ones and is
+// cleared by Reset and ResetFast. Distinct estimation is not reverted.
+func (cf *Filter) RollbackLast(n int) (int, error) {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	for k := 0; k < n; k++ {
+		e, ok := cf.journal.pop()
+		if !ok {
+			return k, nil
+		}
+		if e.deleted {
+			if !cf.insertFingerprint(e.fp, e.i1) {
+				cf.journal.record(e)
+				return k, fmt.Errorf("%w: reverting delete %d", ErrFull, k+1)
+			}
+			cf.debug.recordInsert(e.data)
+			continue
+		}
+		i2 := cf.altIndex(e.fp, e.i1)
+		if !cf.delete(e.fp, e.i1) && !cf.delete(e.fp, i2) && !cf.deleteStashed(e.fp, e.i1, i2) {
+			return k, fmt.Errorf("%w: reverting insert %d: fingerprint not found", ErrInvalidState, k+1)
+		}
+		cf.debug.checkDelete(e.data)
+	}
+	return n, nil
+}
+
+// journalEntry is an insert or delete of the fingerprint fp with primary bucket i1.
+type journalEntry struct {
+	fp      fingerprint
+	deleted bool
+	i1      uint
+	// data is the item if misuse detection is enabled.
+	data []byte
+}
+
+// opJournal is a ring buffer of the last operations on a filter.
+// All methods are safe to call on a nil receiver, which disables the journal.
+type opJournal struct {
+	entries []journalEntry
+	// next is the index of the next entry to write and n the number of recorded entries.
+	next, n int
+}
+
+func (j *opJournal) recordInsert(fp fingerprint, i1 uint, data []byte) {
+	j.record(journalEntry{fp: fp, i1: i1, data: data})
+}
+
+func (j *opJournal) recordDelete(fp fingerprint, i1 uint, data []byte) {
+	j.record(journalEntry{fp: fp, i1: i1, deleted: true, data: data})
+}
+
+func (j *opJournal) record(e journalEntry) {
+	if j == nil || len(j.entries) == 0 {
+		return
+	}
+	j.entries[j.next] = e
+	j.next = (j.next + 1) % len(j.entries)
+	if j.n < len(j.entries) {
+		j.n++
+	}
+}
+
+// pop removes and returns the most recent entry.
+func (j *opJournal) pop() (journalEntry, bool) {
+	if j == nil || j.n == 0 {
+		return journalEntry{}, false
+	}
+	j.next = (j.next + len(j.entries) - 1) % len(j.entries)
+	j.n--
+	e := j.entries[j.next]
+	j.entries[j.next] = journalEntry{}
+	return e, true
+}
+
+func (j *opJournal) reset() {
+	if j == nil {
+		return
+	}
+	for k := range j.entries {
+		j.entries[k] = journalEntry{}
+	}
+	j.next, j.n = 0, 0
+}
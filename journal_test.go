@@ -0,0 +1,90 @@
+package cuckoo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestRollbackLast(t *testing.T) {
+	cf := NewFilter(1000, WithJournal(10), WithMisuseDetection(func(data []byte) {
+		t.Errorf("misuse detected for %q", data)
+	}))
+	for i := 0; i < 20; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	cf.Delete([]byte("0"))
+	cf.Delete([]byte("19"))
+
+	// Revert both deletes and the inserts of 19 and 18.
+	if n, err := cf.RollbackLast(4); n != 4 || err != nil {
+		t.Fatalf("RollbackLast(4) = %d, %v, want 4, nil", n, err)
+	}
+	if cf.Count() != 18 {
+		t.Errorf("Count() after RollbackLast(4) = %d, want 18", cf.Count())
+	}
+	for i, want := range map[int]bool{0: true, 17: true, 18: false, 19: false} {
+		if got := cf.Lookup([]byte(strconv.Itoa(i))); got != want {
+			t.Errorf("Lookup(%d) after RollbackLast(4) = %v, want %v", i, got, want)
+		}
+	}
+	// Deleting an item whose delete was reverted is no misuse.
+	cf.Delete([]byte("0"))
+
+	// The journal holds the last 10 operations: 6 are left, the inserts of 12 to 17, plus the
+	// new delete.
+	if n, err := cf.RollbackLast(100); n != 7 || err != nil {
+		t.Errorf("RollbackLast(100) = %d, %v, want 7, nil", n, err)
+	}
+	if cf.Count() != 12 {
+		t.Errorf("Count() after RollbackLast(100) = %d, want 12", cf.Count())
+	}
+
+	cf.Insert([]byte("new"))
+	cf.Reset()
+	if n, err := cf.RollbackLast(1); n != 0 || err != nil {
+		t.Errorf("RollbackLast() after Reset() = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestRollbackLastDisabled(t *testing.T) {
+	cf := NewFilter(1000)
+	cf.Insert([]byte("one"))
+	if n, err := cf.RollbackLast(1); n != 0 || err != nil || !cf.Lookup([]byte("one")) {
+		t.Errorf("RollbackLast() without journal = %d, %v, want no change", n, err)
+	}
+}
+
+func TestRollbackLastStash(t *testing.T) {
+	cf := newFilter(make([]bucket, 1))
+	WithOverflowStash(4)(cf)
+	WithJournal(10)(cf)
+	for i := 0; i < 4; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	// The only bucket is full, so an insert of an item whose fingerprint is not kicked out
+	// leaves it stashed.
+	i1, fp := cf.indexAndFingerprint([]byte("stashed"))
+	cf.stashFingerprint(fp, i1)
+	cf.journal.recordInsert(fp, i1, nil)
+	if n, err := cf.RollbackLast(1); n != 1 || err != nil || cf.Count() != 4 || cf.stash.len() != 0 {
+		t.Errorf("RollbackLast(1) = %d, %v, count %d, stash %d, want 1, nil, 4, 0", n, err, cf.Count(), cf.stash.len())
+	}
+}
+
+func TestRollbackLastMissing(t *testing.T) {
+	cf := NewFilter(1000, WithJournal(10))
+	cf.Insert([]byte("a"))
+	cf.Insert([]byte("b"))
+	// Drop the fingerprint of a, as a failed insert could.
+	i1, fp := cf.indexAndFingerprint([]byte("a"))
+	if !cf.delete(fp, i1) && !cf.delete(fp, cf.altIndex(fp, i1)) {
+		t.Fatal("fingerprint of a not found")
+	}
+	if n, err := cf.RollbackLast(2); n != 1 || !errors.Is(err, ErrInvalidState) {
+		t.Errorf("RollbackLast(2) = %d, %v, want 1, %v", n, err, ErrInvalidState)
+	}
+	if n, err := cf.RollbackLast(1); n != 0 || err != nil {
+		t.Errorf("RollbackLast(1) after the missing insert = %d, %v, want 0, nil", n, err)
+	}
+}
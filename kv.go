@@ -0,0 +1,160 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	metro "github.com/dgryski/go-metro"
+)
+
+// kvChunkBuckets is the number of buckets stored per key by KVPersister, i.e. 32KiB values.
+const kvChunkBuckets = 4096
+
+// KV is the subset of an embedded key-value store used by KVPersister. Adapting a
+// transaction of BoltDB (Bucket.Get and Bucket.Put) or BadgerDB (Txn.Get and Txn.Set)
+// takes a few lines.
+type KV interface {
+	// Get returns the value stored for key, or nil if there is none.
+	Get(key []byte) ([]byte, error)
+	// Set stores value for key.
+	Set(key, value []byte) error
+}
+
+// KVPersister stores a filter in a key-value store, split into chunks of buckets stored under
+// separate keys. It remembers what it last saved or loaded, so Save only writes chunks that
+// changed since. Use a KV bound to a single transaction to make Save atomic.
+//
+// A KVPersister is meant for a single filter and is not safe for concurrent use.
+type KVPersister struct {
+	prefix []byte
+	// chunkHashes holds the hash of every chunk as last saved or loaded.
+	chunkHashes []uint64
+}
+
+// NewKVPersister returns a KVPersister storing all keys under prefix.
+func NewKVPersister(prefix []byte) *KVPersister {
+	return &KVPersister{prefix: prefix}
+}
+
+func (p *KVPersister) metaKey() []byte {
+	return append(append([]byte{}, p.prefix...), "meta"...)
+}
+
+func (p *KVPersister) chunkKey(i int) []byte {
+	key := append(append([]byte{}, p.prefix...), "chunk/0000"...)
+	binary.BigEndian.PutUint32(key[len(key)-4:], uint32(i))
+	return key
+}
+
+// Save writes cf to kv and returns the number of chunks written. Writes to cf block while the
+// changed chunks are copied, but not while they are written to kv.
+func (p *KVPersister) Save(kv KV, cf *Filter) (int, error) {
+	type chunk struct {
+		index int
+		value []byte
+	}
+	var changed []chunk
+
+	cf.lock.RLock()
+	if cf.stash.len() > 0 {
+		cf.lock.RUnlock()
+		return 0, fmt.Errorf("%w: saving stashed items, use Encode", ErrUnsupported)
+	}
+	numBuckets := len(cf.buckets)
+	numChunks := (numBuckets + kvChunkBuckets - 1) / kvChunkBuckets
+	if len(p.chunkHashes) != numChunks {
+		// Different geometry, rewrite everything.
+		p.chunkHashes = make([]uint64, numChunks)
+	}
+	hashes := make([]uint64, numChunks)
+	buf := make([]byte, 0, kvChunkBuckets*bucketSize*2)
+	for i := range hashes {
+		to := (i + 1) * kvChunkBuckets
+		if to > numBuckets {
+			to = numBuckets
+		}
+		buf = cf.appendBuckets(buf[:0], i*kvChunkBuckets, to)
+		// Never return 0, which marks chunks that were not saved yet.
+		hashes[i] = metro.Hash64(buf, 1337) | 1
+		if hashes[i] != p.chunkHashes[i] {
+			changed = append(changed, chunk{i, append([]byte{}, buf...)})
+		}
+	}
+	meta := make([]byte, 32)
+	binary.LittleEndian.PutUint64(meta, uint64(numBuckets))
+	binary.LittleEndian.PutUint64(meta[8:], uint64(cf.count))
+	binary.LittleEndian.PutUint64(meta[16:], cf.seed)
+	meta[24] = byte(cf.altScheme)
+	// The remaining bytes are reserved and zero.
+	cf.lock.RUnlock()
+
+	for n, c := range changed {
+		if err := kv.Set(p.chunkKey(c.index), c.value); err != nil {
+			return n, err
+		}
+	}
+	if err := kv.Set(p.metaKey(), meta); err != nil {
+		return len(changed), err
+	}
+	p.chunkHashes = hashes
+	return len(changed), nil
+}
+
+// Load reads a filter saved with Save from kv.
+func (p *KVPersister) Load(kv KV) (_ *Filter, err error) {
+	defer recoverInvalidState(&err)
+	meta, err := kv.Get(p.metaKey())
+	if err != nil {
+		return nil, err
+	}
+	// Metadata of older versions has no hash seed or alternate index scheme.
+	if len(meta) != 16 && len(meta) != 24 && len(meta) != 32 {
+		return nil, fmt.Errorf("%w: invalid metadata of %d bytes", ErrCorrupted, len(meta))
+	}
+	seed := uint64(defaultHashSeed)
+	if len(meta) >= 24 {
+		seed = binary.LittleEndian.Uint64(meta[16:])
+	}
+	altScheme := AltIndexXOR
+	if len(meta) == 32 {
+		altScheme = AltIndexScheme(meta[24])
+		if !supportedAltIndexScheme(altScheme) {
+			return nil, fmt.Errorf("%w: alternate index scheme %v", ErrIncompatible, altScheme)
+		}
+	}
+	numBuckets := binary.LittleEndian.Uint64(meta)
+	if numBuckets == 0 || numBuckets&(numBuckets-1) != 0 {
+		return nil, fmt.Errorf("%w: invalid number of buckets %d", ErrCorrupted, numBuckets)
+	}
+	if numBuckets > maxBuckets {
+		return nil, fmt.Errorf("%w: %d buckets", ErrTooLarge, numBuckets)
+	}
+	// The buckets grow with the chunks read, so forged metadata allocates nothing up front.
+	numChunks := int((numBuckets + kvChunkBuckets - 1) / kvChunkBuckets)
+	var hashes []uint64
+	var bytes []byte
+	for i := 0; i < numChunks; i++ {
+		value, err := kv.Get(p.chunkKey(i))
+		if err != nil {
+			return nil, err
+		}
+		want := kvChunkBuckets * bucketSize * 2
+		if i == numChunks-1 {
+			want = int(numBuckets*bucketSize*2) - i*want
+		}
+		if len(value) != want {
+			return nil, fmt.Errorf("%w: chunk %d has %d bytes, want %d", ErrCorrupted, i, len(value), want)
+		}
+		hashes = append(hashes, metro.Hash64(value, 1337)|1)
+		bytes = append(bytes, value...)
+	}
+	cf, err := Decode(bytes, WithHashSeed(seed), WithAltIndexScheme(altScheme))
+	if err != nil {
+		return nil, err
+	}
+	if count := binary.LittleEndian.Uint64(meta[8:]); uint64(cf.count) != count {
+		return nil, fmt.Errorf("%w: chunks hold %d items, metadata says %d", ErrCorrupted, cf.count, count)
+	}
+	p.chunkHashes = hashes
+	return cf, nil
+}
@@ -0,0 +1,108 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// mapKV is a KV backed by a map.
+type mapKV struct {
+	values map[string][]byte
+}
+
+func (m *mapKV) Get(key []byte) ([]byte, error) {
+	return m.values[string(key)], nil
+}
+
+func (m *mapKV) Set(key, value []byte) error {
+	m.values[string(key)] = value
+	return nil
+}
+
+func TestKVPersister(t *testing.T) {
+	kv := &mapKV{values: make(map[string][]byte)}
+	// 4 chunks of buckets.
+	cf := NewFilter(3 * kvChunkBuckets * bucketSize)
+	cf.Insert([]byte("one"))
+
+	p := NewKVPersister([]byte("filter/"))
+	if n, err := p.Save(kv, cf); n != 4 || err != nil {
+		t.Fatalf("first Save() = %d, %v, want 4, nil", n, err)
+	}
+	cf.Insert([]byte("two"))
+	if n, err := p.Save(kv, cf); n != 1 || err != nil {
+		t.Errorf("Save() after one insert = %d, %v, want 1, nil", n, err)
+	}
+	if n, err := p.Save(kv, cf); n != 0 || err != nil {
+		t.Errorf("Save() without changes = %d, %v, want 0, nil", n, err)
+	}
+
+	got, err := NewKVPersister([]byte("filter/")).Load(kv)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if !reflect.DeepEqual(got.buckets, cf.buckets) || got.Count() != 2 {
+		t.Errorf("Load() = filter with %d items, want %d", got.Count(), 2)
+	}
+
+	if _, err := NewKVPersister([]byte("other/")).Load(kv); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Load() of missing filter error = %v, want %v", err, ErrCorrupted)
+	}
+}
+
+func TestKVPersister_AltIndexScheme(t *testing.T) {
+	kv := &mapKV{values: make(map[string][]byte)}
+	cf := NewFilter(1000, WithAltIndexScheme(AltIndexOffset))
+	for i := 0; i < 900; i++ {
+		cf.Insert([]byte{byte(i), byte(i >> 8)})
+	}
+	p := NewKVPersister([]byte("filter/"))
+	if _, err := p.Save(kv, cf); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+	got, err := NewKVPersister([]byte("filter/")).Load(kv)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if got.AltIndexScheme() != AltIndexOffset {
+		t.Errorf("Load() restored scheme %v, want %v", got.AltIndexScheme(), AltIndexOffset)
+	}
+	for i := 0; i < 900; i++ {
+		if !got.Lookup([]byte{byte(i), byte(i >> 8)}) {
+			t.Fatalf("Lookup(%d) = false after Load()", i)
+		}
+	}
+}
+
+func TestKVPersister_WrongCount(t *testing.T) {
+	kv := &mapKV{values: make(map[string][]byte)}
+	cf := NewFilter(100)
+	cf.Insert([]byte("one"))
+	p := NewKVPersister([]byte("filter/"))
+	if _, err := p.Save(kv, cf); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+	binary.LittleEndian.PutUint64(kv.values[string(p.metaKey())][8:], 2)
+	if _, err := NewKVPersister([]byte("filter/")).Load(kv); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Load() with wrong count = %v, want %v", err, ErrCorrupted)
+	}
+}
+
+func TestKVPersister_ForgedMeta(t *testing.T) {
+	// Metadata of a huge filter must not allocate it before its chunks are read.
+	p := NewKVPersister([]byte("filter/"))
+	for _, numBuckets := range []uint64{maxBuckets, 1 << 63} {
+		want := ErrCorrupted
+		if numBuckets > maxBuckets {
+			want = ErrTooLarge
+		}
+		meta := make([]byte, 16)
+		binary.LittleEndian.PutUint64(meta, numBuckets)
+		kv := &mapKV{values: map[string][]byte{string(p.metaKey()): meta}}
+		if _, err := p.Load(kv); !errors.Is(err, want) {
+			t.Errorf("Load() of %d buckets = %v, want %v", numBuckets, err, want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// lazyPageBuckets is the number of buckets LazyFilter parses at once, 4 KiB of encoding.
+const lazyPageBuckets = 512
+
+// LazyFilter is a filter decoded lazily by DecodeLazy: buckets are parsed from the encoding a
+// page at a time, on the first lookup touching the page. It restores in constant time, so
+// short-lived jobs querying a fraction of the buckets only pay for those. Inserts, deletes and
+// Filter parse all remaining pages first. It is safe for concurrent use if the encoding is
+// not modified until all pages are parsed.
+type LazyFilter struct {
+	cf *Filter
+	// encoded is the encoding of the buckets, or nil once all pages are parsed.
+	encoded []byte
+	// parsed holds 1 for every parsed page, set after its buckets are.
+	parsed []uint32
+	// complete is 1 once all pages are parsed.
+	complete uint32
+	// hasCount is set if cf.count is known, i.e. was read from the header or counted.
+	hasCount bool
+	lock     sync.Mutex
+}
+
+// DecodeLazy returns a LazyFilter of a byte slice created using Encode, which is validated but
+// not copied. The options are applied like by Decode. As the buckets are parsed on demand,
+// damaged buckets are not detected: only the header and the size are validated.
+func DecodeLazy(encoded []byte, opts ...Option) (_ *LazyFilter, err error) {
+	defer recoverInvalidState(&err)
+	cf := newFilter(nil)
+	cf.formatVersion = 0
+	cf.hashVersion = 1
+	for _, opt := range opts {
+		opt(cf)
+	}
+	var h encodingHeader
+	if hasEncodingHeader(encoded) {
+		if h, err = cf.decodeHeader(encoded); err != nil {
+			cf.log.decodeFailed(err)
+			return nil, err
+		}
+		if h.hasStash {
+			return nil, fmt.Errorf("%w: format version %d with overflow stash, use Decode", ErrIncompatible, h.formatVersion)
+		}
+		encoded = encoded[h.size:]
+	}
+	if err := checkBucketBytes(len(encoded)); err != nil {
+		cf.log.decodeFailed(err)
+		return nil, err
+	}
+	numBuckets := len(encoded) / bucketBytes
+	if h.hasCount && h.count > uint64(numBuckets*bucketSize) {
+		err := fmt.Errorf("%w: header records %d items for %d slots", ErrCorrupted, h.count, numBuckets*bucketSize)
+		cf.log.decodeFailed(err)
+		return nil, err
+	}
+	cf.buckets = make([]bucket, numBuckets)
+	cf.bucketIndexMask = uint(numBuckets - 1)
+	cf.count = uint(h.count)
+	cf.rebuildSummary()
+	return &LazyFilter{
+		cf:       cf,
+		encoded:  encoded,
+		parsed:   make([]uint32, (numBuckets+lazyPageBuckets-1)/lazyPageBuckets),
+		hasCount: h.hasCount,
+	}, nil
+}
+
+// ensure parses the page of bucket i, unless it already is.
+func (lf *LazyFilter) ensure(i uint) {
+	page := i / lazyPageBuckets
+	if atomic.LoadUint32(&lf.parsed[page]) == 1 {
+		return
+	}
+	lf.lock.Lock()
+	defer lf.lock.Unlock()
+	lf.parsePage(page)
+}
+
+// parsePage parses the buckets of page, unless it already is. The caller must hold the lock.
+func (lf *LazyFilter) parsePage(page uint) {
+	if lf.parsed[page] == 1 {
+		return
+	}
+	from := page * lazyPageBuckets
+	to := from + lazyPageBuckets
+	if to > uint(len(lf.cf.buckets)) {
+		to = uint(len(lf.cf.buckets))
+	}
+	for i := from; i < to; i++ {
+		lf.cf.buckets[i] = bucket(binary.LittleEndian.Uint64(lf.encoded[bucketBytes*i:]))
+	}
+	lf.cf.summarizeRange(from, to)
+	atomic.StoreUint32(&lf.parsed[page], 1)
+}
+
+// Filter parses all remaining pages and returns the decoded filter. Later operations on lf
+// act on the returned filter.
+func (lf *LazyFilter) Filter() *Filter {
+	if atomic.LoadUint32(&lf.complete) == 1 {
+		return lf.cf
+	}
+	lf.lock.Lock()
+	defer lf.lock.Unlock()
+
+	if lf.complete == 1 {
+		return lf.cf
+	}
+	for page := range lf.parsed {
+		lf.parsePage(uint(page))
+	}
+	if !lf.hasCount {
+		var count uint
+		for _, b := range lf.cf.buckets {
+			count += uint(bucketSize - b.free())
+		}
+		lf.cf.count = count
+		lf.hasCount = true
+	}
+	lf.encoded = nil
+	atomic.StoreUint32(&lf.complete, 1)
+	return lf.cf
+}
+
+// Lookup returns true if data is in the filter, parsing the pages of its candidate buckets.
+func (lf *LazyFilter) Lookup(data []byte) bool {
+	if atomic.LoadUint32(&lf.complete) == 1 {
+		return lf.cf.Lookup(data)
+	}
+	data = lf.cf.normalize(data)
+	i1, fp := lf.cf.indexAndFingerprint(data)
+	i2 := lf.cf.altIndex(fp, i1)
+	lf.ensure(i1)
+	lf.ensure(i2)
+
+	lf.cf.lock.RLock()
+	defer lf.cf.lock.RUnlock()
+
+	return lf.cf.lookup(fp, i1)
+}
+
+// PagesParsed returns the number of parsed pages and the total number of pages.
+func (lf *LazyFilter) PagesParsed() (parsed, total int) {
+	for k := range lf.parsed {
+		if atomic.LoadUint32(&lf.parsed[k]) == 1 {
+			parsed++
+		}
+	}
+	return parsed, len(lf.parsed)
+}
+
+// Count returns the number of items in the filter. For encodings of format versions before 2,
+// which lack the number of items, it parses all pages.
+func (lf *LazyFilter) Count() uint {
+	lf.lock.Lock()
+	hasCount := lf.hasCount
+	lf.lock.Unlock()
+	if !hasCount {
+		return lf.Filter().Count()
+	}
+	return lf.cf.Count()
+}
+
+// Insert parses all pages and inserts data like Filter.Insert.
+func (lf *LazyFilter) Insert(data []byte) bool {
+	return lf.Filter().Insert(data)
+}
+
+// Delete parses all pages and deletes data like Filter.Delete.
+func (lf *LazyFilter) Delete(data []byte) bool {
+	return lf.Filter().Delete(data)
+}
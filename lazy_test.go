@@ -0,0 +1,83 @@
+package cuckoo
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestDecodeLazy(t *testing.T) {
+	cf := NewFilter(100000)
+	for i := 0; i < 50000; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	lf, err := DecodeLazy(cf.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lf.Count() != cf.Count() {
+		t.Errorf("Count() = %d, want %d", lf.Count(), cf.Count())
+	}
+	if !lf.Lookup([]byte("42")) {
+		t.Error("Lookup(42) = false")
+	}
+	if parsed, total := lf.PagesParsed(); parsed > 2 || total != len(cf.buckets)/lazyPageBuckets {
+		t.Errorf("PagesParsed() = %d, %d after one lookup", parsed, total)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < 50000; i += 4 {
+				if !lf.Lookup([]byte(strconv.Itoa(i))) {
+					t.Errorf("Lookup(%d) = false", i)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if !lf.Delete([]byte("42")) {
+		t.Error("Delete(42) failed")
+	}
+	if lf.Count() != cf.Count()-1 || lf.Filter().Count() != cf.Count()-1 {
+		t.Errorf("Count() after Delete = %d, want %d", lf.Count(), cf.Count()-1)
+	}
+	if parsed, total := lf.PagesParsed(); parsed != total {
+		t.Errorf("PagesParsed() = %d, %d after Delete", parsed, total)
+	}
+}
+
+func TestDecodeLazy_FormatV1(t *testing.T) {
+	cf := NewFilter(1000)
+	for i := 0; i < 500; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	lf, err := DecodeLazy(encodeV1(cf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lf.Lookup([]byte("7")) {
+		t.Error("Lookup(7) = false")
+	}
+	if lf.Count() != cf.Count() {
+		t.Errorf("Count() = %d, want %d", lf.Count(), cf.Count())
+	}
+}
+
+func TestDecodeLazy_Invalid(t *testing.T) {
+	encoded := NewFilter(1000).Encode()
+	for name, enc := range map[string][]byte{
+		"empty":      nil,
+		"truncated":  encoded[:len(encoded)-1],
+		"no buckets": encoded[:encodingHeaderSize],
+	} {
+		if _, err := DecodeLazy(enc); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("DecodeLazy(%s) = %v, want %v", name, err, ErrCorrupted)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package cuckoo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Limiter limits how often each key is allowed per time window, with memory bounded by the
+// number of distinct keys per window rather than by all keys ever seen. Counts are kept in
+// two CountingFilters, for the current and the previous window; the previous count is weighted
+// by how much of the sliding window still overlaps the previous window.
+//
+// Limits are approximate: keys whose fingerprints collide share their count, and concurrent
+// calls for the same key may exceed the limit slightly. It is safe for concurrent use.
+type Limiter struct {
+	limit  uint
+	window time.Duration
+	now    func() time.Time
+
+	// lock is held for reading while using the filters, and for writing while rotating them.
+	lock              sync.RWMutex
+	current, previous *CountingFilter
+	// start is the start of the current window.
+	start time.Time
+}
+
+// NewLimiter returns a Limiter allowing each key limit times per window, for about numKeys
+// distinct keys per window. As counts are kept in 4-bit counters, limit must be between 1 and
+// CountingMaxCount; larger limits return ErrUnsupported.
+func NewLimiter(numKeys uint, limit uint, window time.Duration) (*Limiter, error) {
+	if limit == 0 || limit > CountingMaxCount {
+		return nil, fmt.Errorf("%w: limit %d is not between 1 and %d", ErrUnsupported, limit, CountingMaxCount)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("%w: window %v is not positive", ErrUnsupported, window)
+	}
+	l := &Limiter{
+		limit:    limit,
+		window:   window,
+		now:      time.Now,
+		current:  NewCountingFilter(numKeys),
+		previous: NewCountingFilter(numKeys),
+	}
+	l.start = l.now()
+	return l, nil
+}
+
+// Allow returns true and counts the call if key was allowed fewer than limit times in the
+// last window. If the counts are full, keys are allowed without being counted.
+func (l *Limiter) Allow(key []byte) bool {
+	now := l.now()
+
+	l.lock.RLock()
+	if now.Sub(l.start) >= l.window {
+		l.lock.RUnlock()
+		l.lock.Lock()
+		l.rotate(now)
+		l.lock.Unlock()
+		l.lock.RLock()
+	}
+	defer l.lock.RUnlock()
+
+	// After rotating, now is less than a window past the start. But a concurrent rotation for
+	// a later time may have moved the start past now, which would weight the previous window
+	// by more than 1.
+	weight := 1 - float64(now.Sub(l.start))/float64(l.window)
+	if weight < 0 {
+		weight = 0
+	} else if weight > 1 {
+		weight = 1
+	}
+	estimate := weight*float64(l.previous.EstimateCount(key)) + float64(l.current.EstimateCount(key))
+	if estimate >= float64(l.limit) {
+		return false
+	}
+	l.current.Insert(key)
+	return true
+}
+
+// rotate starts the window containing now. The caller must hold the write lock.
+func (l *Limiter) rotate(now time.Time) {
+	elapsed := now.Sub(l.start)
+	if elapsed < l.window {
+		// Rotated concurrently.
+		return
+	}
+	if elapsed < 2*l.window {
+		l.previous, l.current = l.current, l.previous
+	} else {
+		l.previous.Reset()
+	}
+	l.current.Reset()
+	l.start = l.start.Add(elapsed / l.window * l.window)
+}
@@ -0,0 +1,82 @@
+package cuckoo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(t *testing.T, limit uint, window time.Duration) (*Limiter, *fakeClock) {
+	l, err := NewLimiter(1000, limit, window)
+	if err != nil {
+		t.Fatalf("NewLimiter() = %v", err)
+	}
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	l.now = clock.now
+	l.start = clock.t
+	return l, clock
+}
+
+func TestLimiter(t *testing.T) {
+	l, clock := newTestLimiter(t, 3, time.Minute)
+	key, other := []byte("key"), []byte("other")
+	allowed := 0
+	for k := 0; k < 10; k++ {
+		if l.Allow(key) {
+			allowed++
+		}
+	}
+	if allowed != 3 || !l.Allow(other) {
+		t.Errorf("Allow() allowed %d of 10 calls, want 3 and other keys unaffected", allowed)
+	}
+
+	// Half way into the next window, half of the previous count still applies, so only 2 calls
+	// are allowed: 1.5 + 2 >= 3.
+	clock.t = clock.t.Add(90 * time.Second)
+	allowed = 0
+	for k := 0; k < 10; k++ {
+		if l.Allow(key) {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("Allow() in the next window allowed %d of 10 calls, want 2", allowed)
+	}
+
+	clock.t = clock.t.Add(2 * time.Minute)
+	allowed = 0
+	for k := 0; k < 10; k++ {
+		if l.Allow(key) {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("Allow() after two idle windows allowed %d of 10 calls, want 3", allowed)
+	}
+}
+
+func TestLimiter_StaleNow(t *testing.T) {
+	l, clock := newTestLimiter(t, 3, time.Minute)
+	key := []byte("key")
+	l.Allow(key)
+	l.Allow(key)
+	l.rotate(clock.t.Add(90 * time.Second))
+
+	// A call that read the clock before a concurrent rotation sees the start after its now, and
+	// must weight the previous count of 2 by 1, not by 1.5: 2 + 0 < 3.
+	clock.t = l.start.Add(-30 * time.Second)
+	if !l.Allow(key) {
+		t.Errorf("Allow() before the start of the window = false, want true")
+	}
+}
+
+func TestNewLimiter_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		limit  uint
+		window time.Duration
+	}{{0, time.Second}, {CountingMaxCount + 1, time.Second}, {1, 0}} {
+		if _, err := NewLimiter(10, tc.limit, tc.window); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("NewLimiter(%d, %v) = %v, want %v", tc.limit, tc.window, err, ErrUnsupported)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cuckoo
+
+// Logger receives rare but important events of a filter. *slog.Logger implements it.
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// loadThresholds are the load factors whose crossing is logged.
+var loadThresholds = []float64{0.5, 0.75, 0.9, 0.95}
+
+// WithLogger logs insert failures, crossings of load factors 0.5, 0.75, 0.9 and 0.95, and
+// validation errors of Decode to l. Events are logged while the filter is locked, so l must
+// not call back into the filter.
+func WithLogger(l Logger) Option {
+	return func(cf *Filter) {
+		cf.log = &eventLogger{logger: l}
+	}
+}
+
+// eventLogger logs the events of a filter.
+// All methods are safe to call on a nil receiver, which disables logging.
+type eventLogger struct {
+	logger Logger
+	// nextThreshold is the index of the next load threshold to log.
+	nextThreshold int
+}
+
+func (l *eventLogger) insertFailed(count uint, capacity int) {
+	if l == nil {
+		return
+	}
+	l.logger.Warn("cuckoo: insert failed, filter is full", "count", count, "capacity", capacity)
+}
+
+func (l *eventLogger) checkLoad(count uint, capacity int) {
+	if l == nil || capacity == 0 || l.nextThreshold == len(loadThresholds) {
+		return
+	}
+	load := float64(count) / float64(capacity)
+	if load < loadThresholds[l.nextThreshold] {
+		return
+	}
+	for l.nextThreshold < len(loadThresholds) && load >= loadThresholds[l.nextThreshold] {
+		l.nextThreshold++
+	}
+	log := l.logger.Info
+	if load >= 0.9 {
+		log = l.logger.Warn
+	}
+	log("cuckoo: load factor threshold crossed", "threshold", loadThresholds[l.nextThreshold-1], "count", count, "capacity", capacity)
+}
+
+func (l *eventLogger) stashFull(size int) {
+	if l == nil {
+		return
+	}
+	l.logger.Warn("cuckoo: overflow stash full", "size", size)
+}
+
+func (l *eventLogger) decodeFailed(err error) {
+	if l == nil {
+		return
+	}
+	l.logger.Error("cuckoo: decode failed", "error", err)
+}
+
+func (l *eventLogger) reset() {
+	if l == nil {
+		return
+	}
+	l.nextThreshold = 0
+}
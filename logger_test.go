@@ -0,0 +1,50 @@
+package cuckoo
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recordingLogger records logged messages with their level.
+type recordingLogger struct {
+	events []string
+}
+
+func (l *recordingLogger) log(level, msg string) { l.events = append(l.events, level+" "+msg) }
+
+func (l *recordingLogger) Info(msg string, _ ...interface{})  { l.log("INFO", msg) }
+func (l *recordingLogger) Warn(msg string, _ ...interface{})  { l.log("WARN", msg) }
+func (l *recordingLogger) Error(msg string, _ ...interface{}) { l.log("ERROR", msg) }
+
+func TestWithLogger(t *testing.T) {
+	l := &recordingLogger{}
+	cf := NewFilter(8, WithLogger(l))
+	for i := 0; i < 100; i++ {
+		cf.Insert([]byte(fmt.Sprint(i)))
+	}
+	want := []string{
+		"INFO cuckoo: load factor threshold crossed",
+		"INFO cuckoo: load factor threshold crossed",
+	}
+	if len(l.events) < 3 || !reflect.DeepEqual(l.events[:2], want) {
+		t.Fatalf("Logged %q, want %q followed by warnings", l.events, want)
+	}
+	if got, want := l.events[len(l.events)-1], "WARN cuckoo: insert failed, filter is full"; got != want {
+		t.Errorf("Last event = %q, want %q", got, want)
+	}
+
+	l.events = nil
+	cf.Reset()
+	cf.Insert([]byte("one"))
+	if len(l.events) != 0 {
+		t.Errorf("After Reset(): logged %q, want nothing", l.events)
+	}
+
+	if _, err := Decode([]byte{1, 2, 3}, WithLogger(l)); err == nil {
+		t.Fatal("Decode() of corrupted data succeeded")
+	}
+	if want := []string{"ERROR cuckoo: decode failed"}; !reflect.DeepEqual(l.events, want) {
+		t.Errorf("Decode() logged %q, want %q", l.events, want)
+	}
+}
@@ -0,0 +1,871 @@
+package cuckoo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// This file holds an interpreter of the subset of Lua 5.1 used by RedisLookupScript, so that
+// tests run the script itself rather than a port of it. Like in Lua 5.1, numbers are float64,
+// which is what the limb arithmetic of the script has to cope with.
+
+// luaTable is a Lua table. Keys are float64 or string.
+type luaTable struct {
+	fields map[interface{}]interface{}
+}
+
+func newLuaTable(values ...interface{}) *luaTable {
+	t := &luaTable{fields: make(map[interface{}]interface{})}
+	for k, v := range values {
+		t.fields[float64(k+1)] = v
+	}
+	return t
+}
+
+// length returns the border of t, as the # operator.
+func (t *luaTable) length() float64 {
+	n := 0.0
+	for t.fields[n+1] != nil {
+		n++
+	}
+	return n
+}
+
+// luaFunction is a Lua function, either a builtin or a closure.
+type luaFunction func(args []interface{}) []interface{}
+
+// luaError is raised by panicking, by the interpreter and by error.
+type luaError string
+
+func luaFail(format string, args ...interface{}) {
+	panic(luaError(fmt.Sprintf(format, args...)))
+}
+
+// luaScope holds the locals of a block and links to the enclosing one.
+type luaScope struct {
+	vars    map[string]*interface{}
+	parent  *luaScope
+	globals map[string]interface{}
+}
+
+func (s *luaScope) child() *luaScope {
+	return &luaScope{vars: make(map[string]*interface{}), parent: s, globals: s.globals}
+}
+
+func (s *luaScope) declare(name string, v interface{}) {
+	s.vars[name] = &v
+}
+
+func (s *luaScope) lookup(name string) *interface{} {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// luaNode is a compiled expression. multi is set for calls, which can return several values,
+// and assign for names and indexing, which can be assigned to.
+type luaNode struct {
+	eval   func(*luaScope) interface{}
+	multi  func(*luaScope) []interface{}
+	assign func(*luaScope, interface{})
+}
+
+// luaStmt is a compiled statement. It returns the values of a return statement and whether
+// one was executed.
+type luaStmt func(*luaScope) ([]interface{}, bool)
+
+type luaToken struct {
+	kind string // "name", "number", "string", "op" or "eof"
+	text string
+	num  float64
+}
+
+var luaKeywords = map[string]bool{
+	"and": true, "do": true, "else": true, "elseif": true, "end": true, "false": true,
+	"for": true, "function": true, "if": true, "in": true, "local": true, "nil": true,
+	"not": true, "or": true, "return": true, "then": true, "true": true, "while": true,
+}
+
+func luaLex(src string) []luaToken {
+	var toks []luaToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			kind := "name"
+			if luaKeywords[src[i:j]] {
+				kind = "op"
+			}
+			toks = append(toks, luaToken{kind: kind, text: src[i:j]})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] == 'x' || src[j] == 'X' || src[j] == '.' || strings.IndexByte("0123456789abcdefABCDEF", src[j]) >= 0) {
+				j++
+			}
+			var num float64
+			if strings.HasPrefix(src[i:j], "0x") || strings.HasPrefix(src[i:j], "0X") {
+				n, err := strconv.ParseUint(src[i+2:j], 16, 64)
+				if err != nil {
+					luaFail("invalid number %q", src[i:j])
+				}
+				num = float64(n)
+			} else {
+				n, err := strconv.ParseFloat(src[i:j], 64)
+				if err != nil {
+					luaFail("invalid number %q", src[i:j])
+				}
+				num = n
+			}
+			toks = append(toks, luaToken{kind: "number", text: src[i:j], num: num})
+			i = j
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+					switch src[j] {
+					case 'n':
+						b.WriteByte('\n')
+					default:
+						b.WriteByte(src[j])
+					}
+					continue
+				}
+				b.WriteByte(src[j])
+			}
+			if j == len(src) {
+				luaFail("unfinished string")
+			}
+			toks = append(toks, luaToken{kind: "string", text: b.String()})
+			i = j + 1
+		default:
+			op := ""
+			for _, candidate := range []string{"...", "..", "==", "~=", "<=", ">=", "+", "-", "*", "/", "%", "^", "#", "<", ">", "=", "(", ")", "{", "}", "[", "]", ";", ",", "."} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				luaFail("unexpected character %q", c)
+			}
+			toks = append(toks, luaToken{kind: "op", text: op})
+			i += len(op)
+		}
+	}
+	return append(toks, luaToken{kind: "eof"})
+}
+
+type luaParser struct {
+	toks []luaToken
+	pos  int
+}
+
+func (p *luaParser) peek() luaToken { return p.toks[p.pos] }
+
+func (p *luaParser) next() luaToken {
+	t := p.toks[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *luaParser) is(op string) bool {
+	t := p.peek()
+	return t.kind == "op" && t.text == op
+}
+
+func (p *luaParser) accept(op string) bool {
+	if p.is(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *luaParser) expect(op string) {
+	if !p.accept(op) {
+		luaFail("expected %q, got %q", op, p.peek().text)
+	}
+}
+
+func (p *luaParser) name() string {
+	t := p.next()
+	if t.kind != "name" {
+		luaFail("expected a name, got %q", t.text)
+	}
+	return t.text
+}
+
+func (p *luaParser) blockEnds() bool {
+	return p.peek().kind == "eof" || p.is("end") || p.is("else") || p.is("elseif")
+}
+
+// block parses statements until the end of the block and returns a statement running them in
+// a new scope.
+func (p *luaParser) block() luaStmt {
+	var stmts []luaStmt
+	for !p.blockEnds() {
+		if p.accept("return") {
+			var values []luaNode
+			if !p.blockEnds() && !p.is(";") {
+				values = p.exprList()
+			}
+			p.accept(";")
+			stmts = append(stmts, func(s *luaScope) ([]interface{}, bool) {
+				return luaEvalList(s, values), true
+			})
+			break
+		}
+		stmts = append(stmts, p.statement())
+		p.accept(";")
+	}
+	return func(s *luaScope) ([]interface{}, bool) {
+		inner := s.child()
+		for _, stmt := range stmts {
+			if ret, ok := stmt(inner); ok {
+				return ret, true
+			}
+		}
+		return nil, false
+	}
+}
+
+func (p *luaParser) statement() luaStmt {
+	switch {
+	case p.accept("local"):
+		if p.accept("function") {
+			name := p.name()
+			body := p.funcBody()
+			return func(s *luaScope) ([]interface{}, bool) {
+				// Declare first, so that the function can call itself.
+				s.declare(name, nil)
+				*s.lookup(name) = body.eval(s)
+				return nil, false
+			}
+		}
+		names := []string{p.name()}
+		for p.accept(",") {
+			names = append(names, p.name())
+		}
+		var values []luaNode
+		if p.accept("=") {
+			values = p.exprList()
+		}
+		return func(s *luaScope) ([]interface{}, bool) {
+			v := luaEvalList(s, values)
+			for k, name := range names {
+				var value interface{}
+				if k < len(v) {
+					value = v[k]
+				}
+				s.declare(name, value)
+			}
+			return nil, false
+		}
+	case p.accept("if"):
+		var conds []luaNode
+		var blocks []luaStmt
+		conds = append(conds, p.expr(0))
+		p.expect("then")
+		blocks = append(blocks, p.block())
+		for p.accept("elseif") {
+			conds = append(conds, p.expr(0))
+			p.expect("then")
+			blocks = append(blocks, p.block())
+		}
+		var otherwise luaStmt
+		if p.accept("else") {
+			otherwise = p.block()
+		}
+		p.expect("end")
+		return func(s *luaScope) ([]interface{}, bool) {
+			for k, cond := range conds {
+				if luaTruthy(cond.eval(s)) {
+					return blocks[k](s)
+				}
+			}
+			if otherwise != nil {
+				return otherwise(s)
+			}
+			return nil, false
+		}
+	case p.accept("while"):
+		cond := p.expr(0)
+		p.expect("do")
+		body := p.block()
+		p.expect("end")
+		return func(s *luaScope) ([]interface{}, bool) {
+			for luaTruthy(cond.eval(s)) {
+				if ret, ok := body(s); ok {
+					return ret, true
+				}
+			}
+			return nil, false
+		}
+	case p.accept("for"):
+		names := []string{p.name()}
+		if p.accept("=") {
+			from := p.expr(0)
+			p.expect(",")
+			to := p.expr(0)
+			step := luaNode{eval: func(*luaScope) interface{} { return 1.0 }}
+			if p.accept(",") {
+				step = p.expr(0)
+			}
+			p.expect("do")
+			body := p.block()
+			p.expect("end")
+			return func(s *luaScope) ([]interface{}, bool) {
+				i, limit, inc := luaNumber(from.eval(s)), luaNumber(to.eval(s)), luaNumber(step.eval(s))
+				for ; inc > 0 && i <= limit || inc <= 0 && i >= limit; i += inc {
+					inner := s.child()
+					inner.declare(names[0], i)
+					if ret, ok := body(inner); ok {
+						return ret, true
+					}
+				}
+				return nil, false
+			}
+		}
+		for p.accept(",") {
+			names = append(names, p.name())
+		}
+		p.expect("in")
+		values := p.exprList()
+		p.expect("do")
+		body := p.block()
+		p.expect("end")
+		return func(s *luaScope) ([]interface{}, bool) {
+			v := append(luaEvalList(s, values), nil, nil, nil)
+			iter, ok := v[0].(luaFunction)
+			if !ok {
+				luaFail("attempt to iterate over %T", v[0])
+			}
+			state, control := v[1], v[2]
+			for {
+				vars := append(iter([]interface{}{state, control}), nil)
+				if vars[0] == nil {
+					return nil, false
+				}
+				control = vars[0]
+				inner := s.child()
+				for k, name := range names {
+					var value interface{}
+					if k < len(vars) {
+						value = vars[k]
+					}
+					inner.declare(name, value)
+				}
+				if ret, ok := body(inner); ok {
+					return ret, true
+				}
+			}
+		}
+	case p.accept("do"):
+		body := p.block()
+		p.expect("end")
+		return body
+	}
+	target := p.suffixedExpr()
+	if !p.is("=") && !p.is(",") {
+		if target.multi == nil {
+			luaFail("syntax error near %q", p.peek().text)
+		}
+		return func(s *luaScope) ([]interface{}, bool) {
+			target.multi(s)
+			return nil, false
+		}
+	}
+	targets := []luaNode{target}
+	for p.accept(",") {
+		targets = append(targets, p.suffixedExpr())
+	}
+	p.expect("=")
+	values := p.exprList()
+	for _, t := range targets {
+		if t.assign == nil {
+			luaFail("cannot assign")
+		}
+	}
+	return func(s *luaScope) ([]interface{}, bool) {
+		v := luaEvalList(s, values)
+		for k, t := range targets {
+			var value interface{}
+			if k < len(v) {
+				value = v[k]
+			}
+			t.assign(s, value)
+		}
+		return nil, false
+	}
+}
+
+func (p *luaParser) exprList() []luaNode {
+	list := []luaNode{p.expr(0)}
+	for p.accept(",") {
+		list = append(list, p.expr(0))
+	}
+	return list
+}
+
+// luaEvalList evaluates an expression list, expanding all values of a call at its end.
+func luaEvalList(s *luaScope, list []luaNode) []interface{} {
+	var values []interface{}
+	for k, e := range list {
+		if k == len(list)-1 && e.multi != nil {
+			values = append(values, e.multi(s)...)
+		} else {
+			values = append(values, e.eval(s))
+		}
+	}
+	return values
+}
+
+// luaBinaryPriority holds the left and right priority of binary operators, as in Lua 5.1.
+var luaBinaryPriority = map[string][2]int{
+	"or": {1, 1}, "and": {2, 2},
+	"<": {3, 3}, ">": {3, 3}, "<=": {3, 3}, ">=": {3, 3}, "~=": {3, 3}, "==": {3, 3},
+	"..": {5, 4}, "+": {6, 6}, "-": {6, 6}, "*": {7, 7}, "/": {7, 7}, "%": {7, 7},
+	"^": {10, 9},
+}
+
+const luaUnaryPriority = 8
+
+func (p *luaParser) expr(limit int) luaNode {
+	var left luaNode
+	if t := p.peek(); t.kind == "op" && (t.text == "-" || t.text == "not" || t.text == "#") {
+		p.next()
+		operand := p.expr(luaUnaryPriority)
+		switch t.text {
+		case "-":
+			left = luaValue(func(s *luaScope) interface{} { return -luaNumber(operand.eval(s)) })
+		case "not":
+			left = luaValue(func(s *luaScope) interface{} { return !luaTruthy(operand.eval(s)) })
+		case "#":
+			left = luaValue(func(s *luaScope) interface{} {
+				switch v := operand.eval(s).(type) {
+				case string:
+					return float64(len(v))
+				case *luaTable:
+					return v.length()
+				default:
+					luaFail("attempt to get length of %T", v)
+					return nil
+				}
+			})
+		}
+	} else {
+		left = p.simpleExpr()
+	}
+	for {
+		t := p.peek()
+		prio, ok := luaBinaryPriority[t.text]
+		if t.kind != "op" || !ok || prio[0] <= limit {
+			return left
+		}
+		p.next()
+		right := p.expr(prio[1])
+		left = luaBinary(t.text, left, right)
+	}
+}
+
+func luaValue(eval func(*luaScope) interface{}) luaNode {
+	return luaNode{eval: eval}
+}
+
+func luaBinary(op string, left, right luaNode) luaNode {
+	switch op {
+	case "and":
+		return luaValue(func(s *luaScope) interface{} {
+			if v := left.eval(s); !luaTruthy(v) {
+				return v
+			}
+			return right.eval(s)
+		})
+	case "or":
+		return luaValue(func(s *luaScope) interface{} {
+			if v := left.eval(s); luaTruthy(v) {
+				return v
+			}
+			return right.eval(s)
+		})
+	case "==":
+		return luaValue(func(s *luaScope) interface{} { return left.eval(s) == right.eval(s) })
+	case "~=":
+		return luaValue(func(s *luaScope) interface{} { return left.eval(s) != right.eval(s) })
+	case "..":
+		return luaValue(func(s *luaScope) interface{} { return luaString(left.eval(s)) + luaString(right.eval(s)) })
+	case "<", ">", "<=", ">=":
+		return luaValue(func(s *luaScope) interface{} {
+			x, y := left.eval(s), right.eval(s)
+			var c int
+			if a, ok := x.(string); ok {
+				b, ok := y.(string)
+				if !ok {
+					luaFail("attempt to compare string with %T", y)
+				}
+				c = strings.Compare(a, b)
+			} else {
+				a, b := luaNumber(x), luaNumber(y)
+				switch {
+				case a < b:
+					c = -1
+				case a > b:
+					c = 1
+				}
+			}
+			switch op {
+			case "<":
+				return c < 0
+			case ">":
+				return c > 0
+			case "<=":
+				return c <= 0
+			}
+			return c >= 0
+		})
+	}
+	return luaValue(func(s *luaScope) interface{} {
+		a, b := luaNumber(left.eval(s)), luaNumber(right.eval(s))
+		switch op {
+		case "+":
+			return a + b
+		case "-":
+			return a - b
+		case "*":
+			return a * b
+		case "/":
+			return a / b
+		case "%":
+			return a - math.Floor(a/b)*b
+		}
+		return math.Pow(a, b)
+	})
+}
+
+func (p *luaParser) simpleExpr() luaNode {
+	t := p.peek()
+	switch {
+	case t.kind == "number":
+		p.next()
+		return luaValue(func(*luaScope) interface{} { return t.num })
+	case t.kind == "string":
+		p.next()
+		return luaValue(func(*luaScope) interface{} { return t.text })
+	case p.accept("nil"):
+		return luaValue(func(*luaScope) interface{} { return nil })
+	case p.accept("true"):
+		return luaValue(func(*luaScope) interface{} { return true })
+	case p.accept("false"):
+		return luaValue(func(*luaScope) interface{} { return false })
+	case p.accept("function"):
+		return p.funcBody()
+	case p.accept("{"):
+		var values []luaNode
+		for !p.is("}") {
+			values = append(values, p.expr(0))
+			if !p.accept(",") && !p.accept(";") {
+				break
+			}
+		}
+		p.expect("}")
+		return luaValue(func(s *luaScope) interface{} { return newLuaTable(luaEvalList(s, values)...) })
+	}
+	return p.suffixedExpr()
+}
+
+func (p *luaParser) funcBody() luaNode {
+	p.expect("(")
+	var params []string
+	for !p.is(")") {
+		params = append(params, p.name())
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect(")")
+	body := p.block()
+	p.expect("end")
+	return luaValue(func(def *luaScope) interface{} {
+		return luaFunction(func(args []interface{}) []interface{} {
+			s := def.child()
+			for k, name := range params {
+				var value interface{}
+				if k < len(args) {
+					value = args[k]
+				}
+				s.declare(name, value)
+			}
+			ret, _ := body(s)
+			return ret
+		})
+	})
+}
+
+func (p *luaParser) suffixedExpr() luaNode {
+	var e luaNode
+	if p.accept("(") {
+		inner := p.expr(0)
+		p.expect(")")
+		e = luaValue(inner.eval)
+	} else {
+		name := p.name()
+		e = luaNode{
+			eval: func(s *luaScope) interface{} {
+				if v := s.lookup(name); v != nil {
+					return *v
+				}
+				return s.globals[name]
+			},
+			assign: func(s *luaScope, value interface{}) {
+				if v := s.lookup(name); v != nil {
+					*v = value
+				} else {
+					s.globals[name] = value
+				}
+			},
+		}
+	}
+	for {
+		switch {
+		case p.accept("."):
+			key := p.name()
+			e = luaIndex(e, luaValue(func(*luaScope) interface{} { return key }))
+		case p.accept("["):
+			key := p.expr(0)
+			p.expect("]")
+			e = luaIndex(e, key)
+		case p.accept("("):
+			var args []luaNode
+			if !p.is(")") {
+				args = p.exprList()
+			}
+			p.expect(")")
+			callee := e
+			multi := func(s *luaScope) []interface{} {
+				f, ok := callee.eval(s).(luaFunction)
+				if !ok {
+					luaFail("attempt to call %T", callee.eval(s))
+				}
+				return f(luaEvalList(s, args))
+			}
+			e = luaNode{
+				eval: func(s *luaScope) interface{} {
+					if v := multi(s); len(v) > 0 {
+						return v[0]
+					}
+					return nil
+				},
+				multi: multi,
+			}
+		default:
+			return e
+		}
+	}
+}
+
+// luaIndex returns the node indexing table with key.
+func luaIndex(table, key luaNode) luaNode {
+	get := func(s *luaScope) *luaTable {
+		t, ok := table.eval(s).(*luaTable)
+		if !ok {
+			luaFail("attempt to index %T", table.eval(s))
+		}
+		return t
+	}
+	return luaNode{
+		eval: func(s *luaScope) interface{} {
+			return get(s).fields[key.eval(s)]
+		},
+		assign: func(s *luaScope, value interface{}) {
+			t, k := get(s), key.eval(s)
+			if value == nil {
+				delete(t.fields, k)
+			} else {
+				t.fields[k] = value
+			}
+		},
+	}
+}
+
+func luaTruthy(v interface{}) bool {
+	return v != nil && v != false
+}
+
+func luaNumber(v interface{}) float64 {
+	x, ok := v.(float64)
+	if !ok {
+		luaFail("attempt to perform arithmetic on %T", v)
+	}
+	return x
+}
+
+func luaString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'g', 14, 64)
+	}
+	luaFail("attempt to concatenate %T", v)
+	return ""
+}
+
+func luaInt(v interface{}) int {
+	return int(luaNumber(v))
+}
+
+// luaStdlib returns the globals of the subset of the standard library the script uses:
+// math.floor, bit.bxor, string.byte, string.sub, string.char and ipairs.
+func luaStdlib() map[string]interface{} {
+	byteAt := func(args []interface{}) []interface{} {
+		s := args[0].(string)
+		i := 1
+		if len(args) > 1 {
+			i = luaInt(args[1])
+		}
+		j := i
+		if len(args) > 2 {
+			j = luaInt(args[2])
+		}
+		var out []interface{}
+		for k := i; k <= j; k++ {
+			if k >= 1 && k <= len(s) {
+				out = append(out, float64(s[k-1]))
+			}
+		}
+		return out
+	}
+	sub := func(args []interface{}) []interface{} {
+		s := args[0].(string)
+		i, j := luaInt(args[1]), -1
+		if len(args) > 2 {
+			j = luaInt(args[2])
+		}
+		if i < 0 {
+			i += len(s) + 1
+		}
+		if j < 0 {
+			j += len(s) + 1
+		}
+		if i < 1 {
+			i = 1
+		}
+		if j > len(s) {
+			j = len(s)
+		}
+		if i > j {
+			return []interface{}{""}
+		}
+		return []interface{}{s[i-1 : j]}
+	}
+	char := func(args []interface{}) []interface{} {
+		b := make([]byte, len(args))
+		for k, a := range args {
+			c := luaInt(a)
+			if c < 0 || c > 255 {
+				luaFail("string.char: value %d out of range", c)
+			}
+			b[k] = byte(c)
+		}
+		return []interface{}{string(b)}
+	}
+	bxor := func(args []interface{}) []interface{} {
+		var r int32
+		for _, a := range args {
+			// LuaBitOp normalizes numbers to 32-bit integers.
+			r ^= int32(int64(luaNumber(a)))
+		}
+		return []interface{}{float64(r)}
+	}
+	ipairs := func(args []interface{}) []interface{} {
+		iter := luaFunction(func(args []interface{}) []interface{} {
+			i := luaNumber(args[1]) + 1
+			v := args[0].(*luaTable).fields[i]
+			if v == nil {
+				return []interface{}{nil}
+			}
+			return []interface{}{i, v}
+		})
+		return []interface{}{iter, args[0], 0.0}
+	}
+	lib := func(fns map[string]luaFunction) *luaTable {
+		t := newLuaTable()
+		for name, f := range fns {
+			t.fields[name] = f
+		}
+		return t
+	}
+	return map[string]interface{}{
+		"math":   lib(map[string]luaFunction{"floor": func(args []interface{}) []interface{} { return []interface{}{math.Floor(luaNumber(args[0]))} }}),
+		"bit":    lib(map[string]luaFunction{"bxor": bxor}),
+		"string": lib(map[string]luaFunction{"byte": byteAt, "sub": sub, "char": char}),
+		"ipairs": luaFunction(ipairs),
+	}
+}
+
+// runLua runs src with the given globals added to the standard library and returns the
+// values it returns, or the error it raised.
+func runLua(src string, globals map[string]interface{}) (ret []interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg, ok := r.(luaError)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("lua: %s", msg)
+		}
+	}()
+	p := &luaParser{toks: luaLex(src)}
+	chunk := p.block()
+	if p.peek().kind != "eof" {
+		luaFail("unexpected %q", p.peek().text)
+	}
+	env := luaStdlib()
+	for name, v := range globals {
+		env[name] = v
+	}
+	ret, _ = chunk(&luaScope{vars: make(map[string]*interface{}), globals: env})
+	return ret, nil
+}
+
+func TestRunLua(t *testing.T) {
+	ret, err := runLua(`
+local function fib(n)
+  if n < 2 then return n end
+  return fib(n - 1) + fib(n - 2)
+end
+local t, s = {}, 0
+for i = 1, 10 do t[i] = fib(i) end
+for _, v in ipairs(t) do s = s + v end
+return s, #t, 7 % 3, -7 % 3, 2 ^ 10, 'a' .. 1, string.byte('AB', 2), bit.bxor(5, 3), not nil and 1 or 2
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{143.0, 10.0, 1.0, 2.0, 1024.0, "a1", 66.0, 6.0, 1.0}
+	if fmt.Sprint(ret) != fmt.Sprint(want) {
+		t.Errorf("runLua() = %v, want %v", ret, want)
+	}
+	if _, err := runLua(`return 1 + {}`, nil); err == nil {
+		t.Error("runLua() of invalid arithmetic succeeded")
+	}
+}
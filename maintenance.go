@@ -0,0 +1,94 @@
+package cuckoo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MaintenanceTask is a task run periodically by StartMaintenance.
+type MaintenanceTask struct {
+	// Name identifies the task in errors.
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+	// Final makes the task also run once when maintenance is stopped, e.g. to persist the
+	// last state. It runs with a context that is not canceled.
+	Final bool
+}
+
+// CompactTask returns a task compacting cf, see Filter.Compact.
+func CompactTask(cf *Filter, interval time.Duration) MaintenanceTask {
+	return MaintenanceTask{Name: "compact", Interval: interval, Run: func(context.Context) error {
+		cf.Compact()
+		return nil
+	}}
+}
+
+// SweepTask returns a task evicting expired items of ef, see ExpiringFilter.Sweep.
+func SweepTask(ef *ExpiringFilter, interval time.Duration) MaintenanceTask {
+	return MaintenanceTask{Name: "sweep", Interval: interval, Run: func(context.Context) error {
+		ef.Sweep()
+		return nil
+	}}
+}
+
+// SampleTask returns a task reporting the count and load factor of cf to sample, e.g. to
+// export them as metrics.
+func SampleTask(cf *Filter, interval time.Duration, sample func(count uint, loadFactor float64)) MaintenanceTask {
+	return MaintenanceTask{Name: "sample", Interval: interval, Run: func(context.Context) error {
+		sample(cf.Count(), cf.LoadFactor())
+		return nil
+	}}
+}
+
+// PersistTask returns a task calling persist, e.g. to write a snapshot with SnapshotTo. It
+// also runs when maintenance is stopped, so the last state is persisted.
+func PersistTask(interval time.Duration, persist func(ctx context.Context) error) MaintenanceTask {
+	return MaintenanceTask{Name: "persist", Interval: interval, Run: persist, Final: true}
+}
+
+// Maintenance runs maintenance tasks in the background, see StartMaintenance.
+type Maintenance struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// StartMaintenance runs every task in its own goroutine at its interval until ctx is done or
+// Stop is called. Errors of tasks are passed to onError, which may be nil, together with the
+// name of the task; a failing task keeps running at its interval.
+func StartMaintenance(ctx context.Context, onError func(task string, err error), tasks ...MaintenanceTask) *Maintenance {
+	ctx, cancel := context.WithCancel(ctx)
+	m := &Maintenance{cancel: cancel}
+	run := func(ctx context.Context, task MaintenanceTask) {
+		if err := task.Run(ctx); err != nil && onError != nil {
+			onError(task.Name, err)
+		}
+	}
+	for _, task := range tasks {
+		m.wg.Add(1)
+		go func(task MaintenanceTask) {
+			defer m.wg.Done()
+			ticker := time.NewTicker(task.Interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					if task.Final {
+						run(context.Background(), task)
+					}
+					return
+				case <-ticker.C:
+					run(ctx, task)
+				}
+			}
+		}(task)
+	}
+	return m
+}
+
+// Stop stops all tasks and waits until running and final tasks have returned.
+func (m *Maintenance) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
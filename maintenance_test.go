@@ -0,0 +1,62 @@
+package cuckoo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartMaintenance(t *testing.T) {
+	cf := NewFilter(1000)
+	cf.Insert([]byte("one"))
+
+	var lock sync.Mutex
+	var samples []uint
+	persisted := 0
+	var errs []string
+	errFailed := errors.New("failed")
+	m := StartMaintenance(context.Background(), func(task string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		errs = append(errs, task)
+	},
+		CompactTask(cf, time.Millisecond),
+		SampleTask(cf, time.Millisecond, func(count uint, _ float64) {
+			lock.Lock()
+			defer lock.Unlock()
+			samples = append(samples, count)
+		}),
+		PersistTask(time.Hour, func(context.Context) error {
+			lock.Lock()
+			defer lock.Unlock()
+			persisted++
+			return nil
+		}),
+		MaintenanceTask{Name: "failing", Interval: time.Millisecond, Run: func(context.Context) error { return errFailed }},
+	)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lock.Lock()
+		ready := len(samples) > 0 && len(errs) > 0
+		lock.Unlock()
+		if ready || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	m.Stop()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(samples) == 0 || samples[0] != 1 {
+		t.Errorf("Got samples %v, want count 1", samples)
+	}
+	if len(errs) == 0 || errs[0] != "failing" {
+		t.Errorf("Got errors of tasks %v, want failing", errs)
+	}
+	if persisted != 1 {
+		t.Errorf("Persist task ran %d times, want once on Stop()", persisted)
+	}
+}
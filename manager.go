@@ -0,0 +1,388 @@
+package cuckoo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FilterConfig configures a filter owned by a Manager.
+type FilterConfig struct {
+	// Capacity is the number of elements the filter is created for, see NewFilter.
+	Capacity uint
+	// FPP, if set, is the false positive rate the filter must not exceed with Capacity items.
+	// The filter is created for more elements if needed.
+	FPP float64
+	// TTL is the time without access after which DisposeIdle disposes the filter. Zero keeps
+	// the filter until it is removed.
+	TTL time.Duration
+	// Options are applied when the filter is created.
+	Options []Option
+	// Source, if set, inserts all keys of the filter with insert. Reconfigure uses it to
+	// rebuild the filter for the new configuration.
+	Source func(name string, insert func(key []byte) bool) error
+}
+
+// fppElements returns the number of elements a filter must be created for to keep the false
+// positive rate at FPP with Capacity items: with load factor a, a lookup compares
+// 2*bucketSize*a fingerprints.
+func (c FilterConfig) fppElements() float64 {
+	return math.Ceil(2 * bucketSize * float64(c.Capacity) / (c.FPP * maxFingerprint))
+}
+
+// validate returns an error wrapping ErrUnsupported if no filter has the configuration, or
+// ErrTooLarge if the filter does not fit into the address space.
+func (c FilterConfig) validate() error {
+	if c.FPP < 0 || c.FPP >= 1 || c.FPP > 0 && c.fppElements() > float64(maxInt/bucketSize) {
+		return fmt.Errorf("%w: false positive rate %v for %d elements", ErrUnsupported, c.FPP, c.Capacity)
+	}
+	_, err := bucketsFor(uint64(c.numElements()))
+	return err
+}
+
+// numElements returns the number of elements to create the filter for. The configuration must
+// be valid.
+func (c FilterConfig) numElements() uint {
+	if c.FPP <= 0 {
+		return c.Capacity
+	}
+	if n := c.fppElements(); n > float64(c.Capacity) {
+		return uint(n)
+	}
+	return c.Capacity
+}
+
+// newFilter returns an empty filter of the configuration.
+func (c FilterConfig) newFilter() *Filter {
+	return NewFilter(c.numElements(), c.Options...)
+}
+
+// ManagerStats aggregates the filters of a Manager.
+type ManagerStats struct {
+	// Filters is the number of live filters.
+	Filters int
+	// Count and Capacity are summed over all live filters; LoadFactor is Count per Capacity.
+	Stats
+	// Created and Disposed are the numbers of filters created and disposed so far. Removed
+	// filters count as disposed.
+	Created, Disposed uint64
+	// Rebuilds and FailedRebuilds are the numbers of completed and failed rebuilds, see
+	// Reconfigure.
+	Rebuilds, FailedRebuilds uint64
+}
+
+// Manager owns named filters, e.g. one per customer or topic. Filters are created on first
+// access with the configuration returned for their name and disposed when idle for longer
+// than their TTL, and can be reconfigured at runtime. It is safe for concurrent use.
+type Manager struct {
+	config func(name string) FilterConfig
+
+	lock sync.Mutex
+	// filters holds the live filters, overrides the configurations set by Reconfigure.
+	filters                  map[string]*managedFilter
+	overrides                map[string]FilterConfig
+	created, disposed        uint64
+	rebuilds, failedRebuilds uint64
+	now                      func() time.Time
+}
+
+// managedFilter is a filter owned by a Manager. Operations hold the lock for reading, while
+// replacing the filter holds it for writing. The Manager's lock guards config and lastUsed.
+type managedFilter struct {
+	lock sync.RWMutex
+	cf   *Filter
+	// next is the filter being rebuilt by Reconfigure, or nil. It receives all writes.
+	next     *Filter
+	config   FilterConfig
+	lastUsed time.Time
+}
+
+// NewManager returns a Manager creating filters with the configuration config returns for
+// their name. config is called with the manager's lock held and must not call the manager.
+func NewManager(config func(name string) FilterConfig) *Manager {
+	return &Manager{
+		config:    config,
+		filters:   make(map[string]*managedFilter),
+		overrides: make(map[string]FilterConfig),
+		now:       time.Now,
+	}
+}
+
+// configFor returns the configuration of the filter with the given name. The caller must hold
+// the lock.
+func (m *Manager) configFor(name string) FilterConfig {
+	if config, ok := m.overrides[name]; ok {
+		return config
+	}
+	return m.config(name)
+}
+
+// use returns the filter with the given name, creating it if there is none, and marks it used.
+func (m *Manager) use(name string) *managedFilter {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+	mf, ok := m.filters[name]
+	if !ok {
+		config := m.configFor(name)
+		mf = &managedFilter{cf: config.newFilter(), config: config}
+		m.filters[name] = mf
+		m.created++
+	}
+	mf.lastUsed = now
+	return mf
+}
+
+// Get returns the filter with the given name, creating it if there is none, and marks it used.
+// Reconfigure replaces the filter: while it rebuilds, writes to the returned filter are not
+// carried over, so prefer Insert, Lookup and Delete of the Manager, which are.
+func (m *Manager) Get(name string) *Filter {
+	mf := m.use(name)
+	mf.lock.RLock()
+	defer mf.lock.RUnlock()
+
+	return mf.cf
+}
+
+// Peek returns the filter with the given name without creating it or marking it used.
+func (m *Manager) Peek(name string) (*Filter, bool) {
+	m.lock.Lock()
+	mf, ok := m.filters[name]
+	m.lock.Unlock()
+	if !ok {
+		return nil, false
+	}
+	mf.lock.RLock()
+	defer mf.lock.RUnlock()
+
+	return mf.cf, true
+}
+
+// Insert inserts key into the filter with the given name like Filter.Insert, creating the
+// filter if there is none.
+func (m *Manager) Insert(name string, key []byte) bool {
+	mf := m.use(name)
+	mf.lock.RLock()
+	defer mf.lock.RUnlock()
+
+	if mf.next != nil {
+		mf.next.Insert(key)
+	}
+	return mf.cf.Insert(key)
+}
+
+// Lookup returns true if key is in the filter with the given name, creating the filter if
+// there is none.
+func (m *Manager) Lookup(name string, key []byte) bool {
+	mf := m.use(name)
+	mf.lock.RLock()
+	defer mf.lock.RUnlock()
+
+	return mf.cf.Lookup(key)
+}
+
+// Delete deletes key from the filter with the given name like Filter.Delete, creating the
+// filter if there is none.
+func (m *Manager) Delete(name string, key []byte) bool {
+	mf := m.use(name)
+	mf.lock.RLock()
+	defer mf.lock.RUnlock()
+
+	if mf.next != nil {
+		mf.next.Delete(key)
+	}
+	return mf.cf.Delete(key)
+}
+
+// Reconfigure changes the configuration of the filter with the given name at runtime, also
+// for filters created later. The returned channel receives nil once the configuration is in
+// effect, or an error.
+//
+// A new TTL applies immediately. If the new configuration needs a new filter, it replaces the
+// current one transparently: with a Source, the new filter is rebuilt in the background,
+// receiving the writes made meanwhile through the Manager, while the current one keeps
+// serving. Without a Source, fingerprints are migrated directly, which only works if the new
+// filter hashes keys the same way and has at most as many buckets; otherwise it fails with
+// ErrUnsupported and the filter keeps its configuration.
+func (m *Manager) Reconfigure(name string, config FilterConfig) <-chan error {
+	done := make(chan error, 1)
+	if err := config.validate(); err != nil {
+		done <- err
+		return done
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	mf, ok := m.filters[name]
+	if !ok {
+		m.overrides[name] = config
+		done <- nil
+		return done
+	}
+	mf.config.TTL = config.TTL
+
+	mf.lock.Lock()
+	defer mf.lock.Unlock()
+
+	if mf.next != nil {
+		done <- fmt.Errorf("%w: filter %q is already being rebuilt", ErrUnsupported, name)
+		return done
+	}
+	next := config.newFilter()
+	if config.Source == nil {
+		if err := migrateFingerprints(mf.cf, next); err != nil {
+			done <- err
+			return done
+		}
+		mf.cf = next
+		mf.config = config
+		m.overrides[name] = config
+		m.rebuilds++
+		done <- nil
+		return done
+	}
+	mf.next = next
+	go func() {
+		err := config.Source(name, next.Insert)
+		m.finishRebuild(name, mf, config, err)
+		done <- err
+	}()
+	return done
+}
+
+// finishRebuild replaces the filter of mf by the rebuilt one, unless the rebuild failed.
+func (m *Manager) finishRebuild(name string, mf *managedFilter, config FilterConfig, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	mf.lock.Lock()
+	defer mf.lock.Unlock()
+
+	if err != nil {
+		mf.next = nil
+		m.failedRebuilds++
+		return
+	}
+	mf.cf, mf.next = mf.next, nil
+	mf.config = config
+	// A concurrent Reconfigure of a disposed filter may have set another override.
+	if m.filters[name] == mf {
+		m.overrides[name] = config
+	}
+	m.rebuilds++
+}
+
+// migrateFingerprints moves the fingerprints of cf, including stashed ones, into the empty
+// filter next, folding them if next has fewer buckets.
+func migrateFingerprints(cf, next *Filter) error {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	if next.seed != cf.seed || next.hashVersion != cf.hashVersion || next.altScheme != cf.altScheme || next.transform != nil || cf.transform != nil {
+		return fmt.Errorf("%w: fingerprints cannot be migrated to different hashing without a Source", ErrUnsupported)
+	}
+	if len(next.buckets) > len(cf.buckets) {
+		return fmt.Errorf("%w: fingerprints cannot be migrated from %d to %d buckets without a Source", ErrUnsupported, len(cf.buckets), len(next.buckets))
+	}
+	staged, ok := cf.fold(uint(len(next.buckets)))
+	// Stashed fingerprints may need the stash of next again.
+	staged.stash = next.stash
+	for _, e := range cf.stash.all() {
+		ok = ok && staged.insertFingerprint(e.fp, e.i&staged.bucketIndexMask)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %d items do not fit into %d buckets", ErrFull, cf.count, len(next.buckets))
+	}
+	copy(next.buckets, staged.buckets)
+	next.count = staged.count
+	next.rebuildSummary()
+	return nil
+}
+
+// Remove disposes the filter with the given name. Returns false if there is none.
+func (m *Manager) Remove(name string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.filters[name]; !ok {
+		return false
+	}
+	delete(m.filters, name)
+	delete(m.overrides, name)
+	m.disposed++
+	return true
+}
+
+// Names returns the sorted names of the live filters.
+func (m *Manager) Names() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := make([]string, 0, len(m.filters))
+	for name := range m.filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DisposeIdle disposes the filters not used for longer than their TTL and returns their number.
+// Callers still holding a disposed filter can keep using it, but Get creates a new one.
+func (m *Manager) DisposeIdle() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+	n := 0
+	for name, mf := range m.filters {
+		if mf.config.TTL > 0 && now.Sub(mf.lastUsed) > mf.config.TTL {
+			delete(m.filters, name)
+			n++
+		}
+	}
+	m.disposed += uint64(n)
+	return n
+}
+
+// Run calls DisposeIdle every interval until ctx is done.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.DisposeIdle()
+		}
+	}
+}
+
+// Stats returns aggregate statistics of the filters.
+func (m *Manager) Stats() ManagerStats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	stats := ManagerStats{
+		Filters:        len(m.filters),
+		Created:        m.created,
+		Disposed:       m.disposed,
+		Rebuilds:       m.rebuilds,
+		FailedRebuilds: m.failedRebuilds,
+	}
+	for _, mf := range m.filters {
+		mf.lock.RLock()
+		s := mf.cf.Stats()
+		mf.lock.RUnlock()
+		stats.Count += s.Count
+		stats.Capacity += s.Capacity
+	}
+	if stats.Capacity > 0 {
+		stats.LoadFactor = float64(stats.Count) / float64(stats.Capacity)
+	}
+	return stats
+}
@@ -0,0 +1,170 @@
+package cuckoo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestManager(config func(name string) FilterConfig) (*Manager, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	m := NewManager(config)
+	m.now = clock.now
+	return m, clock
+}
+
+func TestManager(t *testing.T) {
+	m, clock := newTestManager(func(name string) FilterConfig {
+		if name == "big" {
+			return FilterConfig{Capacity: 10000}
+		}
+		return FilterConfig{Capacity: 100, TTL: time.Minute, Options: []Option{WithHashSeed(7)}}
+	})
+	if _, ok := m.Peek("a"); ok {
+		t.Fatal("Peek() found a filter before creation")
+	}
+	a := m.Get("a")
+	a.Insert([]byte("x"))
+	if m.Get("a") != a || a.HashSeed() != 7 {
+		t.Error("Get() did not return the filter created with the configuration")
+	}
+	big := m.Get("big")
+	if big.Cap() <= a.Cap() || big.HashSeed() != defaultHashSeed {
+		t.Errorf("per-filter configuration ignored: caps %d, %d", big.Cap(), a.Cap())
+	}
+	m.Get("b")
+	if got := m.Names(); !reflect.DeepEqual(got, []string{"a", "b", "big"}) {
+		t.Errorf("Names() = %v", got)
+	}
+
+	clock.t = clock.t.Add(45 * time.Second)
+	m.Get("a")
+	clock.t = clock.t.Add(45 * time.Second)
+	if n := m.DisposeIdle(); n != 1 {
+		t.Errorf("DisposeIdle() = %d, want 1", n)
+	}
+	if _, ok := m.Peek("b"); ok {
+		t.Error("idle filter b was not disposed")
+	}
+	if _, ok := m.Peek("big"); !ok {
+		t.Error("filter without TTL was disposed")
+	}
+	if !m.Remove("big") || m.Remove("big") {
+		t.Error("Remove() failed")
+	}
+
+	stats := m.Stats()
+	want := ManagerStats{Filters: 1, Stats: a.Stats(), Created: 3, Disposed: 2}
+	if stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestManagerRun(t *testing.T) {
+	m, clock := newTestManager(func(string) FilterConfig {
+		return FilterConfig{Capacity: 100, TTL: time.Second}
+	})
+	m.Get("a")
+	clock.t = clock.t.Add(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); len(m.Names()) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("Run() did not dispose the idle filter")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestManagerReconfigure(t *testing.T) {
+	m, _ := newTestManager(func(string) FilterConfig { return FilterConfig{Capacity: 1000} })
+	for i := 0; i < 100; i++ {
+		m.Insert("a", []byte(strconv.Itoa(i)))
+	}
+	before := m.Get("a").Cap()
+
+	// Fewer buckets: the fingerprints are folded into the new filter.
+	if err := <-m.Reconfigure("a", FilterConfig{Capacity: 200, TTL: time.Hour}); err != nil {
+		t.Fatalf("Reconfigure() to smaller capacity = %v", err)
+	}
+	if got := m.Get("a"); got.Cap() >= before || got.Count() != 100 {
+		t.Errorf("after Reconfigure() cap %d, count %d", got.Cap(), got.Count())
+	}
+	for i := 0; i < 100; i++ {
+		if !m.Lookup("a", []byte(strconv.Itoa(i))) {
+			t.Fatalf("Lookup(%d) = false after migration", i)
+		}
+	}
+
+	// More buckets need a Source.
+	if err := <-m.Reconfigure("a", FilterConfig{Capacity: 100000}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Reconfigure() to larger capacity without Source = %v, want %v", err, ErrUnsupported)
+	}
+	if err := <-m.Reconfigure("a", FilterConfig{Capacity: 100, FPP: 2}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Reconfigure() with FPP 2 = %v, want %v", err, ErrUnsupported)
+	}
+
+	// Filters created later use the new configuration too.
+	if err := <-m.Reconfigure("b", FilterConfig{Capacity: 100, FPP: 1e-6}); err != nil {
+		t.Fatal(err)
+	}
+	// 2*bucketSize*100 fingerprints compared per lookup at a rate of 1e-6 need 12207 slots.
+	if got := m.Get("b").Cap(); got < 12207 {
+		t.Errorf("filter for FPP 1e-6 has %d slots", got)
+	}
+
+	stats := m.Stats()
+	if stats.Rebuilds != 1 {
+		t.Errorf("Stats().Rebuilds = %d, want 1", stats.Rebuilds)
+	}
+}
+
+func TestManagerReconfigureSource(t *testing.T) {
+	m, _ := newTestManager(func(string) FilterConfig { return FilterConfig{Capacity: 100} })
+	for i := 0; i < 90; i++ {
+		m.Insert("a", []byte(strconv.Itoa(i)))
+	}
+	started, resume := make(chan struct{}), make(chan struct{})
+	done := m.Reconfigure("a", FilterConfig{
+		Capacity: 10000,
+		Source: func(name string, insert func([]byte) bool) error {
+			close(started)
+			<-resume
+			for i := 0; i < 90; i++ {
+				insert([]byte(strconv.Itoa(i)))
+			}
+			return nil
+		},
+	})
+	<-started
+	// Writes during the rebuild go to both filters.
+	m.Insert("a", []byte("during"))
+	if m.Get("a").Cap() > 1000 || !m.Lookup("a", []byte("during")) {
+		t.Error("the current filter stopped serving during the rebuild")
+	}
+	close(resume)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Get("a"); got.Cap() < 10000 || got.Count() != 91 || !got.Lookup([]byte("during")) {
+		t.Errorf("rebuilt filter has cap %d, count %d", got.Cap(), got.Count())
+	}
+
+	failing := errors.New("source unavailable")
+	done = m.Reconfigure("a", FilterConfig{
+		Capacity: 100000,
+		Source:   func(string, func([]byte) bool) error { return failing },
+	})
+	if err := <-done; err != failing || m.Get("a").Cap() > 100000/2 || m.Stats().FailedRebuilds != 1 {
+		t.Errorf("failed rebuild = %v, cap %d", err, m.Get("a").Cap())
+	}
+}
@@ -0,0 +1,200 @@
+package cuckoo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+)
+
+// memcachedMaxKey is the maximum key length accepted by memcached.
+const memcachedMaxKey = 250
+
+// MemcachedServer serves a set over a subset of the memcached text protocol, so that
+// existing memcached clients can use it as a dedup service:
+//
+//	add <key> <flags> <exptime> <bytes> [noreply]  inserts key, replying NOT_STORED if it was present
+//	get <key>*                                      returns an empty value for every present key
+//	delete <key> [noreply]                          deletes key
+//
+// The data block of add is read and ignored, as are flags and exptime. Clients that treat
+// add as "set if absent" therefore get the filter's dedup semantics unchanged.
+type MemcachedServer struct {
+	set ApproxSet
+}
+
+// NewMemcachedServer returns a server for set, which must be safe for concurrent use.
+func NewMemcachedServer(set ApproxSet) *MemcachedServer {
+	return &MemcachedServer{set: set}
+}
+
+// Serve accepts connections on l and serves each of them in its own goroutine. It returns
+// when l.Accept fails, e.g. after l is closed.
+func (s *MemcachedServer) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			s.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn serves requests read from rw until it is closed, a read fails or the client
+// sends quit.
+func (s *MemcachedServer) ServeConn(rw io.ReadWriter) error {
+	r := bufio.NewReader(rw)
+	w := bufio.NewWriter(rw)
+	for {
+		line, err := r.ReadSlice('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if errors.Is(err, bufio.ErrBufferFull) {
+				// Never a valid command line; the connection cannot be resynchronized.
+				w.WriteString("CLIENT_ERROR line too long\r\n")
+				return w.Flush()
+			}
+			return err
+		}
+		fields := bytes.Fields(line)
+		if len(fields) == 0 {
+			w.WriteString("ERROR\r\n")
+		} else {
+			quit, err := s.handle(r, w, fields)
+			if err != nil || quit {
+				w.Flush()
+				return err
+			}
+		}
+		if r.Buffered() == 0 {
+			// Flush once all pipelined requests are handled.
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle executes the command with the given fields, writing its reply to w.
+func (s *MemcachedServer) handle(r *bufio.Reader, w *bufio.Writer, fields [][]byte) (quit bool, err error) {
+	cmd, args := string(fields[0]), fields[1:]
+	noreply := len(args) > 0 && string(args[len(args)-1]) == "noreply"
+	reply := func(msg string) {
+		if !noreply {
+			w.WriteString(msg)
+		}
+	}
+
+	switch cmd {
+	case "add":
+		if noreply {
+			args = args[:len(args)-1]
+		}
+		if len(args) != 4 || !validMemcachedKey(args[0]) {
+			w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return false, nil
+		}
+		n, err := strconv.ParseUint(string(args[3]), 10, 31)
+		if err != nil {
+			w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return false, nil
+		}
+		// The key points into the buffer of r, which skipping the data block refills.
+		key := append([]byte(nil), args[0]...)
+		// Skip the data block and its terminating \r\n.
+		if _, err := r.Discard(int(n) + 2); err != nil {
+			return false, err
+		}
+		switch s.add(key) {
+		case memcachedAdded:
+			reply("STORED\r\n")
+		case memcachedPresent:
+			reply("NOT_STORED\r\n")
+		default:
+			reply("SERVER_ERROR filter is full\r\n")
+		}
+	case "get", "gets":
+		if len(args) == 0 {
+			w.WriteString("ERROR\r\n")
+			return false, nil
+		}
+		for _, key := range args {
+			if validMemcachedKey(key) && s.set.Lookup(key) {
+				w.WriteString("VALUE ")
+				w.Write(key)
+				w.WriteString(" 0 0\r\n\r\n")
+			}
+		}
+		w.WriteString("END\r\n")
+	case "delete":
+		if noreply {
+			args = args[:len(args)-1]
+		}
+		if len(args) != 1 || !validMemcachedKey(args[0]) {
+			w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return false, nil
+		}
+		if s.set.Delete(args[0]) {
+			reply("DELETED\r\n")
+		} else {
+			reply("NOT_FOUND\r\n")
+		}
+	case "version":
+		w.WriteString("VERSION cuckoofilter\r\n")
+	case "quit":
+		return true, nil
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return false, nil
+}
+
+const (
+	memcachedAdded = iota
+	memcachedPresent
+	memcachedFull
+)
+
+func (s *MemcachedServer) add(key []byte) int {
+	if c, ok := s.set.(interface {
+		ContainsOrAdd([]byte) (bool, bool)
+	}); ok {
+		// Atomic on filters that support it.
+		wasPresent, added := c.ContainsOrAdd(key)
+		switch {
+		case wasPresent:
+			return memcachedPresent
+		case added:
+			return memcachedAdded
+		}
+		return memcachedFull
+	}
+	if s.set.Lookup(key) {
+		return memcachedPresent
+	}
+	if s.set.Insert(key) {
+		return memcachedAdded
+	}
+	return memcachedFull
+}
+
+// validMemcachedKey returns true if key is a valid memcached key: at most 250 bytes without
+// control characters or whitespace.
+func validMemcachedKey(key []byte) bool {
+	if len(key) > memcachedMaxKey {
+		return false
+	}
+	for _, c := range key {
+		if c <= ' ' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
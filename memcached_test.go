@@ -0,0 +1,85 @@
+package cuckoo
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestMemcachedServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Listen(): %v", err)
+	}
+	defer l.Close()
+	go NewMemcachedServer(NewFilter(1000)).Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for _, tc := range []struct {
+		request string
+		want    []string
+	}{
+		{"add one 0 0 1\r\nx\r\n", []string{"STORED"}},
+		{"add one 0 0 0\r\n\r\n", []string{"NOT_STORED"}},
+		{"add two 0 0 5 noreply\r\nhello\r\nversion\r\n", []string{"VERSION cuckoofilter"}},
+		{"get one two three\r\n", []string{"VALUE one 0 0", "", "VALUE two 0 0", "", "END"}},
+		{"delete one\r\n", []string{"DELETED"}},
+		{"delete one\r\n", []string{"NOT_FOUND"}},
+		{"get one\r\n", []string{"END"}},
+		{"add bad\r\n", []string{"CLIENT_ERROR bad command line format"}},
+		{"set one 0 0 0\r\n", []string{"ERROR"}},
+	} {
+		if _, err := conn.Write([]byte(tc.request)); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tc.want {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSuffix(line, "\r\n"); got != want {
+				t.Errorf("Request %q: got %q, want %q", tc.request, got, want)
+			}
+		}
+	}
+}
+
+// chunkedConn is a connection whose reads return at most 64 bytes.
+type chunkedConn struct {
+	io.Reader
+	bytes.Buffer
+}
+
+func (c *chunkedConn) Read(p []byte) (int, error) {
+	if len(p) > 64 {
+		p = p[:64]
+	}
+	return c.Reader.Read(p)
+}
+
+func TestMemcachedServer_LargeValue(t *testing.T) {
+	// The data block is larger than the read buffer, so skipping it refills the buffer.
+	value := strings.Repeat("x", 5000)
+	request := "add mykey 0 0 5000\r\n" + value + "\r\n"
+	conn := &chunkedConn{Reader: strings.NewReader(request)}
+	cf := NewFilter(1000)
+	if err := NewMemcachedServer(cf).ServeConn(conn); err != nil {
+		t.Fatalf("ServeConn() = %v", err)
+	}
+	if got := conn.String(); got != "STORED\r\n" {
+		t.Errorf("reply = %q, want STORED", got)
+	}
+	if !cf.Lookup([]byte("mykey")) || cf.Lookup([]byte("xxxxx")) || cf.Count() != 1 {
+		t.Errorf("after add: Lookup(mykey) = %v, Lookup(xxxxx) = %v, Count() = %d, want true, false, 1",
+			cf.Lookup([]byte("mykey")), cf.Lookup([]byte("xxxxx")), cf.Count())
+	}
+}
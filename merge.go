@@ -0,0 +1,60 @@
+package cuckoo
+
+import "fmt"
+
+// Merge inserts all items of other into cf. The merge is all-or-nothing: fingerprints are
+// placed into a copy of the buckets, so if cf cannot hold all items, it is left unchanged and
+// ErrFull is returned instead of being left half-merged.
+//
+// other may have more buckets than cf, in which case its fingerprints are folded into the
+// buckets of cf, but not fewer: bucket indices are derived from hash bits that are not stored,
+// so a filter cannot be grown to make room. Use EstimateUnionCount to size cf beforehand.
+// Both filters must share the hash seed, hashing version and alternate index scheme.
+func (cf *Filter) Merge(other *Filter) error {
+	if cf == other {
+		return fmt.Errorf("%w: cannot merge a filter into itself", ErrIncompatible)
+	}
+	// Copy the fingerprints of other first, so the filters are never locked at the same time.
+	other.lock.RLock()
+	fps := other.storedFingerprints()
+	for _, e := range other.stash.all() {
+		fps = append(fps, StoredFP{Bucket: e.i, Fingerprint: uint16(e.fp)})
+	}
+	otherMask, otherSeed, otherHashVersion, otherAltScheme := other.bucketIndexMask, other.seed, other.hashVersion, other.altScheme
+	other.lock.RUnlock()
+
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	if otherSeed != cf.seed || otherHashVersion != cf.hashVersion || otherAltScheme != cf.altScheme {
+		return fmt.Errorf("%w: different hashing", ErrIncompatible)
+	}
+	if otherMask < cf.bucketIndexMask {
+		return fmt.Errorf("%w: got %d buckets, want at least %d", ErrIncompatible, otherMask+1, len(cf.buckets))
+	}
+	if cf.count+uint(len(fps)) > cf.maxItems() {
+		return fmt.Errorf("%w: merging %d into %d items exceeds the limit of %d", ErrFull, len(fps), cf.count, cf.maxItems())
+	}
+
+	staged := newFilter(make([]bucket, len(cf.buckets)))
+	staged.seed = cf.seed
+	staged.hashVersion = cf.hashVersion
+	staged.altScheme = cf.altScheme
+	staged.count = cf.count
+	for i := range cf.buckets {
+		if !cf.stale(uint(i)) {
+			staged.buckets[i] = cf.buckets[i]
+		}
+	}
+	for n, fp := range fps {
+		if !staged.insertFingerprint(fingerprint(fp.Fingerprint), fp.Bucket&cf.bucketIndexMask) {
+			return fmt.Errorf("%w: placed %d of %d items", ErrFull, n, len(fps))
+		}
+	}
+	copy(cf.buckets, staged.buckets)
+	cf.count = staged.count
+	cf.generations = nil
+	cf.rebuildSummary()
+	cf.log.checkLoad(cf.count, cf.Cap())
+	return nil
+}
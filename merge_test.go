@@ -0,0 +1,60 @@
+package cuckoo
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	a := NewFilter(2000)
+	b := NewFilter(4000)
+	for i := 0; i < 500; i++ {
+		a.Insert([]byte(strconv.Itoa(i)))
+		b.Insert([]byte(strconv.Itoa(i + 1000)))
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge() = %v", err)
+	}
+	if a.Count() != 1000 {
+		t.Errorf("Count() after Merge() = %d, want 1000", a.Count())
+	}
+	for i := 0; i < 500; i++ {
+		for _, data := range [][]byte{[]byte(strconv.Itoa(i)), []byte(strconv.Itoa(i + 1000))} {
+			if !a.Lookup(data) {
+				t.Fatalf("Lookup(%q) after Merge() = false, want true", data)
+			}
+		}
+	}
+
+	if err := b.Merge(a); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Merge() into a larger filter = %v, want %v", err, ErrIncompatible)
+	}
+	if err := a.Merge(a); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Merge() into itself = %v, want %v", err, ErrIncompatible)
+	}
+	if err := a.Merge(NewFilter(2000, WithHashSeed(1))); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Merge() of a different seed = %v, want %v", err, ErrIncompatible)
+	}
+}
+
+func TestMerge_FullLeavesReceiverUnchanged(t *testing.T) {
+	a := NewFilter(1000)
+	b := NewFilter(1000)
+	// Together, the filters have a load factor of 0.99, which cuckoo hashing cannot reach.
+	for i := 0; i < a.Cap()*85/100; i++ {
+		a.Insert([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < b.Cap()*14/100; i++ {
+		b.Insert([]byte(strconv.Itoa(-i - 1)))
+	}
+	want := append([]bucket(nil), a.buckets...)
+	count := a.Count()
+	if err := a.Merge(b); !errors.Is(err, ErrFull) {
+		t.Fatalf("Merge() = %v, want %v", err, ErrFull)
+	}
+	if !reflect.DeepEqual(a.buckets, want) || a.Count() != count {
+		t.Errorf("Merge() failing with ErrFull modified the receiver")
+	}
+}
@@ -0,0 +1,184 @@
+package cuckoo
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Kinds of operations sampled by WithSampledMetrics.
+const (
+	sampledInsert = iota
+	sampledLookup
+	sampledDelete
+	numSampledOps
+)
+
+// WithSampledMetrics records the latency of 1 in every n calls of Insert, Lookup and Delete,
+// and the kickouts of sampled inserts, see SampledMetrics. Unsampled calls only draw a random
+// number, so detailed metrics can be collected in production with negligible overhead. The
+// rate can be changed at runtime with SetMetricsSampling; n <= 0 starts with sampling paused.
+func WithSampledMetrics(n int) Option {
+	return func(cf *Filter) {
+		cf.metrics = &opSampler{}
+		cf.metrics.setEvery(n)
+	}
+}
+
+// SampledStats holds the metrics of the sampled operations of one kind. Multiplying Samples
+// by the sampling rate estimates the number of operations.
+type SampledStats struct {
+	Samples uint64
+	// TotalLatency and MaxLatency are the sum and the maximum of the latencies of the sampled
+	// operations, including the time spent waiting for the lock.
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+	// Kickouts and MaxKickouts are the sum and the maximum of the kickouts of sampled
+	// inserts. They are 0 for other operations.
+	Kickouts    uint64
+	MaxKickouts uint64
+}
+
+// MeanLatency returns the mean latency of the sampled operations, or 0 if there are none.
+func (s SampledStats) MeanLatency() time.Duration {
+	if s.Samples == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Samples)
+}
+
+// SampledMetrics holds the metrics recorded by WithSampledMetrics since the filter was
+// created. Like Evictions, they are not reset by Reset.
+type SampledMetrics struct {
+	// Every is the current sampling rate: 1 in Every operations is recorded, or none if it
+	// is 0.
+	Every  int
+	Insert SampledStats
+	Lookup SampledStats
+	Delete SampledStats
+}
+
+// SampledMetrics returns the metrics of sampled operations. It returns the zero value if
+// the filter was not created with WithSampledMetrics.
+func (cf *Filter) SampledMetrics() SampledMetrics {
+	m := cf.metrics
+	if m == nil {
+		return SampledMetrics{}
+	}
+	return SampledMetrics{
+		Every:  int(atomic.LoadInt64(&m.every)),
+		Insert: m.ops[sampledInsert].load(),
+		Lookup: m.ops[sampledLookup].load(),
+		Delete: m.ops[sampledDelete].load(),
+	}
+}
+
+// SetMetricsSampling changes the sampling rate of WithSampledMetrics to 1 in every n
+// operations, or pauses sampling if n <= 0. It is a no-op if the filter was not created with
+// WithSampledMetrics, and safe to call concurrently with other operations.
+func (cf *Filter) SetMetricsSampling(n int) {
+	cf.metrics.setEvery(n)
+}
+
+// opSampler decides which operations are sampled and accumulates their metrics. It is safe
+// for concurrent use, as lookups record samples under the read lock. All methods are safe
+// to call on a nil receiver, which disables sampling.
+type opSampler struct {
+	every int64
+	ops   [numSampledOps]sampledOp
+}
+
+// sampledOp accumulates the metrics of one kind of operation.
+type sampledOp struct {
+	samples, nanos, maxNanos, kickouts, maxKickouts uint64
+}
+
+func (s *opSampler) setEvery(n int) {
+	if s == nil {
+		return
+	}
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt64(&s.every, int64(n))
+}
+
+// sample returns true if the next operation is to be sampled.
+func (s *opSampler) sample() bool {
+	if s == nil {
+		return false
+	}
+	every := atomic.LoadInt64(&s.every)
+	return every > 0 && (every == 1 || rand.Int63n(every) == 0)
+}
+
+func (s *opSampler) record(op int, latency time.Duration, kickouts uint64) {
+	o := &s.ops[op]
+	atomic.AddUint64(&o.samples, 1)
+	atomic.AddUint64(&o.nanos, uint64(latency))
+	storeMax(&o.maxNanos, uint64(latency))
+	atomic.AddUint64(&o.kickouts, kickouts)
+	storeMax(&o.maxKickouts, kickouts)
+}
+
+func (o *sampledOp) load() SampledStats {
+	return SampledStats{
+		Samples:      atomic.LoadUint64(&o.samples),
+		TotalLatency: time.Duration(atomic.LoadUint64(&o.nanos)),
+		MaxLatency:   time.Duration(atomic.LoadUint64(&o.maxNanos)),
+		Kickouts:     atomic.LoadUint64(&o.kickouts),
+		MaxKickouts:  atomic.LoadUint64(&o.maxKickouts),
+	}
+}
+
+// storeMax sets *addr to v if v is larger.
+func storeMax(addr *uint64, v uint64) {
+	for {
+		old := atomic.LoadUint64(addr)
+		if v <= old || atomic.CompareAndSwapUint64(addr, old, v) {
+			return
+		}
+	}
+}
+
+// insertSampled is Insert, recording its latency and kickouts.
+func (cf *Filter) insertSampled(data []byte, fp fingerprint, i1 uint) bool {
+	start := time.Now()
+	var kickouts uint64
+	ok := func() bool {
+		cf.lock.Lock()
+		defer cf.lock.Unlock()
+
+		before := cf.kickouts
+		defer func() { kickouts = cf.kickouts - before }()
+		return cf.insertKey(data, fp, i1)
+	}()
+	cf.metrics.record(sampledInsert, time.Since(start), kickouts)
+	return ok
+}
+
+// lookupSampled is Lookup, recording its latency.
+func (cf *Filter) lookupSampled(fp fingerprint, i1 uint) bool {
+	start := time.Now()
+	ok := func() bool {
+		cf.lock.RLock()
+		defer cf.lock.RUnlock()
+
+		return cf.lookup(fp, i1)
+	}()
+	cf.metrics.record(sampledLookup, time.Since(start), 0)
+	return ok
+}
+
+// deleteSampled is Delete, recording its latency.
+func (cf *Filter) deleteSampled(data []byte, fp fingerprint, i1 uint) bool {
+	start := time.Now()
+	ok := func() bool {
+		cf.lock.Lock()
+		defer cf.lock.Unlock()
+
+		return cf.deleteKey(data, fp, i1)
+	}()
+	cf.metrics.record(sampledDelete, time.Since(start), 0)
+	return ok
+}
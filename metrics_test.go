@@ -0,0 +1,51 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestWithSampledMetrics(t *testing.T) {
+	cf := NewFilter(1000, WithSampledMetrics(1))
+	for i := 0; i < 950; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	for i := 0; i < 100; i++ {
+		cf.Lookup([]byte(strconv.Itoa(i)))
+		cf.Delete([]byte(strconv.Itoa(i)))
+	}
+	m := cf.SampledMetrics()
+	if m.Every != 1 || m.Insert.Samples != 950 || m.Lookup.Samples != 100 || m.Delete.Samples != 100 {
+		t.Fatalf("SampledMetrics() = %+v, want every operation sampled", m)
+	}
+	// Filling a filter to 95% needs kickouts.
+	if m.Insert.Kickouts == 0 || m.Insert.MaxKickouts == 0 || m.Insert.MaxKickouts > m.Insert.Kickouts {
+		t.Errorf("insert kickouts = %d, max %d", m.Insert.Kickouts, m.Insert.MaxKickouts)
+	}
+	if m.Lookup.Kickouts != 0 {
+		t.Errorf("lookup kickouts = %d, want 0", m.Lookup.Kickouts)
+	}
+	if m.Insert.MaxLatency <= 0 || m.Insert.MeanLatency() > m.Insert.MaxLatency {
+		t.Errorf("insert latency: mean %v, max %v", m.Insert.MeanLatency(), m.Insert.MaxLatency)
+	}
+
+	cf.SetMetricsSampling(0)
+	cf.Lookup([]byte("x"))
+	if got := cf.SampledMetrics(); got.Every != 0 || got.Lookup.Samples != 100 {
+		t.Errorf("After SetMetricsSampling(0): SampledMetrics() = %+v", got)
+	}
+	cf.SetMetricsSampling(10)
+	for i := 0; i < 10000; i++ {
+		cf.Lookup([]byte(strconv.Itoa(i)))
+	}
+	if got := cf.SampledMetrics().Lookup.Samples - 100; got < 800 || got > 1200 {
+		t.Errorf("sampled %d of 10000 lookups at 1 in 10", got)
+	}
+
+	plain := NewFilter(100)
+	plain.SetMetricsSampling(1)
+	plain.Insert([]byte("x"))
+	if got := plain.SampledMetrics(); got != (SampledMetrics{}) {
+		t.Errorf("SampledMetrics() without WithSampledMetrics = %+v", got)
+	}
+}
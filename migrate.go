@@ -0,0 +1,31 @@
+package cuckoo
+
+import "fmt"
+
+// Migrate decodes a filter encoded with format version oldVersion, see FormatVersion. Encode
+// of the returned filter writes the current format, so stored filters can be upgraded without
+// access to the original keys.
+//
+// Unlike Decode, Migrate does not detect the format: version 0, the encoding without header
+// of older releases, is decoded as such even if it happens to look like a header.
+func Migrate(old []byte, oldVersion int) (_ *Filter, err error) {
+	defer recoverInvalidState(&err)
+	switch oldVersion {
+	case 0:
+		if err := checkBucketBytes(len(old)); err != nil {
+			return nil, err
+		}
+		cf := newFilter(nil)
+		cf.formatVersion = 0
+		if err := cf.decodeBuckets(old); err != nil {
+			return nil, err
+		}
+		return cf, nil
+	case 1, CurrentFormatVersion, stashFormatVersion:
+		if !hasEncodingHeader(old) || old[4] != uint8(oldVersion) {
+			return nil, fmt.Errorf("%w: missing header of format version %d", ErrCorrupted, oldVersion)
+		}
+		return Decode(old)
+	}
+	return nil, fmt.Errorf("%w: format version %d", ErrUnsupported, oldVersion)
+}
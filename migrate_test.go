@@ -0,0 +1,39 @@
+package cuckoo
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	cf := NewFilter(1000)
+	cf.Insert([]byte("one"))
+	current := cf.Encode()
+	legacy := current[encodingHeaderSize:]
+
+	migrated, err := Migrate(legacy, 0)
+	if err != nil {
+		t.Fatalf("Migrate() = %v", err)
+	}
+	if !migrated.Lookup([]byte("one")) || !bytes.Equal(migrated.Encode(), current) {
+		t.Errorf("Migrate() of headerless encoding differs from filter")
+	}
+	if migrated, err := Migrate(current, CurrentFormatVersion); err != nil || !migrated.Lookup([]byte("one")) {
+		t.Errorf("Migrate() of current encoding = %v", err)
+	}
+
+	for _, tc := range []struct {
+		old     []byte
+		version int
+		want    error
+	}{
+		{legacy[:24], 0, ErrCorrupted},
+		{legacy, CurrentFormatVersion, ErrCorrupted},
+		{current, 7, ErrUnsupported},
+	} {
+		if _, err := Migrate(tc.old, tc.version); !errors.Is(err, tc.want) {
+			t.Errorf("Migrate(%d bytes, %d) error = %v, want %v", len(tc.old), tc.version, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package cuckoo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RangeNode is a Node that exposes its fingerprints for anti-entropy between mirrors.
+// Fingerprint ranges are defined over canonical bucket indexes: the smaller of the two
+// candidate buckets of a fingerprint. This makes ranges independent of where kickouts
+// placed a fingerprint.
+type RangeNode interface {
+	Node
+	// NumBuckets returns the number of buckets of the filter.
+	NumBuckets(ctx context.Context) (uint, error)
+	// RangeDigests splits the canonical bucket indexes into numRanges equal ranges and
+	// returns a digest of the fingerprints in every range.
+	RangeDigests(ctx context.Context, numRanges uint) ([]uint64, error)
+	// RangeFingerprints returns the fingerprints in range r of numRanges, with Bucket set to
+	// the canonical bucket.
+	RangeFingerprints(ctx context.Context, r, numRanges uint) ([]StoredFP, error)
+	// AddFingerprints stores fingerprints, given with their canonical bucket.
+	AddFingerprints(ctx context.Context, fps []StoredFP) error
+}
+
+// LocalFilterNode returns a RangeNode for a filter in this process.
+func LocalFilterNode(cf *Filter) RangeNode {
+	return localFilterNode{localNode{cf}, cf}
+}
+
+type localFilterNode struct {
+	localNode
+	cf *Filter
+}
+
+func (n localFilterNode) NumBuckets(context.Context) (uint, error) {
+	return uint(len(n.cf.buckets)), nil
+}
+
+func (n localFilterNode) RangeDigests(_ context.Context, numRanges uint) ([]uint64, error) {
+	digests := make([]uint64, numRanges)
+	n.cf.forEachCanonical(func(i uint, fp fingerprint) {
+		// Summing makes the digest independent of the order of fingerprints.
+		digests[i*numRanges/uint(len(n.cf.buckets))] += splitmix64(uint64(i)<<16 | uint64(fp))
+	})
+	return digests, nil
+}
+
+func (n localFilterNode) RangeFingerprints(_ context.Context, r, numRanges uint) ([]StoredFP, error) {
+	var fps []StoredFP
+	n.cf.forEachCanonical(func(i uint, fp fingerprint) {
+		if i*numRanges/uint(len(n.cf.buckets)) == r {
+			fps = append(fps, StoredFP{Bucket: i, Fingerprint: uint16(fp)})
+		}
+	})
+	return fps, nil
+}
+
+func (n localFilterNode) AddFingerprints(_ context.Context, fps []StoredFP) error {
+	cf := n.cf
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	for _, fp := range fps {
+		if fp.Bucket >= uint(len(cf.buckets)) || fp.Fingerprint == nullFp {
+			return fmt.Errorf("%w: invalid fingerprint %v", ErrIncompatible, fp)
+		}
+		if !cf.insertFingerprint(fingerprint(fp.Fingerprint), fp.Bucket) {
+			return fmt.Errorf("%w: adding fingerprint %v", ErrFull, fp)
+		}
+	}
+	return nil
+}
+
+// forEachCanonical calls fn for every stored fingerprint with its canonical bucket.
+func (cf *Filter) forEachCanonical(fn func(i uint, fp fingerprint)) {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	cf.forEachFingerprint(func(i, _ uint, fp fingerprint) {
+		if alt := cf.altIndex(fp, i); alt < i {
+			i = alt
+		}
+		fn(i, fp)
+	})
+}
+
+// mirrorSyncRanges is the number of fingerprint ranges compared by Mirror.AntiEntropy.
+const mirrorSyncRanges = 1024
+
+// Mirror is a Node made of several filters holding the same items, e.g. on different hosts.
+// Writes go to all replicas and succeed once the write quorum did; reads succeed once the read
+// quorum answered. AntiEntropy repairs replicas that missed writes or were replaced.
+// A Mirror can be used as a node of a Cluster for sharding on top of replication.
+type Mirror struct {
+	group replicaGroup
+	nodes []RangeNode
+}
+
+var _ Node = (*Mirror)(nil)
+
+// NewMirror returns a Mirror of the given replicas, which must have the same number of
+// buckets. Quorums are clamped to [1, len(replicas)], see Cluster.SetQuorum.
+func NewMirror(replicas []RangeNode, writeQuorum, readQuorum int) *Mirror {
+	m := &Mirror{
+		group: replicaGroup{
+			writeQuorum: clamp(writeQuorum, 1, len(replicas)),
+			readQuorum:  clamp(readQuorum, 1, len(replicas)),
+		},
+		nodes: replicas,
+	}
+	for i, r := range replicas {
+		m.group.names = append(m.group.names, fmt.Sprintf("replica%d", i))
+		m.group.nodes = append(m.group.nodes, r)
+	}
+	return m
+}
+
+// Insert data into all replicas, see Cluster.Insert.
+func (m *Mirror) Insert(ctx context.Context, data []byte) (bool, error) {
+	return m.group.write(ctx, data, Node.Insert)
+}
+
+// Lookup data in the replicas, see Cluster.Lookup.
+func (m *Mirror) Lookup(ctx context.Context, data []byte) (bool, error) {
+	return m.group.read(ctx, data)
+}
+
+// Delete data from all replicas, see Cluster.Delete.
+func (m *Mirror) Delete(ctx context.Context, data []byte) (bool, error) {
+	return m.group.write(ctx, data, Node.Delete)
+}
+
+// AntiEntropy compares digests of fingerprint ranges between all replicas and, for ranges
+// that differ, adds every fingerprint missing on a replica. A fingerprint stored n times on
+// any replica ends up stored n times on all of them. Deletes that a replica missed are
+// therefore undone rather than propagated, which errs on the side of keeping dedup state.
+//
+// Returns the number of fingerprints added. Replicas that fail are skipped and reported in
+// the returned error after all others have been repaired.
+func (m *Mirror) AntiEntropy(ctx context.Context) (int, error) {
+	var firstErr error
+	fail := func(i int, err error) {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", m.group.names[i], err)
+		}
+	}
+
+	var numBuckets uint
+	digests := make([][]uint64, len(m.nodes))
+	for i, n := range m.nodes {
+		nb, err := n.NumBuckets(ctx)
+		if err == nil && numBuckets != 0 && nb != numBuckets {
+			err = fmt.Errorf("%w: %d buckets, want %d", ErrIncompatible, nb, numBuckets)
+		}
+		if err == nil {
+			digests[i], err = n.RangeDigests(ctx, mirrorSyncRanges)
+		}
+		if err != nil {
+			fail(i, err)
+			continue
+		}
+		numBuckets = nb
+	}
+
+	added := 0
+	for r := uint(0); r < mirrorSyncRanges; r++ {
+		var replicas []int
+		differ := false
+		for i := range m.nodes {
+			if digests[i] == nil {
+				continue
+			}
+			if len(replicas) > 0 && digests[i][r] != digests[replicas[0]][r] {
+				differ = true
+			}
+			replicas = append(replicas, i)
+		}
+		if !differ {
+			continue
+		}
+		n, err := m.syncRange(ctx, r, replicas, fail)
+		added += n
+		if err != nil {
+			return added, err
+		}
+	}
+	return added, firstErr
+}
+
+// syncRange adds the fingerprints of range r missing on any of the given replicas.
+func (m *Mirror) syncRange(ctx context.Context, r uint, replicas []int, fail func(int, error)) (int, error) {
+	type entry struct {
+		bucket uint
+		fp     uint16
+	}
+	counts := make([]map[entry]int, len(m.nodes))
+	union := make(map[entry]int)
+	for _, i := range replicas {
+		fps, err := m.nodes[i].RangeFingerprints(ctx, r, mirrorSyncRanges)
+		if err != nil {
+			fail(i, err)
+			continue
+		}
+		counts[i] = make(map[entry]int)
+		for _, fp := range fps {
+			e := entry{fp.Bucket, fp.Fingerprint}
+			counts[i][e]++
+			if counts[i][e] > union[e] {
+				union[e] = counts[i][e]
+			}
+		}
+	}
+	added := 0
+	for _, i := range replicas {
+		if counts[i] == nil {
+			continue
+		}
+		var missing []StoredFP
+		for e, n := range union {
+			for k := counts[i][e]; k < n; k++ {
+				missing = append(missing, StoredFP{Bucket: e.bucket, Fingerprint: e.fp})
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if err := m.nodes[i].AddFingerprints(ctx, missing); err != nil {
+			fail(i, err)
+			continue
+		}
+		added += len(missing)
+	}
+	return added, ctx.Err()
+}
+
+// RunAntiEntropy calls AntiEntropy every interval until ctx is done, reporting the result of
+// every round to report, which may be nil. Run it in its own goroutine.
+func (m *Mirror) RunAntiEntropy(ctx context.Context, interval time.Duration, report func(added int, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			added, err := m.AntiEntropy(ctx)
+			if report != nil {
+				report(added, err)
+			}
+		}
+	}
+}
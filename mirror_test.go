@@ -0,0 +1,98 @@
+package cuckoo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMirror_Quorum(t *testing.T) {
+	ctx := context.Background()
+	a, b := NewFilter(1000), NewFilter(1000)
+	m := NewMirror([]RangeNode{LocalFilterNode(a), LocalFilterNode(b)}, 2, 1)
+
+	if ok, err := m.Insert(ctx, []byte("one")); !ok || err != nil {
+		t.Fatalf("Insert() = %v, %v, want true, nil", ok, err)
+	}
+	if !a.Lookup([]byte("one")) || !b.Lookup([]byte("one")) {
+		t.Errorf("Insert() did not write to all replicas")
+	}
+	if ok, err := m.Lookup(ctx, []byte("one")); !ok || err != nil {
+		t.Errorf("Lookup() = %v, %v, want true, nil", ok, err)
+	}
+
+	// A replica that missed a write still answers with the quorum. With a read quorum of 1,
+	// the replica answering first would decide, so ask both.
+	b.Insert([]byte("two"))
+	both := NewMirror([]RangeNode{LocalFilterNode(a), LocalFilterNode(b)}, 2, 2)
+	if ok, err := both.Lookup(ctx, []byte("two")); !ok || err != nil {
+		t.Errorf("Lookup() of item on one replica = %v, %v, want true, nil", ok, err)
+	}
+
+	down := NewMirror([]RangeNode{LocalFilterNode(a), failingRangeNode{}}, 2, 1)
+	if _, err := down.Insert(ctx, []byte("three")); !errors.Is(err, ErrNoQuorum) {
+		t.Errorf("Insert() with replica down error = %v, want %v", err, ErrNoQuorum)
+	}
+}
+
+func TestMirror_AntiEntropy(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := NewFilter(10000), NewFilter(10000), NewFilter(10000)
+	m := NewMirror([]RangeNode{LocalFilterNode(a), LocalFilterNode(b), LocalFilterNode(c)}, 3, 1)
+	for i := 0; i < 5000; i++ {
+		m.Insert(ctx, []byte{byte(i), byte(i >> 8)})
+	}
+	// c is replaced by an empty replica, b misses some writes.
+	c.Reset()
+	for i := 0; i < 100; i++ {
+		a.Insert([]byte{byte(i), byte(i >> 8), 1})
+	}
+	a.Insert([]byte("twice"))
+	a.Insert([]byte("twice"))
+
+	added, err := m.AntiEntropy(ctx)
+	if err != nil {
+		t.Fatalf("AntiEntropy() = %d, %v", added, err)
+	}
+	if want := 5102 + 102; added != want {
+		t.Errorf("AntiEntropy() added %d fingerprints, want %d", added, want)
+	}
+	for _, f := range []*Filter{a, b, c} {
+		if got, want := f.Count(), uint(5102); got != want {
+			t.Errorf("After AntiEntropy(): Count() = %d, want %d", got, want)
+		}
+	}
+	if !reflect.DeepEqual(canonicalFingerprints(a), canonicalFingerprints(c)) {
+		t.Errorf("After AntiEntropy(): replicas hold different fingerprints")
+	}
+	if added, err := m.AntiEntropy(ctx); added != 0 || err != nil {
+		t.Errorf("Second AntiEntropy() = %d, %v, want 0, nil", added, err)
+	}
+}
+
+func canonicalFingerprints(cf *Filter) []StoredFP {
+	var fps []StoredFP
+	cf.forEachCanonical(func(i uint, fp fingerprint) {
+		fps = append(fps, StoredFP{Bucket: i, Fingerprint: uint16(fp)})
+	})
+	sort.Slice(fps, func(i, j int) bool {
+		return fps[i].Bucket < fps[j].Bucket || fps[i].Bucket == fps[j].Bucket && fps[i].Fingerprint < fps[j].Fingerprint
+	})
+	return fps
+}
+
+// failingRangeNode is a RangeNode that is unavailable.
+type failingRangeNode struct {
+	failingNode
+}
+
+func (failingRangeNode) NumBuckets(context.Context) (uint, error) { return 0, errUnavailable }
+func (failingRangeNode) RangeDigests(context.Context, uint) ([]uint64, error) {
+	return nil, errUnavailable
+}
+func (failingRangeNode) RangeFingerprints(context.Context, uint, uint) ([]StoredFP, error) {
+	return nil, errUnavailable
+}
+func (failingRangeNode) AddFingerprints(context.Context, []StoredFP) error { return errUnavailable }
@@ -0,0 +1,31 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package cuckoo
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// maxMappedBuckets is the maximum number of buckets of a memory-mapped filter: 1<<40 on
+// 64-bit platforms, 1<<27 on 32-bit platforms.
+const maxMappedBuckets = 1 << (27 + 13*(^uint(0)>>63))
+
+// mappedBuckets returns the first n buckets stored in data without copying. Data must be
+// aligned for uint64, which is the case for offsets of memory mappings that are a multiple of 8.
+func mappedBuckets(data []byte, n int) []bucket {
+	if n == 0 {
+		return []bucket{}
+	}
+	return (*[maxMappedBuckets]bucket)(unsafe.Pointer(&data[0]))[:n:n]
+}
+
+// nativeEndian is the byte order of the running machine, used for files that are mapped directly.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
@@ -0,0 +1,135 @@
+package cuckoo
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Operation describes a finished operation reported to an Observer.
+type Operation struct {
+	// Name is the operation, e.g. "Insert" or "Encode".
+	Name     string
+	Duration time.Duration
+	// Result is the boolean result of Insert, Lookup and Delete.
+	Result bool
+	Err    error
+	// LoadFactor is the load factor after the operation, or 0 if unknown, e.g. for remote nodes.
+	LoadFactor float64
+}
+
+// Observer receives the operations of an observed filter or node. It is meant to be
+// implemented by a thin adapter over a tracing and metrics library such as OpenTelemetry:
+// Start starts a span and returns a context holding it, End sets the attributes of the span,
+// ends it, and records metrics such as Duration in a histogram.
+// Implementations must be safe for concurrent use.
+type Observer interface {
+	Start(ctx context.Context, name string) context.Context
+	End(ctx context.Context, op Operation)
+}
+
+// observe runs fn as the operation name, reporting it to o.
+func observe(ctx context.Context, o Observer, name string, fn func(ctx context.Context) (bool, error), loadFactor func() float64) (bool, error) {
+	ctx = o.Start(ctx, name)
+	start := time.Now()
+	result, err := fn(ctx)
+	op := Operation{Name: name, Duration: time.Since(start), Result: result, Err: err}
+	if loadFactor != nil {
+		op.LoadFactor = loadFactor()
+	}
+	o.End(ctx, op)
+	return result, err
+}
+
+// ObserveNode returns a Node reporting every operation of n to o.
+func ObserveNode(n Node, o Observer) Node {
+	return observedNode{n, o}
+}
+
+type observedNode struct {
+	node     Node
+	observer Observer
+}
+
+func (n observedNode) Insert(ctx context.Context, data []byte) (bool, error) {
+	return observe(ctx, n.observer, "Insert", func(ctx context.Context) (bool, error) {
+		return n.node.Insert(ctx, data)
+	}, nil)
+}
+
+func (n observedNode) Lookup(ctx context.Context, data []byte) (bool, error) {
+	return observe(ctx, n.observer, "Lookup", func(ctx context.Context) (bool, error) {
+		return n.node.Lookup(ctx, data)
+	}, nil)
+}
+
+func (n observedNode) Delete(ctx context.Context, data []byte) (bool, error) {
+	return observe(ctx, n.observer, "Delete", func(ctx context.Context) (bool, error) {
+		return n.node.Delete(ctx, data)
+	}, nil)
+}
+
+// ObservedFilter is a Filter reporting its operations to an Observer. It is also a Node, so
+// it can serve as an instrumented cluster node.
+type ObservedFilter struct {
+	*Filter
+	observer Observer
+}
+
+var _ Node = (*ObservedFilter)(nil)
+
+// ObserveFilter returns cf reporting operations that take a context to o.
+// The methods of Filter without a context are not reported.
+func ObserveFilter(cf *Filter, o Observer) *ObservedFilter {
+	return &ObservedFilter{cf, o}
+}
+
+// Insert data into the filter, see Filter.Insert. The error is always nil.
+func (f *ObservedFilter) Insert(ctx context.Context, data []byte) (bool, error) {
+	return observe(ctx, f.observer, "Insert", func(context.Context) (bool, error) {
+		return f.Filter.Insert(data), nil
+	}, f.LoadFactor)
+}
+
+// Lookup data in the filter, see Filter.Lookup. The error is always nil.
+func (f *ObservedFilter) Lookup(ctx context.Context, data []byte) (bool, error) {
+	return observe(ctx, f.observer, "Lookup", func(context.Context) (bool, error) {
+		return f.Filter.Lookup(data), nil
+	}, f.LoadFactor)
+}
+
+// Delete data from the filter, see Filter.Delete. The error is always nil.
+func (f *ObservedFilter) Delete(ctx context.Context, data []byte) (bool, error) {
+	return observe(ctx, f.observer, "Delete", func(context.Context) (bool, error) {
+		return f.Filter.Delete(data), nil
+	}, f.LoadFactor)
+}
+
+// Encode the filter, see Filter.Encode.
+func (f *ObservedFilter) Encode(ctx context.Context) []byte {
+	var bytes []byte
+	observe(ctx, f.observer, "Encode", func(context.Context) (bool, error) {
+		bytes = f.Filter.Encode()
+		return true, nil
+	}, f.LoadFactor)
+	return bytes
+}
+
+// Compact the filter, see Filter.Compact.
+func (f *ObservedFilter) Compact(ctx context.Context) int {
+	var moved int
+	observe(ctx, f.observer, "Compact", func(context.Context) (bool, error) {
+		moved = f.Filter.Compact()
+		return true, nil
+	}, f.LoadFactor)
+	return moved
+}
+
+// SnapshotTo writes a snapshot of the filter to w, see Filter.SnapshotTo.
+func (f *ObservedFilter) SnapshotTo(ctx context.Context, w io.Writer) error {
+	_, err := observe(ctx, f.observer, "SnapshotTo", func(ctx context.Context) (bool, error) {
+		err := f.Filter.SnapshotTo(ctx, w)
+		return err == nil, err
+	}, f.LoadFactor)
+	return err
+}
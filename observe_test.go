@@ -0,0 +1,64 @@
+package cuckoo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type spanKey struct{}
+
+// recordingObserver records operations, checking that End gets the context returned by Start.
+type recordingObserver struct {
+	lock sync.Mutex
+	ops  []Operation
+	t    *testing.T
+}
+
+func (o *recordingObserver) Start(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, spanKey{}, name)
+}
+
+func (o *recordingObserver) End(ctx context.Context, op Operation) {
+	if got := ctx.Value(spanKey{}); got != op.Name {
+		o.t.Errorf("End(%q) got context of %v", op.Name, got)
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.ops = append(o.ops, op)
+}
+
+func TestObserveFilter(t *testing.T) {
+	ctx := context.Background()
+	o := &recordingObserver{t: t}
+	cf := ObserveFilter(NewFilter(100), o)
+	cf.Insert(ctx, []byte("one"))
+	cf.Lookup(ctx, []byte("two"))
+	cf.Encode(ctx)
+
+	if len(o.ops) != 3 {
+		t.Fatalf("Got %d operations, want 3", len(o.ops))
+	}
+	for i, want := range []Operation{
+		{Name: "Insert", Result: true},
+		{Name: "Lookup", Result: false},
+		{Name: "Encode", Result: true},
+	} {
+		got := o.ops[i]
+		if got.Name != want.Name || got.Result != want.Result || got.LoadFactor != cf.LoadFactor() {
+			t.Errorf("Operation %d = %+v, want %+v with load factor %v", i, got, want, cf.LoadFactor())
+		}
+	}
+}
+
+func TestObserveNode(t *testing.T) {
+	o := &recordingObserver{t: t}
+	n := ObserveNode(failingNode{}, o)
+	if _, err := n.Insert(context.Background(), []byte("one")); !errors.Is(err, errUnavailable) {
+		t.Errorf("Insert() error = %v, want %v", err, errUnavailable)
+	}
+	if len(o.ops) != 1 || !errors.Is(o.ops[0].Err, errUnavailable) || o.ops[0].LoadFactor != 0 {
+		t.Errorf("Got operations %+v, want one failed Insert", o.ops)
+	}
+}
@@ -0,0 +1,214 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// OptimisticFilter is a Filter for read-heavy concurrent use. Lookups take no lock: they read
+// both candidate buckets and retry if a per-bucket sequence counter shows a concurrent change.
+// Writers latch only the buckets they modify; inserts that need kickouts and deletes are
+// additionally serialized by a mutex. Encode writes the buckets without header, the format
+// of version 0, which Decode reads back into a Filter with the default hash seed.
+type OptimisticFilter struct {
+	// buckets holds the fingerprints of every bucket packed into a uint64, so that they can
+	// be read atomically.
+	buckets []uint64
+	// seqs holds a sequence counter per bucket, which is odd while the bucket is latched.
+	seqs            []uint32
+	count           uint64
+	bucketIndexMask uint
+	// lock serializes kickout chains and deletes.
+	lock sync.Mutex
+}
+
+var _ ApproxSet = (*OptimisticFilter)(nil)
+
+// NewOptimisticFilter returns a new filter suitable for the given number of elements, see NewFilter.
+func NewOptimisticFilter(numElements uint) *OptimisticFilter {
+	numBuckets := numBucketsFor(numElements)
+	return &OptimisticFilter{
+		buckets:         make([]uint64, numBuckets),
+		seqs:            make([]uint32, numBuckets),
+		bucketIndexMask: uint(numBuckets - 1),
+	}
+}
+
+// latch waits until bucket i is not latched and latches it.
+func (of *OptimisticFilter) latch(i uint) {
+	for {
+		s := atomic.LoadUint32(&of.seqs[i])
+		if s&1 == 0 && atomic.CompareAndSwapUint32(&of.seqs[i], s, s+1) {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+func (of *OptimisticFilter) unlatch(i uint) {
+	atomic.AddUint32(&of.seqs[i], 1)
+}
+
+// load returns bucket i. The caller must hold the latch of bucket i.
+func (of *OptimisticFilter) load(i uint) bucket {
+	return bucket(atomic.LoadUint64(&of.buckets[i]))
+}
+
+// store sets bucket i. The caller must hold the latch of bucket i.
+func (of *OptimisticFilter) store(i uint, b bucket) {
+	atomic.StoreUint64(&of.buckets[i], uint64(b))
+}
+
+// Lookup returns true if data is in the filter. It never blocks on writers, but retries
+// while one of the two candidate buckets is being modified.
+func (of *OptimisticFilter) Lookup(data []byte) bool {
+	i1, fp := getIndexAndFingerprint(data, of.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, of.bucketIndexMask)
+	for {
+		s1, s2 := atomic.LoadUint32(&of.seqs[i1]), atomic.LoadUint32(&of.seqs[i2])
+		if (s1|s2)&1 == 0 {
+			b1 := bucket(atomic.LoadUint64(&of.buckets[i1]))
+			b2 := bucket(atomic.LoadUint64(&of.buckets[i2]))
+			if atomic.LoadUint32(&of.seqs[i1]) == s1 && atomic.LoadUint32(&of.seqs[i2]) == s2 {
+				return b1.contains(fp) || b2.contains(fp)
+			}
+		}
+		runtime.Gosched()
+	}
+}
+
+// Insert data into the filter. Returns false if insertion failed, see Filter.Insert.
+func (of *OptimisticFilter) Insert(data []byte) bool {
+	i1, fp := getIndexAndFingerprint(data, of.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, of.bucketIndexMask)
+	if of.tryInsert(fp, i1) || of.tryInsert(fp, i2) {
+		return true
+	}
+
+	of.lock.Lock()
+	defer of.lock.Unlock()
+
+	return of.reinsert(fp, randi(i1, i2))
+}
+
+// tryInsert inserts fp into bucket i if it has a free slot.
+func (of *OptimisticFilter) tryInsert(fp fingerprint, i uint) bool {
+	of.latch(i)
+	defer of.unlatch(i)
+
+	b := of.load(i)
+	if !b.insert(fp) {
+		return false
+	}
+	of.store(i, b)
+	atomic.AddUint64(&of.count, 1)
+	return true
+}
+
+// reinsert is Filter.reinsert, keeping every bucket of the kickout chain latched until the
+// chain ends, so that lookups never observe a kicked out fingerprint in neither bucket.
+// The caller must hold the lock. Holding several latches cannot deadlock, as other writers
+// holding a latch never wait for another one.
+func (of *OptimisticFilter) reinsert(fp fingerprint, i uint) bool {
+	var latched []uint
+	defer func() {
+		for _, i := range latched {
+			of.unlatch(i)
+		}
+	}()
+	latch := func(i uint) {
+		for _, l := range latched {
+			if l == i {
+				return
+			}
+		}
+		of.latch(i)
+		latched = append(latched, i)
+	}
+
+	for k := 0; k < maxCuckooKickouts; k++ {
+		latch(i)
+		b := of.load(i)
+		j := rand.Intn(bucketSize)
+		fp = b.swap(j, fp)
+		of.store(i, b)
+
+		i = getAltIndex(fp, i, of.bucketIndexMask)
+		latch(i)
+		if b := of.load(i); b.insert(fp) {
+			of.store(i, b)
+			atomic.AddUint64(&of.count, 1)
+			return true
+		}
+	}
+	return false
+}
+
+// Delete data from the filter. Returns true if the data was found and deleted.
+func (of *OptimisticFilter) Delete(data []byte) bool {
+	i1, fp := getIndexAndFingerprint(data, of.bucketIndexMask)
+	i2 := getAltIndex(fp, i1, of.bucketIndexMask)
+
+	// Kickout chains could move fp between its buckets while they are checked one by one.
+	of.lock.Lock()
+	defer of.lock.Unlock()
+
+	return of.tryDelete(fp, i1) || of.tryDelete(fp, i2)
+}
+
+func (of *OptimisticFilter) tryDelete(fp fingerprint, i uint) bool {
+	of.latch(i)
+	defer of.unlatch(i)
+
+	b := of.load(i)
+	if !b.delete(fp) {
+		return false
+	}
+	of.store(i, b)
+	atomic.AddUint64(&of.count, ^uint64(0))
+	return true
+}
+
+// Count returns the number of items in the filter.
+func (of *OptimisticFilter) Count() uint {
+	return uint(atomic.LoadUint64(&of.count))
+}
+
+// LoadFactor returns the fraction of slots that are occupied.
+func (of *OptimisticFilter) LoadFactor() float64 {
+	return float64(of.Count()) / float64(len(of.buckets)*bucketSize)
+}
+
+// Reset removes all items from the filter. Writes concurrent with Reset may survive it.
+func (of *OptimisticFilter) Reset() {
+	of.lock.Lock()
+	defer of.lock.Unlock()
+
+	for i := range of.buckets {
+		of.latch(uint(i))
+		if b := of.load(uint(i)); b.free() < bucketSize {
+			n := bucketSize - b.free()
+			atomic.AddUint64(&of.count, -uint64(n))
+		}
+		of.store(uint(i), 0)
+		of.unlatch(uint(i))
+	}
+}
+
+// Encode returns a byte slice representing the buckets of the filter without header, which
+// can be decoded by Decode as the format of version 0.
+func (of *OptimisticFilter) Encode() []byte {
+	bytes := make([]byte, len(of.buckets)*bucketSize*fingerprintSizeBits/8)
+	for i := range of.buckets {
+		of.latch(uint(i))
+		b := of.load(uint(i))
+		of.unlatch(uint(i))
+		for j, fp := range b.fingerprints() {
+			binary.LittleEndian.PutUint16(bytes[2*(i*bucketSize+j):], uint16(fp))
+		}
+	}
+	return bytes
+}
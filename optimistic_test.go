@@ -0,0 +1,92 @@
+package cuckoo
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestOptimisticFilter(t *testing.T) {
+	of := NewOptimisticFilter(1000)
+	for i := 0; i < 900; i++ {
+		if !of.Insert([]byte(fmt.Sprint(i))) {
+			t.Fatalf("Insert(%d) failed", i)
+		}
+	}
+	for i := 0; i < 900; i++ {
+		if !of.Lookup([]byte(fmt.Sprint(i))) {
+			t.Fatalf("Lookup(%d) = false", i)
+		}
+	}
+	if encoded := of.Encode(); len(encoded) != 8*len(of.buckets) || hasEncodingHeader(encoded) {
+		t.Errorf("Encode() has %d bytes, want %d bytes of buckets without header", len(encoded), 8*len(of.buckets))
+	}
+	cf, err := Decode(of.Encode())
+	if err != nil || cf.Count() != of.Count() || !cf.Lookup([]byte("1")) {
+		t.Errorf("Decode(Encode()) = %v, %v, want filter with %d items", cf, err, of.Count())
+	}
+	if !of.Delete([]byte("1")) || of.Count() != 899 {
+		t.Errorf("Delete() failed, Count() = %d", of.Count())
+	}
+	of.Reset()
+	if of.Count() != 0 || of.Lookup([]byte("2")) {
+		t.Errorf("After Reset(): Count() = %d", of.Count())
+	}
+}
+
+func TestOptimisticFilter_ConcurrentKickouts(t *testing.T) {
+	of := NewOptimisticFilter(1 << 12)
+	const stable = 1000
+	for i := 0; i < stable; i++ {
+		of.Insert([]byte(fmt.Sprint("stable", i)))
+	}
+
+	// Lookups of stable items must never miss while writers kick them around.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				for i := 0; i < stable; i++ {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					if !of.Lookup([]byte(fmt.Sprint("stable", i))) {
+						t.Errorf("Lookup(stable%d) = false during concurrent inserts", i)
+						return
+					}
+				}
+			}
+		}()
+	}
+	var writers sync.WaitGroup
+	for w := 0; w < 2; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < 1500; i++ {
+				of.Insert([]byte(fmt.Sprint(w, "/", i)))
+			}
+		}(w)
+	}
+	writers.Wait()
+	close(done)
+	wg.Wait()
+}
+
+func BenchmarkOptimisticFilter_Lookup(b *testing.B) {
+	of := NewOptimisticFilter(1 << 16)
+	for i := 0; i < 1<<15; i++ {
+		of.Insert([]byte(fmt.Sprint(i)))
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		key := []byte("1234")
+		for pb.Next() {
+			of.Lookup(key)
+		}
+	})
+}
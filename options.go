@@ -0,0 +1,4 @@
+package cuckoo
+
+// Option configures optional behavior of a Filter created by NewFilter.
+type Option func(*Filter)
@@ -0,0 +1,290 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	metro "github.com/dgryski/go-metro"
+)
+
+// packedFingerprintSeed is the hash seed for fingerprints with more than 32 bits.
+const packedFingerprintSeed = 4242
+
+// PackedFilter is a Filter whose fingerprints have a configurable number of bits, packed
+// without padding. 4-bit fingerprints use a quarter of the memory of Filter at a false
+// positive rate of about 50%, 12-bit fingerprints three quarters at about 0.2%.
+// For very low false positive rates, 32-bit fingerprints use twice the memory of Filter at
+// about 2e-9, and 64-bit fingerprints four times at about 4e-19.
+// It is safe for concurrent use.
+type PackedFilter struct {
+	// words holds the slots as a little-endian bit stream, followed by one word of padding
+	// so that reading a slot never goes out of bounds.
+	words []uint64
+	bits  uint
+	count uint
+	// Bit mask set to the number of buckets - 1, which is always a power of 2.
+	bucketIndexMask uint
+	lock            sync.RWMutex
+}
+
+var _ ApproxSet = (*PackedFilter)(nil)
+
+// packedFingerprintBits returns true if PackedFilter supports fingerprints of the given size.
+func packedFingerprintBits(bits uint) bool {
+	switch bits {
+	case 4, 8, 12, 16, 32, 64:
+		return true
+	}
+	return false
+}
+
+// NewPackedFilter returns a filter for the given number of elements with fingerprints of
+// fingerprintBits bits, which must be 4, 8, 12, 16, 32 or 64. Other sizes give an error wrapping
+// ErrUnsupported.
+func NewPackedFilter(numElements uint, fingerprintBits uint) (*PackedFilter, error) {
+	if !packedFingerprintBits(fingerprintBits) {
+		return nil, fmt.Errorf("%w: %d-bit fingerprints", ErrUnsupported, fingerprintBits)
+	}
+	return newPackedFilter(numBucketsFor(numElements), fingerprintBits), nil
+}
+
+func newPackedFilter(numBuckets, bits uint) *PackedFilter {
+	return &PackedFilter{
+		words:           make([]uint64, packedWords(numBuckets, bits)+1),
+		bits:            bits,
+		bucketIndexMask: numBuckets - 1,
+	}
+}
+
+// packedWords returns the number of words holding numBuckets buckets of bits-bit fingerprints.
+func packedWords(numBuckets, bits uint) uint {
+	return (numBuckets*bucketSize*bits + 63) / 64
+}
+
+// FingerprintBits returns the size of fingerprints in bits.
+func (pf *PackedFilter) FingerprintBits() uint {
+	return pf.bits
+}
+
+func (pf *PackedFilter) slotMask() uint64 {
+	return 1<<pf.bits - 1
+}
+
+// get returns the fingerprint in slot j of bucket i.
+func (pf *PackedFilter) get(i uint, j uint) uint64 {
+	pos := (i*bucketSize + j) * pf.bits
+	w, off := pos/64, pos%64
+	v := pf.words[w] >> off
+	if off+pf.bits > 64 {
+		v |= pf.words[w+1] << (64 - off)
+	}
+	return v & pf.slotMask()
+}
+
+// set stores fp in slot j of bucket i.
+func (pf *PackedFilter) set(i uint, j uint, fp uint64) {
+	pos := (i*bucketSize + j) * pf.bits
+	w, off := pos/64, pos%64
+	mask := pf.slotMask()
+	pf.words[w] = pf.words[w]&^(mask<<off) | fp<<off
+	if off+pf.bits > 64 {
+		pf.words[w+1] = pf.words[w+1]&^(mask>>(64-off)) | fp>>(64-off)
+	}
+}
+
+// indexAndFingerprint returns the primary bucket index and fingerprint of data.
+func (pf *PackedFilter) indexAndFingerprint(data []byte) (uint, uint64) {
+	hash := hashKey(data)
+	if pf.bits > 32 {
+		// The most significant bits would overlap with the bits of the index.
+		return uint(hash) & pf.bucketIndexMask, metro.Hash64(data, packedFingerprintSeed)%pf.slotMask() + 1
+	}
+	return pf.indexAndFingerprintFromHash(hash)
+}
+
+// indexAndFingerprintFromHash returns the primary bucket index and fingerprint for a hash
+// returned by hashKey. pf must have fingerprints of at most 32 bits.
+func (pf *PackedFilter) indexAndFingerprintFromHash(hash uint64) (uint, uint64) {
+	// Like getFingerprint, use the most significant bits and leave 0 as the empty state.
+	return uint(hash) & pf.bucketIndexMask, hash>>(64-pf.bits)%pf.slotMask() + 1
+}
+
+// altIndex returns the alternate index of fp in bucket i. Unlike getAltIndex, it hashes the
+// fingerprint with splitmix64, which mixes all bits of fingerprints of any size.
+func (pf *PackedFilter) altIndex(fp uint64, i uint) uint {
+	return (i ^ uint(splitmix64(fp))) & pf.bucketIndexMask
+}
+
+func (pf *PackedFilter) contains(fp uint64, i uint) bool {
+	for j := uint(0); j < bucketSize; j++ {
+		if pf.get(i, j) == fp {
+			return true
+		}
+	}
+	return false
+}
+
+func (pf *PackedFilter) insert(fp uint64, i uint) bool {
+	for j := uint(0); j < bucketSize; j++ {
+		if pf.get(i, j) == nullFp {
+			pf.set(i, j, fp)
+			pf.count++
+			return true
+		}
+	}
+	return false
+}
+
+func (pf *PackedFilter) delete(fp uint64, i uint) bool {
+	for j := uint(0); j < bucketSize; j++ {
+		if pf.get(i, j) == fp {
+			pf.set(i, j, nullFp)
+			pf.count--
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns true if data is in the filter.
+func (pf *PackedFilter) Lookup(data []byte) bool {
+	i1, fp := pf.indexAndFingerprint(data)
+
+	pf.lock.RLock()
+	defer pf.lock.RUnlock()
+
+	return pf.contains(fp, i1) || pf.contains(fp, pf.altIndex(fp, i1))
+}
+
+// Insert data into the filter. Returns false if insertion failed, see Filter.Insert.
+func (pf *PackedFilter) Insert(data []byte) bool {
+	i1, fp := pf.indexAndFingerprint(data)
+
+	pf.lock.Lock()
+	defer pf.lock.Unlock()
+
+	return pf.insertFingerprint(fp, i1)
+}
+
+// insertHash inserts the item with the given hashKey, see Filter.insertHash. pf must have
+// fingerprints of at most 32 bits.
+func (pf *PackedFilter) insertHash(hash uint64) bool {
+	i1, fp := pf.indexAndFingerprintFromHash(hash)
+
+	pf.lock.Lock()
+	defer pf.lock.Unlock()
+
+	return pf.insertFingerprint(fp, i1)
+}
+
+// insertFingerprint places fp into one of its candidate buckets, kicking out other
+// fingerprints if necessary. The caller must hold the write lock.
+func (pf *PackedFilter) insertFingerprint(fp uint64, i1 uint) bool {
+	i2 := pf.altIndex(fp, i1)
+	if pf.insert(fp, i1) || pf.insert(fp, i2) {
+		return true
+	}
+	i := randi(i1, i2)
+	for k := 0; k < maxCuckooKickouts; k++ {
+		j := uint(rand.Intn(bucketSize))
+		old := pf.get(i, j)
+		pf.set(i, j, fp)
+		fp = old
+
+		i = pf.altIndex(fp, i)
+		if pf.insert(fp, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete data from the filter. Returns true if the data was found and deleted.
+func (pf *PackedFilter) Delete(data []byte) bool {
+	i1, fp := pf.indexAndFingerprint(data)
+
+	pf.lock.Lock()
+	defer pf.lock.Unlock()
+
+	return pf.delete(fp, i1) || pf.delete(fp, pf.altIndex(fp, i1))
+}
+
+// Count returns the number of items in the filter.
+func (pf *PackedFilter) Count() uint {
+	pf.lock.RLock()
+	defer pf.lock.RUnlock()
+
+	return pf.count
+}
+
+// Cap returns the number of slots of the filter.
+func (pf *PackedFilter) Cap() int {
+	return int(pf.bucketIndexMask+1) * bucketSize
+}
+
+// LoadFactor returns the fraction of slots that are occupied.
+func (pf *PackedFilter) LoadFactor() float64 {
+	pf.lock.RLock()
+	defer pf.lock.RUnlock()
+
+	return float64(pf.count) / float64((pf.bucketIndexMask+1)*bucketSize)
+}
+
+// Reset removes all items from the filter, setting count to 0.
+func (pf *PackedFilter) Reset() {
+	pf.lock.Lock()
+	defer pf.lock.Unlock()
+
+	for i := range pf.words {
+		pf.words[i] = 0
+	}
+	pf.count = 0
+}
+
+// Encode returns a byte slice representing a PackedFilter: one byte holding the fingerprint
+// size, followed by the packed slots.
+func (pf *PackedFilter) Encode() []byte {
+	pf.lock.RLock()
+	defer pf.lock.RUnlock()
+
+	numBytes := ((pf.bucketIndexMask+1)*bucketSize*pf.bits + 7) / 8
+	bytes := make([]byte, 1+len(pf.words)*8)
+	bytes[0] = byte(pf.bits)
+	for i, w := range pf.words {
+		binary.LittleEndian.PutUint64(bytes[1+8*i:], w)
+	}
+	return bytes[:1+numBytes]
+}
+
+// DecodePackedFilter returns a PackedFilter from a byte slice created using Encode.
+func DecodePackedFilter(bytes []byte) (_ *PackedFilter, err error) {
+	defer recoverInvalidState(&err)
+	if len(bytes) == 0 || !packedFingerprintBits(uint(bytes[0])) {
+		return nil, fmt.Errorf("%w: invalid fingerprint size", ErrCorrupted)
+	}
+	bits := uint(bytes[0])
+	bytes = bytes[1:]
+	bucketBits := bucketSize * bits
+	numBuckets := uint(len(bytes)) * 8 / bucketBits
+	if numBuckets == 0 || getNextPow2(uint64(numBuckets)) != numBuckets || (numBuckets*bucketBits+7)/8 != uint(len(bytes)) {
+		return nil, fmt.Errorf("%w: %d bytes do not hold a power of 2 of %d-bit buckets", ErrCorrupted, len(bytes), bucketBits)
+	}
+	pf := newPackedFilter(numBuckets, bits)
+	for i := range pf.words {
+		var word [8]byte
+		if 8*i < len(bytes) {
+			copy(word[:], bytes[8*i:])
+		}
+		pf.words[i] = binary.LittleEndian.Uint64(word[:])
+	}
+	for i := uint(0); i < numBuckets; i++ {
+		for j := uint(0); j < bucketSize; j++ {
+			if pf.get(i, j) != nullFp {
+				pf.count++
+			}
+		}
+	}
+	return pf, nil
+}
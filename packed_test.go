@@ -0,0 +1,86 @@
+package cuckoo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPackedFilter(t *testing.T) {
+	for _, bits := range []uint{4, 8, 12, 16, 32, 64} {
+		t.Run(fmt.Sprint(bits), func(t *testing.T) {
+			pf, err := NewPackedFilter(1000, bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 900; i++ {
+				if !pf.Insert([]byte(fmt.Sprint(i))) {
+					t.Fatalf("Insert(%d) failed", i)
+				}
+			}
+			for i := 0; i < 900; i++ {
+				if !pf.Lookup([]byte(fmt.Sprint(i))) {
+					t.Fatalf("Lookup(%d) = false", i)
+				}
+			}
+			falsePositives := 0
+			for i := 0; i < 10000; i++ {
+				if pf.Lookup([]byte(fmt.Sprint("absent", i))) {
+					falsePositives++
+				}
+			}
+			// Eight slots are compared per lookup.
+			if want := 8 * pf.LoadFactor() / float64(uint64(1)<<bits-1); float64(falsePositives)/10000 > 1.5*want+0.002 {
+				t.Errorf("False positive rate = %v, want about %v", float64(falsePositives)/10000, want)
+			}
+
+			decoded, err := DecodePackedFilter(pf.Encode())
+			if err != nil {
+				t.Fatalf("DecodePackedFilter() = %v", err)
+			}
+			if !reflect.DeepEqual(decoded.words, pf.words) || decoded.count != pf.count {
+				t.Errorf("DecodePackedFilter(Encode()) differs from filter")
+			}
+			for i := 0; i < 900; i++ {
+				if !pf.Delete([]byte(fmt.Sprint(i))) {
+					t.Fatalf("Delete(%d) failed", i)
+				}
+			}
+			if pf.Count() != 0 {
+				t.Errorf("After deleting all items: Count() = %d", pf.Count())
+			}
+		})
+	}
+}
+
+func TestPackedFilter_Sizes(t *testing.T) {
+	pf, _ := NewPackedFilter(1<<11, 12)
+	if got, want := len(pf.Encode()), 1+1024*4*12/8; got != want {
+		t.Errorf("len(Encode()) = %d, want %d", got, want)
+	}
+	if _, err := NewPackedFilter(100, 5); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("NewPackedFilter() with 5 bits error = %v, want %v", err, ErrUnsupported)
+	}
+	if _, err := DecodePackedFilter([]byte{12, 1, 2, 3}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("DecodePackedFilter() of truncated data error = %v, want %v", err, ErrCorrupted)
+	}
+}
+
+func TestPackedFilter_Wide(t *testing.T) {
+	pf, _ := NewPackedFilter(1000, 64)
+	pf.Insert([]byte("one"))
+	_, fp := pf.indexAndFingerprint([]byte("one"))
+	if fp>>32 == 0 || fp&(1<<32-1) == 0 {
+		t.Errorf("64-bit fingerprint %#x does not use all bits", fp)
+	}
+	found := false
+	for i := uint(0); i <= pf.bucketIndexMask; i++ {
+		for j := uint(0); j < bucketSize; j++ {
+			found = found || pf.get(i, j) == fp
+		}
+	}
+	if !found {
+		t.Errorf("Fingerprint %#x not stored", fp)
+	}
+}
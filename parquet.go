@@ -0,0 +1,528 @@
+package cuckoo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// parquetMagic starts and ends Parquet files.
+var parquetMagic = []byte("PAR1")
+
+// Parquet physical types, repetitions, encodings and page types used by DumpParquet and
+// LoadParquet.
+const (
+	parquetInt32 = 1
+	parquetInt64 = 2
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetPlain           = 0
+	parquetPlainDictionary = 2
+	parquetRLE             = 3
+	parquetRLEDictionary   = 8
+
+	parquetDataPage       = 0
+	parquetDictionaryPage = 2
+	parquetDataPageV2     = 3
+
+	parquetUncompressed = 0
+
+	parquetUint32 = 13
+	parquetUint64 = 14
+)
+
+// parquetPageValues is the maximum number of values per data page written by DumpParquet.
+const parquetPageValues = 1 << 20
+
+// Keys of the Parquet key-value metadata holding the geometry, seed and alternate index scheme
+// of the filter.
+const (
+	parquetBucketsKey   = "cuckoo.buckets"
+	parquetSeedKey      = "cuckoo.seed"
+	parquetAltSchemeKey = "cuckoo.alt_scheme"
+)
+
+// LoadParquet accepts at most parquetFreeBuckets buckets plus parquetBucketsPerByte buckets per
+// byte of the file, so that a few bytes of forged metadata cannot allocate a huge filter. Dumps
+// of filters holding at least one item per 256 buckets stay well within the bound.
+const (
+	parquetFreeBuckets    = 1 << 20
+	parquetBucketsPerByte = 64
+)
+
+// parquetColumns are the columns of the table written by DumpParquet.
+var parquetColumns = [3]struct {
+	name string
+	typ  int32
+}{
+	{"bucket", parquetInt64},
+	{"slot", parquetInt32},
+	{"fingerprint", parquetInt32},
+}
+
+// DumpParquet writes all stored fingerprints as a Parquet table with the unsigned integer
+// columns bucket, slot and fingerprint, e.g. for processing with Spark or DuckDB. The number
+// of buckets, the hash seed and alternate index schemes other than AltIndexXOR are stored in
+// the key-value metadata. LoadParquet reads the output back.
+//
+// Data is written uncompressed.
+func (cf *Filter) DumpParquet(w io.Writer) error {
+	cf.lock.RLock()
+	if cf.stash.len() > 0 {
+		cf.lock.RUnlock()
+		return fmt.Errorf("%w: dumping stashed items, use Encode", ErrUnsupported)
+	}
+	fps := cf.storedFingerprints()
+	numBuckets := len(cf.buckets)
+	cf.lock.RUnlock()
+
+	columns := make([][]int64, len(parquetColumns))
+	for k := range columns {
+		columns[k] = make([]int64, len(fps))
+	}
+	for n, fp := range fps {
+		columns[0][n] = int64(fp.Bucket)
+		columns[1][n] = int64(fp.Slot)
+		columns[2][n] = int64(fp.Fingerprint)
+	}
+
+	var file bytes.Buffer
+	file.Write(parquetMagic)
+	var chunks []thriftWriter
+	if len(fps) > 0 {
+		for k, col := range parquetColumns {
+			chunks = append(chunks, writeParquetChunk(&file, col.name, col.typ, columns[k]))
+		}
+	}
+
+	var meta thriftWriter
+	meta.begin()
+	meta.i32(1, 1)
+	meta.list(2, thriftStruct, len(parquetColumns)+1)
+	meta.begin()
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(parquetColumns)))
+	meta.end()
+	for _, col := range parquetColumns {
+		meta.begin()
+		meta.i32(1, col.typ)
+		meta.i32(3, parquetRequired)
+		meta.binary(4, []byte(col.name))
+		if col.typ == parquetInt64 {
+			meta.i32(6, parquetUint64)
+		} else {
+			meta.i32(6, parquetUint32)
+		}
+		meta.end()
+	}
+	meta.i64(3, int64(len(fps)))
+	if len(chunks) == 0 {
+		meta.list(4, thriftStruct, 0)
+	} else {
+		meta.list(4, thriftStruct, 1)
+		meta.begin()
+		meta.list(1, thriftStruct, len(chunks))
+		for _, chunk := range chunks {
+			meta.buf = append(meta.buf, chunk.buf...)
+		}
+		meta.i64(2, int64(file.Len()-len(parquetMagic)))
+		meta.i64(3, int64(len(fps)))
+		meta.end()
+	}
+	kvs := [][2]string{
+		{parquetBucketsKey, strconv.Itoa(numBuckets)},
+		{parquetSeedKey, strconv.FormatUint(cf.seed, 10)},
+	}
+	if cf.altScheme != AltIndexXOR {
+		kvs = append(kvs, [2]string{parquetAltSchemeKey, strconv.Itoa(int(cf.altScheme))})
+	}
+	meta.list(5, thriftStruct, len(kvs))
+	for _, kv := range kvs {
+		meta.begin()
+		meta.binary(1, []byte(kv[0]))
+		meta.binary(2, []byte(kv[1]))
+		meta.end()
+	}
+	meta.binary(6, []byte("github.com/chenny7/cuckoofilter"))
+	meta.end()
+
+	file.Write(meta.buf)
+	var footer [4]byte
+	binary.LittleEndian.PutUint32(footer[:], uint32(len(meta.buf)))
+	file.Write(footer[:])
+	file.Write(parquetMagic)
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// writeParquetChunk appends PLAIN encoded data pages of values to file and returns the
+// encoded ColumnChunk describing them.
+func writeParquetChunk(file *bytes.Buffer, name string, typ int32, values []int64) thriftWriter {
+	offset := file.Len()
+	for start := 0; start < len(values); start += parquetPageValues {
+		end := start + parquetPageValues
+		if end > len(values) {
+			end = len(values)
+		}
+		var body []byte
+		for _, v := range values[start:end] {
+			if typ == parquetInt64 {
+				body = append(body, 0, 0, 0, 0, 0, 0, 0, 0)
+				binary.LittleEndian.PutUint64(body[len(body)-8:], uint64(v))
+			} else {
+				body = append(body, 0, 0, 0, 0)
+				binary.LittleEndian.PutUint32(body[len(body)-4:], uint32(v))
+			}
+		}
+		var header thriftWriter
+		header.begin()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(body)))
+		header.i32(3, int32(len(body)))
+		header.structField(5)
+		header.i32(1, int32(end-start))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+		file.Write(header.buf)
+		file.Write(body)
+	}
+	size := int64(file.Len() - offset)
+
+	var chunk thriftWriter
+	chunk.begin()
+	chunk.i64(2, int64(offset))
+	chunk.structField(3)
+	chunk.i32(1, typ)
+	chunk.list(2, thriftI32, 1)
+	chunk.zigzag(parquetPlain)
+	chunk.list(3, thriftBinary, 1)
+	chunk.binaryValue([]byte(name))
+	chunk.i32(4, parquetUncompressed)
+	chunk.i64(5, int64(len(values)))
+	chunk.i64(6, size)
+	chunk.i64(7, size)
+	chunk.i64(9, int64(offset))
+	chunk.end()
+	chunk.end()
+	return chunk
+}
+
+// LoadParquet returns a Cuckoofilter from a Parquet file written by DumpParquet, or by other
+// tools such as Spark or DuckDB. The file must have integer columns bucket, slot and
+// fingerprint without nulls, and the number of buckets must be stored as the key-value
+// metadata cuckoo.buckets; cuckoo.seed and cuckoo.alt_scheme are optional and default to the
+// default seed and AltIndexXOR.
+// Other columns are ignored. Files declaring more than 2^20 buckets plus 64 per byte of the
+// file are rejected as corrupted.
+//
+// Only uncompressed, PLAIN or dictionary encoded columns are supported; anything else returns
+// ErrUnsupported.
+func LoadParquet(data []byte) (_ *Filter, err error) {
+	defer recoverInvalidState(&err)
+	meta, err := parquetFileMetaData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	numBuckets := uint64(0)
+	seed := uint64(defaultHashSeed)
+	altScheme := uint64(AltIndexXOR)
+	for _, kv := range meta.list(5) {
+		kv, _ := kv.(thriftFields)
+		key, _ := kv.bytes(1)
+		value, _ := kv.bytes(2)
+		var err error
+		switch string(key) {
+		case parquetBucketsKey:
+			numBuckets, err = strconv.ParseUint(string(value), 10, 64)
+		case parquetSeedKey:
+			seed, err = strconv.ParseUint(string(value), 10, 64)
+		case parquetAltSchemeKey:
+			altScheme, err = strconv.ParseUint(string(value), 10, 8)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid metadata %s: %v", ErrCorrupted, key, err)
+		}
+	}
+	if numBuckets == 0 || numBuckets&(numBuckets-1) != 0 {
+		return nil, fmt.Errorf("%w: number of buckets %d is not a power of 2", ErrCorrupted, numBuckets)
+	}
+	if !supportedAltIndexScheme(AltIndexScheme(altScheme)) {
+		return nil, fmt.Errorf("%w: unsupported alternate index scheme %d", ErrIncompatible, altScheme)
+	}
+	if numBuckets > maxBuckets {
+		return nil, fmt.Errorf("%w: %d buckets", ErrTooLarge, numBuckets)
+	}
+	if numBuckets > parquetFreeBuckets+parquetBucketsPerByte*uint64(len(data)) {
+		return nil, fmt.Errorf("%w: %d buckets in a file of %d bytes", ErrCorrupted, numBuckets, len(data))
+	}
+
+	optional := map[string]bool{}
+	for k, s := range meta.list(2) {
+		s, _ := s.(thriftFields)
+		if children, _ := s.int(5); children > 0 && k > 0 {
+			return nil, fmt.Errorf("%w: nested Parquet schema", ErrUnsupported)
+		}
+		name, _ := s.bytes(4)
+		repetition, _ := s.int(3)
+		optional[string(name)] = repetition == parquetOptional
+	}
+
+	cf := newFilter(make([]bucket, numBuckets))
+	cf.seed = seed
+	cf.altScheme = AltIndexScheme(altScheme)
+	for _, rg := range meta.list(4) {
+		rg, _ := rg.(thriftFields)
+		var columns [len(parquetColumns)][]int64
+		for _, chunk := range rg.list(1) {
+			chunk, _ := chunk.(thriftFields)
+			cm, ok := chunk.fields(3)
+			if !ok {
+				return nil, fmt.Errorf("%w: column chunk without metadata", ErrUnsupported)
+			}
+			path := cm.list(3)
+			if len(path) != 1 {
+				continue
+			}
+			name, _ := path[0].([]byte)
+			for k, col := range parquetColumns {
+				if string(name) == col.name {
+					if columns[k], err = readParquetChunk(data, cm, optional[col.name]); err != nil {
+						return nil, fmt.Errorf("column %s: %w", col.name, err)
+					}
+				}
+			}
+		}
+		for k, col := range parquetColumns {
+			if columns[k] == nil || len(columns[k]) != len(columns[0]) {
+				return nil, fmt.Errorf("%w: missing or incomplete column %s", ErrCorrupted, col.name)
+			}
+		}
+		for n := range columns[0] {
+			i, j, fp := columns[0][n], columns[1][n], columns[2][n]
+			if i < 0 || uint64(i) >= numBuckets || j < 0 || j >= bucketSize || fp <= 0 || fp > maxFingerprint {
+				return nil, fmt.Errorf("%w: invalid row (%d, %d, %d)", ErrCorrupted, i, j, fp)
+			}
+			if cf.buckets[i].get(int(j)) == nullFp {
+				cf.count++
+			}
+			cf.buckets[i].set(int(j), fingerprint(fp))
+		}
+	}
+	return cf, nil
+}
+
+// parquetFileMetaData returns the decoded footer of a Parquet file.
+func parquetFileMetaData(data []byte) (thriftFields, error) {
+	n := len(data)
+	if n < 12 || !bytes.Equal(data[:4], parquetMagic) || !bytes.Equal(data[n-4:], parquetMagic) {
+		return nil, fmt.Errorf("%w: not a Parquet file", ErrCorrupted)
+	}
+	size := uint64(binary.LittleEndian.Uint32(data[n-8:]))
+	if size > uint64(n-12) {
+		return nil, fmt.Errorf("%w: invalid Parquet footer size %d", ErrCorrupted, size)
+	}
+	r := thriftReader{buf: data[n-8-int(size) : n-8]}
+	meta, err := r.readStruct(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: Parquet footer: %v", ErrCorrupted, err)
+	}
+	return meta, nil
+}
+
+// readParquetChunk returns the values of a column chunk described by the ColumnMetaData cm.
+func readParquetChunk(data []byte, cm thriftFields, optional bool) ([]int64, error) {
+	typ, _ := cm.int(1)
+	if typ != parquetInt32 && typ != parquetInt64 {
+		return nil, fmt.Errorf("%w: physical type %d", ErrUnsupported, typ)
+	}
+	if codec, _ := cm.int(4); codec != parquetUncompressed {
+		return nil, fmt.Errorf("%w: compression codec %d", ErrUnsupported, codec)
+	}
+	numValues, _ := cm.int(5)
+	offset, _ := cm.int(9)
+	if dictOffset, ok := cm.int(11); ok && dictOffset > 0 && dictOffset < offset {
+		offset = dictOffset
+	}
+	if numValues < 0 || numValues > int64(len(data)) {
+		return nil, fmt.Errorf("%w: invalid number of values %d", ErrCorrupted, numValues)
+	}
+
+	values := make([]int64, 0, numValues)
+	var dict []int64
+	for int64(len(values)) < numValues {
+		if offset < 0 || offset >= int64(len(data)) {
+			return nil, fmt.Errorf("%w: page offset %d out of range", ErrCorrupted, offset)
+		}
+		r := thriftReader{buf: data[offset:]}
+		header, err := r.readStruct(0)
+		if err != nil {
+			return nil, fmt.Errorf("%w: page header: %v", ErrCorrupted, err)
+		}
+		size, _ := header.int(3)
+		if size < 0 || size > int64(len(r.buf)-r.pos) {
+			return nil, fmt.Errorf("%w: page size %d out of range", ErrCorrupted, size)
+		}
+		body := r.buf[r.pos : r.pos+int(size)]
+		offset += int64(r.pos) + size
+
+		pageType, _ := header.int(1)
+		switch pageType {
+		case parquetDictionaryPage:
+			ph, _ := header.fields(7)
+			n, _ := ph.int(1)
+			if dict, err = decodeParquetPlain(body, typ, n); err != nil {
+				return nil, err
+			}
+		case parquetDataPage, parquetDataPageV2:
+			var n, defLength int64
+			var encoding int64
+			if pageType == parquetDataPage {
+				ph, _ := header.fields(5)
+				n, _ = ph.int(1)
+				encoding, _ = ph.int(2)
+				if optional {
+					if len(body) < 4 {
+						return nil, fmt.Errorf("%w: truncated definition levels", ErrCorrupted)
+					}
+					defLength = 4 + int64(binary.LittleEndian.Uint32(body))
+				}
+			} else {
+				ph, _ := header.fields(8)
+				n, _ = ph.int(1)
+				encoding, _ = ph.int(4)
+				defLength, _ = ph.int(5)
+				if nulls, _ := ph.int(2); nulls != 0 {
+					return nil, fmt.Errorf("%w: null values", ErrCorrupted)
+				}
+			}
+			if n < 0 || n > numValues-int64(len(values)) || defLength < 0 || defLength > int64(len(body)) {
+				return nil, fmt.Errorf("%w: invalid data page", ErrCorrupted)
+			}
+			if optional && pageType == parquetDataPage {
+				levels, err := decodeParquetHybrid(body[4:defLength], 1, int(n))
+				if err != nil {
+					return nil, err
+				}
+				for _, l := range levels {
+					if l != 1 {
+						return nil, fmt.Errorf("%w: null values", ErrCorrupted)
+					}
+				}
+			}
+			body = body[defLength:]
+			var page []int64
+			switch encoding {
+			case parquetPlain:
+				page, err = decodeParquetPlain(body, typ, n)
+			case parquetPlainDictionary, parquetRLEDictionary:
+				page, err = decodeParquetDictionary(body, dict, n)
+			default:
+				err = fmt.Errorf("%w: encoding %d", ErrUnsupported, encoding)
+			}
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, page...)
+		default:
+			// Skip index pages.
+		}
+	}
+	return values, nil
+}
+
+// decodeParquetPlain decodes n PLAIN encoded values of physical type typ.
+func decodeParquetPlain(body []byte, typ int64, n int64) ([]int64, error) {
+	width := int64(4)
+	if typ == parquetInt64 {
+		width = 8
+	}
+	if n < 0 || n > int64(len(body))/width {
+		return nil, fmt.Errorf("%w: truncated page", ErrCorrupted)
+	}
+	values := make([]int64, n)
+	for k := range values {
+		if typ == parquetInt64 {
+			values[k] = int64(binary.LittleEndian.Uint64(body[8*k:]))
+		} else {
+			values[k] = int64(int32(binary.LittleEndian.Uint32(body[4*k:])))
+		}
+	}
+	return values, nil
+}
+
+// decodeParquetDictionary decodes n dictionary indices into values of dict.
+func decodeParquetDictionary(body []byte, dict []int64, n int64) ([]int64, error) {
+	if len(body) == 0 {
+		return nil, fmt.Errorf("%w: truncated page", ErrCorrupted)
+	}
+	indices, err := decodeParquetHybrid(body[1:], int(body[0]), int(n))
+	if err != nil {
+		return nil, err
+	}
+	values := make([]int64, n)
+	for k, index := range indices {
+		if uint64(index) >= uint64(len(dict)) {
+			return nil, fmt.Errorf("%w: dictionary index %d out of range", ErrCorrupted, index)
+		}
+		values[k] = dict[index]
+	}
+	return values, nil
+}
+
+// decodeParquetHybrid decodes n values of the given bit width in the RLE/bit-packing hybrid
+// encoding.
+func decodeParquetHybrid(body []byte, bitWidth int, n int) ([]uint32, error) {
+	if bitWidth > 32 {
+		return nil, fmt.Errorf("%w: bit width %d", ErrCorrupted, bitWidth)
+	}
+	values := make([]uint32, 0, n)
+	for len(values) < n {
+		header, k := binary.Uvarint(body)
+		if k <= 0 {
+			return nil, fmt.Errorf("%w: truncated levels or indices", ErrCorrupted)
+		}
+		body = body[k:]
+		if header&1 == 0 {
+			// A run of one value repeated header/2 times.
+			width := (bitWidth + 7) / 8
+			if len(body) < width || header>>1 > uint64(n-len(values)) {
+				return nil, fmt.Errorf("%w: invalid run", ErrCorrupted)
+			}
+			var v uint32
+			for b := 0; b < width; b++ {
+				v |= uint32(body[b]) << (8 * b)
+			}
+			body = body[width:]
+			for r := uint64(0); r < header>>1; r++ {
+				values = append(values, v)
+			}
+			continue
+		}
+		// header/2 groups of 8 bit-packed values.
+		groups := header >> 1
+		if groups > uint64(len(body)) || int(groups)*bitWidth > len(body) {
+			return nil, fmt.Errorf("%w: invalid bit-packed run", ErrCorrupted)
+		}
+		packed := body[:int(groups)*bitWidth]
+		body = body[len(packed):]
+		for r := 0; r < 8*int(groups) && len(values) < n; r++ {
+			var v uint32
+			for b := 0; b < bitWidth; b++ {
+				bit := r*bitWidth + b
+				if packed[bit/8]>>(bit%8)&1 != 0 {
+					v |= 1 << b
+				}
+			}
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
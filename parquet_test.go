@@ -0,0 +1,220 @@
+package cuckoo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDumpLoadParquet(t *testing.T) {
+	cf := NewFilter(1000, WithHashSeed(7))
+	for i := 0; i < 500; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	var buf bytes.Buffer
+	if err := cf.DumpParquet(&buf); err != nil {
+		t.Fatalf("DumpParquet() = %v", err)
+	}
+	got, err := LoadParquet(buf.Bytes())
+	if err != nil {
+		t.Fatalf("LoadParquet() = %v", err)
+	}
+	if !reflect.DeepEqual(got.buckets, cf.buckets) || got.Count() != cf.Count() || got.HashSeed() != 7 {
+		t.Errorf("LoadParquet() = filter with %d items and seed %d, want %d and 7", got.Count(), got.HashSeed(), cf.Count())
+	}
+
+	buf.Reset()
+	if err := NewFilter(10).DumpParquet(&buf); err != nil {
+		t.Fatalf("DumpParquet() of an empty filter = %v", err)
+	}
+	if got, err := LoadParquet(buf.Bytes()); err != nil || got.Count() != 0 {
+		t.Errorf("LoadParquet() of an empty filter = %v", err)
+	}
+}
+
+// testParquetFile returns a Parquet file holding the given columns as an optional, dictionary
+// encoded column in a single data page, as written by other tools.
+func testParquetFile(numBuckets uint64, codec int32, columns map[string][]int64) []byte {
+	file := append([]byte(nil), parquetMagic...)
+	var chunks [][]byte
+	var names []string
+	for _, col := range parquetColumns {
+		values, ok := columns[col.name]
+		if !ok {
+			continue
+		}
+		names = append(names, col.name)
+		var dict []int64
+		indices := map[int64]int{}
+		for _, v := range values {
+			if _, ok := indices[v]; !ok {
+				indices[v] = len(dict)
+				dict = append(dict, v)
+			}
+		}
+		dictOffset := len(file)
+		var dictBody []byte
+		for _, v := range dict {
+			dictBody = append(dictBody, 0, 0, 0, 0, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint64(dictBody[len(dictBody)-8:], uint64(v))
+		}
+		var header thriftWriter
+		header.begin()
+		header.i32(1, parquetDictionaryPage)
+		header.i32(2, int32(len(dictBody)))
+		header.i32(3, int32(len(dictBody)))
+		header.structField(7)
+		header.i32(1, int32(len(dict)))
+		header.i32(2, parquetPlain)
+		header.end()
+		header.end()
+		file = append(append(file, header.buf...), dictBody...)
+
+		// All definition levels are 1, encoded as a single run. Indices are 16 bit wide and
+		// bit-packed, padded to a multiple of 8 values.
+		body := []byte{0, 0, 0, 0}
+		body = append(body, byte(len(values)<<1))
+		body = append(body, 1)
+		binary.LittleEndian.PutUint32(body, uint32(len(body)-4))
+		groups := (len(values) + 7) / 8
+		body = append(body, 16)
+		body = append(body, byte(groups<<1|1))
+		for k := 0; k < 8*groups; k++ {
+			index := 0
+			if k < len(values) {
+				index = indices[values[k]]
+			}
+			body = append(body, byte(index), byte(index>>8))
+		}
+		dataOffset := len(file)
+		header = thriftWriter{}
+		header.begin()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(body)))
+		header.i32(3, int32(len(body)))
+		header.structField(5)
+		header.i32(1, int32(len(values)))
+		header.i32(2, parquetRLEDictionary)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+		file = append(append(file, header.buf...), body...)
+
+		var chunk thriftWriter
+		chunk.begin()
+		chunk.i64(2, int64(dictOffset))
+		chunk.structField(3)
+		chunk.i32(1, parquetInt64)
+		chunk.list(2, thriftI32, 2)
+		chunk.zigzag(parquetRLEDictionary)
+		chunk.zigzag(parquetRLE)
+		chunk.list(3, thriftBinary, 1)
+		chunk.binaryValue([]byte(col.name))
+		chunk.i32(4, codec)
+		chunk.i64(5, int64(len(values)))
+		chunk.i64(6, int64(len(file)-dictOffset))
+		chunk.i64(7, int64(len(file)-dictOffset))
+		chunk.i64(9, int64(dataOffset))
+		chunk.i64(11, int64(dictOffset))
+		chunk.end()
+		chunk.end()
+		chunks = append(chunks, chunk.buf)
+	}
+
+	var meta thriftWriter
+	meta.begin()
+	meta.i32(1, 2)
+	meta.list(2, thriftStruct, len(names)+1)
+	meta.begin()
+	meta.binary(4, []byte("duckdb_schema"))
+	meta.i32(5, int32(len(names)))
+	meta.end()
+	for _, name := range names {
+		meta.begin()
+		meta.i32(1, parquetInt64)
+		meta.i32(3, parquetOptional)
+		meta.binary(4, []byte(name))
+		meta.end()
+	}
+	meta.i64(3, int64(len(columns["bucket"])))
+	meta.list(4, thriftStruct, 1)
+	meta.begin()
+	meta.list(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		meta.buf = append(meta.buf, chunk...)
+	}
+	meta.i64(2, int64(len(file)-4))
+	meta.i64(3, int64(len(columns["bucket"])))
+	meta.end()
+	meta.list(5, thriftStruct, 1)
+	meta.begin()
+	meta.binary(1, []byte(parquetBucketsKey))
+	meta.binary(2, []byte(strconv.FormatUint(numBuckets, 10)))
+	meta.end()
+	meta.end()
+
+	file = append(file, meta.buf...)
+	file = append(file, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(file[len(file)-4:], uint32(len(meta.buf)))
+	return append(file, parquetMagic...)
+}
+
+func TestLoadParquet_Dictionary(t *testing.T) {
+	data := testParquetFile(4, parquetUncompressed, map[string][]int64{
+		"bucket":      {0, 3, 3, 1, 0},
+		"slot":        {0, 1, 2, 3, 1},
+		"fingerprint": {7, 7, 9, 1, 65535},
+	})
+	cf, err := LoadParquet(data)
+	if err != nil {
+		t.Fatalf("LoadParquet() = %v", err)
+	}
+	want := newFilter(make([]bucket, 4))
+	want.buckets[0].set(0, 7)
+	want.buckets[0].set(1, 65535)
+	want.buckets[1].set(3, 1)
+	want.buckets[3].set(1, 7)
+	want.buckets[3].set(2, 9)
+	if !reflect.DeepEqual(cf.buckets, want.buckets) || cf.Count() != 5 || cf.HashSeed() != defaultHashSeed {
+		t.Errorf("LoadParquet() = buckets %v with %d items, want %v with 5", cf.buckets, cf.Count(), want.buckets)
+	}
+}
+
+func TestLoadParquet_Invalid(t *testing.T) {
+	valid := map[string][]int64{"bucket": {0}, "slot": {0}, "fingerprint": {1}}
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"not parquet", []byte("PAR1garbagePAR1"), ErrCorrupted},
+		{"compressed", testParquetFile(4, 1, valid), ErrUnsupported},
+		{"buckets not a power of 2", testParquetFile(3, parquetUncompressed, valid), ErrCorrupted},
+		{"too many buckets", testParquetFile(1<<60, parquetUncompressed, valid), ErrTooLarge},
+		{"implausible buckets", testParquetFile(1<<26, parquetUncompressed, valid), ErrCorrupted},
+		{"missing column", testParquetFile(4, parquetUncompressed, map[string][]int64{"bucket": {0}, "slot": {0}}), ErrCorrupted},
+		{"bucket out of range", testParquetFile(4, parquetUncompressed, map[string][]int64{"bucket": {4}, "slot": {0}, "fingerprint": {1}}), ErrCorrupted},
+		{"empty fingerprint", testParquetFile(4, parquetUncompressed, map[string][]int64{"bucket": {0}, "slot": {0}, "fingerprint": {0}}), ErrCorrupted},
+	} {
+		if _, err := LoadParquet(tc.data); !errors.Is(err, tc.want) {
+			t.Errorf("LoadParquet(%s) = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	// Truncating or damaging any byte must not panic.
+	var buf bytes.Buffer
+	cf := NewFilter(100)
+	cf.Insert([]byte("one"))
+	cf.DumpParquet(&buf)
+	data := buf.Bytes()
+	for n := range data {
+		LoadParquet(data[:n])
+		damaged := append([]byte(nil), data...)
+		damaged[n] ^= 0xff
+		LoadParquet(damaged)
+	}
+}
@@ -0,0 +1,52 @@
+package cuckoo
+
+// Partition distributes the stored fingerprints of cf into n new filters, putting every
+// fingerprint into the filter with the index fn returns for it, e.g. by bucket range for
+// resharding a monolithic filter into a sharded deployment. Fingerprints for which fn returns
+// an index outside [0, n) are dropped.
+//
+// The new filters have the geometry and hashing of cf and keep every fingerprint in its
+// slot, so they answer lookups exactly like cf for the items they hold. Stashed fingerprints,
+// see WithOverflowStash, are passed to fn with Slot 4, past the slots of a bucket, and moved
+// to the stash of their filter. Call Shrink on them to reclaim the memory of the emptied
+// buckets.
+func (cf *Filter) Partition(fn func(fp StoredFP) int, n int) []*Filter {
+	if n <= 0 {
+		return nil
+	}
+	cf.lock.RLock()
+	fps := cf.storedFingerprints()
+	stashed := append([]stashEntry(nil), cf.stash.all()...)
+	stash := cf.stash
+	numBuckets := len(cf.buckets)
+	seed, hashVersion, altScheme, transform := cf.seed, cf.hashVersion, cf.altScheme, cf.transform
+	cf.lock.RUnlock()
+
+	parts := make([]*Filter, n)
+	for k := range parts {
+		parts[k] = newFilter(make([]bucket, numBuckets))
+		parts[k].seed = seed
+		parts[k].hashVersion = hashVersion
+		parts[k].altScheme = altScheme
+		parts[k].transform = transform
+		if stash != nil {
+			parts[k].stash = &overflowStash{size: stash.size}
+		}
+	}
+	for _, fp := range fps {
+		k := fn(fp)
+		if k < 0 || k >= n {
+			continue
+		}
+		parts[k].buckets[fp.Bucket].set(int(fp.Slot), fingerprint(fp.Fingerprint))
+		parts[k].count++
+	}
+	for _, e := range stashed {
+		k := fn(StoredFP{Bucket: e.i, Slot: bucketSize, Fingerprint: uint16(e.fp)})
+		if k < 0 || k >= n {
+			continue
+		}
+		parts[k].stashFingerprint(e.fp, e.i)
+	}
+	return parts
+}
@@ -0,0 +1,42 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPartition(t *testing.T) {
+	cf := NewFilter(10000, WithHashSeed(5))
+	for i := 0; i < 5000; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	half := uint(len(cf.buckets) / 2)
+	parts := cf.Partition(func(fp StoredFP) int {
+		if fp.Bucket < half {
+			return 0
+		}
+		return 1
+	}, 2)
+	if len(parts) != 2 || parts[0].Count()+parts[1].Count() != 5000 {
+		t.Fatalf("Partition() counts do not add up to 5000")
+	}
+	for _, p := range parts {
+		if p.HashSeed() != 5 || p.Cap() != cf.Cap() {
+			t.Errorf("partition HashSeed(), Cap() = %d, %d, want 5, %d", p.HashSeed(), p.Cap(), cf.Cap())
+		}
+	}
+	for i := 0; i < 5000; i++ {
+		key := []byte(strconv.Itoa(i))
+		if !parts[0].Lookup(key) && !parts[1].Lookup(key) {
+			t.Fatalf("Lookup(%d) = false in all partitions", i)
+		}
+	}
+
+	dropped := cf.Partition(func(fp StoredFP) int { return -1 }, 3)
+	if len(dropped) != 3 || dropped[0].Count() != 0 {
+		t.Errorf("Partition() into an invalid index kept items")
+	}
+	if cf.Partition(func(StoredFP) int { return 0 }, 0) != nil {
+		t.Errorf("Partition(fn, 0) != nil")
+	}
+}
@@ -0,0 +1,130 @@
+package cuckoo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// persistTimeout is the deadline for writing filters once PersistOnSignal is triggered,
+	// well within the default termination grace period of Kubernetes.
+	persistTimeout = 10 * time.Second
+	// persistChunkSize is the number of bytes written between checks of the deadline.
+	persistChunkSize = 1 << 20
+	// persistExt is the extension of the files written by Manager.PersistOnSignal.
+	persistExt = ".cuckoo"
+)
+
+// waitForShutdown blocks until the process receives SIGTERM or SIGINT, or ctx is done.
+func waitForShutdown(ctx context.Context) {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	<-ctx.Done()
+}
+
+// PersistOnSignal blocks until the process receives SIGTERM or SIGINT, or ctx is done, and
+// then writes the filter to the file at path, so a rolling restart does not lose it. The file
+// holds Encode and is replaced atomically; writing must finish within 10 seconds. Restore it
+// with LoadFromFileWithProgress.
+func (cf *Filter) PersistOnSignal(ctx context.Context, path string) error {
+	waitForShutdown(ctx)
+	deadline, cancel := context.WithTimeout(context.Background(), persistTimeout)
+	defer cancel()
+	return persistEncoded(deadline, path, cf.Encode())
+}
+
+// PersistOnSignal blocks until the process receives SIGTERM or SIGINT, or ctx is done, and
+// then writes every live filter to a file in dir named after the escaped filter name, see
+// Filter.PersistOnSignal. Writing all filters must finish within 10 seconds. Restore them with
+// Restore.
+func (m *Manager) PersistOnSignal(ctx context.Context, dir string) error {
+	waitForShutdown(ctx)
+	deadline, cancel := context.WithTimeout(context.Background(), persistTimeout)
+	defer cancel()
+
+	for _, name := range m.Names() {
+		cf, ok := m.Peek(name)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, url.PathEscape(name)+persistExt)
+		if err := persistEncoded(deadline, path, cf.Encode()); err != nil {
+			return fmt.Errorf("persisting filter %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Restore loads the filters written to dir by PersistOnSignal, decoding them with the options
+// of their configuration. Returns the number of restored filters. Restored filters replace
+// live ones of the same name.
+func (m *Manager) Restore(dir string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+persistExt))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, path := range paths {
+		name, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(path), persistExt))
+		if err != nil {
+			return n, fmt.Errorf("restoring %s: %w", path, err)
+		}
+		m.lock.Lock()
+		config := m.configFor(name)
+		m.lock.Unlock()
+		cf, err := LoadFromFileWithProgress(path, nil, config.Options...)
+		if err != nil {
+			return n, fmt.Errorf("restoring filter %q: %w", name, err)
+		}
+
+		m.lock.Lock()
+		if _, ok := m.filters[name]; !ok {
+			m.created++
+		}
+		m.filters[name] = &managedFilter{cf: cf, config: config, lastUsed: m.now()}
+		m.lock.Unlock()
+		n++
+	}
+	return n, nil
+}
+
+// persistEncoded atomically replaces the file at path by encoded, stopping with the context's
+// error when ctx is done.
+func persistEncoded(ctx context.Context, path string, encoded []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	for len(encoded) > 0 {
+		if err := ctx.Err(); err != nil {
+			tmp.Close()
+			return err
+		}
+		chunk := encoded
+		if len(chunk) > persistChunkSize {
+			chunk = chunk[:persistChunkSize]
+		}
+		if _, err := tmp.Write(chunk); err != nil {
+			tmp.Close()
+			return err
+		}
+		encoded = encoded[len(chunk):]
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,58 @@
+package cuckoo
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersistOnSignal(t *testing.T) {
+	cf := NewFilter(1000)
+	cf.Insert([]byte("state"))
+	path := filepath.Join(t.TempDir(), "filter.cuckoo")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- cf.PersistOnSignal(ctx, path) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("PersistOnSignal() returned %v before shutdown", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	restored, err := LoadFromFileWithProgress(path, nil)
+	if err != nil || !restored.Lookup([]byte("state")) {
+		t.Errorf("restored filter = %v, error %v", restored, err)
+	}
+}
+
+func TestManagerPersistOnSignal(t *testing.T) {
+	config := func(string) FilterConfig {
+		return FilterConfig{Capacity: 100, Options: []Option{WithKeyTransform(bytes.ToLower)}}
+	}
+	m := NewManager(config)
+	m.Insert("customer/1", []byte("a"))
+	m.Insert("customer 2", []byte("b"))
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.PersistOnSignal(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewManager(config)
+	if n, err := restored.Restore(dir); n != 2 || err != nil {
+		t.Fatalf("Restore() = %d, %v", n, err)
+	}
+	if !restored.Lookup("customer/1", []byte("a")) || !restored.Lookup("customer 2", []byte("b")) {
+		t.Error("restored filters lost items")
+	}
+	if !restored.Lookup("customer/1", []byte("A")) {
+		t.Error("restored filter ignores the options of its configuration")
+	}
+}
@@ -0,0 +1,60 @@
+package cuckoo
+
+import "fmt"
+
+// Prepared is an item hashed for insertion by Prepare. It is only valid for the filter that
+// prepared it.
+type Prepared struct {
+	i1   uint
+	fp   fingerprint
+	mask uint
+	// data is only kept if misuse detection is enabled, see WithMisuseDetection.
+	data []byte
+	// hash is only set if distinct estimation is enabled, see WithDistinctEstimation.
+	hash uint64
+	// keyHash is only set if auditing is enabled, see WithAuditSink.
+	keyHash uint64
+}
+
+// Prepare hashes data for a later Commit. It does not lock the filter and is safe for
+// concurrent use, so many keys can be hashed in parallel and committed in batches.
+func (cf *Filter) Prepare(data []byte) Prepared {
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+	p := Prepared{i1: i1, fp: fp, mask: cf.bucketIndexMask}
+	if cf.debug != nil {
+		p.data = data
+	}
+	if cf.distinct != nil {
+		p.hash = distinctHash(data)
+	}
+	if cf.audit != nil {
+		p.keyHash = cf.keyHash(data)
+	}
+	return p
+}
+
+// Commit inserts the prepared items in order, locking the filter once for the whole batch.
+// Returns the number of inserted items. If an insertion fails, it stops and returns an
+// error wrapping ErrFull. Items prepared by a filter of a different size give an error
+// wrapping ErrIncompatible.
+func (cf *Filter) Commit(batch []Prepared) (int, error) {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	for n, p := range batch {
+		if p.mask != cf.bucketIndexMask {
+			return n, fmt.Errorf("%w: item %d was prepared for %d buckets, have %d", ErrIncompatible, n, p.mask+1, len(cf.buckets))
+		}
+		if !cf.insertFingerprint(p.fp, p.i1) {
+			cf.audit.record(AuditInsert, p.keyHash, false)
+			return n, fmt.Errorf("%w: committed %d of %d items", ErrFull, n, len(batch))
+		}
+		cf.debug.recordInsert(p.data)
+		cf.distinct.add(p.hash)
+		cf.journal.recordInsert(p.fp, p.i1, p.data)
+		cf.audit.record(AuditInsert, p.keyHash, true)
+	}
+	cf.checkInvariants()
+	return len(batch), nil
+}
@@ -0,0 +1,46 @@
+package cuckoo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPrepareCommit(t *testing.T) {
+	cf := NewFilter(10000)
+	batch := make([]Prepared, 1000)
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(batch); i += 4 {
+				batch[i] = cf.Prepare([]byte(fmt.Sprint(i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if n, err := cf.Commit(batch); n != len(batch) || err != nil {
+		t.Fatalf("Commit() = %d, %v, want %d, nil", n, err, len(batch))
+	}
+	for i := range batch {
+		if !cf.Lookup([]byte(fmt.Sprint(i))) {
+			t.Fatalf("Lookup(%d) = false after Commit()", i)
+		}
+	}
+
+	other := NewFilter(100)
+	if _, err := other.Commit(batch); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Commit() of items prepared by another filter error = %v, want %v", err, ErrIncompatible)
+	}
+	small := NewFilter(8)
+	var full []Prepared
+	for i := 0; i < 100; i++ {
+		full = append(full, small.Prepare([]byte(fmt.Sprint(i))))
+	}
+	if n, err := small.Commit(full); !errors.Is(err, ErrFull) || uint(n) != small.Count() {
+		t.Errorf("Commit() into full filter = %d, %v, want %d, %v", n, err, small.Count(), ErrFull)
+	}
+}
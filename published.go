@@ -0,0 +1,237 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package cuckoo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// publishedMagic identifies files written by Publish.
+var publishedMagic = [8]byte{'C', 'U', 'C', 'K', 'O', 'O', 'P', 'B'}
+
+// publishedHeader is stored at the beginning of a published filter file, followed by the buckets.
+type publishedHeader struct {
+	magic      [8]byte
+	version    uint64
+	numBuckets uint64
+	count      uint64
+}
+
+const publishedHeaderSize = 32
+
+// Publish atomically replaces the file at path with a snapshot of cf, which readers can map
+// with OpenPublished. Every snapshot gets a version one higher than the one it replaces.
+// Writes to cf block while the snapshot is written.
+// The file uses the native byte order and is meant for processes on the same host.
+// Filters with a hash seed or alternate index scheme other than the default give an error
+// wrapping ErrUnsupported.
+func Publish(path string, cf *Filter) error {
+	if cf.seed != defaultHashSeed {
+		return fmt.Errorf("%w: publishing filter with custom hash seed", ErrUnsupported)
+	}
+	if cf.altScheme != AltIndexXOR {
+		return fmt.Errorf("%w: publishing filter with alternate index scheme %v", ErrUnsupported, cf.altScheme)
+	}
+	var version uint64 = 1
+	if prev, err := readPublishedHeader(path); err == nil {
+		version = prev.version + 1
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := writePublished(tmp, cf, version); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func writePublished(f *os.File, cf *Filter, version uint64) error {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	if cf.stash.len() > 0 {
+		return fmt.Errorf("%w: publishing stashed items", ErrUnsupported)
+	}
+	header := publishedHeader{
+		magic:      publishedMagic,
+		version:    version,
+		numBuckets: uint64(len(cf.buckets)),
+		count:      uint64(cf.count),
+	}
+	w := bufio.NewWriter(f)
+	if _, err := w.Write(header.encode()); err != nil {
+		return err
+	}
+	var buf [8]byte
+	for i, b := range cf.buckets {
+		if cf.stale(uint(i)) {
+			b = 0
+		}
+		nativeEndian.PutUint64(buf[:], uint64(b))
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func readPublishedHeader(path string) (publishedHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return publishedHeader{}, err
+	}
+	defer f.Close()
+	buf := make([]byte, publishedHeaderSize)
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return publishedHeader{}, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	return decodePublishedHeader(buf)
+}
+
+func (h publishedHeader) encode() []byte {
+	buf := make([]byte, publishedHeaderSize)
+	copy(buf, h.magic[:])
+	nativeEndian.PutUint64(buf[8:], h.version)
+	nativeEndian.PutUint64(buf[16:], h.numBuckets)
+	nativeEndian.PutUint64(buf[24:], h.count)
+	return buf
+}
+
+func decodePublishedHeader(buf []byte) (publishedHeader, error) {
+	var h publishedHeader
+	if len(buf) < publishedHeaderSize || !bytes.Equal(buf[:8], publishedMagic[:]) {
+		return h, fmt.Errorf("%w: not a published filter", ErrCorrupted)
+	}
+	copy(h.magic[:], buf)
+	h.version = nativeEndian.Uint64(buf[8:])
+	h.numBuckets = nativeEndian.Uint64(buf[16:])
+	h.count = nativeEndian.Uint64(buf[24:])
+	return h, nil
+}
+
+// PublishedFilter is a read-only view of a filter file written by Publish. The buckets are
+// mapped into memory without copying, so opening is cheap and the memory is shared between
+// all processes mapping the same snapshot. It is safe for concurrent use.
+type PublishedFilter struct {
+	path   string
+	lock   sync.RWMutex
+	data   []byte
+	header publishedHeader
+	filter *Filter
+}
+
+// OpenPublished maps the filter file at path.
+func OpenPublished(path string) (*PublishedFilter, error) {
+	pf := &PublishedFilter{path: path}
+	if _, err := pf.Reload(); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+// Reload maps the file at the path of pf again if a snapshot with a different version was
+// published since it was last mapped. Returns true if it did.
+func (pf *PublishedFilter) Reload() (bool, error) {
+	header, err := readPublishedHeader(pf.path)
+	if err != nil {
+		return false, err
+	}
+	pf.lock.RLock()
+	unchanged := pf.data != nil && header.version == pf.header.version
+	pf.lock.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	f, err := os.Open(pf.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return false, err
+	}
+	// The file may have been replaced since the header was read, parse the mapped one.
+	header, err = decodePublishedHeader(data)
+	numBuckets := header.numBuckets
+	if err != nil || numBuckets == 0 || numBuckets > maxMappedBuckets ||
+		numBuckets&(numBuckets-1) != 0 || info.Size() != int64(publishedHeaderSize)+int64(numBuckets)*8 {
+		syscall.Munmap(data)
+		return false, fmt.Errorf("%w: invalid published filter %s", ErrCorrupted, pf.path)
+	}
+	filter := newFilter(mappedBuckets(data[publishedHeaderSize:], int(numBuckets)))
+	filter.count = uint(header.count)
+
+	pf.lock.Lock()
+	defer pf.lock.Unlock()
+	if pf.data != nil {
+		syscall.Munmap(pf.data)
+	}
+	pf.data, pf.header, pf.filter = data, header, filter
+	return true, nil
+}
+
+// Lookup returns true if data is in the filter.
+func (pf *PublishedFilter) Lookup(data []byte) bool {
+	pf.lock.RLock()
+	defer pf.lock.RUnlock()
+
+	if pf.filter == nil {
+		return false
+	}
+	return pf.filter.Lookup(data)
+}
+
+// Count returns the number of items in the filter.
+func (pf *PublishedFilter) Count() uint {
+	pf.lock.RLock()
+	defer pf.lock.RUnlock()
+
+	return uint(pf.header.count)
+}
+
+// Version returns the version of the mapped snapshot.
+func (pf *PublishedFilter) Version() uint64 {
+	pf.lock.RLock()
+	defer pf.lock.RUnlock()
+
+	return pf.header.version
+}
+
+// Close unmaps the filter.
+func (pf *PublishedFilter) Close() error {
+	pf.lock.Lock()
+	defer pf.lock.Unlock()
+
+	if pf.data == nil {
+		return os.ErrClosed
+	}
+	err := syscall.Munmap(pf.data)
+	pf.data, pf.filter = nil, nil
+	return err
+}
@@ -0,0 +1,72 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package cuckoo
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter")
+	cf := NewFilter(1000)
+	cf.Insert([]byte("one"))
+	if err := Publish(path, cf); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+
+	pf, err := OpenPublished(path)
+	if err != nil {
+		t.Fatalf("OpenPublished() = %v", err)
+	}
+	defer pf.Close()
+	if !pf.Lookup([]byte("one")) || pf.Lookup([]byte("two")) {
+		t.Errorf("Lookup() does not match published filter")
+	}
+	if reloaded, err := pf.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() without new snapshot = %v, %v, want false, nil", reloaded, err)
+	}
+
+	cf.Insert([]byte("two"))
+	if err := Publish(path, cf); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if reloaded, err := pf.Reload(); !reloaded || err != nil {
+		t.Errorf("Reload() after new snapshot = %v, %v, want true, nil", reloaded, err)
+	}
+	if got, want := pf.Version(), uint64(2); got != want {
+		t.Errorf("Version() = %d, want %d", got, want)
+	}
+	if !pf.Lookup([]byte("two")) || pf.Count() != 2 {
+		t.Errorf("After Reload(): Lookup() = false, Count() = %d, want true, 2", pf.Count())
+	}
+}
+
+func TestOpenPublished_Corrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter")
+	if err := ioutil.WriteFile(path, []byte("not a filter, but long enough for a header"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenPublished(path); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("OpenPublished() error = %v, want %v", err, ErrCorrupted)
+	}
+}
+
+func TestPublish_Stash(t *testing.T) {
+	cf, _ := fillWithStash(t)
+	if err := Publish(filepath.Join(t.TempDir(), "filter"), cf); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Publish() with stashed items = %v, want %v", err, ErrUnsupported)
+	}
+}
+
+func TestPublish_AltIndexScheme(t *testing.T) {
+	for _, s := range []AltIndexScheme{AltIndexOffset, AltIndexCacheLine} {
+		cf := NewFilter(1000, WithAltIndexScheme(s))
+		if err := Publish(filepath.Join(t.TempDir(), "filter"), cf); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Publish() with %v = %v, want %v", s, err, ErrUnsupported)
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+// Metadata bits of a quotient filter slot.
+const (
+	qfOccupied     = 1 << iota // The slot is the canonical slot of some stored item.
+	qfContinuation             // The slot holds an item of the same run as the previous slot.
+	qfShifted                  // The item in the slot is not in its canonical slot.
+)
+
+// qfMaxLoad is the load factor used for sizing quotient filters. Above it, runs get long
+// and operations slow down.
+const qfMaxLoad = 0.75
+
+const (
+	// qfRemainderBits is the number of remainder bits of new quotient filters. Every Resize
+	// moves one of them into the quotient.
+	qfRemainderBits = 16
+	// qfMinRemainderBits is the number of remainder bits Resize stops at, with a false
+	// positive rate of about 1/64 at the maximum load factor.
+	qfMinRemainderBits = 6
+	// quotientFormatVersion is the format version in the encoding header written by
+	// QuotientFilter.Encode. Decode does not support it, so quotient filters cannot be
+	// mistaken for cuckoo filters.
+	quotientFormatVersion = 0x81
+	// quotientHeaderSize is the size of the encoding of a QuotientFilter before its slots: the
+	// encoding header, the number of remainder bits and 7 reserved bytes.
+	quotientHeaderSize = encodingHeaderSize + 8
+)
+
+// QuotientFilter is a quotient filter, an alternative to Filter with the same API.
+// Items are stored as sorted remainders in runs of consecutive slots, which makes
+// merging filters efficient, and the quotient and remainder are the high bits of the hash,
+// so Resize can grow the filter without the original keys. It is safe for concurrent use.
+//
+// See "Don't Thrash: How to Cache Your Hash on Flash" by Bender et al.
+type QuotientFilter struct {
+	remainders []uint16
+	meta       []uint8
+	count      uint
+	// Bit mask set to len(remainders) - 1, which is always a power of 2.
+	slotMask uint
+	// quotientBits is log2(len(remainders)) and remainderBits the size of the remainders.
+	quotientBits, remainderBits uint8
+	lock                        sync.RWMutex
+}
+
+var _ ApproxSet = (*QuotientFilter)(nil)
+
+// NewQuotientFilter returns a new quotient filter suitable for the given number of elements.
+// Every element uses 24 bits, which gives a false positive rate comparable to Filter.
+func NewQuotientFilter(numElements uint) *QuotientFilter {
+	numSlots := getNextPow2(uint64(float64(numElements) / qfMaxLoad))
+	if numSlots < 2 {
+		numSlots = 2
+	}
+	return newQuotientFilter(numSlots, qfRemainderBits)
+}
+
+// newQuotientFilter returns an empty quotient filter with numSlots slots, a power of 2, and
+// remainders of the given size.
+func newQuotientFilter(numSlots uint, remainderBits uint8) *QuotientFilter {
+	return &QuotientFilter{
+		remainders:    make([]uint16, numSlots),
+		meta:          make([]uint8, numSlots),
+		slotMask:      numSlots - 1,
+		quotientBits:  uint8(bits.TrailingZeros(numSlots)),
+		remainderBits: remainderBits,
+	}
+}
+
+// quotientAndRemainder returns the canonical slot and the remainder stored for data: the
+// highest bits of its hash and the bits following them. The caller must hold at least the
+// read lock, as Resize changes the split.
+func (qf *QuotientFilter) quotientAndRemainder(data []byte) (uint, uint16) {
+	hash := hashKey(data)
+	rest := hash << qf.quotientBits
+	return uint(hash >> (64 - qf.quotientBits)), uint16(rest >> (64 - qf.remainderBits))
+}
+
+func (qf *QuotientFilter) incr(i uint) uint { return (i + 1) & qf.slotMask }
+func (qf *QuotientFilter) decr(i uint) uint { return (i - 1) & qf.slotMask }
+
+func (qf *QuotientFilter) isEmpty(i uint) bool { return qf.meta[i] == 0 }
+func (qf *QuotientFilter) is(i uint, bit uint8) bool {
+	return qf.meta[i]&bit != 0
+}
+
+// isRunStart returns true if slot i holds the first item of a run.
+func (qf *QuotientFilter) isRunStart(i uint) bool {
+	return !qf.is(i, qfContinuation) && (qf.is(i, qfOccupied) || qf.is(i, qfShifted))
+}
+
+// findRunIndex returns the slot holding the first item of the run of quotient fq.
+func (qf *QuotientFilter) findRunIndex(fq uint) uint {
+	// Walk back to the start of the cluster.
+	b := fq
+	for qf.is(b, qfShifted) {
+		b = qf.decr(b)
+	}
+	// Walk forward, skipping one run for every occupied quotient until reaching fq.
+	s := b
+	for b != fq {
+		for {
+			s = qf.incr(s)
+			if !qf.is(s, qfContinuation) {
+				break
+			}
+		}
+		for {
+			b = qf.incr(b)
+			if qf.is(b, qfOccupied) {
+				break
+			}
+		}
+	}
+	return s
+}
+
+// Lookup returns true if data is in the filter.
+func (qf *QuotientFilter) Lookup(data []byte) bool {
+	qf.lock.RLock()
+	defer qf.lock.RUnlock()
+
+	fq, fr := qf.quotientAndRemainder(data)
+	if !qf.is(fq, qfOccupied) {
+		return false
+	}
+	s := qf.findRunIndex(fq)
+	for {
+		if rem := qf.remainders[s]; rem == fr {
+			return true
+		} else if rem > fr {
+			// Runs are sorted.
+			return false
+		}
+		s = qf.incr(s)
+		if !qf.is(s, qfContinuation) {
+			return false
+		}
+	}
+}
+
+// Insert data into the filter. Returns false if the filter is full.
+// Like Filter, it allows inserting the same item multiple times.
+func (qf *QuotientFilter) Insert(data []byte) bool {
+	qf.lock.Lock()
+	defer qf.lock.Unlock()
+
+	fq, fr := qf.quotientAndRemainder(data)
+	return qf.insert(fq, fr)
+}
+
+func (qf *QuotientFilter) insert(fq uint, fr uint16) bool {
+	// Keep at least one empty slot, which terminates all cluster scans.
+	if qf.count >= uint(len(qf.meta))-1 {
+		return false
+	}
+	qf.count++
+
+	if qf.isEmpty(fq) {
+		qf.remainders[fq] = fr
+		qf.meta[fq] = qfOccupied
+		return true
+	}
+
+	wasOccupied := qf.is(fq, qfOccupied)
+	qf.meta[fq] |= qfOccupied
+	start := qf.findRunIndex(fq)
+	s := start
+	var entryMeta uint8
+	if wasOccupied {
+		// Find the insert position in the sorted run of fq.
+		for qf.remainders[s] <= fr {
+			s = qf.incr(s)
+			if !qf.is(s, qfContinuation) {
+				break
+			}
+		}
+		if s == start {
+			// The old start of the run becomes a continuation.
+			qf.meta[start] |= qfContinuation
+		} else {
+			entryMeta |= qfContinuation
+		}
+	}
+	if s != fq {
+		entryMeta |= qfShifted
+	}
+	qf.shiftIn(s, fr, entryMeta)
+	return true
+}
+
+// shiftIn puts the item (rem, m) into slot s, shifting all following items of the cluster
+// right by one slot. Occupied bits stay with their slot.
+func (qf *QuotientFilter) shiftIn(s uint, rem uint16, m uint8) {
+	for {
+		prevRem, prevMeta := qf.remainders[s], qf.meta[s]
+		empty := prevMeta == 0
+		qf.remainders[s] = rem
+		qf.meta[s] = m&^qfOccupied | prevMeta&qfOccupied
+		if empty {
+			return
+		}
+		rem, m = prevRem, prevMeta&^qfOccupied|qfShifted
+		s = qf.incr(s)
+	}
+}
+
+// Delete data from the filter. Returns true if the data was found and deleted.
+func (qf *QuotientFilter) Delete(data []byte) bool {
+	qf.lock.Lock()
+	defer qf.lock.Unlock()
+
+	fq, fr := qf.quotientAndRemainder(data)
+	if !qf.is(fq, qfOccupied) {
+		return false
+	}
+	// Decode the whole cluster, then clear and rebuild it without the deleted item.
+	start := fq
+	for qf.is(start, qfShifted) {
+		start = qf.decr(start)
+	}
+	type item struct {
+		quotient  uint
+		remainder uint16
+	}
+	var items []item
+	found := false
+	quotient := start
+	s := start
+	for {
+		if qf.isRunStart(s) && s != start {
+			for {
+				quotient = qf.incr(quotient)
+				if qf.is(quotient, qfOccupied) {
+					break
+				}
+			}
+		}
+		if !found && quotient == fq && qf.remainders[s] == fr {
+			found = true
+		} else {
+			items = append(items, item{quotient, qf.remainders[s]})
+		}
+		s = qf.incr(s)
+		if !qf.is(s, qfShifted) {
+			// Reached an empty slot or the start of the next cluster.
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	for i := start; i != s; i = qf.incr(i) {
+		qf.meta[i] = 0
+	}
+	qf.count -= uint(len(items)) + 1
+	for _, it := range items {
+		qf.insert(it.quotient, it.remainder)
+	}
+	return true
+}
+
+// Count returns the number of items in the filter.
+func (qf *QuotientFilter) Count() uint {
+	qf.lock.RLock()
+	defer qf.lock.RUnlock()
+
+	return qf.count
+}
+
+// LoadFactor returns the fraction of slots that are occupied.
+func (qf *QuotientFilter) LoadFactor() float64 {
+	qf.lock.RLock()
+	defer qf.lock.RUnlock()
+
+	return float64(qf.count) / float64(len(qf.meta))
+}
+
+// Reset removes all items from the filter, setting count to 0.
+func (qf *QuotientFilter) Reset() {
+	qf.lock.Lock()
+	defer qf.lock.Unlock()
+
+	for i := range qf.meta {
+		qf.meta[i] = 0
+		qf.remainders[i] = 0
+	}
+	qf.count = 0
+}
+
+// Encode returns a byte slice representing a QuotientFilter. It starts with the header of
+// Encode, with a format version of its own, followed by the remainder size and 3 bytes per
+// slot.
+func (qf *QuotientFilter) Encode() []byte {
+	qf.lock.RLock()
+	defer qf.lock.RUnlock()
+
+	bytes := make([]byte, quotientHeaderSize+3*len(qf.meta))
+	copy(bytes, encodingMagic[:])
+	bytes[4] = quotientFormatVersion
+	bytes[5] = CurrentHashVersion
+	binary.LittleEndian.PutUint64(bytes[8:], defaultHashSeed)
+	binary.LittleEndian.PutUint64(bytes[16:], uint64(qf.count))
+	bytes[encodingHeaderSize] = qf.remainderBits
+	slots := bytes[quotientHeaderSize:]
+	for i, rem := range qf.remainders {
+		binary.LittleEndian.PutUint16(slots[3*i:], rem)
+		slots[3*i+2] = qf.meta[i]
+	}
+	return bytes
+}
+
+// DecodeQuotientFilter returns a QuotientFilter from a byte slice created using Encode.
+func DecodeQuotientFilter(bytes []byte) (_ *QuotientFilter, err error) {
+	defer recoverInvalidState(&err)
+	if len(bytes) < quotientHeaderSize || !hasEncodingHeader(bytes) {
+		return nil, fmt.Errorf("%w: not an encoded quotient filter", ErrCorrupted)
+	}
+	if v := bytes[4]; v != quotientFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported quotient filter format version %d", ErrIncompatible, v)
+	}
+	if v := bytes[5]; !supportedHashVersion(v) {
+		return nil, fmt.Errorf("%w: unsupported hash version %d", ErrIncompatible, v)
+	}
+	for _, b := range bytes[encodingHeaderSize+1 : quotientHeaderSize] {
+		if b != 0 {
+			return nil, fmt.Errorf("%w: reserved bytes are not zero", ErrCorrupted)
+		}
+	}
+	slots := bytes[quotientHeaderSize:]
+	numSlots := uint(len(slots) / 3)
+	if len(slots)%3 != 0 || numSlots < 2 || getNextPow2(uint64(numSlots)) != numSlots {
+		return nil, fmt.Errorf("%w: expected slots to take 3 times a power of 2 bytes, got %d", ErrCorrupted, len(slots))
+	}
+	remainderBits := bytes[encodingHeaderSize]
+	if remainderBits < qfMinRemainderBits || remainderBits > qfRemainderBits || bits.TrailingZeros(numSlots)+int(remainderBits) > 64 {
+		return nil, fmt.Errorf("%w: invalid remainder size %d", ErrCorrupted, remainderBits)
+	}
+	qf := newQuotientFilter(numSlots, remainderBits)
+	for i := range qf.meta {
+		qf.remainders[i] = binary.LittleEndian.Uint16(slots[3*i:])
+		qf.meta[i] = slots[3*i+2]
+		if qf.meta[i]&^(qfOccupied|qfContinuation|qfShifted) != 0 {
+			return nil, fmt.Errorf("%w: invalid metadata %#x in slot %d", ErrCorrupted, qf.meta[i], i)
+		}
+		if uint(qf.remainders[i])>>remainderBits != 0 {
+			return nil, fmt.Errorf("%w: remainder %#x in slot %d exceeds %d bits", ErrCorrupted, qf.remainders[i], i, remainderBits)
+		}
+		if !qf.isEmpty(uint(i)) {
+			qf.count++
+		}
+	}
+	if qf.count >= numSlots {
+		return nil, fmt.Errorf("%w: no empty slot", ErrCorrupted)
+	}
+	if count := binary.LittleEndian.Uint64(bytes[16:]); count != uint64(qf.count) {
+		return nil, fmt.Errorf("%w: header records %d items, slots hold %d", ErrCorrupted, count, qf.count)
+	}
+	return qf, nil
+}
+
+// Merge inserts all items of other into qf. Both filters must have the same number of slots
+// and remainder size. The merge is all-or-nothing: if qf cannot hold all items, it is left
+// unchanged and ErrFull is returned.
+func (qf *QuotientFilter) Merge(other *QuotientFilter) error {
+	if qf == other {
+		return fmt.Errorf("%w: cannot merge a filter into itself", ErrIncompatible)
+	}
+	// Copy the items of other first, so that the two filters are never locked at the same
+	// time and concurrent merges in opposite directions cannot deadlock.
+	type item struct {
+		quotient  uint
+		remainder uint16
+	}
+	other.lock.RLock()
+	numSlots, remainderBits := len(other.meta), other.remainderBits
+	items := make([]item, 0, other.count)
+	other.forEach(func(quotient uint, remainder uint16) {
+		items = append(items, item{quotient, remainder})
+	})
+	other.lock.RUnlock()
+
+	qf.lock.Lock()
+	defer qf.lock.Unlock()
+
+	if len(qf.meta) != numSlots || qf.remainderBits != remainderBits {
+		return fmt.Errorf("%w: got %d slots with %d-bit remainders, want %d with %d", ErrIncompatible, numSlots, remainderBits, len(qf.meta), qf.remainderBits)
+	}
+	if qf.count+uint(len(items)) >= uint(len(qf.meta)) {
+		return fmt.Errorf("%w: merging %d into %d items exceeds %d slots", ErrFull, len(items), qf.count, len(qf.meta)-1)
+	}
+	for _, it := range items {
+		qf.insert(it.quotient, it.remainder)
+	}
+	return nil
+}
+
+// Resize doubles the number of slots of the filter without the original keys, by moving the
+// highest remainder bit of every item into its quotient. Every Resize thus doubles the false
+// positive rate at a given load factor. It returns an error wrapping ErrTooLarge once the
+// remainders are down to 6 bits.
+func (qf *QuotientFilter) Resize() error {
+	qf.lock.Lock()
+	defer qf.lock.Unlock()
+
+	if qf.remainderBits <= qfMinRemainderBits {
+		return fmt.Errorf("%w: remainders are down to %d bits", ErrTooLarge, qf.remainderBits)
+	}
+	if len(qf.meta) > maxInt/2 {
+		return fmt.Errorf("%w: %d slots", ErrTooLarge, len(qf.meta))
+	}
+	rb := qf.remainderBits - 1
+	resized := newQuotientFilter(2*uint(len(qf.meta)), rb)
+	qf.forEach(func(quotient uint, remainder uint16) {
+		resized.insert(quotient<<1|uint(remainder>>rb), remainder&(1<<rb-1))
+	})
+	qf.remainders, qf.meta = resized.remainders, resized.meta
+	qf.slotMask, qf.quotientBits, qf.remainderBits = resized.slotMask, resized.quotientBits, resized.remainderBits
+	return nil
+}
+
+// forEach calls fn for every stored item. The caller must hold at least the read lock.
+func (qf *QuotientFilter) forEach(fn func(quotient uint, remainder uint16)) {
+	// Start iterating at a cluster start, i.e. after an empty slot.
+	first := uint(0)
+	for !qf.isEmpty(first) {
+		first = qf.incr(first)
+	}
+	quotient := first
+	s := first
+	for {
+		s = qf.incr(s)
+		if s == first {
+			return
+		}
+		if qf.isEmpty(s) {
+			continue
+		}
+		if !qf.is(s, qfShifted) {
+			// Cluster start, the item is in its canonical slot.
+			quotient = s
+		} else if qf.isRunStart(s) {
+			for {
+				quotient = qf.incr(quotient)
+				if qf.is(quotient, qfOccupied) {
+					break
+				}
+			}
+		}
+		fn(quotient, qf.remainders[s])
+	}
+}
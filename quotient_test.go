@@ -0,0 +1,179 @@
+package cuckoo
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestQuotientFilter_RandomOperations(t *testing.T) {
+	// A tiny filter produces long clusters that wrap around the end of the table.
+	qf := NewQuotientFilter(48)
+	inserted := make(map[int]int)
+	total := 0
+	rng := rand.New(rand.NewSource(1))
+	for op := 0; op < 20000; op++ {
+		item := rng.Intn(100)
+		data := []byte{byte(item)}
+		if rng.Intn(2) == 0 {
+			if qf.Insert(data) {
+				inserted[item]++
+				total++
+			}
+		} else if inserted[item] > 0 {
+			if !qf.Delete(data) {
+				t.Fatalf("op %d: Delete(%v) = false for inserted item", op, data)
+			}
+			inserted[item]--
+			total--
+		}
+		if got := qf.Count(); got != uint(total) {
+			t.Fatalf("op %d: Count() = %d, want %d", op, got, total)
+		}
+		for item, n := range inserted {
+			if n > 0 && !qf.Lookup([]byte{byte(item)}) {
+				t.Fatalf("op %d: Lookup(%v) = false for inserted item", op, item)
+			}
+		}
+	}
+}
+
+func TestQuotientFilter_FalsePositives(t *testing.T) {
+	const size = 10000
+	qf := NewQuotientFilter(size)
+	for i := 0; i < size; i++ {
+		if !qf.Insert([]byte{byte(i), byte(i >> 8), 0}) {
+			t.Fatalf("Insert() failed after %d items", i)
+		}
+	}
+	fp := 0
+	for i := 0; i < size; i++ {
+		if qf.Lookup([]byte{byte(i), byte(i >> 8), 1}) {
+			fp++
+		}
+	}
+	if fp > 10 {
+		t.Errorf("Lookup() of %d missing items: %d false positives, want <= 10", size, fp)
+	}
+	if got, want := qf.Count(), uint(size); got != want {
+		t.Errorf("Count() = %d, want %d", got, want)
+	}
+}
+
+func TestQuotientFilter_EncodeDecode(t *testing.T) {
+	qf := NewQuotientFilter(10)
+	for i := byte(0); i < 9; i++ {
+		qf.Insert([]byte{i})
+	}
+	got, err := DecodeQuotientFilter(qf.Encode())
+	if err != nil {
+		t.Fatalf("DecodeQuotientFilter() = %v", err)
+	}
+	if !reflect.DeepEqual(qf, got) {
+		t.Errorf("DecodeQuotientFilter() = %v, want %v", got, qf)
+	}
+
+	if _, err := DecodeQuotientFilter([]byte{1, 2}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("DecodeQuotientFilter() error = %v, want %v", err, ErrCorrupted)
+	}
+	// The encoding shares the header of Filter.Encode, with a format version of its own.
+	if _, err := Decode(qf.Encode()); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Decode() of a quotient filter error = %v, want %v", err, ErrIncompatible)
+	}
+	if _, err := DecodeQuotientFilter(NewFilter(100).Encode()); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("DecodeQuotientFilter() of a cuckoo filter error = %v, want %v", err, ErrIncompatible)
+	}
+	encoded := qf.Encode()
+	encoded[16]++
+	if _, err := DecodeQuotientFilter(encoded); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("DecodeQuotientFilter() with wrong count error = %v, want %v", err, ErrCorrupted)
+	}
+}
+
+func TestQuotientFilter_Resize(t *testing.T) {
+	qf := NewQuotientFilter(1000)
+	for i := 0; i < 1000; i++ {
+		qf.Insert([]byte{byte(i), byte(i >> 8)})
+	}
+	numSlots := len(qf.meta)
+	for r := 0; r < 3; r++ {
+		if err := qf.Resize(); err != nil {
+			t.Fatalf("Resize() = %v", err)
+		}
+	}
+	if len(qf.meta) != 8*numSlots || qf.remainderBits != qfRemainderBits-3 || qf.Count() != 1000 {
+		t.Fatalf("After Resize(): %d slots, %d-bit remainders, %d items", len(qf.meta), qf.remainderBits, qf.Count())
+	}
+	for i := 0; i < 1000; i++ {
+		if !qf.Lookup([]byte{byte(i), byte(i >> 8)}) {
+			t.Fatalf("After Resize(): Lookup(%d) = false", i)
+		}
+	}
+	// The resized filter keeps growing.
+	for i := 1000; i < 5000; i++ {
+		if !qf.Insert([]byte{byte(i), byte(i >> 8)}) {
+			t.Fatalf("After Resize(): Insert(%d) = false", i)
+		}
+	}
+	got, err := DecodeQuotientFilter(qf.Encode())
+	if err != nil || !reflect.DeepEqual(qf, got) {
+		t.Errorf("DecodeQuotientFilter() of resized filter = %v, %v", got, err)
+	}
+	if err := qf.Merge(NewQuotientFilter(uint(len(qf.meta)) * 3 / 4)); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Merge() with different remainder size error = %v, want %v", err, ErrIncompatible)
+	}
+
+	for qf.remainderBits > qfMinRemainderBits {
+		if err := qf.Resize(); err != nil {
+			t.Fatalf("Resize() = %v", err)
+		}
+	}
+	if err := qf.Resize(); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Resize() with %d-bit remainders = %v, want %v", qf.remainderBits, err, ErrTooLarge)
+	}
+}
+
+func TestQuotientFilter_Merge(t *testing.T) {
+	a, b := NewQuotientFilter(50), NewQuotientFilter(50)
+	for i := byte(0); i < 40; i++ {
+		a.Insert([]byte{i})
+		b.Insert([]byte{i + 100})
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge() = %v", err)
+	}
+	if got, want := a.Count(), uint(80); got != want {
+		t.Errorf("After Merge(): Count() = %d, want %d", got, want)
+	}
+	for i := byte(0); i < 40; i++ {
+		if !a.Lookup([]byte{i}) || !a.Lookup([]byte{i + 100}) {
+			t.Errorf("After Merge(): Lookup() = false for item %d of a or b", i)
+		}
+	}
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge() = %v", err)
+	}
+	if err := a.Merge(b); !errors.Is(err, ErrFull) {
+		t.Errorf("Merge() into full filter error = %v, want %v", err, ErrFull)
+	}
+	if err := a.Merge(NewQuotientFilter(1000)); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("Merge() of different size error = %v, want %v", err, ErrIncompatible)
+	}
+
+	// Merges in opposite directions do not deadlock.
+	x, y := NewQuotientFilter(1000), NewQuotientFilter(1000)
+	var wg sync.WaitGroup
+	for k := 0; k < 2; k++ {
+		wg.Add(1)
+		go func(into, from *QuotientFilter) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				into.Merge(from)
+			}
+		}([]*QuotientFilter{x, y}[k], []*QuotientFilter{y, x}[k])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,110 @@
+package cuckoo
+
+import (
+	"math"
+	"time"
+)
+
+// rateIntervals is the number of intervals the insert rate is tracked over.
+const rateIntervals = 8
+
+// fullLoadFactor is the load factor at which a filter is considered full by ProjectedFullIn.
+// Above it, inserts start to fail.
+const fullLoadFactor = 0.95
+
+// WithRateTracking tracks the growth of the filter over the last 8 intervals of the given
+// length, see ProjectedFullIn.
+func WithRateTracking(interval time.Duration) Option {
+	return func(cf *Filter) {
+		cf.rate = &rateTracker{interval: interval, now: time.Now}
+	}
+}
+
+// ProjectedFullIn returns the estimated time until the filter reaches a load factor of 0.95 at
+// the rate it grew over the tracked intervals, or 0 if it already has. It returns -1 if rate
+// tracking is disabled or the filter has not grown recently.
+func (cf *Filter) ProjectedFullIn() time.Duration {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	left := fullLoadFactor*float64(cf.Cap()) - float64(cf.count)
+	if left <= 0 {
+		return 0
+	}
+	rate := cf.rate.perSecond(cf.count)
+	if rate <= 0 {
+		return -1
+	}
+	seconds := left / rate
+	if seconds >= math.MaxInt64/float64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// rateSample is the count of a filter at some time.
+type rateSample struct {
+	time  time.Time
+	count uint
+}
+
+// rateTracker samples the count of a filter once per interval.
+// All methods are safe to call on a nil receiver, which disables tracking.
+type rateTracker struct {
+	interval time.Duration
+	now      func() time.Time
+	// samples is a ring buffer of the last samples, next is the index of the next one to
+	// write and n the number of valid samples.
+	samples [rateIntervals + 1]rateSample
+	next    int
+	n       int
+}
+
+func (r *rateTracker) observe(count uint) {
+	if r == nil {
+		return
+	}
+	now := r.now()
+	if r.n > 0 && now.Sub(r.last().time) < r.interval {
+		return
+	}
+	r.samples[r.next] = rateSample{time: now, count: count}
+	r.next = (r.next + 1) % len(r.samples)
+	if r.n < len(r.samples) {
+		r.n++
+	}
+}
+
+func (r *rateTracker) last() rateSample {
+	return r.samples[(r.next+len(r.samples)-1)%len(r.samples)]
+}
+
+// perSecond returns the growth per second from the oldest sample within the tracked intervals
+// to count, the current count.
+func (r *rateTracker) perSecond(count uint) float64 {
+	if r == nil {
+		return 0
+	}
+	now := r.now()
+	window := rateIntervals * r.interval
+	for k := r.n; k > 0; k-- {
+		s := r.samples[(r.next+len(r.samples)-k)%len(r.samples)]
+		elapsed := now.Sub(s.time)
+		if elapsed > window {
+			continue
+		}
+		if elapsed <= 0 {
+			return 0
+		}
+		return (float64(count) - float64(s.count)) / elapsed.Seconds()
+	}
+	return 0
+}
+
+func (r *rateTracker) reset() {
+	if r == nil {
+		return
+	}
+	r.n = 0
+	r.next = 0
+}
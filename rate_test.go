@@ -0,0 +1,47 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestProjectedFullIn(t *testing.T) {
+	cf := NewFilter(1000, WithRateTracking(time.Second))
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	cf.rate.now = clock.now
+	if got := cf.ProjectedFullIn(); got != -1 {
+		t.Errorf("ProjectedFullIn() of an empty filter = %v, want -1", got)
+	}
+
+	// Insert 10 items per second.
+	for i := 0; i < 100; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+		clock.t = clock.t.Add(100 * time.Millisecond)
+	}
+	left := fullLoadFactor*float64(cf.Cap()) - float64(cf.Count())
+	want := time.Duration(left / 10 * float64(time.Second))
+	if got := cf.ProjectedFullIn(); got < want*9/10 || got > want*11/10 {
+		t.Errorf("ProjectedFullIn() = %v, want about %v", got, want)
+	}
+
+	clock.t = clock.t.Add(time.Hour)
+	if got := cf.ProjectedFullIn(); got != -1 {
+		t.Errorf("ProjectedFullIn() without recent growth = %v, want -1", got)
+	}
+
+	cf.Reset()
+	for i := 0; cf.Insert([]byte(strconv.Itoa(i))); i++ {
+	}
+	if got := cf.ProjectedFullIn(); got != 0 {
+		t.Errorf("ProjectedFullIn() of a full filter = %v, want 0", got)
+	}
+}
+
+func TestProjectedFullInDisabled(t *testing.T) {
+	cf := NewFilter(1000)
+	cf.Insert([]byte("one"))
+	if got := cf.ProjectedFullIn(); got != -1 {
+		t.Errorf("ProjectedFullIn() without rate tracking = %v, want -1", got)
+	}
+}
@@ -0,0 +1,79 @@
+package cuckoo
+
+import (
+	"context"
+	"fmt"
+)
+
+// MissHandler is the backing store consulted by LookupOrLoad for keys missing in the filter.
+type MissHandler interface {
+	// Load returns true if key exists in the backing store.
+	Load(ctx context.Context, key []byte) (bool, error)
+}
+
+// MissHandlerFunc adapts a function to a MissHandler.
+type MissHandlerFunc func(ctx context.Context, key []byte) (bool, error)
+
+// Load calls f.
+func (f MissHandlerFunc) Load(ctx context.Context, key []byte) (bool, error) {
+	return f(ctx, key)
+}
+
+// FalsePositiveReporter is implemented by sets that adapt to false positives reported by
+// their users, e.g. by changing the fingerprint of the colliding item.
+type FalsePositiveReporter interface {
+	ReportFalsePositive(key []byte)
+}
+
+// ReadThrough puts a set in front of a backing store it does not fully mirror, e.g. one that
+// other processes write to. It is safe for concurrent use if the set and handler are.
+type ReadThrough struct {
+	set  ApproxSet
+	miss MissHandler
+}
+
+// NewReadThrough returns a ReadThrough consulting miss for keys missing in set.
+func NewReadThrough(set ApproxSet, miss MissHandler) *ReadThrough {
+	return &ReadThrough{set: set, miss: miss}
+}
+
+// LookupOrLoad returns true if key is probably in the backing store. If the set holds key, the
+// store is not consulted. Otherwise, the miss handler is asked and a key it confirms is
+// inserted into the set, so later lookups are answered by the set. Callers that find a key
+// missing from the store after LookupOrLoad returned true should call ReportFalsePositive.
+//
+// If the set is full, the confirmed key is not cached and an error wrapping ErrFull is
+// returned together with true.
+func (rt *ReadThrough) LookupOrLoad(ctx context.Context, key []byte) (bool, error) {
+	if rt.set.Lookup(key) {
+		return true, nil
+	}
+	found, err := rt.miss.Load(ctx, key)
+	if err != nil || !found {
+		return false, err
+	}
+	added := false
+	if c, ok := rt.set.(interface {
+		ContainsOrAdd([]byte) (bool, bool)
+	}); ok {
+		// Concurrent misses of the same key insert it only once on filters that support it.
+		wasPresent, inserted := c.ContainsOrAdd(key)
+		added = wasPresent || inserted
+	} else {
+		added = rt.set.Insert(key)
+	}
+	if !added {
+		return true, fmt.Errorf("%w: caching confirmed key", ErrFull)
+	}
+	return true, nil
+}
+
+// ReportFalsePositive reports that key is not in the backing store although the set holds
+// it. It is passed on if the set implements FalsePositiveReporter; returns false otherwise.
+func (rt *ReadThrough) ReportFalsePositive(key []byte) bool {
+	r, ok := rt.set.(FalsePositiveReporter)
+	if ok {
+		r.ReportFalsePositive(key)
+	}
+	return ok
+}
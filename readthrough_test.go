@@ -0,0 +1,63 @@
+package cuckoo
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// reportingSet is an ApproxSet recording reported false positives.
+type reportingSet struct {
+	ApproxSet
+	reported []string
+}
+
+func (s *reportingSet) ReportFalsePositive(key []byte) {
+	s.reported = append(s.reported, string(key))
+}
+
+func TestReadThrough(t *testing.T) {
+	store := map[string]bool{"stored": true}
+	loads := 0
+	errStore := errors.New("store unavailable")
+	miss := MissHandlerFunc(func(_ context.Context, key []byte) (bool, error) {
+		loads++
+		if string(key) == "broken" {
+			return false, errStore
+		}
+		return store[string(key)], nil
+	})
+	cf := NewFilter(1000)
+	rt := NewReadThrough(cf, miss)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		key       string
+		want      bool
+		wantErr   error
+		wantLoads int
+	}{
+		{"stored", true, nil, 1},
+		// Answered by the filter.
+		{"stored", true, nil, 1},
+		{"missing", false, nil, 2},
+		{"missing", false, nil, 3},
+		{"broken", false, errStore, 4},
+	} {
+		got, err := rt.LookupOrLoad(ctx, []byte(tc.key))
+		if got != tc.want || !errors.Is(err, tc.wantErr) || loads != tc.wantLoads {
+			t.Errorf("LookupOrLoad(%q) = %v, %v after %d loads, want %v, %v after %d", tc.key, got, err, loads, tc.want, tc.wantErr, tc.wantLoads)
+		}
+	}
+	if cf.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", cf.Count())
+	}
+
+	if rt.ReportFalsePositive([]byte("stored")) {
+		t.Errorf("ReportFalsePositive() = true for a set not implementing FalsePositiveReporter")
+	}
+	set := &reportingSet{ApproxSet: cf}
+	if !NewReadThrough(set, miss).ReportFalsePositive([]byte("stored")) || len(set.reported) != 1 {
+		t.Errorf("ReportFalsePositive() was not passed on, reported %v", set.reported)
+	}
+}
@@ -0,0 +1,69 @@
+package cuckoo
+
+import "sort"
+
+// rebalanceMaxVisits is the number of buckets RebalanceTop searches for a free slot per
+// neighborhood.
+const rebalanceMaxVisits = 1 << 10
+
+// RebalanceTop frees a slot in each of the k most crowded bucket neighborhoods by moving
+// fingerprints along the shortest path to a free slot, found by breadth-first search. The
+// pressure of a full bucket is the number of its fingerprints whose alternate bucket is full
+// too, plus the stashed items waiting for it, see WithOverflowStash; inserts into such
+// buckets need long kickout chains and fail first. Freed slots take in stashed items.
+//
+// This recovers insert headroom where it is needed without resizing the filter. Returns the
+// number of neighborhoods a slot was freed in. RebalanceTop holds the write lock while
+// scanning the whole filter.
+func (cf *Filter) RebalanceTop(k int) int {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	type hotBucket struct {
+		i        uint
+		pressure int
+	}
+	var hot []hotBucket
+	waiting := make(map[uint]int, cf.stash.len())
+	for _, e := range cf.stash.all() {
+		waiting[e.i]++
+		waiting[cf.altIndex(e.fp, e.i)]++
+	}
+	for i := range cf.buckets {
+		i := uint(i)
+		if cf.stale(i) || cf.buckets[i].free() > 0 {
+			continue
+		}
+		pressure := waiting[i]
+		for _, fp := range cf.buckets[i].fingerprints() {
+			alt := cf.altIndex(fp, i)
+			if !cf.stale(alt) && cf.buckets[alt].free() == 0 {
+				pressure++
+			}
+		}
+		if pressure > 0 {
+			hot = append(hot, hotBucket{i, pressure})
+		}
+	}
+	sort.Slice(hot, func(a, b int) bool {
+		if hot[a].pressure != hot[b].pressure {
+			return hot[a].pressure > hot[b].pressure
+		}
+		return hot[a].i < hot[b].i
+	})
+	if k < len(hot) {
+		hot = hot[:k]
+	}
+
+	var paths pathFinder
+	freed := 0
+	for _, h := range hot {
+		// An earlier path may already have moved a fingerprint out of the bucket.
+		if _, ok := paths.makeRoom(&cf.core, rebalanceMaxVisits, h.i); ok {
+			cf.unstash(h.i)
+			freed++
+		}
+	}
+	cf.checkInvariants()
+	return freed
+}
@@ -0,0 +1,54 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRebalanceTop(t *testing.T) {
+	cf := NewFilter(1024)
+	n := 0
+	for cf.Insert([]byte(strconv.Itoa(n))) {
+		n++
+	}
+	count := cf.Count()
+	if got := cf.RebalanceTop(0); got != 0 {
+		t.Errorf("RebalanceTop(0) = %d", got)
+	}
+	if got := cf.RebalanceTop(16); got != 16 {
+		t.Errorf("RebalanceTop(16) = %d, want 16", got)
+	}
+	if cf.Count() != count {
+		t.Errorf("Count() = %d after rebalancing, want %d", cf.Count(), count)
+	}
+	if err := cf.Validate(); err != nil {
+		t.Error(err)
+	}
+	missing := 0
+	for i := 0; i <= n; i++ {
+		if !cf.Lookup([]byte(strconv.Itoa(i))) {
+			missing++
+		}
+	}
+	// The failed insert dropped one fingerprint.
+	if missing > 1 {
+		t.Errorf("%d items are missing after rebalancing", missing)
+	}
+}
+
+func TestRebalanceTopStash(t *testing.T) {
+	cf, _ := fillWithStash(t)
+	// Free slots in every fourth bucket. Deletes move stashed items into the freed slots of
+	// their own candidate buckets only.
+	for i := 0; i < len(cf.buckets); i += 4 {
+		cf.delete(cf.buckets[i].get(0), uint(i))
+	}
+	before := cf.stash.len()
+	cf.RebalanceTop(len(cf.buckets))
+	if cf.stash.len() >= before {
+		t.Errorf("stash holds %d items after rebalancing, want fewer than %d", cf.stash.len(), before)
+	}
+	if err := cf.Validate(); err != nil {
+		t.Error(err)
+	}
+}
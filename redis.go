@@ -0,0 +1,207 @@
+package cuckoo
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+// redisChunkSize is the default number of bytes per SETRANGE command of ExportRedis.
+const redisChunkSize = 1 << 20
+
+// ExportRedis writes Redis commands storing the filter in the string key to w, e.g. a
+// connection to Redis or a file for redis-cli --pipe. The value of key becomes Encode, so it
+// can be read by Lua scripts on the Redis side, see RedisLookupScript, or fetched with GET
+// and passed to Decode.
+//
+// The encoding is written to key + ":tmp" in SETRANGE commands of chunkSize bytes, or 1MiB
+// if chunkSize is not positive, which is then renamed to key, so readers see either the old
+// or the new filter. All commands are written without waiting for replies; ExportRedis
+// returns their number, so a pipelining caller knows how many replies to read.
+func (cf *Filter) ExportRedis(w io.Writer, key string, chunkSize int) (int, error) {
+	if chunkSize <= 0 {
+		chunkSize = redisChunkSize
+	}
+	encoded := cf.Encode()
+	tmp := key + ":tmp"
+	bw := bufio.NewWriter(w)
+	writeRedisCommand(bw, "DEL", tmp)
+	n := 1
+	for offset := 0; offset < len(encoded); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		writeRedisCommand(bw, "SETRANGE", tmp, strconv.Itoa(offset), string(encoded[offset:end]))
+		n++
+	}
+	writeRedisCommand(bw, "RENAME", tmp, key)
+	return n + 1, bw.Flush()
+}
+
+// writeRedisCommand writes a command in the Redis serialization protocol.
+func writeRedisCommand(w *bufio.Writer, args ...string) {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		w.WriteString(arg)
+		w.WriteString("\r\n")
+	}
+}
+
+// RedisLookupScript returns a Lua script for EVAL that looks up items in a filter stored in
+// Redis by ExportRedis, hashing them like Lookup, so clients in other languages can query the
+// same filter:
+//
+//	EVAL <script> 1 <key> <item>...
+//
+// It returns an array holding 1 for every item that is in the filter and 0 for every other
+// one. The script reads the hash seed and the number of buckets from the stored encoding: a
+// 24-byte header (16 bytes for format version 1) with the hash seed in bytes 8 to 15, followed
+// by one 8-byte little-endian bucket per 16-bit fingerprint quadruple. It fails for unknown
+// format and hashing versions and for alternate index schemes other than AltIndexXOR.
+func RedisLookupScript() string {
+	return redisLookupScript
+}
+
+// redisLookupScript implements RedisLookupScript. Redis runs Lua 5.1, whose numbers are
+// doubles, so 64-bit integers are held as four 16-bit limbs, least significant first.
+const redisLookupScript = `
+local M = 65536
+local function add(x, y)
+  local r, c = {}, 0
+  for i = 1, 4 do
+    local s = x[i] + y[i] + c
+    r[i] = s % M
+    c = math.floor(s / M)
+  end
+  return r
+end
+local function mul(x, y)
+  local r = {0, 0, 0, 0}
+  for i = 1, 4 do
+    local c = 0
+    for j = 1, 5 - i do
+      local s = r[i + j - 1] + x[i] * y[j] + c
+      r[i + j - 1] = s % M
+      c = math.floor(s / M)
+    end
+  end
+  return r
+end
+local function xor(x, y)
+  return {bit.bxor(x[1], y[1]), bit.bxor(x[2], y[2]), bit.bxor(x[3], y[3]), bit.bxor(x[4], y[4])}
+end
+local function rotr(x, n)
+  local q, s, r = math.floor(n / 16), n % 16, {}
+  for i = 1, 4 do
+    local lo, hi = x[(i - 1 + q) % 4 + 1], x[(i + q) % 4 + 1]
+    r[i] = math.floor(lo / 2 ^ s) + (hi % 2 ^ s) * 2 ^ (16 - s)
+  end
+  return r
+end
+local function load(b, p, n)
+  local l = {0, 0, 0, 0}
+  for k = 1, n / 2 do
+    l[k] = string.byte(b, p + 2 * k - 2) + string.byte(b, p + 2 * k - 1) * 256
+  end
+  return l
+end
+local K0, K1, K2, K3 = {0x18F5, 0xD6D0, 0, 0}, {0x033B, 0xA2AA, 0, 0}, {0x2FC1, 0x6299, 0, 0}, {0x5B29, 0x30BC, 0, 0}
+local function metro(b, seed)
+  local h, p, n = mul(add(seed, K2), K0), 1, #b
+  if n - p + 1 >= 32 then
+    local v0, v1, v2, v3 = h, h, h, h
+    while n - p + 1 >= 32 do
+      v0 = add(rotr(add(v0, mul(load(b, p, 8), K0)), 29), v2)
+      v1 = add(rotr(add(v1, mul(load(b, p + 8, 8), K1)), 29), v3)
+      v2 = add(rotr(add(v2, mul(load(b, p + 16, 8), K2)), 29), v0)
+      v3 = add(rotr(add(v3, mul(load(b, p + 24, 8), K3)), 29), v1)
+      p = p + 32
+    end
+    v2 = xor(v2, mul(rotr(add(mul(add(v0, v3), K0), v1), 37), K1))
+    v3 = xor(v3, mul(rotr(add(mul(add(v1, v2), K1), v0), 37), K0))
+    v0 = xor(v0, mul(rotr(add(mul(add(v0, v2), K0), v3), 37), K1))
+    v1 = xor(v1, mul(rotr(add(mul(add(v1, v3), K1), v2), 37), K0))
+    h = add(h, xor(v0, v1))
+  end
+  if n - p + 1 >= 16 then
+    local v0 = mul(rotr(add(h, mul(load(b, p, 8), K2)), 29), K3)
+    local v1 = mul(rotr(add(h, mul(load(b, p + 8, 8), K2)), 29), K3)
+    v0 = xor(v0, add(rotr(mul(v0, K0), 21), v1))
+    v1 = xor(v1, add(rotr(mul(v1, K3), 21), v0))
+    h = add(h, v1)
+    p = p + 16
+  end
+  if n - p + 1 >= 8 then
+    h = add(h, mul(load(b, p, 8), K3))
+    p = p + 8
+    h = xor(h, mul(rotr(h, 55), K1))
+  end
+  if n - p + 1 >= 4 then
+    h = add(h, mul(load(b, p, 4), K3))
+    h = xor(h, mul(rotr(h, 26), K1))
+    p = p + 4
+  end
+  if n - p + 1 >= 2 then
+    h = add(h, mul(load(b, p, 2), K3))
+    p = p + 2
+    h = xor(h, mul(rotr(h, 48), K1))
+  end
+  if n - p + 1 >= 1 then
+    h = add(h, mul({string.byte(b, p), 0, 0, 0}, K3))
+    h = xor(h, mul(rotr(h, 37), K1))
+  end
+  h = xor(h, rotr(h, 28))
+  h = mul(h, K0)
+  return xor(h, rotr(h, 29))
+end
+
+local key = KEYS[1]
+local header = redis.call('GETRANGE', key, 0, 23)
+if #header < 16 or string.sub(header, 1, 4) ~= 'CKOO' then
+  return redis.error_reply('not a cuckoo filter: ' .. key)
+end
+local headerSize = 0
+if string.byte(header, 5) == 1 then
+  headerSize = 16
+elseif string.byte(header, 5) == 2 and #header == 24 then
+  headerSize = 24
+else
+  return redis.error_reply('unsupported format version ' .. string.byte(header, 5))
+end
+if string.byte(header, 6) ~= 1 then
+  return redis.error_reply('unsupported hashing version ' .. string.byte(header, 6))
+end
+if string.byte(header, 7) ~= 0 then
+  return redis.error_reply('unsupported alternate index scheme ' .. string.byte(header, 7))
+end
+local seed = load(header, 9, 8)
+local numBuckets = (redis.call('STRLEN', key) - headerSize) / 8
+local function index(x)
+  return (x[1] + x[2] * M + x[3] * M * M) % numBuckets
+end
+local function contains(i, fp)
+  local b = redis.call('GETRANGE', key, headerSize + 8 * i, headerSize + 8 * i + 7)
+  for j = 1, 8, 2 do
+    if string.byte(b, j) + string.byte(b, j + 1) * 256 == fp then
+      return true
+    end
+  end
+  return false
+end
+local result = {}
+for k, item in ipairs(ARGV) do
+  local h = metro(item, seed)
+  local fp = h[4] % 65534 + 1
+  local i1 = index(h)
+  local alt = metro(string.char(fp % 256, math.floor(fp / 256)), {1337, 0, 0, 0})
+  local i2 = index(xor({i1 % M, math.floor(i1 / M) % M, math.floor(i1 / M / M), 0}, alt))
+  if contains(i1, fp) or contains(i2, fp) then
+    result[k] = 1
+  else
+    result[k] = 0
+  end
+end
+return result
+`
@@ -0,0 +1,245 @@
+package cuckoo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	metro "github.com/dgryski/go-metro"
+)
+
+// readRedisCommand reads a command in the Redis serialization protocol.
+func readRedisCommand(r *bufio.Reader) ([]string, error) {
+	readLine := func(prefix byte) (int, error) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		if line[0] != prefix {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return strconv.Atoi(line[1 : len(line)-2])
+	}
+	n, err := readLine('*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for k := range args {
+		size, err := readLine('$')
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[k] = string(arg[:size])
+	}
+	return args, nil
+}
+
+// applyRedisCommands runs the DEL, SETRANGE and RENAME commands read from r on db and returns
+// their number.
+func applyRedisCommands(t *testing.T, r io.Reader, db map[string][]byte) int {
+	br := bufio.NewReader(r)
+	n := 0
+	for {
+		args, err := readRedisCommand(br)
+		if err == io.EOF {
+			return n
+		}
+		if err != nil {
+			t.Fatalf("reading command %d: %v", n, err)
+		}
+		n++
+		switch args[0] {
+		case "DEL":
+			delete(db, args[1])
+		case "SETRANGE":
+			offset, _ := strconv.Atoi(args[2])
+			value := db[args[1]]
+			for len(value) < offset+len(args[3]) {
+				value = append(value, 0)
+			}
+			copy(value[offset:], args[3])
+			db[args[1]] = value
+		case "RENAME":
+			db[args[2]] = db[args[1]]
+			delete(db, args[1])
+		default:
+			t.Fatalf("unexpected command %q", args)
+		}
+	}
+}
+
+func TestExportRedis(t *testing.T) {
+	cf := NewFilter(10000)
+	for i := 0; i < 1000; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	var out bytes.Buffer
+	n, err := cf.ExportRedis(&out, "filter", 1000)
+	if err != nil {
+		t.Fatalf("ExportRedis() = %v", err)
+	}
+	db := map[string][]byte{"filter": []byte("old"), "filter:tmp": []byte("stale")}
+	if got := applyRedisCommands(t, &out, db); got != n {
+		t.Errorf("ExportRedis() = %d, wrote %d commands", n, got)
+	}
+	if n != 2+(len(cf.Encode())+999)/1000 {
+		t.Errorf("ExportRedis() wrote %d commands, want chunks of 1000 bytes", n)
+	}
+	if !bytes.Equal(db["filter"], cf.Encode()) {
+		t.Errorf("exported value differs from Encode()")
+	}
+	if _, ok := db["filter:tmp"]; ok || len(db) != 1 {
+		t.Errorf("ExportRedis() left keys %v", db)
+	}
+}
+
+func TestRedisLookupScriptLayout(t *testing.T) {
+	// The script reads the stored encoding directly; check the layout it relies on.
+	cf := NewFilter(100, WithHashSeed(0x0102030405060708))
+	cf.Insert([]byte("item"))
+	encoded := cf.Encode()
+	if string(encoded[:4]) != "CKOO" || encoded[4] != 2 || encoded[5] != 1 {
+		t.Errorf("header = %x, want magic CKOO, format version 2 and hashing version 1", encoded[:24])
+	}
+	if seed := binary.LittleEndian.Uint64(encoded[8:16]); seed != cf.HashSeed() {
+		t.Errorf("seed in header = %#x, want %#x", seed, cf.HashSeed())
+	}
+	trace := cf.Explain([]byte("item"))
+	b := encoded[24+8*trace.Bucket:]
+	if fp := binary.LittleEndian.Uint16(b[2*trace.Slot:]); fp != trace.Fingerprint {
+		t.Errorf("fingerprint at bucket %d slot %d = %d, want %d", trace.Bucket, trace.Slot, fp, trace.Fingerprint)
+	}
+	if len(encoded) != 24+8*len(cf.buckets) {
+		t.Errorf("len(Encode()) = %d, want 24 + 8 bytes per bucket", len(encoded))
+	}
+	script := RedisLookupScript()
+	for _, want := range []string{"KEYS[1]", "ARGV", "{1337, 0, 0, 0}", "65534"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script does not contain %q", want)
+		}
+	}
+}
+
+// evalRedisScript runs script with runLua like EVAL with one key would, against db, and
+// returns the values it returns.
+func evalRedisScript(script string, db map[string][]byte, key string, args ...[]byte) ([]interface{}, error) {
+	argv := newLuaTable()
+	for k, arg := range args {
+		argv.fields[float64(k+1)] = string(arg)
+	}
+	call := func(args []interface{}) []interface{} {
+		value := string(db[args[1].(string)])
+		switch args[0] {
+		case "STRLEN":
+			return []interface{}{float64(len(value))}
+		case "GETRANGE":
+			start, end := luaInt(args[2]), luaInt(args[3])
+			if end >= len(value) {
+				end = len(value) - 1
+			}
+			if start > end {
+				return []interface{}{""}
+			}
+			return []interface{}{value[start : end+1]}
+		}
+		luaFail("unsupported command %v", args[0])
+		return nil
+	}
+	errorReply := func(args []interface{}) []interface{} {
+		t := newLuaTable()
+		t.fields["err"] = args[0]
+		return []interface{}{t}
+	}
+	redis := newLuaTable()
+	redis.fields["call"] = luaFunction(call)
+	redis.fields["error_reply"] = luaFunction(errorReply)
+	return runLua(script, map[string]interface{}{
+		"KEYS":  newLuaTable(key),
+		"ARGV":  argv,
+		"redis": redis,
+	})
+}
+
+func TestRedisLookupScriptHash(t *testing.T) {
+	// Run the script's own metro port, up to its first use of KEYS, on keys of every tail
+	// length and of more than one 32-byte block.
+	script := RedisLookupScript()
+	prelude := script[:strings.Index(script, "local key = KEYS[1]")]
+	rng := rand.New(rand.NewSource(1))
+	for _, seed := range []uint64{0, 1337, 0xfedcba9876543210, ^uint64(0)} {
+		var seedBytes [8]byte
+		binary.LittleEndian.PutUint64(seedBytes[:], seed)
+		for n := 0; n <= 72; n++ {
+			data := make([]byte, n)
+			rng.Read(data)
+			ret, err := evalRedisScript(prelude+"return metro(ARGV[1], load(ARGV[2], 1, 8))", nil, "", data, seedBytes[:])
+			if err != nil {
+				t.Fatalf("metro(%x, %#x): %v", data, seed, err)
+			}
+			limbs := ret[0].(*luaTable)
+			var got uint64
+			for i := 4; i >= 1; i-- {
+				got = got<<16 | uint64(limbs.fields[float64(i)].(float64))
+			}
+			if want := metro.Hash64(data, seed); got != want {
+				t.Errorf("metro(%x, %#x) = %#x, want %#x", data, seed, got, want)
+			}
+		}
+	}
+}
+
+func TestRedisLookupScript(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var keys [][]byte
+	for n := 0; n <= 40; n++ {
+		for i := 0; i < 4; i++ {
+			key := make([]byte, n)
+			rng.Read(key)
+			keys = append(keys, key)
+		}
+	}
+	for _, seed := range []uint64{defaultHashSeed, 0xfedcba9876543210} {
+		// A small filter pushes some fingerprints to their alternate bucket.
+		cf := NewFilter(100, WithHashSeed(seed))
+		for i := 0; i < len(keys); i += 2 {
+			cf.Insert(keys[i])
+		}
+		db := map[string][]byte{"filter": cf.Encode()}
+		ret, err := evalRedisScript(RedisLookupScript(), db, "filter", keys...)
+		if err != nil {
+			t.Fatalf("EVAL: %v", err)
+		}
+		result := ret[0].(*luaTable)
+		for i, key := range keys {
+			want := 0.0
+			if cf.Lookup(key) {
+				want = 1
+			}
+			if got := result.fields[float64(i+1)]; got != want {
+				t.Errorf("seed %#x: script returned %v for %x, want %v", seed, got, key, want)
+			}
+		}
+	}
+
+	cf := NewFilter(100, WithAltIndexScheme(AltIndexOffset))
+	db := map[string][]byte{"filter": cf.Encode(), "other": []byte("value")}
+	for key, want := range map[string]string{"filter": "unsupported alternate index scheme 1", "other": "not a cuckoo filter: other"} {
+		ret, err := evalRedisScript(RedisLookupScript(), db, key, []byte("item"))
+		if err != nil {
+			t.Fatalf("EVAL: %v", err)
+		}
+		if reply, ok := ret[0].(*luaTable); !ok || reply.fields["err"] != want {
+			t.Errorf("EVAL on %s = %v, want error %q", key, ret[0], want)
+		}
+	}
+}
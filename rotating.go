@@ -0,0 +1,81 @@
+package cuckoo
+
+import "sync"
+
+// RotatingFilter is a pair of filters for windowed deduplication. Items are inserted into
+// the current filter, while lookups check both the current and the previous one. Rotate
+// drops the previous filter and starts a fresh current one, so items are forgotten
+// gradually: an item stays queryable for at least one and at most two rotation periods.
+type RotatingFilter struct {
+	lock     sync.RWMutex
+	current  *Filter
+	previous *Filter
+}
+
+// NewRotatingFilter returns a RotatingFilter made of two filters, each created using
+// NewFilter with the given arguments.
+func NewRotatingFilter(numElements uint, opts ...Option) *RotatingFilter {
+	return &RotatingFilter{
+		current:  NewFilter(numElements, opts...),
+		previous: NewFilter(numElements, opts...),
+	}
+}
+
+var _ ApproxSet = (*RotatingFilter)(nil)
+
+// Rotate atomically makes the current filter the previous one and swaps in an empty
+// current filter. Items only present in the previous filter are dropped.
+func (r *RotatingFilter) Rotate() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// Reuse the memory of the dropped filter.
+	r.previous.Reset()
+	r.current, r.previous = r.previous, r.current
+}
+
+// Insert data into the current filter. Returns false if insertion failed.
+func (r *RotatingFilter) Insert(data []byte) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.current.Insert(data)
+}
+
+// Lookup returns true if data is in the current or the previous filter.
+func (r *RotatingFilter) Lookup(data []byte) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.current.Lookup(data) || r.previous.Lookup(data)
+}
+
+// ContainsOrAdd inserts data into the current filter unless it is in the current or the
+// previous filter already. See Filter.ContainsOrAdd.
+func (r *RotatingFilter) ContainsOrAdd(data []byte) (wasPresent bool, added bool) {
+	// Hold the write lock so that no concurrent call inserts the same data in between.
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.previous.Lookup(data) {
+		return true, false
+	}
+	return r.current.ContainsOrAdd(data)
+}
+
+// Delete data from the current filter, or from the previous filter if it is not in the
+// current one. Returns true if the data was found and deleted.
+func (r *RotatingFilter) Delete(data []byte) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.current.Delete(data) || r.previous.Delete(data)
+}
+
+// Count returns the number of items in both filters.
+func (r *RotatingFilter) Count() uint {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.current.Count() + r.previous.Count()
+}
@@ -0,0 +1,40 @@
+package cuckoo
+
+import (
+	"testing"
+)
+
+func TestRotatingFilter_Rotate(t *testing.T) {
+	rf := NewRotatingFilter(100)
+	rf.Insert([]byte("old"))
+	rf.Rotate()
+	rf.Insert([]byte("new"))
+
+	if !rf.Lookup([]byte("old")) || !rf.Lookup([]byte("new")) {
+		t.Errorf("After one Rotate(): Lookup() = false for inserted items, want true")
+	}
+	if got, want := rf.Count(), uint(2); got != want {
+		t.Errorf("After one Rotate(): Count() = %d, want %d", got, want)
+	}
+
+	rf.Rotate()
+	if rf.Lookup([]byte("old")) {
+		t.Errorf("After two Rotate(): Lookup(%q) = true, want false", "old")
+	}
+	if !rf.Lookup([]byte("new")) {
+		t.Errorf("After two Rotate(): Lookup(%q) = false, want true", "new")
+	}
+}
+
+func TestRotatingFilter_ContainsOrAdd(t *testing.T) {
+	rf := NewRotatingFilter(100)
+	rf.ContainsOrAdd([]byte("one"))
+	rf.Rotate()
+
+	if wasPresent, added := rf.ContainsOrAdd([]byte("one")); !wasPresent || added {
+		t.Errorf("ContainsOrAdd(%q) of item in previous filter = %v, %v, want true, false", "one", wasPresent, added)
+	}
+	if got, want := rf.Count(), uint(1); got != want {
+		t.Errorf("Count() = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package cuckoo
+
+// SaturationPolicy selects what Insert does when the filter is full, i.e. when the kickout
+// chain of an insert ends without finding a free slot.
+type SaturationPolicy int
+
+const (
+	// RejectWhenFull makes Insert return false. It is the default.
+	RejectWhenFull SaturationPolicy = iota
+	// EvictWhenFull stores the new item and evicts the fingerprint at the end of the kickout
+	// chain, a random older item, so inserts always succeed. Evicted items become false
+	// negatives, which suits streams that only need to remember recent items.
+	EvictWhenFull
+	// EvictRandomWhenFull stores the new item and evicts a random item of its candidate
+	// buckets if both are full, without running a kickout chain. Inserts take constant time,
+	// as in streaming deduplication, at the cost of evicting items before the filter is as
+	// full as with EvictWhenFull.
+	EvictRandomWhenFull
+)
+
+// WithSaturationPolicy sets the behavior of inserts into a full filter to p. It applies to
+// Insert, ContainsOrAdd and InsertStatus. Operations that must not lose items, such as
+// InsertBatchAtomic, Merge and RollbackLast, keep failing instead.
+//
+// A Filter cannot grow in place, as it does not store the index bits a larger table needs;
+// use a Cascade for a set that grows without bound.
+func WithSaturationPolicy(p SaturationPolicy) Option {
+	return func(cf *Filter) {
+		cf.saturation = p
+	}
+}
+
+// Evictions returns the number of items evicted by the saturation policy since the filter was
+// created. Unlike Count, it is not reset by Reset, so it can be exported as a counter metric.
+func (cf *Filter) Evictions() uint64 {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	return cf.evictions
+}
+
+// Evictions returns the number of items evicted by the saturation policy, see Filter.Evictions.
+func (cf *UnsyncFilter) Evictions() uint64 {
+	return cf.evictions
+}
@@ -0,0 +1,58 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestWithSaturationPolicy(t *testing.T) {
+	reject := newFilter(make([]bucket, 4))
+	evict := newFilter(make([]bucket, 4))
+	WithSaturationPolicy(EvictWhenFull)(evict)
+
+	failed := 0
+	for i := 0; i < 100; i++ {
+		key := []byte(strconv.Itoa(i))
+		if !reject.Insert(key) {
+			failed++
+		}
+		if !evict.Insert(key) {
+			t.Fatalf("Insert(%d) with EvictWhenFull = false, want true", i)
+		}
+		// The newest item is never the one evicted.
+		if !evict.Lookup(key) {
+			t.Fatalf("Lookup(%d) right after Insert with EvictWhenFull = false", i)
+		}
+	}
+	if failed == 0 {
+		t.Errorf("Insert with RejectWhenFull never failed")
+	}
+	if evict.Evictions() != uint64(100-evict.Cap()) {
+		t.Errorf("Evictions() = %d, want %d", evict.Evictions(), 100-evict.Cap())
+	}
+	if evict.Count() != uint(evict.Cap()) {
+		t.Errorf("Count() with EvictWhenFull = %d, want %d", evict.Count(), evict.Cap())
+	}
+	// Atomic batches still fail rather than evict.
+	if err := evict.InsertBatchAtomic([][]byte{[]byte("x")}); err == nil {
+		t.Errorf("InsertBatchAtomic() into full filter with EvictWhenFull succeeded")
+	}
+}
+
+func TestEvictRandomWhenFull(t *testing.T) {
+	cf := NewFilter(1000, WithSaturationPolicy(EvictRandomWhenFull))
+	for i := 0; i < 10000; i++ {
+		key := []byte(strconv.Itoa(i))
+		if !cf.Insert(key) || !cf.Lookup(key) {
+			t.Fatalf("Insert(%d), Lookup(%d) with EvictRandomWhenFull = false", i, i)
+		}
+	}
+	if cf.Evictions() == 0 || uint64(cf.Count())+cf.Evictions() != 10000 {
+		t.Errorf("Count() + Evictions() = %d + %d, want 10000", cf.Count(), cf.Evictions())
+	}
+	evictions := cf.Evictions()
+	cf.Reset()
+	if cf.Evictions() != evictions {
+		t.Errorf("Evictions() after Reset() = %d, want %d", cf.Evictions(), evictions)
+	}
+}
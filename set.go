@@ -0,0 +1,79 @@
+package cuckoo
+
+import "sync/atomic"
+
+// ApproxSet is an approximate set-membership data structure. It is implemented by Filter
+// and can wrap other structures (see NewBloomAdapter), so applications can swap the
+// data structure behind a single type.
+type ApproxSet interface {
+	// Insert adds data to the set. Returns false if the insertion failed.
+	Insert(data []byte) bool
+	// Lookup returns true if data is probably in the set, false if it is definitely not.
+	Lookup(data []byte) bool
+	// Delete removes data from the set. Returns false if data was not found or
+	// deletion is not supported.
+	Delete(data []byte) bool
+	// Count returns the number of items in the set.
+	Count() uint
+}
+
+var _ ApproxSet = (*Filter)(nil)
+
+// bloomAdapter implements ApproxSet on top of a Bloom filter.
+type bloomAdapter struct {
+	add   func(data []byte)
+	test  func(data []byte) bool
+	count uint64
+}
+
+// NewBloomAdapter returns an ApproxSet backed by a Bloom filter, given functions for adding
+// and testing items. For example, with github.com/bits-and-blooms/bloom:
+//
+//	set := cuckoo.NewBloomAdapter(func(data []byte) { bf.Add(data) }, bf.Test)
+//
+// Bloom filters do not support deletion, so Delete always returns false.
+// Count returns the number of calls to Insert. The returned set is safe for concurrent use
+// if add and test are.
+func NewBloomAdapter(add func(data []byte), test func(data []byte) bool) ApproxSet {
+	return &bloomAdapter{add: add, test: test}
+}
+
+func (b *bloomAdapter) Insert(data []byte) bool {
+	b.add(data)
+	atomic.AddUint64(&b.count, 1)
+	return true
+}
+
+func (b *bloomAdapter) Lookup(data []byte) bool {
+	return b.test(data)
+}
+
+func (b *bloomAdapter) Delete(data []byte) bool {
+	return false
+}
+
+func (b *bloomAdapter) Count() uint {
+	return uint(atomic.LoadUint64(&b.count))
+}
+
+// BloomCompat exposes a Filter through the method names commonly used by Bloom filter
+// libraries, so code written against them can switch to a cuckoo filter with minimal changes.
+type BloomCompat struct {
+	*Filter
+}
+
+// Add inserts data into the filter.
+func (b BloomCompat) Add(data []byte) {
+	b.Insert(data)
+}
+
+// Test returns true if data is in the filter.
+func (b BloomCompat) Test(data []byte) bool {
+	return b.Lookup(data)
+}
+
+// TestAndAdd returns true if data was already in the filter, and inserts it otherwise.
+func (b BloomCompat) TestAndAdd(data []byte) bool {
+	wasPresent, _ := b.ContainsOrAdd(data)
+	return wasPresent
+}
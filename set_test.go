@@ -0,0 +1,43 @@
+package cuckoo
+
+import (
+	"testing"
+)
+
+func TestBloomAdapter(t *testing.T) {
+	items := make(map[string]bool)
+	var set ApproxSet = NewBloomAdapter(
+		func(data []byte) { items[string(data)] = true },
+		func(data []byte) bool { return items[string(data)] },
+	)
+
+	set.Insert([]byte("one"))
+	set.Insert([]byte("one"))
+
+	if !set.Lookup([]byte("one")) {
+		t.Errorf("Lookup(%q) = false, want true", "one")
+	}
+	if set.Lookup([]byte("two")) {
+		t.Errorf("Lookup(%q) = true, want false", "two")
+	}
+	if set.Delete([]byte("one")) {
+		t.Errorf("Delete(%q) = true, want false", "one")
+	}
+	if got, want := set.Count(), uint(2); got != want {
+		t.Errorf("Count() = %d, want %d", got, want)
+	}
+}
+
+func TestBloomCompat(t *testing.T) {
+	bf := BloomCompat{NewFilter(100)}
+	if bf.TestAndAdd([]byte("one")) {
+		t.Errorf("TestAndAdd(%q) = true on empty filter, want false", "one")
+	}
+	if !bf.Test([]byte("one")) {
+		t.Errorf("Test(%q) = false after TestAndAdd, want true", "one")
+	}
+	bf.Add([]byte("two"))
+	if !bf.TestAndAdd([]byte("two")) {
+		t.Errorf("TestAndAdd(%q) = false after Add, want true", "two")
+	}
+}
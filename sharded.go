@@ -0,0 +1,129 @@
+package cuckoo
+
+// pageSize is the granularity at which the operating system places memory on NUMA nodes.
+const pageSize = 4096
+
+// ShardedFilter splits items across independent filters, each with its own lock, to reduce
+// contention. On multi-socket machines, shards can be placed on NUMA nodes with
+// WithNUMANodes, and callers that know which node an item belongs to can keep its traffic on
+// that node using the Hint methods. It is safe for concurrent use.
+type ShardedFilter struct {
+	shards []*Filter
+	// nodeShards holds the indexes of the shards placed on every node.
+	nodeShards [][]int
+}
+
+var _ ApproxSet = (*ShardedFilter)(nil)
+
+// ShardedOption configures a ShardedFilter.
+type ShardedOption func(*shardedConfig)
+
+type shardedConfig struct {
+	numNodes int
+	place    func(node int, alloc func())
+}
+
+// WithNUMANodes spreads the shards round-robin across numNodes NUMA nodes. The memory of every
+// shard is allocated and first touched in alloc, which place must call on the given node,
+// e.g. from a goroutine locked to an OS thread bound to that node. With the default first-touch
+// policy of the operating system, the pages of the shard are then local to the node.
+// If place is nil, alloc is called directly.
+func WithNUMANodes(numNodes int, place func(node int, alloc func())) ShardedOption {
+	return func(c *shardedConfig) {
+		if numNodes > 0 {
+			c.numNodes = numNodes
+		}
+		c.place = place
+	}
+}
+
+// NewShardedFilter returns a filter for numElements items made of numShards filters, which is
+// clamped to [1, 65536]. If a NUMA node count is set, every node gets at least one shard.
+func NewShardedFilter(numElements uint, numShards int, opts ...ShardedOption) *ShardedFilter {
+	c := shardedConfig{numNodes: 1}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	numShards = clamp(numShards, c.numNodes, 1<<16)
+	if c.numNodes > numShards {
+		c.numNodes = numShards
+	}
+	sf := &ShardedFilter{
+		shards:     make([]*Filter, numShards),
+		nodeShards: make([][]int, c.numNodes),
+	}
+	perShard := numElements / uint(numShards)
+	for s := range sf.shards {
+		node := s % c.numNodes
+		sf.nodeShards[node] = append(sf.nodeShards[node], s)
+		alloc := func() {
+			cf := NewFilter(perShard)
+			// Touch every page, as fresh memory from the operating system is placed lazily.
+			for i := 0; i < len(cf.buckets); i += pageSize / 8 {
+				cf.buckets[i] = 0
+			}
+			sf.shards[s] = cf
+		}
+		if c.place != nil {
+			c.place(node, alloc)
+		} else {
+			alloc()
+		}
+	}
+	return sf
+}
+
+// shardHash returns the hash used for picking the shard of data. Bits 32 to 47 of the hash
+// are used neither for the bucket index, nor for the fingerprint.
+func shardHash(data []byte) int {
+	return int(uint16(hashKey(data) >> 32))
+}
+
+// shard returns the shard of data, restricted to the shards of node if it is a valid hint.
+func (sf *ShardedFilter) shard(node int, data []byte) *Filter {
+	if node < 0 || node >= len(sf.nodeShards) {
+		return sf.shards[shardHash(data)%len(sf.shards)]
+	}
+	shards := sf.nodeShards[node]
+	return sf.shards[shards[shardHash(data)%len(shards)]]
+}
+
+// Insert data into its shard. Returns false if insertion failed.
+func (sf *ShardedFilter) Insert(data []byte) bool {
+	return sf.shard(-1, data).Insert(data)
+}
+
+// Lookup returns true if data is in the filter.
+func (sf *ShardedFilter) Lookup(data []byte) bool {
+	return sf.shard(-1, data).Lookup(data)
+}
+
+// Delete data from the filter. Returns true if the data was found and deleted.
+func (sf *ShardedFilter) Delete(data []byte) bool {
+	return sf.shard(-1, data).Delete(data)
+}
+
+// InsertHint inserts data into a shard on the given NUMA node. Items inserted with a hint
+// must be looked up and deleted with the same hint. Invalid hints are ignored.
+func (sf *ShardedFilter) InsertHint(node int, data []byte) bool {
+	return sf.shard(node, data).Insert(data)
+}
+
+// LookupHint looks up data inserted with InsertHint on the given node.
+func (sf *ShardedFilter) LookupHint(node int, data []byte) bool {
+	return sf.shard(node, data).Lookup(data)
+}
+
+// DeleteHint deletes data inserted with InsertHint on the given node.
+func (sf *ShardedFilter) DeleteHint(node int, data []byte) bool {
+	return sf.shard(node, data).Delete(data)
+}
+
+// Count returns the number of items in all shards.
+func (sf *ShardedFilter) Count() uint {
+	var n uint
+	for _, cf := range sf.shards {
+		n += cf.Count()
+	}
+	return n
+}
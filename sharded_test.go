@@ -0,0 +1,36 @@
+package cuckoo
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardedFilter(t *testing.T) {
+	var placed []int
+	sf := NewShardedFilter(10000, 4, WithNUMANodes(2, func(node int, alloc func()) {
+		placed = append(placed, node)
+		alloc()
+	}))
+	if want := []int{0, 1, 0, 1}; fmt.Sprint(placed) != fmt.Sprint(want) {
+		t.Errorf("Shards placed on nodes %v, want %v", placed, want)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if !sf.Insert([]byte(fmt.Sprint(i))) || !sf.InsertHint(i%2, []byte(fmt.Sprint("hint", i))) {
+			t.Fatalf("Insert(%d) failed", i)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if !sf.Lookup([]byte(fmt.Sprint(i))) || !sf.LookupHint(i%2, []byte(fmt.Sprint("hint", i))) {
+			t.Fatalf("Lookup(%d) = false", i)
+		}
+	}
+	for _, cf := range sf.shards {
+		if cf.Count() == 0 {
+			t.Errorf("Shard is empty, items are not spread")
+		}
+	}
+	if !sf.DeleteHint(1, []byte("hint1")) || sf.Count() != 1999 {
+		t.Errorf("DeleteHint() failed, Count() = %d", sf.Count())
+	}
+}
@@ -0,0 +1,193 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package cuckoo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// sharedMagic identifies files created by OpenSharedFilter.
+var sharedMagic = [8]byte{'C', 'U', 'C', 'K', 'O', 'O', 'S', 'H'}
+
+// sharedHeader is stored at the beginning of a shared filter file, followed by the buckets.
+type sharedHeader struct {
+	magic      [8]byte
+	numBuckets uint64
+	count      uint64
+}
+
+const sharedHeaderSize = int(unsafe.Sizeof(sharedHeader{}))
+
+// SharedFilter is a filter whose buckets live in a memory-mapped file, e.g. in /dev/shm, so
+// that multiple processes on one host can share it. Operations are synchronized across
+// processes with flock, taking a shared lock for lookups and an exclusive lock for writes.
+// Within one process, operations on a SharedFilter are serialized.
+//
+// The file uses the native byte order and is not meant to be moved between machines,
+// use Encode for that.
+type SharedFilter struct {
+	// lock serializes operations of this process, as flock does not distinguish goroutines.
+	lock   sync.Mutex
+	file   *os.File
+	data   []byte
+	header *sharedHeader
+	filter *Filter
+}
+
+var _ ApproxSet = (*SharedFilter)(nil)
+
+// OpenSharedFilter opens the shared filter at path, creating it with room for numElements
+// if it does not exist. Opening an existing filter of a different size returns ErrIncompatible.
+func OpenSharedFilter(path string, numElements uint) (*SharedFilter, error) {
+	numBuckets, err := bucketsFor(uint64(numElements))
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	sf, err := openSharedFilter(f, int(numBuckets))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return sf, nil
+}
+
+func openSharedFilter(f *os.File, numBuckets int) (*SharedFilter, error) {
+	// Hold an exclusive lock, so that only one process initializes a new file.
+	if err := flock(f, syscall.LOCK_EX); err != nil {
+		return nil, err
+	}
+	defer flock(f, syscall.LOCK_UN)
+
+	if numBuckets > maxMappedBuckets {
+		return nil, fmt.Errorf("%w: %d buckets", ErrTooLarge, numBuckets)
+	}
+	size := sharedHeaderSize + numBuckets*int(unsafe.Sizeof(bucket(0)))
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	create := info.Size() == 0
+	if create {
+		if err := f.Truncate(int64(size)); err != nil {
+			return nil, err
+		}
+	} else if info.Size() != int64(size) {
+		return nil, fmt.Errorf("%w: shared filter has %d bytes, want %d", ErrIncompatible, info.Size(), size)
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	header := (*sharedHeader)(unsafe.Pointer(&data[0]))
+	if create {
+		header.magic = sharedMagic
+		header.numBuckets = uint64(numBuckets)
+	} else if !bytes.Equal(header.magic[:], sharedMagic[:]) || header.numBuckets != uint64(numBuckets) {
+		syscall.Munmap(data)
+		return nil, fmt.Errorf("%w: not a shared filter with %d buckets", ErrIncompatible, numBuckets)
+	}
+	buckets := mappedBuckets(data[sharedHeaderSize:], numBuckets)
+	return &SharedFilter{
+		file:   f,
+		data:   data,
+		header: header,
+		filter: newFilter(buckets),
+	}, nil
+}
+
+// do runs fn while holding the process-wide lock of type how.
+func (sf *SharedFilter) do(how int, fn func(cf *Filter)) error {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	if sf.data == nil {
+		return os.ErrClosed
+	}
+	if err := flock(sf.file, how); err != nil {
+		return err
+	}
+	defer flock(sf.file, syscall.LOCK_UN)
+
+	// The count is maintained in the shared header.
+	sf.filter.count = uint(sf.header.count)
+	fn(sf.filter)
+	if how == syscall.LOCK_EX {
+		sf.header.count = uint64(sf.filter.count)
+	}
+	return nil
+}
+
+// Insert data into the filter. Returns false if insertion failed or the filter is closed.
+func (sf *SharedFilter) Insert(data []byte) bool {
+	ok := false
+	sf.do(syscall.LOCK_EX, func(cf *Filter) { ok = cf.Insert(data) })
+	return ok
+}
+
+// Lookup returns true if data is in the filter.
+func (sf *SharedFilter) Lookup(data []byte) bool {
+	ok := false
+	sf.do(syscall.LOCK_SH, func(cf *Filter) { ok = cf.Lookup(data) })
+	return ok
+}
+
+// Delete data from the filter. Returns true if the data was found and deleted.
+func (sf *SharedFilter) Delete(data []byte) bool {
+	ok := false
+	sf.do(syscall.LOCK_EX, func(cf *Filter) { ok = cf.Delete(data) })
+	return ok
+}
+
+// Count returns the number of items in the filter.
+func (sf *SharedFilter) Count() uint {
+	var n uint
+	sf.do(syscall.LOCK_SH, func(cf *Filter) { n = cf.count })
+	return n
+}
+
+// Reset removes all items from the filter, setting count to 0.
+func (sf *SharedFilter) Reset() error {
+	return sf.do(syscall.LOCK_EX, func(cf *Filter) { cf.Reset() })
+}
+
+// Encode returns a byte slice representing the filter, see Filter.Encode.
+func (sf *SharedFilter) Encode() ([]byte, error) {
+	var encoded []byte
+	err := sf.do(syscall.LOCK_SH, func(cf *Filter) { encoded = cf.Encode() })
+	return encoded, err
+}
+
+// Close unmaps the filter and closes the file. The shared data stays in the file.
+func (sf *SharedFilter) Close() error {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	if sf.data == nil {
+		return os.ErrClosed
+	}
+	err := syscall.Munmap(sf.data)
+	sf.data, sf.header, sf.filter.buckets = nil, nil, nil
+	if cerr := sf.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func flock(f *os.File, how int) error {
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
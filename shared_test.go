@@ -0,0 +1,65 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package cuckoo
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSharedFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter")
+	// Two handles on the same file behave like two processes.
+	a, err := OpenSharedFilter(path, 1000)
+	if err != nil {
+		t.Fatalf("OpenSharedFilter() = %v", err)
+	}
+	defer a.Close()
+	b, err := OpenSharedFilter(path, 1000)
+	if err != nil {
+		t.Fatalf("OpenSharedFilter() = %v", err)
+	}
+
+	a.Insert([]byte("one"))
+	b.Insert([]byte("two"))
+	if !b.Lookup([]byte("one")) || !a.Lookup([]byte("two")) {
+		t.Errorf("Lookup() = false for item inserted through other handle")
+	}
+	if got, want := a.Count(), uint(2); got != want {
+		t.Errorf("Count() = %d, want %d", got, want)
+	}
+	if !a.Delete([]byte("two")) || b.Lookup([]byte("two")) {
+		t.Errorf("Lookup() = true for item deleted through other handle")
+	}
+
+	// Data survives closing all handles.
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if b.Lookup([]byte("one")) {
+		t.Errorf("Lookup() on closed filter = true, want false")
+	}
+	c, err := OpenSharedFilter(path, 1000)
+	if err != nil {
+		t.Fatalf("OpenSharedFilter() = %v", err)
+	}
+	defer c.Close()
+	if !c.Lookup([]byte("one")) || c.Count() != 1 {
+		t.Errorf("After reopening: Lookup() = false, Count() = %d, want true, 1", c.Count())
+	}
+}
+
+func TestSharedFilter_Incompatible(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter")
+	sf, err := OpenSharedFilter(path, 1000)
+	if err != nil {
+		t.Fatalf("OpenSharedFilter() = %v", err)
+	}
+	defer sf.Close()
+
+	if _, err := OpenSharedFilter(path, 100000); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("OpenSharedFilter() with different size error = %v, want %v", err, ErrIncompatible)
+	}
+}
@@ -0,0 +1,49 @@
+package cuckoo
+
+// shrinkLoadFactor is the maximum load factor Shrink targets. It leaves room for the kickout
+// chains of later inserts.
+const shrinkLoadFactor = 0.9
+
+// Shrink rebuilds the filter into the smallest power-of-two number of buckets that holds its
+// items at a load factor of at most 90%, reclaiming memory after large delete waves or
+// over-provisioned construction. It returns true if the filter shrank.
+//
+// Shrinking halves the number of buckets by folding the upper half onto the lower half:
+// candidate buckets are derived by masking the hash, so every item keeps both its
+// candidates. If the items do not fit, Shrink retries with twice as many buckets and leaves
+// the filter unchanged if nothing smaller works. It clears the journal, see WithJournal.
+func (cf *Filter) Shrink() bool {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+
+	numBuckets := uint(1)
+	for float64(cf.count) > shrinkLoadFactor*float64(numBuckets*bucketSize) {
+		numBuckets <<= 1
+	}
+	for ; numBuckets < uint(len(cf.buckets)); numBuckets <<= 1 {
+		if staged, ok := cf.fold(numBuckets); ok {
+			cf.buckets = staged.buckets
+			cf.bucketIndexMask = staged.bucketIndexMask
+			cf.stash.fold(cf.bucketIndexMask)
+			cf.generations = nil
+			cf.generation = 0
+			cf.rebuildSummary()
+			cf.journal.reset()
+			cf.log.checkLoad(cf.count, cf.Cap())
+			return true
+		}
+	}
+	return false
+}
+
+// fold returns the items of cf placed into numBuckets buckets, which must be fewer than cf
+// has. It returns false if they do not fit. The caller must hold at least the read lock.
+func (cf *Filter) fold(numBuckets uint) (*core, bool) {
+	staged := newCore(make([]bucket, numBuckets))
+	staged.altScheme = cf.altScheme
+	ok := true
+	cf.forEachFingerprint(func(i, _ uint, fp fingerprint) {
+		ok = ok && staged.insertFingerprint(fp, i&staged.bucketIndexMask)
+	})
+	return &staged, ok
+}
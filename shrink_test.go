@@ -0,0 +1,45 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestShrink(t *testing.T) {
+	cf := NewFilter(100000)
+	for i := 0; i < 10000; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	for i := 1000; i < 10000; i++ {
+		cf.Delete([]byte(strconv.Itoa(i)))
+	}
+	if !cf.Shrink() {
+		t.Fatalf("Shrink() = false, want true")
+	}
+	// 1000 items need 278 buckets at 90% load, so 512.
+	if cf.Cap() != 512*bucketSize {
+		t.Errorf("Cap() after Shrink() = %d, want %d", cf.Cap(), 512*bucketSize)
+	}
+	if cf.Count() != 1000 {
+		t.Errorf("Count() after Shrink() = %d, want 1000", cf.Count())
+	}
+	for i := 0; i < 1000; i++ {
+		if !cf.Lookup([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Lookup(%d) = false after Shrink()", i)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if !cf.Delete([]byte(strconv.Itoa(i))) {
+			t.Fatalf("Delete(%d) = false after Shrink()", i)
+		}
+	}
+	if cf.Count() != 0 {
+		t.Errorf("Count() after deleting all = %d, want 0", cf.Count())
+	}
+	if !cf.Shrink() || cf.Cap() != bucketSize {
+		t.Errorf("Shrink() of empty filter left %d slots, want %d", cf.Cap(), bucketSize)
+	}
+	if cf.Shrink() {
+		t.Errorf("Shrink() of minimal filter = true, want false")
+	}
+}
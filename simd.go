@@ -0,0 +1,90 @@
+package cuckoo
+
+import "sync"
+
+// matchGroupFunc reports which of the first n buckets of b1s and b2s hold the fingerprint of
+// the same index in fps: bit k of m1 is set if b1s[k] contains fps[k], and of m2 for b2s[k].
+// It is the kernel of LookupBatch.
+type matchGroupFunc func(b1s, b2s *[lookupBatchGroup]bucket, fps *[lookupBatchGroup]fingerprint, n int) (m1, m2 uint32)
+
+// matchImpl is an implementation of the kernel of LookupBatch.
+type matchImpl struct {
+	name string
+	// requires is the name of the CPU feature the implementation needs, see CPUFeatures.
+	requires   string
+	matchGroup matchGroupFunc
+}
+
+// matchImpls holds the accelerated implementations, fastest first. Architecture-specific
+// files add to it with registerMatch in init functions; on first use, the first one whose
+// required CPU feature is detected is selected, falling back to genericMatch.
+var matchImpls []matchImpl
+
+// registerMatch adds an accelerated implementation, slower than those registered before. It
+// must be called from init functions.
+func registerMatch(impl matchImpl) {
+	matchImpls = append(matchImpls, impl)
+}
+
+// genericMatch compares all lanes of a bucket at once in ordinary 64-bit registers, see
+// bucket.matchLanes. It runs on every CPU.
+var genericMatch = matchImpl{name: "generic", matchGroup: matchGroupGeneric}
+
+var (
+	// cpuFeatures holds the detected CPU features, see CPUFeatures.
+	cpuFeatures = detectCPUFeatures()
+	// activeMatch is the selected implementation, see matchImplementation. Package-level
+	// initializers run before init functions, so it is selected lazily.
+	activeMatch     matchImpl
+	activeMatchOnce sync.Once
+)
+
+// matchImplementation returns the selected implementation, selecting it on first use.
+func matchImplementation() matchImpl {
+	activeMatchOnce.Do(func() { activeMatch = selectMatch(cpuFeatures) })
+	return activeMatch
+}
+
+func matchGroupGeneric(b1s, b2s *[lookupBatchGroup]bucket, fps *[lookupBatchGroup]fingerprint, n int) (m1, m2 uint32) {
+	for k := 0; k < n; k++ {
+		if b1s[k].contains(fps[k]) {
+			m1 |= 1 << k
+		}
+		if b2s[k].contains(fps[k]) {
+			m2 |= 1 << k
+		}
+	}
+	return m1, m2
+}
+
+// selectMatch returns the fastest implementation supported by a CPU with the given features.
+func selectMatch(features []string) matchImpl {
+	for _, impl := range matchImpls {
+		for _, f := range features {
+			if f == impl.requires {
+				return impl
+			}
+		}
+	}
+	return genericMatch
+}
+
+// CPUFeatures returns the CPU features relevant to the accelerated implementations that were
+// detected at startup, e.g. "sse4.1", "avx2" or "neon".
+func CPUFeatures() []string {
+	return append([]string(nil), cpuFeatures...)
+}
+
+// Implementation returns the name of the implementation of bucket matching in use, "generic"
+// unless an accelerated implementation for a detected CPU feature is available.
+func Implementation() string {
+	return matchImplementation().name
+}
+
+// ForceGenericImplementation selects the generic implementation regardless of the detected
+// CPU features, e.g. to rule out a faulty accelerated path while debugging. It is not safe to
+// call concurrently with operations on filters; call it during initialization.
+func ForceGenericImplementation() {
+	activeMatchOnce.Do(func() {})
+	activeMatch = genericMatch
+}
@@ -0,0 +1,74 @@
+package cuckoo
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestMatchGroupGeneric(t *testing.T) {
+	var b1s, b2s [lookupBatchGroup]bucket
+	var fps [lookupBatchGroup]fingerprint
+	for k := range fps {
+		b1s[k], b2s[k] = bucket(rand.Uint64()), bucket(rand.Uint64())
+		fps[k] = fingerprint(rand.Intn(maxFingerprint) + 1)
+		switch k % 3 {
+		case 0:
+			b1s[k].set(k%bucketSize, fps[k])
+		case 1:
+			b2s[k].set(k%bucketSize, fps[k])
+		}
+	}
+	m1, m2 := matchGroupGeneric(&b1s, &b2s, &fps, 10)
+	for k := 0; k < lookupBatchGroup; k++ {
+		want1, want2 := k < 10 && b1s[k].contains(fps[k]), k < 10 && b2s[k].contains(fps[k])
+		if got1, got2 := m1&(1<<k) != 0, m2&(1<<k) != 0; got1 != want1 || got2 != want2 {
+			t.Errorf("bucket pair %d: matched %v, %v, want %v, %v", k, got1, got2, want1, want2)
+		}
+	}
+}
+
+func TestSelectMatch(t *testing.T) {
+	saved := matchImpls
+	defer func() { matchImpls = saved }()
+	fast := matchImpl{name: "fast", requires: "avx2", matchGroup: matchGroupGeneric}
+	matchImpls = []matchImpl{fast}
+
+	if got := selectMatch([]string{"sse4.1", "avx2"}); got.name != "fast" {
+		t.Errorf("selectMatch(avx2) = %s, want fast", got.name)
+	}
+	if got := selectMatch([]string{"neon"}); got.name != "generic" {
+		t.Errorf("selectMatch(neon) = %s, want generic", got.name)
+	}
+
+	ForceGenericImplementation()
+	if Implementation() != "generic" {
+		t.Errorf("Implementation() after ForceGenericImplementation() = %s, want generic", Implementation())
+	}
+	t.Logf("detected CPU features: %v", CPUFeatures())
+}
+
+func TestRegisterMatch(t *testing.T) {
+	savedImpls, savedFeatures := matchImpls, cpuFeatures
+	defer func() {
+		matchImpls, cpuFeatures = savedImpls, savedFeatures
+		activeMatchOnce = sync.Once{}
+	}()
+	// Start over as if the init functions of the package had just run.
+	matchImpls, cpuFeatures = nil, []string{"fake"}
+	activeMatchOnce = sync.Once{}
+	calls := 0
+	registerMatch(matchImpl{name: "fake", requires: "fake", matchGroup: func(b1s, b2s *[lookupBatchGroup]bucket, fps *[lookupBatchGroup]fingerprint, n int) (uint32, uint32) {
+		calls++
+		return matchGroupGeneric(b1s, b2s, fps, n)
+	}})
+
+	if got := Implementation(); got != "fake" {
+		t.Errorf("Implementation() = %s, want fake", got)
+	}
+	cf := NewFilter(100)
+	cf.Insert([]byte("one"))
+	if found := cf.LookupBatch([][]byte{[]byte("one"), []byte("two")}, nil); !found[0] || found[1] || calls != 1 {
+		t.Errorf("LookupBatch() = %v with %d calls of the registered implementation, want [true false] with 1", found, calls)
+	}
+}
@@ -0,0 +1,173 @@
+package cuckoo
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// snapshotMagic identifies snapshots written by SnapshotTo.
+var snapshotMagic = [8]byte{'C', 'U', 'C', 'K', 'O', 'O', 'S', 'N'}
+
+const (
+	snapshotFormatVersion = 1
+	// snapshotChunkSize is the number of encoded bytes per chunk frame.
+	snapshotChunkSize      = 1 << 20
+	snapshotHeaderSize     = 40
+	snapshotFrameHeaderLen = 16
+)
+
+// SnapshotTo writes a compressed snapshot of cf to w, e.g. an upload to an object store.
+//
+// The snapshot consists of a header followed by independently compressed chunk frames of 1MiB
+// of filter data, each carrying its index and a checksum. RestoreFrom accepts frames that are
+// repeated or out of order, so a multipart upload can resume by resending failed parts, and
+// a damaged or truncated snapshot is detected before any of it is used.
+// SnapshotTo stops with the context's error when ctx is done.
+//
+// The filter is copied first, so writes to cf only block while copying, not during I/O.
+func (cf *Filter) SnapshotTo(ctx context.Context, w io.Writer) error {
+	encoded, count := cf.encodeWithCount()
+	header := make([]byte, snapshotHeaderSize)
+	copy(header, snapshotMagic[:])
+	binary.LittleEndian.PutUint32(header[8:], snapshotFormatVersion)
+	binary.LittleEndian.PutUint64(header[12:], uint64(len(encoded)))
+	binary.LittleEndian.PutUint64(header[20:], uint64(count))
+	binary.LittleEndian.PutUint32(header[28:], snapshotChunkSize)
+	binary.LittleEndian.PutUint32(header[32:], uint32(numSnapshotChunks(len(encoded))))
+	binary.LittleEndian.PutUint32(header[36:], crc32.ChecksumIEEE(header[:36]))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	return writeSnapshotFrames(ctx, w, encoded)
+}
+
+// encodeWithCount returns Encode and Count of a consistent state of cf.
+func (cf *Filter) encodeWithCount() ([]byte, uint) {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	return cf.encode(), cf.count
+}
+
+func numSnapshotChunks(size int) int {
+	return (size + snapshotChunkSize - 1) / snapshotChunkSize
+}
+
+func writeSnapshotFrames(ctx context.Context, w io.Writer, encoded []byte) error {
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.BestSpeed)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < numSnapshotChunks(len(encoded)); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		raw := chunkOf(encoded, i)
+		compressed.Reset()
+		fw.Reset(&compressed)
+		if _, err := fw.Write(raw); err != nil {
+			return err
+		}
+		if err := fw.Close(); err != nil {
+			return err
+		}
+		frame := make([]byte, snapshotFrameHeaderLen)
+		binary.LittleEndian.PutUint32(frame, uint32(i))
+		binary.LittleEndian.PutUint32(frame[4:], uint32(len(raw)))
+		binary.LittleEndian.PutUint32(frame[8:], uint32(compressed.Len()))
+		binary.LittleEndian.PutUint32(frame[12:], crc32.ChecksumIEEE(raw))
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+		if _, err := w.Write(compressed.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreFrom reads a snapshot written by SnapshotTo from r. It returns ErrCorrupted if the
+// snapshot is damaged or incomplete, and the context's error when ctx is done.
+func RestoreFrom(ctx context.Context, r io.Reader) (_ *Filter, err error) {
+	defer recoverInvalidState(&err)
+	header := make([]byte, snapshotHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: reading snapshot header: %v", ErrCorrupted, err)
+	}
+	if !bytes.Equal(header[:8], snapshotMagic[:]) || crc32.ChecksumIEEE(header[:36]) != binary.LittleEndian.Uint32(header[36:]) {
+		return nil, fmt.Errorf("%w: invalid snapshot header", ErrCorrupted)
+	}
+	if v := binary.LittleEndian.Uint32(header[8:]); v != snapshotFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported snapshot format version %d", ErrIncompatible, v)
+	}
+	size := binary.LittleEndian.Uint64(header[12:])
+	count := binary.LittleEndian.Uint64(header[20:])
+	chunkSize := binary.LittleEndian.Uint32(header[28:])
+	numChunks := int(binary.LittleEndian.Uint32(header[32:]))
+	if size > uint64(maxInt) {
+		return nil, fmt.Errorf("%w: snapshot of %d bytes", ErrTooLarge, size)
+	}
+	if chunkSize != snapshotChunkSize || numChunks != numSnapshotChunks(int(size)) {
+		return nil, fmt.Errorf("%w: invalid snapshot geometry", ErrCorrupted)
+	}
+
+	// Chunks are kept until all arrived, so a forged size allocates nothing before the data
+	// has been read and verified.
+	chunks := make(map[int][]byte)
+	for len(chunks) < numChunks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		frame := make([]byte, snapshotFrameHeaderLen)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, fmt.Errorf("%w: snapshot is missing %d of %d chunks: %v", ErrCorrupted, numChunks-len(chunks), numChunks, err)
+		}
+		i := int(binary.LittleEndian.Uint32(frame))
+		rawLen := binary.LittleEndian.Uint32(frame[4:])
+		compressedLen := binary.LittleEndian.Uint32(frame[8:])
+		if i >= numChunks || int(rawLen) != snapshotChunkLen(int(size), i) || compressedLen > 2*snapshotChunkSize {
+			return nil, fmt.Errorf("%w: invalid chunk frame %d", ErrCorrupted, i)
+		}
+		raw, err := ioutil.ReadAll(flate.NewReader(io.LimitReader(r, int64(compressedLen))))
+		if err != nil || len(raw) != int(rawLen) || crc32.ChecksumIEEE(raw) != binary.LittleEndian.Uint32(frame[12:]) {
+			return nil, fmt.Errorf("%w: damaged chunk %d", ErrCorrupted, i)
+		}
+		chunks[i] = raw
+	}
+	encoded := make([]byte, 0, size)
+	for i := 0; i < numChunks; i++ {
+		encoded = append(encoded, chunks[i]...)
+	}
+	cf, err := Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(cf.count) != count {
+		return nil, fmt.Errorf("%w: snapshot holds %d items, header says %d", ErrCorrupted, cf.count, count)
+	}
+	return cf, nil
+}
+
+// snapshotChunkLen returns the length of chunk i of an encoded filter of size bytes.
+func snapshotChunkLen(size, i int) int {
+	if end := (i + 1) * snapshotChunkSize; end > size {
+		return size - i*snapshotChunkSize
+	}
+	return snapshotChunkSize
+}
+
+// chunkOf returns chunk i of an encoded filter.
+func chunkOf(encoded []byte, i int) []byte {
+	end := (i + 1) * snapshotChunkSize
+	if end > len(encoded) {
+		end = len(encoded)
+	}
+	return encoded[i*snapshotChunkSize : end]
+}
@@ -0,0 +1,76 @@
+package cuckoo
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	// Several chunks of 1MiB.
+	cf := NewFilter(2 * snapshotChunkSize / 2)
+	for i := 0; i < 10000; i++ {
+		cf.Insert([]byte{byte(i), byte(i >> 8)})
+	}
+	var buf bytes.Buffer
+	if err := cf.SnapshotTo(context.Background(), &buf); err != nil {
+		t.Fatalf("SnapshotTo() = %v", err)
+	}
+	snapshot := buf.Bytes()
+
+	got, err := RestoreFrom(context.Background(), bytes.NewReader(snapshot))
+	if err != nil {
+		t.Fatalf("RestoreFrom() = %v", err)
+	}
+	if !reflect.DeepEqual(got.buckets, cf.buckets) || got.Count() != cf.Count() {
+		t.Errorf("RestoreFrom() = filter with %d items, want %d", got.Count(), cf.Count())
+	}
+
+	// Resend all frames after the first one, as a resumed upload would.
+	frames := snapshot[snapshotHeaderSize:]
+	resumed := append(append([]byte{}, snapshot...), frames...)
+	if _, err := RestoreFrom(context.Background(), bytes.NewReader(resumed)); err != nil {
+		t.Errorf("RestoreFrom() with repeated frames = %v", err)
+	}
+
+	truncated := snapshot[:len(snapshot)-10]
+	if _, err := RestoreFrom(context.Background(), bytes.NewReader(truncated)); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("RestoreFrom() of truncated snapshot error = %v, want %v", err, ErrCorrupted)
+	}
+	damaged := append([]byte{}, snapshot...)
+	damaged[len(damaged)-1] ^= 0xff
+	if _, err := RestoreFrom(context.Background(), bytes.NewReader(damaged)); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("RestoreFrom() of damaged snapshot error = %v, want %v", err, ErrCorrupted)
+	}
+}
+
+func TestSnapshotTo_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	if err := NewFilter(100).SnapshotTo(ctx, &buf); !errors.Is(err, context.Canceled) {
+		t.Errorf("SnapshotTo() with canceled context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRestoreFrom_ForgedSize(t *testing.T) {
+	// A valid header of a huge snapshot must not allocate it before its frames arrive.
+	size := uint64(1) << 50
+	if uint64(maxInt) < size {
+		size = uint64(maxInt) - snapshotChunkSize
+	}
+	header := make([]byte, snapshotHeaderSize)
+	copy(header, snapshotMagic[:])
+	binary.LittleEndian.PutUint32(header[8:], snapshotFormatVersion)
+	binary.LittleEndian.PutUint64(header[12:], size)
+	binary.LittleEndian.PutUint32(header[28:], snapshotChunkSize)
+	binary.LittleEndian.PutUint32(header[32:], uint32(numSnapshotChunks(int(size))))
+	binary.LittleEndian.PutUint32(header[36:], crc32.ChecksumIEEE(header[:36]))
+	if _, err := RestoreFrom(context.Background(), bytes.NewReader(header)); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("RestoreFrom() of a forged header = %v, want %v", err, ErrCorrupted)
+	}
+}
@@ -0,0 +1,107 @@
+package cuckoo
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// StableFilter is a filter for unbounded streams, modeled after the Stable Bloom filter of
+// Deng and Rafiei. Every insert first evicts random slots, on average 1/loadFactor of them,
+// so the load factor converges to loadFactor instead of the filter filling up. Old items
+// are evicted at a known rate: the filter trades false negatives for stale items against a
+// bounded false positive rate, both set by the constructor parameters, see
+// FalsePositiveRate and Retention. It is safe for concurrent use.
+type StableFilter struct {
+	lock sync.RWMutex
+	core
+	// evictions is the expected number of slots evicted per insert, 1/loadFactor.
+	evictions float64
+}
+
+var _ ApproxSet = (*StableFilter)(nil)
+
+// NewStableFilter returns a stable filter with room for numElements, see NewFilter, whose
+// load factor converges to loadFactor, which must be in (0, 1). Lower load factors give
+// fewer false positives, higher ones retain items for longer.
+func NewStableFilter(numElements uint, loadFactor float64) (*StableFilter, error) {
+	if !(loadFactor > 0 && loadFactor < 1) {
+		return nil, fmt.Errorf("%w: load factor %v, want it in (0, 1)", ErrUnsupported, loadFactor)
+	}
+	sf := &StableFilter{
+		core:      newCore(make([]bucket, numBucketsFor(numElements))),
+		evictions: 1 / loadFactor,
+	}
+	sf.saturation = EvictRandomWhenFull
+	return sf, nil
+}
+
+// FalsePositiveRate returns the false positive rate once the load factor has converged.
+func (sf *StableFilter) FalsePositiveRate() float64 {
+	return 2 * bucketSize / sf.evictions / maxFingerprint
+}
+
+// Retention returns the probability that an item is still in the filter after n more inserts
+// of other items, once the load factor has converged.
+func (sf *StableFilter) Retention(n uint) float64 {
+	return math.Exp(-float64(n) * sf.evictions / float64(sf.Cap()))
+}
+
+// Insert evicts random items and inserts data into the filter. Always returns true.
+func (sf *StableFilter) Insert(data []byte) bool {
+	i1, fp := sf.indexAndFingerprint(data)
+
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	n := int(sf.evictions)
+	if rand.Float64() < sf.evictions-float64(n) {
+		n++
+	}
+	for ; n > 0; n-- {
+		slot := rand.Intn(sf.Cap())
+		b := &sf.buckets[slot/bucketSize]
+		if b.get(slot%bucketSize) != nullFp {
+			b.set(slot%bucketSize, nullFp)
+			sf.count--
+		}
+	}
+	return sf.insertKey(data, fp, i1)
+}
+
+// Lookup returns true if data is in the filter.
+func (sf *StableFilter) Lookup(data []byte) bool {
+	i1, fp := sf.indexAndFingerprint(data)
+
+	sf.lock.RLock()
+	defer sf.lock.RUnlock()
+
+	return sf.lookup(fp, i1)
+}
+
+// Delete data from the filter. Returns true if the data was found and deleted.
+func (sf *StableFilter) Delete(data []byte) bool {
+	i1, fp := sf.indexAndFingerprint(data)
+
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	return sf.deleteKey(data, fp, i1)
+}
+
+// Count returns the number of items in the filter.
+func (sf *StableFilter) Count() uint {
+	sf.lock.RLock()
+	defer sf.lock.RUnlock()
+
+	return sf.count
+}
+
+// LoadFactor returns the fraction of slots that are occupied.
+func (sf *StableFilter) LoadFactor() float64 {
+	sf.lock.RLock()
+	defer sf.lock.RUnlock()
+
+	return sf.loadFactor()
+}
@@ -0,0 +1,57 @@
+package cuckoo
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestStableFilter(t *testing.T) {
+	for _, lf := range []float64{0, 1, -0.5, math.NaN()} {
+		if _, err := NewStableFilter(1000, lf); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("NewStableFilter(1000, %v) = %v, want ErrUnsupported", lf, err)
+		}
+	}
+
+	sf, err := NewStableFilter(10000, 0.5)
+	if err != nil {
+		t.Fatalf("NewStableFilter() = %v", err)
+	}
+	const n = 200000
+	for i := 0; i < n; i++ {
+		key := []byte(strconv.Itoa(i))
+		if !sf.Insert(key) || !sf.Lookup(key) {
+			t.Fatalf("Insert(%d), Lookup(%d) = false", i, i)
+		}
+	}
+	if lf := sf.LoadFactor(); math.Abs(lf-0.5) > 0.05 {
+		t.Errorf("LoadFactor() = %v, want about 0.5", lf)
+	}
+
+	// The fraction of recent items still present matches Retention.
+	const recent = 2000
+	found := 0
+	for i := n - recent; i < n; i++ {
+		if sf.Lookup([]byte(strconv.Itoa(i))) {
+			found++
+		}
+	}
+	want := 0.0
+	for k := uint(0); k < recent; k++ {
+		want += sf.Retention(k)
+	}
+	if math.Abs(float64(found)-want) > 0.05*recent {
+		t.Errorf("found %d of the last %d items, want about %.0f", found, recent, want)
+	}
+
+	fps := 0
+	for i := 0; i < 100000; i++ {
+		if sf.Lookup([]byte("absent" + strconv.Itoa(i))) {
+			fps++
+		}
+	}
+	if rate := float64(fps) / 100000; rate > 3*sf.FalsePositiveRate() {
+		t.Errorf("false positive rate = %v, want about %v", rate, sf.FalsePositiveRate())
+	}
+}
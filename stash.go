@@ -0,0 +1,174 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// stashFormatVersion is the format version Encode writes for filters whose overflow stash
+// holds items: format version 2 followed by the stash entries and their number, see
+// WithOverflowStash.
+const stashFormatVersion = 3
+
+// WithOverflowStash adds an overflow area for up to size fingerprints that found no slot in
+// their candidate buckets after the kickout chain, trading a few bytes for near-zero insert
+// failures at very high load factors. Lookup, LookupBatch, Delete, Count, Shrink, Merge,
+// Encode, SnapshotTo, Partition and Manager.Reconfigure cover the stash. While it holds
+// items, MarshalCBOR, DumpCSV, DumpParquet, Publish and KVPersister.Save return an error
+// wrapping ErrUnsupported, and EncodeFlatBuffer, which cannot fail, writes only the buckets.
+// Filters with stashed items are encoded with a format version only Decode reads. Logs when
+// the stash fills up, see WithLogger.
+func WithOverflowStash(size int) Option {
+	return func(cf *Filter) {
+		cf.stash = &overflowStash{size: size}
+	}
+}
+
+// overflowStash holds fingerprints that found no slot. A small stash is scanned linearly.
+// All methods are safe to call on a nil receiver, which disables the stash.
+type overflowStash struct {
+	entries []stashEntry
+	size    int
+}
+
+// stashEntry is a stashed fingerprint and one of its candidate buckets.
+type stashEntry struct {
+	fp fingerprint
+	i  uint
+}
+
+func (s *overflowStash) len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.entries)
+}
+
+func (s *overflowStash) all() []stashEntry {
+	if s == nil {
+		return nil
+	}
+	return s.entries
+}
+
+// add stashes fp with candidate bucket i. Returns false if the stash is full.
+func (s *overflowStash) add(fp fingerprint, i uint) bool {
+	if s == nil || len(s.entries) >= s.size {
+		return false
+	}
+	s.entries = append(s.entries, stashEntry{fp, i})
+	return true
+}
+
+// find returns the index of an entry of fp with candidate buckets i1 and i2, or -1.
+func (s *overflowStash) find(fp fingerprint, i1, i2 uint) int {
+	if s == nil {
+		return -1
+	}
+	for k, e := range s.entries {
+		if e.fp == fp && (e.i == i1 || e.i == i2) {
+			return k
+		}
+	}
+	return -1
+}
+
+func (s *overflowStash) remove(k int) {
+	last := len(s.entries) - 1
+	s.entries[k] = s.entries[last]
+	s.entries = s.entries[:last]
+}
+
+// fold maps the candidate buckets of the entries into the lower bucketIndexMask+1 buckets,
+// see Shrink.
+func (s *overflowStash) fold(bucketIndexMask uint) {
+	if s == nil {
+		return
+	}
+	for k := range s.entries {
+		s.entries[k].i &= bucketIndexMask
+	}
+}
+
+func (s *overflowStash) reset() {
+	if s == nil {
+		return
+	}
+	s.entries = s.entries[:0]
+}
+
+// stashFingerprint stashes fp with candidate bucket i. The caller must hold the write lock.
+func (cf *core) stashFingerprint(fp fingerprint, i uint) bool {
+	if !cf.stash.add(fp, i) {
+		return false
+	}
+	cf.count++
+	if cf.stash.len() == cf.stash.size {
+		cf.log.stashFull(cf.stash.size)
+	}
+	return true
+}
+
+// unstash moves a stashed fingerprint with candidate bucket i into it after a delete freed
+// a slot. The caller must hold the write lock.
+func (cf *core) unstash(i uint) {
+	if cf.stash.len() == 0 {
+		return
+	}
+	for k, e := range cf.stash.entries {
+		if e.i == i || cf.altIndex(e.fp, e.i) == i {
+			if cf.buckets[i].insert(e.fp) {
+				cf.summarize(i, e.fp)
+				cf.stash.remove(k)
+			}
+			return
+		}
+	}
+}
+
+// appendStash appends the stash section of the encoding: the entries as little-endian uint64
+// holding the bucket index above the fingerprint, followed by their number.
+func (cf *core) appendStash(bytes []byte) []byte {
+	var word [8]byte
+	for _, e := range cf.stash.entries {
+		binary.LittleEndian.PutUint64(word[:], uint64(e.i)<<fingerprintSizeBits|uint64(e.fp))
+		bytes = append(bytes, word[:]...)
+	}
+	binary.LittleEndian.PutUint64(word[:], uint64(len(cf.stash.entries)))
+	return append(bytes, word[:]...)
+}
+
+// splitStash splits encoded buckets followed by a stash section into buckets and entries.
+func splitStash(encoded []byte) ([]byte, []byte, error) {
+	if len(encoded) < 8 {
+		return nil, nil, fmt.Errorf("%w: missing stash section", ErrCorrupted)
+	}
+	n := binary.LittleEndian.Uint64(encoded[len(encoded)-8:])
+	if n > uint64(len(encoded)-8)/8 {
+		return nil, nil, fmt.Errorf("%w: stash of %d entries exceeds the encoding", ErrCorrupted, n)
+	}
+	split := len(encoded) - 8 - int(n)*8
+	return encoded[:split], encoded[split : len(encoded)-8], nil
+}
+
+// decodeStash sets the stash of cf to the encoded entries, enlarging it if needed. The buckets
+// must be decoded.
+func (cf *Filter) decodeStash(entries []byte) error {
+	n := len(entries) / 8
+	if cf.stash == nil {
+		cf.stash = &overflowStash{size: n}
+	} else if cf.stash.size < n {
+		cf.stash.size = n
+	}
+	cf.stash.entries = make([]stashEntry, 0, n)
+	for k := 0; k < n; k++ {
+		word := binary.LittleEndian.Uint64(entries[8*k:])
+		e := stashEntry{fp: fingerprint(word), i: uint(word >> fingerprintSizeBits)}
+		if e.fp == nullFp || e.i > cf.bucketIndexMask {
+			return fmt.Errorf("%w: invalid stash entry %d", ErrCorrupted, k)
+		}
+		cf.stash.entries = append(cf.stash.entries, e)
+	}
+	cf.count += uint(n)
+	return nil
+}
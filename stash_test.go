@@ -0,0 +1,204 @@
+package cuckoo
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// fillWithStash inserts keys into a small filter with a stash until an insert fails.
+func fillWithStash(t *testing.T, opts ...Option) (*Filter, int) {
+	cf := newFilter(make([]bucket, 64))
+	for _, opt := range append([]Option{WithOverflowStash(16)}, opts...) {
+		opt(cf)
+	}
+	n := 0
+	for cf.Insert([]byte(strconv.Itoa(n))) {
+		n++
+	}
+	if cf.stash.len() != 16 {
+		t.Fatalf("stash holds %d entries after %d inserts", cf.stash.len(), n)
+	}
+	return cf, n
+}
+
+func TestOverflowStash(t *testing.T) {
+	l := &recordingLogger{}
+	cf, n := fillWithStash(t, WithLogger(l))
+	if cf.Count() != uint(n) || n <= cf.Cap()*9/10 {
+		t.Errorf("inserted %d items into %d slots, count %d", n, cf.Cap(), cf.Count())
+	}
+	// The failed insert dropped one fingerprint, which is either the new item or an old one.
+	missing := 0
+	for i := 0; i < n; i++ {
+		if !cf.Lookup([]byte(strconv.Itoa(i))) {
+			missing++
+		}
+	}
+	if missing > 1 {
+		t.Errorf("%d items are missing", missing)
+	}
+	if err := cf.Validate(); err != nil {
+		t.Error(err)
+	}
+	if !containsEvent(l.events, "WARN cuckoo: overflow stash full") {
+		t.Errorf("logged %q, want a full stash", l.events)
+	}
+
+	for i := 0; i <= n; i++ {
+		cf.Delete([]byte(strconv.Itoa(i)))
+	}
+	if cf.Count() != 0 || cf.stash.len() != 0 {
+		t.Errorf("after deleting all items, count %d, stash %d", cf.Count(), cf.stash.len())
+	}
+}
+
+func containsEvent(events []string, event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOverflowStashEncoding(t *testing.T) {
+	cf, _ := fillWithStash(t)
+	encoded := cf.Encode()
+	if encoded[4] != stashFormatVersion {
+		t.Fatalf("Encode() wrote format version %d, want %d", encoded[4], stashFormatVersion)
+	}
+	decoded, err := Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Count() != cf.Count() || decoded.stash.len() != cf.stash.len() {
+		t.Errorf("Decode() has count %d and %d stashed, want %d and %d", decoded.Count(), decoded.stash.len(), cf.Count(), cf.stash.len())
+	}
+	for i := 0; i < cf.stash.len(); i++ {
+		e := cf.stash.entries[i]
+		if decoded.stash.find(e.fp, e.i, e.i) < 0 {
+			t.Errorf("stash entry %v lost", e)
+		}
+	}
+	if n, err := EncodedCount(encoded); n != cf.Count() || err != nil {
+		t.Errorf("EncodedCount() = %d, %v", n, err)
+	}
+
+	if _, err := NewStaticFilter(encoded); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("NewStaticFilter() with stash = %v, want %v", err, ErrIncompatible)
+	}
+	if _, err := cf.MarshalCBOR(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("MarshalCBOR() with stash = %v, want %v", err, ErrUnsupported)
+	}
+	for name, damaged := range map[string][]byte{
+		"truncated":   encoded[:len(encoded)-1],
+		"no stash":    encoded[:len(encoded)-8*(cf.stash.len()+1)],
+		"empty entry": append(append([]byte(nil), encoded[:len(encoded)-16]...), make([]byte, 16)...),
+	} {
+		if _, err := Decode(damaged); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("Decode(%s) = %v, want %v", name, err, ErrCorrupted)
+		}
+	}
+
+	cf.Reset()
+	if got := cf.Encode()[4]; got != CurrentFormatVersion {
+		t.Errorf("Encode() of empty stash wrote format version %d", got)
+	}
+}
+
+func TestOverflowStashShrink(t *testing.T) {
+	cf, n := fillWithStash(t)
+	for i := 0; i < n*3/4; i++ {
+		cf.Delete([]byte(strconv.Itoa(i)))
+	}
+	found := func() (found int) {
+		for i := n * 3 / 4; i < n; i++ {
+			if cf.Lookup([]byte(strconv.Itoa(i))) {
+				found++
+			}
+		}
+		return found
+	}
+	before := found()
+	if !cf.Shrink() {
+		t.Fatal("Shrink() = false")
+	}
+	if after := found(); after != before {
+		t.Errorf("found %d items after Shrink, want %d", after, before)
+	}
+	if err := cf.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOverflowStashLookupBatch(t *testing.T) {
+	cf, n := fillWithStash(t)
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+	for i, found := range cf.LookupBatch(keys, nil) {
+		if want := cf.Lookup(keys[i]); found != want {
+			t.Errorf("LookupBatch() of %q = %v, Lookup() = %v", keys[i], found, want)
+		}
+	}
+}
+
+func TestOverflowStashExports(t *testing.T) {
+	cf, _ := fillWithStash(t)
+	var buf bytes.Buffer
+	for name, err := range map[string]error{
+		"DumpCSV":     cf.DumpCSV(&buf),
+		"DumpParquet": cf.DumpParquet(&buf),
+	} {
+		if !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s() = %v, want %v", name, err, ErrUnsupported)
+		}
+	}
+	kv := &mapKV{values: make(map[string][]byte)}
+	if _, err := NewKVPersister([]byte("filter/")).Save(kv, cf); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("KVPersister.Save() = %v, want %v", err, ErrUnsupported)
+	}
+}
+
+func TestOverflowStashPartition(t *testing.T) {
+	cf, n := fillWithStash(t)
+	parts := cf.Partition(func(StoredFP) int { return 0 }, 1)
+	if parts[0].Count() != cf.Count() || parts[0].stash.len() != cf.stash.len() {
+		t.Errorf("Partition() count %d, stash %d, want %d, %d", parts[0].Count(), parts[0].stash.len(), cf.Count(), cf.stash.len())
+	}
+	for i := 0; i < n; i++ {
+		key := []byte(strconv.Itoa(i))
+		if got, want := parts[0].Lookup(key), cf.Lookup(key); got != want {
+			t.Errorf("Lookup(%q) = %v after Partition(), want %v", key, got, want)
+		}
+	}
+}
+
+func TestOverflowStashReconfigure(t *testing.T) {
+	config := FilterConfig{Capacity: 256, Options: []Option{WithOverflowStash(16)}}
+	m := NewManager(func(string) FilterConfig { return config })
+	cf := m.Get("a")
+	n := 0
+	for cf.Insert([]byte(strconv.Itoa(n))) {
+		n++
+	}
+	if cf.stash.len() == 0 {
+		t.Fatalf("stash is empty after %d inserts", n)
+	}
+	if err := <-m.Reconfigure("a", config); err != nil {
+		t.Fatalf("Reconfigure() = %v", err)
+	}
+	got := m.Get("a")
+	if got.Count() != cf.Count() {
+		t.Errorf("after Reconfigure() count %d, want %d", got.Count(), cf.Count())
+	}
+	for i := 0; i < n; i++ {
+		key := []byte(strconv.Itoa(i))
+		if cf.Lookup(key) && !got.Lookup(key) {
+			t.Errorf("Lookup(%q) = false after Reconfigure()", key)
+		}
+	}
+}
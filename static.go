@@ -0,0 +1,69 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// StaticFilter is a read-only filter answering lookups directly against a byte slice
+// created using Encode, without decoding or copying the buckets, so it is ready to use in
+// constant time. It is safe for concurrent use if the byte slice is not modified.
+type StaticFilter struct {
+	buckets         []byte
+	bucketIndexMask uint
+	seed            uint64
+	hashVersion     uint8
+	altScheme       AltIndexScheme
+}
+
+// NewStaticFilter returns a StaticFilter querying encoded, which is validated but not copied.
+// As for Decode, encodings of older versions without header use the default seed.
+func NewStaticFilter(encoded []byte) (*StaticFilter, error) {
+	sf := &StaticFilter{seed: defaultHashSeed, hashVersion: 1}
+	if hasEncodingHeader(encoded) {
+		h, err := parseEncodingHeader(encoded)
+		if err != nil {
+			return nil, err
+		}
+		if h.hasStash {
+			return nil, fmt.Errorf("%w: format version %d with overflow stash, use Decode", ErrIncompatible, h.formatVersion)
+		}
+		sf.hashVersion = h.hashVersion
+		sf.altScheme = h.altScheme
+		sf.seed = h.seed
+		encoded = encoded[h.size:]
+	}
+	if err := sf.setBuckets(encoded); err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
+
+// setBuckets sets the encoded buckets queried by sf.
+func (sf *StaticFilter) setBuckets(buckets []byte) error {
+	numBuckets := uint(len(buckets) / 8)
+	if len(buckets)%8 != 0 || numBuckets == 0 || numBuckets&(numBuckets-1) != 0 {
+		return fmt.Errorf("%w: expected a power of 2 of 8 byte buckets, got %d bytes", ErrCorrupted, len(buckets))
+	}
+	sf.buckets = buckets
+	sf.bucketIndexMask = numBuckets - 1
+	return nil
+}
+
+// Lookup returns true if data is in the filter.
+func (sf *StaticFilter) Lookup(data []byte) bool {
+	i1, fp := versionedIndexAndFingerprint(sf.hashVersion, sf.seed, data, sf.bucketIndexMask)
+	if sf.bucket(i1).contains(fp) {
+		return true
+	}
+	return sf.bucket(altIndex(sf.altScheme, fp, i1, sf.bucketIndexMask)).contains(fp)
+}
+
+func (sf *StaticFilter) bucket(i uint) bucket {
+	return bucket(binary.LittleEndian.Uint64(sf.buckets[8*i:]))
+}
+
+// Cap returns the number of slots of the filter.
+func (sf *StaticFilter) Cap() int {
+	return len(sf.buckets) / 8 * bucketSize
+}
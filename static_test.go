@@ -0,0 +1,49 @@
+package cuckoo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestStaticFilter(t *testing.T) {
+	cf := NewFilter(1000, WithHashSeed(5))
+	for i := 0; i < 500; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	encoded := cf.Encode()
+	sf, err := NewStaticFilter(encoded)
+	if err != nil {
+		t.Fatalf("NewStaticFilter() = %v", err)
+	}
+	legacy, err := NewStaticFilter(NewFilter(1000).Encode()[encodingHeaderSize:])
+	if err != nil {
+		t.Fatalf("NewStaticFilter(legacy) = %v", err)
+	}
+	if sf.Cap() != cf.Cap() || legacy.seed != defaultHashSeed {
+		t.Errorf("NewStaticFilter() = capacity %d and legacy seed %d, want %d and %d", sf.Cap(), legacy.seed, cf.Cap(), defaultHashSeed)
+	}
+	for i := 0; i < 2000; i++ {
+		data := []byte(strconv.Itoa(i))
+		if got, want := sf.Lookup(data), cf.Lookup(data); got != want {
+			t.Fatalf("Lookup(%q) = %v, want %v", data, got, want)
+		}
+	}
+
+	newer := append([]byte(nil), encoded...)
+	newer[4] = CurrentFormatVersion + 1
+	for _, tc := range []struct {
+		name    string
+		encoded []byte
+		want    error
+	}{
+		{"empty", nil, ErrCorrupted},
+		{"truncated", encoded[:len(encoded)-1], ErrCorrupted},
+		{"not a power of 2", encoded[:len(encoded)-8], ErrCorrupted},
+		{"newer format", newer, ErrIncompatible},
+	} {
+		if _, err := NewStaticFilter(tc.encoded); !errors.Is(err, tc.want) {
+			t.Errorf("NewStaticFilter(%s) = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
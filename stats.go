@@ -0,0 +1,36 @@
+package cuckoo
+
+import "math"
+
+// Stats describes the occupancy of a filter at one point in time, see Filter.Stats.
+type Stats struct {
+	// Count is the number of items in the filter.
+	Count uint
+	// Capacity is the number of slots of the filter.
+	Capacity uint
+	// LoadFactor is the fraction of slots that are occupied.
+	LoadFactor float64
+}
+
+// Stats returns the current occupancy of the filter.
+func (cf *Filter) Stats() Stats {
+	cf.lock.RLock()
+	defer cf.lock.RUnlock()
+
+	return Stats{
+		Count:      cf.count,
+		Capacity:   uint(cf.Cap()),
+		LoadFactor: cf.loadFactor(),
+	}
+}
+
+// NewFilterLike returns a new filter sized for the number of items recorded in stats plus
+// headroom, the fraction of additional items to expect, e.g. 0.2 for 20% more. It is meant
+// for filters that are rotated periodically, sizing today's filter from yesterday's Stats.
+// A negative headroom is treated as 0.
+func NewFilterLike(stats Stats, headroom float64, opts ...Option) *Filter {
+	if headroom < 0 {
+		headroom = 0
+	}
+	return NewFilter(uint(math.Ceil(float64(stats.Count)*(1+headroom))), opts...)
+}
@@ -0,0 +1,35 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestNewFilterLike(t *testing.T) {
+	old := NewFilter(100000)
+	for i := 0; i < 3000; i++ {
+		old.Insert([]byte(strconv.Itoa(i)))
+	}
+	stats := old.Stats()
+	if stats.Count != 3000 || stats.Capacity != uint(old.Cap()) || stats.LoadFactor != old.LoadFactor() {
+		t.Fatalf("Stats() = %+v, want count 3000, capacity %d", stats, old.Cap())
+	}
+
+	for _, tc := range []struct {
+		headroom float64
+		wantCap  int
+	}{
+		{0, NewFilter(3000).Cap()},
+		{-1, NewFilter(3000).Cap()},
+		{0.5, NewFilter(4500).Cap()},
+		{2, NewFilter(9000).Cap()},
+	} {
+		cf := NewFilterLike(stats, tc.headroom, WithHashSeed(1))
+		if cf.Cap() != tc.wantCap {
+			t.Errorf("NewFilterLike(headroom %v).Cap() = %d, want %d", tc.headroom, cf.Cap(), tc.wantCap)
+		}
+		if cf.HashSeed() != 1 {
+			t.Errorf("NewFilterLike() ignored options")
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cuckoo
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Store provides the memory holding the buckets of a filter, so filters can live outside
+// the Go heap, for example in a memory-mapped file. Backends without directly addressable
+// memory, such as remote stores, can hand out a local copy and synchronize it in Close.
+//
+// The filter reads and writes the words in place. Operations replacing the whole filter
+// with one of a different size, such as UnmarshalCBOR, move it to memory on the Go heap.
+type Store interface {
+	// Words returns the storage of the filter, one word per bucket. Its length must be a
+	// power of 2; empty slots must be zero.
+	Words() []uint64
+	// Close releases the storage. The filter using it must not be used afterwards.
+	Close() error
+}
+
+// NewFilterWithStore returns a filter whose buckets are held by s. Items already in s are
+// kept, so the filter can be reopened from a persistent store. As the hash seed is not
+// stored, opts must configure it the same way every time s is opened.
+func NewFilterWithStore(s Store, opts ...Option) (*Filter, error) {
+	words := s.Words()
+	if n := len(words); n == 0 || n&(n-1) != 0 {
+		return nil, fmt.Errorf("%w: store holds %d buckets, want a power of 2", ErrUnsupported, n)
+	}
+	cf := newFilter(wordsAsBuckets(words))
+	for _, b := range cf.buckets {
+		cf.count += uint(bucketSize - b.free())
+	}
+	for _, opt := range opts {
+		opt(cf)
+	}
+	return cf, nil
+}
+
+// wordsAsBuckets returns words as buckets without copying.
+func wordsAsBuckets(words []uint64) []bucket {
+	return *(*[]bucket)(unsafe.Pointer(&words))
+}
+
+// memoryStore is a Store on the Go heap.
+type memoryStore struct {
+	words []uint64
+}
+
+// NewMemoryStore returns a Store on the Go heap suitable for the given number of elements,
+// see NewFilter.
+func NewMemoryStore(numElements uint) Store {
+	return &memoryStore{words: make([]uint64, numBucketsFor(numElements))}
+}
+
+func (s *memoryStore) Words() []uint64 { return s.words }
+
+func (s *memoryStore) Close() error {
+	s.words = nil
+	return nil
+}
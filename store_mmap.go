@@ -0,0 +1,83 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package cuckoo
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// mmapStore is a Store in a memory-mapped file.
+type mmapStore struct {
+	file *os.File
+	data []byte
+}
+
+// OpenMmapStore opens the Store in the file at path, creating it with room for numElements
+// if it does not exist. Opening an existing file of a different size returns ErrIncompatible.
+//
+// The file holds the buckets in the native byte order without a header and is not meant to
+// be moved between machines, use Encode for that. Unlike SharedFilter, it must not be opened
+// by more than one filter at a time.
+func OpenMmapStore(path string, numElements uint) (Store, error) {
+	numBuckets := numBucketsFor(numElements)
+	if numBuckets > maxMappedBuckets {
+		return nil, fmt.Errorf("%w: %d buckets", ErrTooLarge, numBuckets)
+	}
+	size := int(numBuckets) * 8
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s, err := openMmapStore(f, size)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func openMmapStore(f *os.File, size int) (*mmapStore, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		if err := f.Truncate(int64(size)); err != nil {
+			return nil, err
+		}
+	} else if info.Size() != int64(size) {
+		return nil, fmt.Errorf("%w: store has %d bytes, want %d", ErrIncompatible, info.Size(), size)
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapStore{file: f, data: data}, nil
+}
+
+func (s *mmapStore) Words() []uint64 {
+	if len(s.data) == 0 {
+		return nil
+	}
+	return (*[maxMappedBuckets]uint64)(unsafe.Pointer(&s.data[0]))[: len(s.data)/8 : len(s.data)/8]
+}
+
+// Close unmaps the file, flushes it to disk and closes it.
+func (s *mmapStore) Close() error {
+	if s.data == nil {
+		return os.ErrClosed
+	}
+	err := syscall.Munmap(s.data)
+	s.data = nil
+	if serr := s.file.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package cuckoo
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestMmapStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store")
+	s, err := OpenMmapStore(path, 1000)
+	if err != nil {
+		t.Fatalf("OpenMmapStore() = %v", err)
+	}
+	cf, err := NewFilterWithStore(s, WithHashSeed(3))
+	if err != nil {
+		t.Fatalf("NewFilterWithStore() = %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	want := cf.Encode()
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	if _, err := OpenMmapStore(path, 100000); !errors.Is(err, ErrIncompatible) {
+		t.Errorf("OpenMmapStore(other size) = %v, want ErrIncompatible", err)
+	}
+	s, err = OpenMmapStore(path, 1000)
+	if err != nil {
+		t.Fatalf("OpenMmapStore() = %v", err)
+	}
+	defer s.Close()
+	cf, err = NewFilterWithStore(s, WithHashSeed(3))
+	if err != nil {
+		t.Fatalf("NewFilterWithStore() = %v", err)
+	}
+	if got := cf.Encode(); string(got) != string(want) {
+		t.Errorf("reopened filter differs from the one written")
+	}
+	if cf.Count() != 500 || !cf.Lookup([]byte("499")) {
+		t.Errorf("reopened Count(), Lookup(499) = %d, %v, want 500, true", cf.Count(), cf.Lookup([]byte("499")))
+	}
+}
@@ -0,0 +1,35 @@
+package cuckoo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestNewFilterWithStore(t *testing.T) {
+	s := NewMemoryStore(1000)
+	cf, err := NewFilterWithStore(s)
+	if err != nil {
+		t.Fatalf("NewFilterWithStore() = %v", err)
+	}
+	if cf.Cap() != NewFilter(1000).Cap() {
+		t.Errorf("Cap() = %d, want %d", cf.Cap(), NewFilter(1000).Cap())
+	}
+	for i := 0; i < 100; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	// A second filter on the same store sees the items.
+	reopened, err := NewFilterWithStore(s)
+	if err != nil {
+		t.Fatalf("NewFilterWithStore() = %v", err)
+	}
+	if reopened.Count() != 100 || !reopened.Lookup([]byte("42")) {
+		t.Errorf("reopened Count(), Lookup(42) = %d, %v, want 100, true", reopened.Count(), reopened.Lookup([]byte("42")))
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	if _, err := NewFilterWithStore(s); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("NewFilterWithStore(closed store) = %v, want ErrUnsupported", err)
+	}
+}
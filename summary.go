@@ -0,0 +1,70 @@
+package cuckoo
+
+// WithLookupSummary keeps one summary byte per bucket, a tiny Bloom filter of the fingerprints
+// stored in it, so that lookups of missing items are mostly rejected without reading the
+// buckets. The summaries take an eighth of the memory of the buckets, so they stay in CPU
+// caches much longer than the buckets of huge filters and speed up negative lookups, which
+// dominate many workloads; lookups of stored items read one byte more.
+//
+// Deletes leave the bits of deleted fingerprints set until Compact or Shrink rebuild the
+// summaries, which only makes them less selective. Summaries are not encoded: Decode builds
+// them if the option is passed. They must not be used with buckets that are modified outside
+// the filter, such as shared memory.
+func WithLookupSummary() Option {
+	return func(cf *Filter) {
+		cf.summary = make([]uint8, 0, len(cf.buckets))
+		cf.rebuildSummary()
+	}
+}
+
+// summaryBit returns the bit of the summary byte standing for fp, selected by its 3 most
+// significant bits, which are independent of the bucket index.
+func summaryBit(fp fingerprint) uint8 {
+	return 1 << (fp >> (fingerprintSizeBits - 3))
+}
+
+// summaryOf returns the summary byte of b.
+func summaryOf(b bucket) uint8 {
+	var s uint8
+	for _, fp := range b.fingerprints() {
+		if fp != nullFp {
+			s |= summaryBit(fp)
+		}
+	}
+	return s
+}
+
+// summarize records that fp was written into bucket i. The caller must hold the write lock.
+func (cf *core) summarize(i uint, fp fingerprint) {
+	if cf.summary != nil {
+		cf.summary[i] |= summaryBit(fp)
+	}
+}
+
+// mayContain returns false if bucket i definitely does not hold fp.
+func (cf *core) mayContain(fp fingerprint, i uint) bool {
+	return cf.summary == nil || cf.summary[i]&summaryBit(fp) != 0
+}
+
+// rebuildSummary recomputes all summary bytes from the buckets, if summaries are enabled. The
+// caller must hold the write lock.
+func (cf *core) rebuildSummary() {
+	if cf.summary == nil {
+		return
+	}
+	if cap(cf.summary) < len(cf.buckets) {
+		cf.summary = make([]uint8, len(cf.buckets))
+	}
+	cf.summary = cf.summary[:len(cf.buckets)]
+	cf.summarizeRange(0, uint(len(cf.buckets)))
+}
+
+// summarizeRange recomputes the summary bytes of buckets [from, to), if summaries are enabled.
+func (cf *core) summarizeRange(from, to uint) {
+	if cf.summary == nil {
+		return
+	}
+	for i := from; i < to; i++ {
+		cf.summary[i] = summaryOf(cf.buckets[i])
+	}
+}
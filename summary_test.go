@@ -0,0 +1,94 @@
+package cuckoo
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLookupSummary(t *testing.T) {
+	cf := NewFilter(1000, WithLookupSummary())
+	plain := NewFilter(1000)
+	var items [][]byte
+	for i := 0; i < 950; i++ {
+		items = append(items, []byte(fmt.Sprint(i)))
+		cf.Insert(items[i])
+	}
+	for _, item := range items {
+		plain.Insert(item)
+		if !cf.Lookup(item) {
+			t.Fatalf("Lookup(%q) = false, want true", item)
+		}
+	}
+	if err := cf.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	// The summary only skips bucket reads, so results do not change.
+	for i := 0; i < 10000; i++ {
+		key := []byte(fmt.Sprint("missing", i))
+		if got, want := cf.Lookup(key), plain.Lookup(key); got != want {
+			t.Errorf("Lookup(%q) = %v, want %v as without summary", key, got, want)
+		}
+	}
+
+	for _, item := range items[:len(items)/2] {
+		cf.Delete(item)
+	}
+	cf.Compact()
+	for i, s := range cf.summary {
+		if want := summaryOf(cf.buckets[i]); s != want {
+			t.Fatalf("After Compact(): summary of bucket %d = %#x, want %#x", i, s, want)
+		}
+	}
+	for _, item := range items[len(items)/2:] {
+		if !cf.Lookup(item) {
+			t.Errorf("After Compact(): Lookup(%q) = false, want true", item)
+		}
+	}
+
+	cf.Reset()
+	for i, s := range cf.summary {
+		if s != 0 {
+			t.Fatalf("After Reset(): summary of bucket %d = %#x, want 0", i, s)
+		}
+	}
+}
+
+func TestLookupSummaryDecode(t *testing.T) {
+	cf := NewFilter(1000)
+	for i := 0; i < 500; i++ {
+		cf.Insert([]byte(fmt.Sprint(i)))
+	}
+	decoded, err := Decode(cf.Encode(), WithLookupSummary())
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	lazy, err := DecodeLazy(cf.Encode(), WithLookupSummary())
+	if err != nil {
+		t.Fatalf("DecodeLazy() = %v", err)
+	}
+	if err := decoded.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	for i := 0; i < 500; i++ {
+		key := []byte(fmt.Sprint(i))
+		if !decoded.Lookup(key) {
+			t.Errorf("Decode(): Lookup(%q) = false, want true", key)
+		}
+		if !lazy.Lookup(key) {
+			t.Errorf("DecodeLazy(): Lookup(%q) = false, want true", key)
+		}
+	}
+}
+
+func BenchmarkFilter_LookupMissSummary(b *testing.B) {
+	cf := NewFilter(1<<22, WithLookupSummary())
+	for i := 0; i < 1<<21; i++ {
+		cf.Insert([]byte(fmt.Sprint(i)))
+	}
+	key := make([]byte, 8)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key[0], key[1], key[2], key[3] = byte(i), byte(i>>8), byte(i>>16), byte(i>>24)
+		cf.Lookup(key)
+	}
+}
@@ -0,0 +1,273 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Types of the Thrift compact protocol, used by the Parquet metadata.
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftByte      = 3
+	thriftI16       = 4
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftDouble    = 7
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftSet       = 10
+	thriftMap       = 11
+	thriftStruct    = 12
+)
+
+// thriftMaxDepth limits the nesting of decoded structs and lists.
+const thriftMaxDepth = 32
+
+var errThrift = errors.New("invalid thrift data")
+
+// thriftWriter encodes structs in the Thrift compact protocol.
+type thriftWriter struct {
+	buf []byte
+	// lastID is the id of the previous field of the current struct, ids holds those of the
+	// enclosing structs.
+	lastID int16
+	ids    []int16
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf = append(w.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	if delta := id - w.lastID; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.zigzag(int64(id))
+	}
+	w.lastID = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) binary(id int16, b []byte) {
+	w.field(id, thriftBinary)
+	w.binaryValue(b)
+}
+
+func (w *thriftWriter) binaryValue(b []byte) {
+	w.varint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// list starts a list field of n elements of type typ, which must be written next.
+func (w *thriftWriter) list(id int16, typ byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|typ)
+	} else {
+		w.buf = append(w.buf, 0xf0|typ)
+		w.varint(uint64(n))
+	}
+}
+
+// structField starts a struct field, which is ended by end.
+func (w *thriftWriter) structField(id int16) {
+	w.field(id, thriftStruct)
+	w.begin()
+}
+
+// begin starts a struct that is a list element or the top-level value.
+func (w *thriftWriter) begin() {
+	w.ids = append(w.ids, w.lastID)
+	w.lastID = 0
+}
+
+// end ends the current struct.
+func (w *thriftWriter) end() {
+	w.buf = append(w.buf, 0)
+	w.lastID = w.ids[len(w.ids)-1]
+	w.ids = w.ids[:len(w.ids)-1]
+}
+
+// thriftFields is a decoded struct mapping field ids to values, which are int64 for all
+// integer types, bool, float64, []byte, []interface{} for lists and sets, and thriftFields.
+// Maps are skipped.
+type thriftFields map[int16]interface{}
+
+func (f thriftFields) int(id int16) (int64, bool) {
+	v, ok := f[id].(int64)
+	return v, ok
+}
+
+func (f thriftFields) bytes(id int16) ([]byte, bool) {
+	v, ok := f[id].([]byte)
+	return v, ok
+}
+
+func (f thriftFields) list(id int16) []interface{} {
+	v, _ := f[id].([]interface{})
+	return v
+}
+
+func (f thriftFields) fields(id int16) (thriftFields, bool) {
+	v, ok := f[id].(thriftFields)
+	return v, ok
+}
+
+// thriftReader decodes values in the Thrift compact protocol.
+type thriftReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.buf) {
+		return 0, errThrift
+	}
+	r.pos++
+	return r.buf[r.pos-1], nil
+}
+
+func (r *thriftReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, errThrift
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *thriftReader) zigzag() (int64, error) {
+	v, err := r.varint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+// readStruct decodes a struct.
+func (r *thriftReader) readStruct(depth int) (thriftFields, error) {
+	if depth > thriftMaxDepth {
+		return nil, errThrift
+	}
+	fields := thriftFields{}
+	var id int16
+	for {
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return fields, nil
+		}
+		typ := header & 0x0f
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		var v interface{}
+		switch typ {
+		case thriftBoolTrue, thriftBoolFalse:
+			v = typ == thriftBoolTrue
+		default:
+			if v, err = r.value(typ, depth); err != nil {
+				return nil, err
+			}
+		}
+		fields[id] = v
+	}
+}
+
+// value decodes a value of the given type that is not a bool struct field.
+func (r *thriftReader) value(typ byte, depth int) (interface{}, error) {
+	switch typ {
+	case thriftBoolTrue, thriftBoolFalse:
+		// Bools in lists are encoded as one byte.
+		b, err := r.byte()
+		return b == thriftBoolTrue, err
+	case thriftByte:
+		b, err := r.byte()
+		return int64(int8(b)), err
+	case thriftI16, thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftDouble:
+		if len(r.buf)-r.pos < 8 {
+			return nil, errThrift
+		}
+		r.pos += 8
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.buf[r.pos-8:])), nil
+	case thriftBinary:
+		n, err := r.varint()
+		if err != nil || n > uint64(len(r.buf)-r.pos) {
+			return nil, errThrift
+		}
+		r.pos += int(n)
+		return r.buf[r.pos-int(n) : r.pos], nil
+	case thriftList, thriftSet:
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		n := uint64(header >> 4)
+		if n == 15 {
+			if n, err = r.varint(); err != nil {
+				return nil, err
+			}
+		}
+		// Every element takes at least one byte.
+		if n > uint64(len(r.buf)-r.pos) || depth >= thriftMaxDepth {
+			return nil, errThrift
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			if list[i], err = r.value(header&0x0f, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case thriftMap:
+		n, err := r.varint()
+		if err != nil || n > uint64(len(r.buf)-r.pos) || depth >= thriftMaxDepth {
+			return nil, errThrift
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		types, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < 2*n; i++ {
+			typ := types >> 4
+			if i%2 == 1 {
+				typ = types & 0x0f
+			}
+			if _, err := r.value(typ, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case thriftStruct:
+		return r.readStruct(depth + 1)
+	default:
+		return nil, errThrift
+	}
+}
@@ -0,0 +1,74 @@
+package cuckoo
+
+// TieredFilter combines a small hot filter in memory with a large cold filter holding all
+// items, e.g. one backed by a memory-mapped file. Lookups are answered by the hot filter if
+// possible; items found in the cold filter are promoted to the hot one, so that the working
+// set is served from memory. When the hot filter fills up, the least recently promoted
+// items are dropped from it (see RotatingFilter).
+//
+// TieredFilter is safe for concurrent use if the cold filter is.
+type TieredFilter struct {
+	hot  *RotatingFilter
+	cold ApproxSet
+}
+
+var _ ApproxSet = (*TieredFilter)(nil)
+
+// NewTieredFilter returns a TieredFilter with a hot filter sized for hotElements items
+// in front of cold.
+func NewTieredFilter(hotElements uint, cold ApproxSet) *TieredFilter {
+	return &TieredFilter{
+		hot:  NewRotatingFilter(hotElements),
+		cold: cold,
+	}
+}
+
+// Insert data into the cold filter and, as it is likely to be looked up soon, into the hot
+// filter. Returns false if insertion into the cold filter failed.
+func (tf *TieredFilter) Insert(data []byte) bool {
+	if !tf.cold.Insert(data) {
+		return false
+	}
+	tf.promote(data)
+	return true
+}
+
+// Lookup returns true if data is in the filter. Items found only in the cold filter are
+// promoted into the hot filter.
+func (tf *TieredFilter) Lookup(data []byte) bool {
+	if tf.hot.Lookup(data) {
+		return true
+	}
+	if !tf.cold.Lookup(data) {
+		return false
+	}
+	tf.promote(data)
+	return true
+}
+
+// promote adds data to the hot filter, rotating it if it is full.
+func (tf *TieredFilter) promote(data []byte) {
+	if wasPresent, added := tf.hot.ContainsOrAdd(data); wasPresent || added {
+		return
+	}
+	// The hot filter is saturated, drop the older half of its items.
+	tf.hot.Rotate()
+	tf.hot.Insert(data)
+}
+
+// Delete data from the filter. Returns true if the data was found in the cold filter and deleted.
+func (tf *TieredFilter) Delete(data []byte) bool {
+	if !tf.cold.Delete(data) {
+		return false
+	}
+	// The hot filter holds at most one copy, keep it while the cold filter still has more.
+	if !tf.cold.Lookup(data) {
+		tf.hot.Delete(data)
+	}
+	return true
+}
+
+// Count returns the number of items in the cold filter.
+func (tf *TieredFilter) Count() uint {
+	return tf.cold.Count()
+}
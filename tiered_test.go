@@ -0,0 +1,62 @@
+package cuckoo
+
+import (
+	"testing"
+)
+
+// countingSet wraps an ApproxSet and counts lookups.
+type countingSet struct {
+	ApproxSet
+	lookups int
+}
+
+func (c *countingSet) Lookup(data []byte) bool {
+	c.lookups++
+	return c.ApproxSet.Lookup(data)
+}
+
+func TestTieredFilter_Promotion(t *testing.T) {
+	cold := &countingSet{ApproxSet: NewFilter(10000)}
+	for i := 0; i < 1000; i++ {
+		cold.Insert([]byte{byte(i), byte(i >> 8)})
+	}
+	tf := NewTieredFilter(10, cold)
+
+	for i := 0; i < 3; i++ {
+		if !tf.Lookup([]byte{1, 0}) {
+			t.Fatalf("Lookup() = false for item in cold filter")
+		}
+	}
+	if cold.lookups != 1 {
+		t.Errorf("3 lookups of the same item: got %d cold lookups, want 1", cold.lookups)
+	}
+
+	// Promote more items than the hot filter can hold.
+	for i := 0; i < 1000; i++ {
+		if !tf.Lookup([]byte{byte(i), byte(i >> 8)}) {
+			t.Fatalf("Lookup(%d) = false for item in cold filter", i)
+		}
+	}
+	if tf.Lookup([]byte("missing")) {
+		t.Errorf("Lookup() = true for missing item")
+	}
+}
+
+func TestTieredFilter_InsertDelete(t *testing.T) {
+	tf := NewTieredFilter(10, NewFilter(100))
+	tf.Insert([]byte("one"))
+	tf.Insert([]byte("one"))
+
+	if got, want := tf.Count(), uint(2); got != want {
+		t.Errorf("Count() = %d, want %d", got, want)
+	}
+	if !tf.Delete([]byte("one")) || !tf.Lookup([]byte("one")) {
+		t.Errorf("After deleting one of two copies: Lookup() = false, want true")
+	}
+	if !tf.Delete([]byte("one")) || tf.Lookup([]byte("one")) {
+		t.Errorf("After deleting both copies: Lookup() = true, want false")
+	}
+	if tf.Delete([]byte("one")) {
+		t.Errorf("Delete() of missing item = true, want false")
+	}
+}
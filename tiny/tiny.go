@@ -0,0 +1,248 @@
+// Package tiny is a minimal cuckoo filter for microcontrollers and other TinyGo targets. It
+// avoids fmt, sync, reflection and allocations: the caller provides the storage of the
+// buckets, e.g. a global array, and synchronizes access if needed.
+//
+// Filters use the wire format of cuckoo.Filter.Encode with the current hashing version, so
+// filters built by a backend with the cuckoo package can be decoded and queried on the
+// device, and the other way around. Programs built with TinyGo, which sets the tinygo build
+// tag, should import this package instead of the cuckoo package.
+package tiny
+
+import (
+	"encoding/binary"
+	"errors"
+
+	metro "github.com/dgryski/go-metro"
+)
+
+const (
+	// DefaultSeed is the hash seed of cuckoo.NewFilter without cuckoo.WithHashSeed.
+	DefaultSeed = 1337
+	// HeaderSize is the size of the encoding header preceding the buckets.
+	HeaderSize = 24
+	// headerSizeV1 is the size of the header of format version 1, which lacks the count.
+	headerSizeV1 = 16
+
+	bucketSize     = 4
+	maxFingerprint = 1<<16 - 1
+	maxKickouts    = 500
+	formatVersion  = 2
+	hashVersion    = 1
+)
+
+// Errors returned by Decode.
+var (
+	ErrCorrupted   = errors.New("tiny: corrupted data")
+	ErrUnsupported = errors.New("tiny: unsupported encoding version")
+	ErrTooSmall    = errors.New("tiny: storage too small")
+)
+
+// Filter is a cuckoo filter with 16-bit fingerprints in buckets of four. The zero value is
+// not usable, call Init or Decode first. It is not safe for concurrent use.
+type Filter struct {
+	buckets []uint64
+	mask    uint
+	count   uint
+	seed    uint64
+	// rng is the state of the xorshift generator choosing kickout victims.
+	rng uint64
+}
+
+// Init sets f to an empty filter in storage, using its first power-of-two number of words as
+// buckets of four items each, whose keys are hashed with seed. Returns false if storage is
+// empty.
+func (f *Filter) Init(storage []uint64, seed uint64) bool {
+	if len(storage) == 0 {
+		return false
+	}
+	n := 1
+	for n*2 <= len(storage) {
+		n *= 2
+	}
+	f.buckets = storage[:n]
+	f.mask = uint(n - 1)
+	f.seed = seed
+	f.rng = seed | 1
+	f.Reset()
+	return true
+}
+
+// Decode sets f to the filter encoded by cuckoo.Filter.Encode or AppendEncode, copying its
+// buckets into storage, which must have at least one word per bucket.
+func (f *Filter) Decode(encoded []byte, storage []uint64) error {
+	if len(encoded) < headerSizeV1 || string(encoded[:4]) != "CKOO" || encoded[7] != 0 {
+		return ErrCorrupted
+	}
+	// Only the default alternate index scheme, XOR, is supported.
+	if (encoded[4] != 1 && encoded[4] != formatVersion) || encoded[5] != hashVersion || encoded[6] != 0 {
+		return ErrUnsupported
+	}
+	headerSize := headerSizeV1
+	if encoded[4] == formatVersion {
+		headerSize = HeaderSize
+	}
+	if len(encoded) < headerSize {
+		return ErrCorrupted
+	}
+	data := encoded[headerSize:]
+	n := len(data) / 8
+	if len(data)%8 != 0 || n == 0 || n&(n-1) != 0 {
+		return ErrCorrupted
+	}
+	if len(storage) < n {
+		return ErrTooSmall
+	}
+	f.buckets = storage[:n]
+	f.mask = uint(n - 1)
+	f.seed = binary.LittleEndian.Uint64(encoded[8:])
+	f.rng = f.seed | 1
+	f.count = 0
+	for i := range f.buckets {
+		b := binary.LittleEndian.Uint64(data[8*i:])
+		f.buckets[i] = b
+		for j := 0; j < bucketSize; j++ {
+			if get(b, j) != 0 {
+				f.count++
+			}
+		}
+	}
+	if headerSize == HeaderSize && binary.LittleEndian.Uint64(encoded[16:]) != uint64(f.count) {
+		return ErrCorrupted
+	}
+	return nil
+}
+
+// EncodedSize returns the number of bytes AppendEncode appends.
+func (f *Filter) EncodedSize() int {
+	return HeaderSize + 8*len(f.buckets)
+}
+
+// AppendEncode appends the encoding of f to dst, in the format of cuckoo.Filter.Encode. It
+// does not allocate if dst has EncodedSize bytes of spare capacity.
+func (f *Filter) AppendEncode(dst []byte) []byte {
+	var header [HeaderSize]byte
+	copy(header[:], "CKOO")
+	header[4] = formatVersion
+	header[5] = hashVersion
+	binary.LittleEndian.PutUint64(header[8:], f.seed)
+	binary.LittleEndian.PutUint64(header[16:], uint64(f.count))
+	dst = append(dst, header[:]...)
+	var word [8]byte
+	for _, b := range f.buckets {
+		binary.LittleEndian.PutUint64(word[:], b)
+		dst = append(dst, word[:]...)
+	}
+	return dst
+}
+
+func get(b uint64, j int) uint16 {
+	return uint16(b >> (16 * j))
+}
+
+func set(b *uint64, j int, fp uint16) {
+	shift := uint(16 * j)
+	*b = *b&^(maxFingerprint<<shift) | uint64(fp)<<shift
+}
+
+// indexAndFingerprint returns the primary bucket index and fingerprint of data.
+func (f *Filter) indexAndFingerprint(data []byte) (uint, uint16) {
+	hash := metro.Hash64(data, f.seed)
+	return uint(hash) & f.mask, uint16((hash>>48)%(maxFingerprint-1) + 1)
+}
+
+func (f *Filter) altIndex(fp uint16, i uint) uint {
+	b := [2]byte{byte(fp), byte(fp >> 8)}
+	return (i ^ uint(metro.Hash64(b[:], DefaultSeed))) & f.mask
+}
+
+func (f *Filter) random() uint64 {
+	f.rng ^= f.rng << 13
+	f.rng ^= f.rng >> 7
+	f.rng ^= f.rng << 17
+	return f.rng
+}
+
+func (f *Filter) contains(fp uint16, i uint) bool {
+	for j := 0; j < bucketSize; j++ {
+		if get(f.buckets[i], j) == fp {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) insert(fp uint16, i uint) bool {
+	for j := 0; j < bucketSize; j++ {
+		if get(f.buckets[i], j) == 0 {
+			set(&f.buckets[i], j, fp)
+			f.count++
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) delete(fp uint16, i uint) bool {
+	for j := 0; j < bucketSize; j++ {
+		if get(f.buckets[i], j) == fp {
+			set(&f.buckets[i], j, 0)
+			f.count--
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns true if data is in the filter.
+func (f *Filter) Lookup(data []byte) bool {
+	i1, fp := f.indexAndFingerprint(data)
+	return f.contains(fp, i1) || f.contains(fp, f.altIndex(fp, i1))
+}
+
+// Insert data into the filter. Returns false if the filter is full, see cuckoo.Filter.Insert.
+func (f *Filter) Insert(data []byte) bool {
+	i1, fp := f.indexAndFingerprint(data)
+	i2 := f.altIndex(fp, i1)
+	if f.insert(fp, i1) || f.insert(fp, i2) {
+		return true
+	}
+	i := i1
+	if f.random()&1 == 0 {
+		i = i2
+	}
+	for k := 0; k < maxKickouts; k++ {
+		j := int(f.random() % bucketSize)
+		old := get(f.buckets[i], j)
+		set(&f.buckets[i], j, fp)
+		fp = old
+		i = f.altIndex(fp, i)
+		if f.insert(fp, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete data from the filter. Returns true if the data was found and deleted.
+func (f *Filter) Delete(data []byte) bool {
+	i1, fp := f.indexAndFingerprint(data)
+	return f.delete(fp, i1) || f.delete(fp, f.altIndex(fp, i1))
+}
+
+// Count returns the number of items in the filter.
+func (f *Filter) Count() uint {
+	return f.count
+}
+
+// Cap returns the number of slots of the filter.
+func (f *Filter) Cap() uint {
+	return uint(len(f.buckets)) * bucketSize
+}
+
+// Reset removes all items from the filter.
+func (f *Filter) Reset() {
+	for i := range f.buckets {
+		f.buckets[i] = 0
+	}
+	f.count = 0
+}
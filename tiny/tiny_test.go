@@ -0,0 +1,113 @@
+package tiny
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+
+	cuckoo "github.com/chenny7/cuckoofilter"
+)
+
+func TestWireCompatibility(t *testing.T) {
+	cf := cuckoo.NewFilter(1000, cuckoo.WithHashSeed(9))
+	var storage [512]uint64
+	var f Filter
+	if !f.Init(storage[:], 9) {
+		t.Fatalf("Init() = false")
+	}
+	if f.Cap() != uint(cf.Cap()) {
+		t.Fatalf("Cap() = %d, want %d", f.Cap(), cf.Cap())
+	}
+	// Without kickouts, both filters place items into the same slots.
+	for i := 0; i < 500; i++ {
+		key := []byte(strconv.Itoa(i))
+		if !f.Insert(key) || !cf.Insert(key) {
+			t.Fatalf("Insert(%d) failed", i)
+		}
+	}
+	encoded := f.AppendEncode(nil)
+	if !bytes.Equal(encoded, cf.Encode()) || len(encoded) != f.EncodedSize() {
+		t.Fatalf("AppendEncode() differs from cuckoo.Filter.Encode()")
+	}
+
+	// Decode a filter built by the cuckoo package, with kickouts.
+	for i := 500; i < 1000; i++ {
+		cf.Insert([]byte(strconv.Itoa(i)))
+	}
+	var decoded Filter
+	if err := decoded.Decode(cf.Encode(), storage[:]); err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+	if decoded.Count() != cf.Count() {
+		t.Errorf("Count() = %d, want %d", decoded.Count(), cf.Count())
+	}
+	for i := 0; i < 2000; i++ {
+		key := []byte(strconv.Itoa(i))
+		if decoded.Lookup(key) != cf.Lookup(key) {
+			t.Fatalf("Lookup(%d) = %v, want %v", i, decoded.Lookup(key), cf.Lookup(key))
+		}
+	}
+	if !decoded.Delete([]byte("1")) || decoded.Count() != cf.Count()-1 {
+		t.Errorf("Delete(1) failed")
+	}
+
+	// Format version 1 has a 16-byte header without the count.
+	v1 := append([]byte(nil), cf.Encode()[:headerSizeV1]...)
+	v1[4] = 1
+	v1 = append(v1, cf.Encode()[HeaderSize:]...)
+	if err := decoded.Decode(v1, storage[:]); err != nil || decoded.Count() != cf.Count() {
+		t.Errorf("Decode() of format version 1 = %v with count %d", err, decoded.Count())
+	}
+
+	if err := decoded.Decode(cf.Encode(), storage[:10]); err != ErrTooSmall {
+		t.Errorf("Decode() into small storage = %v, want ErrTooSmall", err)
+	}
+	offset := cuckoo.NewFilter(1000, cuckoo.WithAltIndexScheme(cuckoo.AltIndexOffset))
+	if err := decoded.Decode(offset.Encode(), storage[:]); err != ErrUnsupported {
+		t.Errorf("Decode() with offset scheme = %v, want ErrUnsupported", err)
+	}
+	if err := decoded.Decode([]byte("garbage"), storage[:]); err != ErrCorrupted {
+		t.Errorf("Decode(garbage) = %v, want ErrCorrupted", err)
+	}
+}
+
+func TestInsertFull(t *testing.T) {
+	var storage [3]uint64
+	var f Filter
+	f.Init(storage[:], DefaultSeed)
+	if f.Cap() != 8 {
+		t.Fatalf("Cap() = %d, want 8", f.Cap())
+	}
+	inserted := 0
+	for i := 0; i < 20; i++ {
+		if f.Insert([]byte(strconv.Itoa(i))) {
+			inserted++
+		}
+	}
+	if inserted == 20 || f.Count() != 8 {
+		t.Errorf("inserted %d of 20 items, Count() = %d, want a full filter", inserted, f.Count())
+	}
+	f.Reset()
+	if f.Count() != 0 || storage[0] != 0 {
+		t.Errorf("Reset() did not empty the filter")
+	}
+	if f.Init(nil, DefaultSeed) {
+		t.Errorf("Init(nil) = true")
+	}
+}
+
+// TestImports keeps the package free of dependencies TinyGo targets cannot afford.
+func TestImports(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "tiny.go", nil, parser.ImportsOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := map[string]bool{`"encoding/binary"`: true, `"errors"`: true, `"github.com/dgryski/go-metro"`: true}
+	for _, imp := range file.Imports {
+		if !allowed[imp.Path.Value] {
+			t.Errorf("tiny.go imports %s", imp.Path.Value)
+		}
+	}
+}
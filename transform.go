@@ -0,0 +1,19 @@
+package cuckoo
+
+// WithKeyTransform applies transform to every key before it is hashed, e.g. to lowercase, trim
+// or canonicalize keys, so all entry points normalize keys consistently instead of every call
+// site having to. transform must be deterministic and safe for concurrent use, and must not
+// modify its argument. The transform is not encoded: pass the same option when decoding.
+func WithKeyTransform(transform func([]byte) []byte) Option {
+	return func(cf *Filter) {
+		cf.transform = transform
+	}
+}
+
+// normalize returns data transformed by the key transform, if any.
+func (cf *core) normalize(data []byte) []byte {
+	if cf.transform == nil {
+		return data
+	}
+	return cf.transform(data)
+}
@@ -0,0 +1,42 @@
+package cuckoo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestKeyTransform(t *testing.T) {
+	opt := WithKeyTransform(func(data []byte) []byte {
+		return bytes.ToLower(bytes.TrimSpace(data))
+	})
+	cf := NewFilter(1000, opt)
+	if !cf.Insert([]byte(" Example.COM ")) {
+		t.Fatal("Insert failed")
+	}
+	if !cf.Lookup([]byte("example.com")) {
+		t.Error("Lookup of the normalized key failed")
+	}
+	if got := cf.LookupBatch([][]byte{[]byte("EXAMPLE.com")}, nil); !got[0] {
+		t.Error("LookupBatch of a differently cased key failed")
+	}
+	if !cf.Explain([]byte("example.COM")).Found {
+		t.Error("Explain did not find the key")
+	}
+	if wasPresent, _ := cf.ContainsOrAdd([]byte("Example.com")); !wasPresent {
+		t.Error("ContainsOrAdd did not find the key")
+	}
+
+	decoded, err := Decode(cf.Encode(), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Delete([]byte("\texample.com\n")) || decoded.Count() != 0 {
+		t.Errorf("Delete from the decoded filter failed, count = %d", decoded.Count())
+	}
+
+	uf := NewUnsyncFilter(1000, opt)
+	uf.Insert([]byte("A"))
+	if !uf.Lookup([]byte("a")) {
+		t.Error("UnsyncFilter ignored the key transform")
+	}
+}
@@ -0,0 +1,37 @@
+package cuckoo
+
+import "encoding/binary"
+
+// tupleKey returns the key of a multi-part key: every part prefixed with its length as a
+// uvarint. Unlike concatenation, distinct tuples like ("ab", "c") and ("a", "bc") get distinct
+// keys.
+func tupleKey(parts [][]byte) []byte {
+	size := 0
+	for _, part := range parts {
+		size += binary.MaxVarintLen64 + len(part)
+	}
+	key := make([]byte, 0, size)
+	var prefix [binary.MaxVarintLen64]byte
+	for _, part := range parts {
+		key = append(key, prefix[:binary.PutUvarint(prefix[:], uint64(len(part)))]...)
+		key = append(key, part...)
+	}
+	return key
+}
+
+// InsertTuple inserts the multi-part key made of parts like Insert. Parts are length-prefixed,
+// so ("ab", "c") and ("a", "bc") are different keys.
+func (cf *Filter) InsertTuple(parts ...[]byte) bool {
+	return cf.Insert(tupleKey(parts))
+}
+
+// LookupTuple returns true if the multi-part key made of parts is in the filter, see
+// InsertTuple.
+func (cf *Filter) LookupTuple(parts ...[]byte) bool {
+	return cf.Lookup(tupleKey(parts))
+}
+
+// DeleteTuple deletes the multi-part key made of parts from the filter, see InsertTuple.
+func (cf *Filter) DeleteTuple(parts ...[]byte) bool {
+	return cf.Delete(tupleKey(parts))
+}
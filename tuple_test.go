@@ -0,0 +1,40 @@
+package cuckoo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTupleKey(t *testing.T) {
+	tuples := [][][]byte{
+		{[]byte("ab"), []byte("c")},
+		{[]byte("a"), []byte("bc")},
+		{[]byte("abc")},
+		{[]byte("abc"), nil},
+		{nil, []byte("abc")},
+		{},
+	}
+	for x := range tuples {
+		for y := range tuples {
+			if x != y && bytes.Equal(tupleKey(tuples[x]), tupleKey(tuples[y])) {
+				t.Errorf("tuples %q and %q have the same key", tuples[x], tuples[y])
+			}
+		}
+	}
+}
+
+func TestInsertTuple(t *testing.T) {
+	cf := NewFilter(1000)
+	if !cf.InsertTuple([]byte("user"), []byte("42")) {
+		t.Fatal("InsertTuple failed")
+	}
+	if !cf.LookupTuple([]byte("user"), []byte("42")) {
+		t.Error("LookupTuple failed")
+	}
+	if cf.Lookup([]byte("user42")) {
+		t.Error("Lookup of the concatenation succeeded")
+	}
+	if !cf.DeleteTuple([]byte("user"), []byte("42")) || cf.Count() != 0 {
+		t.Error("DeleteTuple failed")
+	}
+}
@@ -0,0 +1,66 @@
+package cuckoo
+
+// typedBatchSize is the number of keys the bulk helpers prepare before committing them under
+// a single lock acquisition.
+const typedBatchSize = 256
+
+// TypedFilter is a Filter of keys of type K, which are converted to bytes with the function
+// given to NewTypedFilter. It is safe for concurrent use if the conversion is.
+type TypedFilter[K any] struct {
+	*Filter
+	key func(K) []byte
+}
+
+// NewTypedFilter returns a TypedFilter suitable for the given number of elements, converting
+// keys to bytes with key.
+func NewTypedFilter[K any](numElements uint, key func(K) []byte, opts ...Option) *TypedFilter[K] {
+	return &TypedFilter[K]{Filter: NewFilter(numElements, opts...), key: key}
+}
+
+// InsertKey inserts k into the filter like Insert.
+func (f *TypedFilter[K]) InsertKey(k K) bool {
+	return f.Insert(f.key(k))
+}
+
+// LookupKey returns true if k is in the filter.
+func (f *TypedFilter[K]) LookupKey(k K) bool {
+	return f.Lookup(f.key(k))
+}
+
+// DeleteKey deletes k from the filter.
+func (f *TypedFilter[K]) DeleteKey(k K) bool {
+	return f.Delete(f.key(k))
+}
+
+// InsertAll inserts keys into f, hashing them outside the lock and committing them in batches,
+// see Commit. Returns the number of inserted keys. If an insertion fails, it stops and returns
+// an error wrapping ErrFull.
+func InsertAll[K any](f *TypedFilter[K], keys []K) (int, error) {
+	batch := make([]Prepared, 0, typedBatchSize)
+	inserted := 0
+	for start := 0; start < len(keys); start += typedBatchSize {
+		group := keys[start:]
+		if len(group) > typedBatchSize {
+			group = group[:typedBatchSize]
+		}
+		batch = batch[:0]
+		for _, k := range group {
+			batch = append(batch, f.Prepare(f.key(k)))
+		}
+		n, err := f.Commit(batch)
+		inserted += n
+		if err != nil {
+			return inserted, err
+		}
+	}
+	return inserted, nil
+}
+
+// InsertMapKeys inserts the keys of m into f like InsertAll.
+func InsertMapKeys[M ~map[K]V, K comparable, V any](f *TypedFilter[K], m M) (int, error) {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return InsertAll(f, keys)
+}
@@ -0,0 +1,56 @@
+package cuckoo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func intKey(k int) []byte {
+	return []byte(strconv.Itoa(k))
+}
+
+func TestInsertAll(t *testing.T) {
+	f := NewTypedFilter(1000, intKey)
+	keys := make([]int, 700)
+	for i := range keys {
+		keys[i] = i
+	}
+	if n, err := InsertAll(f, keys); n != len(keys) || err != nil {
+		t.Fatalf("InsertAll = %d, %v", n, err)
+	}
+	for _, k := range keys {
+		if !f.LookupKey(k) {
+			t.Fatalf("key %d not found", k)
+		}
+	}
+	if !f.DeleteKey(0) || f.Count() != uint(len(keys))-1 {
+		t.Errorf("DeleteKey failed, count = %d", f.Count())
+	}
+}
+
+func TestInsertMapKeys(t *testing.T) {
+	type userIDs map[int]string
+	f := NewTypedFilter(100, intKey)
+	m := userIDs{1: "a", 2: "b", 3: "c"}
+	if n, err := InsertMapKeys(f, m); n != 3 || err != nil {
+		t.Fatalf("InsertMapKeys = %d, %v", n, err)
+	}
+	for k := range m {
+		if !f.LookupKey(k) {
+			t.Errorf("key %d not found", k)
+		}
+	}
+}
+
+func TestInsertAllFull(t *testing.T) {
+	f := NewTypedFilter(8, intKey)
+	keys := make([]int, 1000)
+	for i := range keys {
+		keys[i] = i
+	}
+	n, err := InsertAll(f, keys)
+	if !errors.Is(err, ErrFull) || n >= len(keys) || uint(n) != f.Count() {
+		t.Errorf("InsertAll = %d, %v with count %d", n, err, f.Count())
+	}
+}
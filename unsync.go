@@ -0,0 +1,69 @@
+package cuckoo
+
+// UnsyncFilter is a Filter without internal synchronization. It is not safe for concurrent
+// use: callers that share it between goroutines must provide their own locking, for example
+// one lock per shard of several filters. In exchange, operations do not pay for a mutex.
+//
+// UnsyncFilter uses the same hashing and encoding as Filter, so an encoding of either can be
+// decoded into the other.
+type UnsyncFilter struct {
+	core
+}
+
+var _ ApproxSet = (*UnsyncFilter)(nil)
+
+// NewUnsyncFilter returns a new UnsyncFilter suitable for the given number of elements,
+// see NewFilter.
+func NewUnsyncFilter(numElements uint, opts ...Option) *UnsyncFilter {
+	return &UnsyncFilter{core: NewFilter(numElements, opts...).core}
+}
+
+// DecodeUnsync returns an UnsyncFilter from a byte slice created using Encode, see Decode.
+func DecodeUnsync(bytes []byte, opts ...Option) (*UnsyncFilter, error) {
+	cf, err := Decode(bytes, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &UnsyncFilter{core: cf.core}, nil
+}
+
+// Lookup returns true if data is in the filter.
+func (cf *UnsyncFilter) Lookup(data []byte) bool {
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+	return cf.lookup(fp, i1)
+}
+
+// Insert data into the filter. Returns false if insertion failed, see Filter.Insert.
+func (cf *UnsyncFilter) Insert(data []byte) bool {
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+	return cf.insertKey(data, fp, i1)
+}
+
+// Delete data from the filter. Returns true if the data was found and deleted.
+func (cf *UnsyncFilter) Delete(data []byte) bool {
+	data = cf.normalize(data)
+	i1, fp := cf.indexAndFingerprint(data)
+	return cf.deleteKey(data, fp, i1)
+}
+
+// Count returns the number of items in the filter.
+func (cf *UnsyncFilter) Count() uint {
+	return cf.count
+}
+
+// LoadFactor returns the fraction slots that are occupied.
+func (cf *UnsyncFilter) LoadFactor() float64 {
+	return cf.loadFactor()
+}
+
+// Reset removes all items from the filter, setting count to 0.
+func (cf *UnsyncFilter) Reset() {
+	cf.reset()
+}
+
+// Encode returns a byte slice representing the filter, see Filter.Encode.
+func (cf *UnsyncFilter) Encode() []byte {
+	return cf.encode()
+}
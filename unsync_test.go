@@ -0,0 +1,46 @@
+package cuckoo
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+func TestUnsyncFilter(t *testing.T) {
+	uf := NewUnsyncFilter(1000, WithHashSeed(7))
+	cf := NewFilter(1000, WithHashSeed(7))
+	for i := 0; i < 500; i++ {
+		key := []byte(strconv.Itoa(i))
+		if !uf.Insert(key) {
+			t.Fatalf("Insert(%d) failed", i)
+		}
+		cf.Insert(key)
+	}
+	if !bytes.Equal(uf.Encode(), cf.Encode()) {
+		t.Errorf("UnsyncFilter and Filter encode differently after the same inserts")
+	}
+	if uf.Count() != 500 || uf.LoadFactor() != cf.LoadFactor() {
+		t.Errorf("Count(), LoadFactor() = %d, %v, want 500, %v", uf.Count(), uf.LoadFactor(), cf.LoadFactor())
+	}
+	for i := 0; i < 500; i++ {
+		if !uf.Lookup([]byte(strconv.Itoa(i))) {
+			t.Errorf("Lookup(%d) = false after Insert", i)
+		}
+	}
+	if !uf.Delete([]byte("0")) || uf.Lookup([]byte("0")) {
+		t.Errorf("Delete(0) did not remove the item")
+	}
+
+	decoded, err := DecodeUnsync(cf.Encode())
+	if err != nil {
+		t.Fatalf("DecodeUnsync() failed: %v", err)
+	}
+	if decoded.Count() != 500 || !decoded.Lookup([]byte("0")) {
+		t.Errorf("decoded Count(), Lookup(0) = %d, %v, want 500, true", decoded.Count(), decoded.Lookup([]byte("0")))
+	}
+
+	uf.Reset()
+	if uf.Count() != 0 || uf.Lookup([]byte("1")) {
+		t.Errorf("filter not empty after Reset")
+	}
+}
@@ -7,6 +7,13 @@ import (
 	metro "github.com/dgryski/go-metro"
 )
 
+// maxInt is the largest value of type int.
+const maxInt = int(^uint(0) >> 1)
+
+// maxBuckets is the largest number of buckets of a filter: 1<<27 on 32-bit platforms and 1<<59
+// on 64-bit ones, the largest powers of 2 whose size in bytes fits into an int.
+const maxBuckets = 1 << (27 + 32*(^uint(0)>>63))
+
 // randi returns either i1 or i2 randomly.
 func randi(i1, i2 uint) uint {
 	if rand.Int31()%2 == 0 {
@@ -16,10 +23,14 @@ func randi(i1, i2 uint) uint {
 }
 
 func getAltIndex(fp fingerprint, i uint, bucketIndexMask uint) uint {
+	return (i ^ fingerprintHash(fp)) & bucketIndexMask
+}
+
+// fingerprintHash returns the hash of fp alternate bucket indices are derived from.
+func fingerprintHash(fp fingerprint) uint {
 	b := make([]byte, 2)
 	binary.LittleEndian.PutUint16(b, uint16(fp))
-	hash := uint(metro.Hash64(b, 1337))
-	return (i ^ hash) & bucketIndexMask
+	return uint(metro.Hash64(b, 1337))
 }
 
 func getFingerprint(hash uint64) fingerprint {
@@ -32,7 +43,17 @@ func getFingerprint(hash uint64) fingerprint {
 
 // getIndexAndFingerprint returns the primary bucket index and fingerprint to be used
 func getIndexAndFingerprint(data []byte, bucketIndexMask uint) (uint, fingerprint) {
-	hash := metro.Hash64(data, 1337)
+	return getIndexAndFingerprintFromHash(hashKey(data), bucketIndexMask)
+}
+
+// hashKey returns the 64-bit hash from which bucket index and fingerprint of data are derived.
+func hashKey(data []byte) uint64 {
+	return metro.Hash64(data, defaultHashSeed)
+}
+
+// getIndexAndFingerprintFromHash returns the primary bucket index and fingerprint for a hash
+// returned by hashKey.
+func getIndexAndFingerprintFromHash(hash uint64, bucketIndexMask uint) (uint, fingerprint) {
 	f := getFingerprint(hash)
 	// Use least significant bits for deriving index.
 	i1 := uint(hash) & bucketIndexMask
@@ -40,6 +61,12 @@ func getIndexAndFingerprint(data []byte, bucketIndexMask uint) (uint, fingerprin
 }
 
 func getNextPow2(n uint64) uint {
+	return uint(nextPow2(n))
+}
+
+// nextPow2 returns the smallest power of 2 that is at least n, or 0 if n is 0 or above 1<<63.
+// Unlike getNextPow2, it does not truncate on 32-bit platforms.
+func nextPow2(n uint64) uint64 {
 	n--
 	n |= n >> 1
 	n |= n >> 2
@@ -48,5 +75,5 @@ func getNextPow2(n uint64) uint {
 	n |= n >> 16
 	n |= n >> 32
 	n++
-	return uint(n)
+	return n
 }
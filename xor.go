@@ -0,0 +1,174 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// maxXorBuildAttempts bounds the number of seeds tried when building an XorFilter.
+// With distinct keys, construction succeeds for a random seed with probability ~0.8.
+const maxXorBuildAttempts = 100
+
+// XorFilter is an immutable filter for static sets, built with BuildXorFilter.
+// It uses 16-bit fingerprints (like Filter) but only ~19.7 bits per item, and a
+// lookup reads 3 fingerprints without branching. It is safe for concurrent use.
+//
+// See "Xor Filters: Faster and Smaller Than Bloom and Cuckoo Filters" by Thomas Mueller Graf
+// and Daniel Lemire (https://arxiv.org/abs/1912.08258).
+type XorFilter struct {
+	seed         uint64
+	blockLength  uint32
+	fingerprints []uint16
+}
+
+// BuildXorFilter returns an XorFilter containing keys. Duplicate keys are allowed.
+func BuildXorFilter(keys [][]byte) (*XorFilter, error) {
+	hashes := make([]uint64, len(keys))
+	for i, k := range keys {
+		hashes[i] = hashKey(k)
+	}
+	// Duplicate hashes can never be peeled, remove them.
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	unique := hashes[:0]
+	for i, h := range hashes {
+		if i == 0 || h != hashes[i-1] {
+			unique = append(unique, h)
+		}
+	}
+	return buildXorFilter(unique)
+}
+
+func buildXorFilter(hashes []uint64) (*XorFilter, error) {
+	capacity := 32 + uint64(math.Ceil(1.23*float64(len(hashes))))
+	if capacity/3 > math.MaxUint32 {
+		return nil, fmt.Errorf("%w: %d keys", ErrTooLarge, len(hashes))
+	}
+	xf := &XorFilter{blockLength: uint32(capacity / 3)}
+	xf.fingerprints = make([]uint16, 3*xf.blockLength)
+
+	type set struct {
+		xormask uint64
+		count   uint32
+	}
+	type peeled struct {
+		hash  uint64
+		index uint32
+	}
+	sets := make([]set, len(xf.fingerprints))
+	queue := make([]uint32, 0, len(sets))
+	stack := make([]peeled, 0, len(hashes))
+	for attempt := 0; attempt < maxXorBuildAttempts; attempt++ {
+		xf.seed = splitmix64(uint64(attempt))
+		for i := range sets {
+			sets[i] = set{}
+		}
+		for _, k := range hashes {
+			h := xf.mix(k)
+			for _, i := range xf.indices(h) {
+				sets[i].xormask ^= h
+				sets[i].count++
+			}
+		}
+		// Repeatedly peel slots that only a single key maps to.
+		queue, stack = queue[:0], stack[:0]
+		for i, s := range sets {
+			if s.count == 1 {
+				queue = append(queue, uint32(i))
+			}
+		}
+		for len(queue) > 0 {
+			i := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if sets[i].count != 1 {
+				continue
+			}
+			h := sets[i].xormask
+			stack = append(stack, peeled{h, i})
+			for _, j := range xf.indices(h) {
+				sets[j].xormask ^= h
+				sets[j].count--
+				if sets[j].count == 1 {
+					queue = append(queue, j)
+				}
+			}
+		}
+		if len(stack) < len(hashes) {
+			continue
+		}
+		// Assign fingerprints in reverse peeling order, so that the slot of every key is
+		// written after the other two slots it depends on.
+		for k := len(stack) - 1; k >= 0; k-- {
+			h, i := stack[k].hash, stack[k].index
+			idx := xf.indices(h)
+			xf.fingerprints[i] = xorFingerprint(h) ^ xf.fingerprints[idx[0]] ^ xf.fingerprints[idx[1]] ^ xf.fingerprints[idx[2]]
+		}
+		return xf, nil
+	}
+	return nil, fmt.Errorf("cuckoo: building xor filter failed after %d attempts", maxXorBuildAttempts)
+}
+
+// Lookup returns true if data is in the filter.
+func (xf *XorFilter) Lookup(data []byte) bool {
+	h := xf.mix(hashKey(data))
+	idx := xf.indices(h)
+	return xorFingerprint(h) == xf.fingerprints[idx[0]]^xf.fingerprints[idx[1]]^xf.fingerprints[idx[2]]
+}
+
+// Encode returns a byte slice representing an XorFilter.
+func (xf *XorFilter) Encode() []byte {
+	bytes := make([]byte, 8+2*len(xf.fingerprints))
+	binary.LittleEndian.PutUint64(bytes, xf.seed)
+	for i, fp := range xf.fingerprints {
+		binary.LittleEndian.PutUint16(bytes[8+2*i:], fp)
+	}
+	return bytes
+}
+
+// DecodeXorFilter returns an XorFilter from a byte slice created using Encode.
+func DecodeXorFilter(bytes []byte) (_ *XorFilter, err error) {
+	defer recoverInvalidState(&err)
+	if len(bytes) <= 8 || (len(bytes)-8)%6 != 0 {
+		return nil, fmt.Errorf("%w: expected 8 + a multiple of 6 bytes, got %d", ErrCorrupted, len(bytes))
+	}
+	xf := &XorFilter{
+		seed:         binary.LittleEndian.Uint64(bytes),
+		blockLength:  uint32((len(bytes) - 8) / 6),
+		fingerprints: make([]uint16, (len(bytes)-8)/2),
+	}
+	for i := range xf.fingerprints {
+		xf.fingerprints[i] = binary.LittleEndian.Uint16(bytes[8+2*i:])
+	}
+	return xf, nil
+}
+
+func (xf *XorFilter) mix(hash uint64) uint64 {
+	return splitmix64(hash + xf.seed)
+}
+
+// indices returns the slot of h in each of the three blocks.
+func (xf *XorFilter) indices(h uint64) [3]uint32 {
+	return [3]uint32{
+		reduce(uint32(h), xf.blockLength),
+		reduce(uint32(h>>21|h<<43), xf.blockLength) + xf.blockLength,
+		reduce(uint32(h>>42|h<<22), xf.blockLength) + 2*xf.blockLength,
+	}
+}
+
+func xorFingerprint(h uint64) uint16 {
+	return uint16(h ^ h>>32)
+}
+
+// reduce maps hash uniformly to [0, n) without a division.
+func reduce(hash, n uint32) uint32 {
+	return uint32(uint64(hash) * uint64(n) >> 32)
+}
+
+// splitmix64 is a fast, well-distributed 64-bit mixing function.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,64 @@
+package cuckoo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestXorFilter(t *testing.T) {
+	const size = 10000
+	var keys [][]byte
+	for i := 0; i < size; i++ {
+		keys = append(keys, []byte{byte(i), byte(i >> 8), 0})
+	}
+	// Duplicates must not break construction.
+	keys = append(keys, keys[:10]...)
+
+	xf, err := BuildXorFilter(keys)
+	if err != nil {
+		t.Fatalf("BuildXorFilter() = %v", err)
+	}
+	for _, k := range keys {
+		if !xf.Lookup(k) {
+			t.Fatalf("Lookup(%v) = false for key in set", k)
+		}
+	}
+	fp := 0
+	for i := 0; i < size; i++ {
+		if xf.Lookup([]byte{byte(i), byte(i >> 8), 1}) {
+			fp++
+		}
+	}
+	if fp > 10 {
+		t.Errorf("Lookup() of %d missing keys: %d false positives, want <= 10", size, fp)
+	}
+}
+
+func TestXorFilter_EncodeDecode(t *testing.T) {
+	xf, err := BuildXorFilter([][]byte{[]byte("one"), []byte("two")})
+	if err != nil {
+		t.Fatalf("BuildXorFilter() = %v", err)
+	}
+	got, err := DecodeXorFilter(xf.Encode())
+	if err != nil {
+		t.Fatalf("DecodeXorFilter() = %v", err)
+	}
+	if !reflect.DeepEqual(xf, got) {
+		t.Errorf("DecodeXorFilter() = %v, want %v", got, xf)
+	}
+
+	if _, err := DecodeXorFilter([]byte{1, 2, 3}); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("DecodeXorFilter() error = %v, want %v", err, ErrCorrupted)
+	}
+}
+
+func TestXorFilter_Empty(t *testing.T) {
+	xf, err := BuildXorFilter(nil)
+	if err != nil {
+		t.Fatalf("BuildXorFilter(nil) = %v", err)
+	}
+	if xf.Lookup([]byte("one")) {
+		t.Errorf("Lookup() on empty filter = true, want false")
+	}
+}